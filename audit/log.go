@@ -0,0 +1,274 @@
+// Package audit keeps an append-only JSONL log of the calibredb commands that
+// changed a library, as reported by calibredb.WithAudit, and answers queries
+// over it.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+// Log appends events to a file and rotates it by size: when the file would
+// grow past the maximum it is renamed to file.1, file.1 to file.2 and so on,
+// and the oldest file beyond the limit is removed.
+type Log struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	onError  func(error)
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// Option configures a Log.
+type Option func(*Log)
+
+// WithMaxSize rotates the file before it grows past n bytes. The default is
+// 10 MiB; zero disables rotation.
+func WithMaxSize(n int64) Option {
+	return func(l *Log) {
+		l.maxSize = n
+	}
+}
+
+// WithMaxFiles keeps n rotated files besides the current one, 5 by default.
+func WithMaxFiles(n int) Option {
+	return func(l *Log) {
+		l.maxFiles = n
+	}
+}
+
+// WithOnError is called when Record fails to write an event. By default the
+// error is printed to stderr.
+func WithOnError(onError func(error)) Option {
+	return func(l *Log) {
+		l.onError = onError
+	}
+}
+
+// Open opens the log at path for appending, creating it and its directory
+// when needed. The file is readable by its owner only.
+func Open(path string, opts ...Option) (*Log, error) {
+	l := &Log{
+		path:     path,
+		maxSize:  10 << 20,
+		maxFiles: 5,
+		onError: func(err error) {
+			fmt.Fprintln(os.Stderr, "audit:", err)
+		},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("audit: %w", err)
+	}
+	l.file, l.size = f, info.Size()
+	return l.terminate()
+}
+
+// terminate ends a line cut short by a crash so that the next event starts on
+// a line of its own.
+func (l *Log) terminate() error {
+	if l.size == 0 {
+		return nil
+	}
+	last := make([]byte, 1)
+	r, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	defer r.Close()
+	if _, err := r.ReadAt(last, l.size-1); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	n, err := l.file.Write([]byte{'\n'})
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	return nil
+}
+
+// Record writes e, reporting failures to the error handler. Its signature
+// fits calibredb.WithAudit.
+func (l *Log) Record(e calibredb.AuditEvent) {
+	if err := l.Write(e); err != nil {
+		l.onError(err)
+	}
+}
+
+// Write appends e as one line.
+func (l *Log) Write(e calibredb.AuditEvent) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return errors.New("audit: log is closed")
+	}
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(line)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(line)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	return nil
+}
+
+func (l *Log) rotate() error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	l.file = nil
+	if l.maxFiles < 1 {
+		if err := os.Remove(l.path); err != nil {
+			return fmt.Errorf("audit: %w", err)
+		}
+		return l.open()
+	}
+	_ = os.Remove(l.rotated(l.maxFiles))
+	for i := l.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(l.rotated(i), l.rotated(i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("audit: %w", err)
+		}
+	}
+	if err := os.Rename(l.path, l.rotated(1)); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	return l.open()
+}
+
+func (l *Log) rotated(i int) string {
+	return l.path + "." + strconv.Itoa(i)
+}
+
+// Close closes the file; later writes fail.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Filter selects events in Query. Zero fields match everything.
+type Filter struct {
+	BookID int
+	Actor  string
+	Since  time.Time
+	Until  time.Time
+	// Limit caps the number of events returned, the newest are kept.
+	Limit int
+}
+
+func (f Filter) match(e calibredb.AuditEvent) bool {
+	switch {
+	case f.BookID != 0 && !e.BookIDs.Contains(f.BookID):
+		return false
+	case f.Actor != "" && e.Actor != f.Actor:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !e.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// Query returns the events matching f, newest first, reading the rotated
+// files too. Until is exclusive. Lines that do not parse are skipped.
+func (l *Log) Query(f Filter) ([]calibredb.AuditEvent, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []calibredb.AuditEvent
+	// oldest file first, so that events end up in the order they were written
+	for i := l.maxFiles; i >= 0; i-- {
+		path := l.path
+		if i > 0 {
+			path = l.rotated(i)
+		}
+		var err error
+		events, err = readEvents(path, f, events)
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.Reverse(events)
+	if f.Limit > 0 && len(events) > f.Limit {
+		events = events[:f.Limit]
+	}
+	return events, nil
+}
+
+func readEvents(path string, f Filter, events []calibredb.AuditEvent) ([]calibredb.AuditEvent, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return events, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			// a line that does not parse was cut short, e.g. by a crash
+			var e calibredb.AuditEvent
+			if json.Unmarshal(line, &e) == nil && f.match(e) {
+				events = append(events, e)
+			}
+		}
+		if err == io.EOF {
+			return events, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("audit: %w", err)
+		}
+	}
+}
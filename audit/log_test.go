@@ -0,0 +1,146 @@
+package audit_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veverkap/calibre-rest/audit"
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+var start = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func event(minute int, actor, command string, ids ...int) calibredb.AuditEvent {
+	return calibredb.AuditEvent{
+		Time:    start.Add(time.Duration(minute) * time.Minute),
+		Actor:   actor,
+		Library: "/books",
+		Command: command,
+		Argv:    []string{command},
+		BookIDs: calibredb.NewBookIDs(ids...),
+	}
+}
+
+func TestLog_Query(t *testing.T) {
+	l, err := audit.Open(filepath.Join(t.TempDir(), "audit", "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for _, e := range []calibredb.AuditEvent{
+		event(0, "alice", "add", 1, 2),
+		event(1, "bob", "set_metadata", 2),
+		event(2, "alice", "remove", 1),
+		event(3, "backup-key", "embed_metadata"),
+	} {
+		if err := l.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter audit.Filter
+		want   string
+	}{
+		{name: "all, newest first", want: "embed_metadata,remove,set_metadata,add"},
+		{name: "book", filter: audit.Filter{BookID: 2}, want: "set_metadata,add"},
+		{name: "user", filter: audit.Filter{Actor: "alice"}, want: "remove,add"},
+		{name: "since", filter: audit.Filter{Since: start.Add(2 * time.Minute)}, want: "embed_metadata,remove"},
+		{name: "until is exclusive", filter: audit.Filter{Until: start.Add(2 * time.Minute)}, want: "set_metadata,add"},
+		{name: "limit keeps the newest", filter: audit.Filter{Limit: 1}, want: "embed_metadata"},
+		{name: "nothing", filter: audit.Filter{BookID: 42}, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var commands []string
+			for _, e := range events {
+				commands = append(commands, e.Command)
+			}
+			if got := strings.Join(commands, ","); got != tt.want {
+				t.Errorf("Query() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLog_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := audit.Open(path, audit.WithMaxSize(300), audit.WithMaxFiles(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i := range 12 {
+		if err := l.Write(event(i, "alice", "add", i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 300 {
+			t.Errorf("%s is %d bytes", name, info.Size())
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Errorf("%s mode = %v", name, info.Mode().Perm())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists, want at most 2 rotated files", path)
+	}
+
+	events, err := l.Query(audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 || len(events) == 12 || !events[0].BookIDs.Contains(12) {
+		t.Fatalf("Query() after rotation returned %d events, newest %+v", len(events), events[0])
+	}
+	for i := 1; i < len(events); i++ {
+		if !events[i].Time.Before(events[i-1].Time) {
+			t.Errorf("events %d and %d are out of order", i-1, i)
+		}
+	}
+}
+
+func TestLog_TruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	l, err := audit.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Write(event(0, "alice", "add", 1)); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"time":"2026-10-01T12:05:00Z","comm`)
+	f.Close()
+
+	l, err = audit.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := l.Write(event(1, "alice", "add", 2)); err != nil {
+		t.Fatal(err)
+	}
+	// the line cut short is skipped, the event after it is intact
+	events, err := l.Query(audit.Filter{})
+	if err != nil || len(events) != 2 || !events[0].BookIDs.Contains(2) {
+		t.Errorf("Query() = %+v, %v", events, err)
+	}
+}
@@ -0,0 +1,129 @@
+// Package auth authenticates requests to the REST server, with HTTP Basic
+// against a file of bcrypt hashed passwords or with bearer API keys, and
+// checks that the caller holds the scope an endpoint requires.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Principal is the authenticated caller.
+type Principal struct {
+	// Name is the user name or the name of the API key.
+	Name string
+	// KeyID is set for API keys.
+	KeyID  string
+	Scopes []Scope
+}
+
+// Can reports whether the principal holds scope.
+func (p *Principal) Can(scope Scope) bool {
+	return grants(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// FromContext returns the principal of an authenticated request, or nil.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Authenticator checks the credentials of requests.
+type Authenticator struct {
+	users Users
+	keys  *KeyStore
+	realm string
+}
+
+// AuthenticatorOption configures an Authenticator.
+type AuthenticatorOption func(*Authenticator)
+
+// WithUsers enables HTTP Basic for users.
+func WithUsers(users Users) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.users = users
+	}
+}
+
+// WithKeyStore enables bearer API keys from keys.
+func WithKeyStore(keys *KeyStore) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.keys = keys
+	}
+}
+
+// WithRealm sets the realm of the WWW-Authenticate header.
+func WithRealm(realm string) AuthenticatorOption {
+	return func(a *Authenticator) {
+		a.realm = realm
+	}
+}
+
+// NewAuthenticator returns an Authenticator. Without users and keys every
+// request is rejected.
+func NewAuthenticator(opts ...AuthenticatorOption) *Authenticator {
+	a := &Authenticator{realm: "calibre-rest"}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Authenticate returns the principal of r, or nil when r carries no valid
+// credentials.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if name, password, ok := r.BasicAuth(); ok {
+		if a.users == nil {
+			return nil, nil
+		}
+		scopes, ok := a.users.verify(name, password)
+		if !ok {
+			return nil, nil
+		}
+		return &Principal{Name: name, Scopes: scopes}, nil
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || a.keys == nil {
+		return nil, nil
+	}
+	key, ok, err := a.keys.verify(strings.TrimSpace(token))
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &Principal{Name: key.Name, KeyID: key.ID, Scopes: key.Scopes}, nil
+}
+
+// Require wraps next so that it only runs for callers holding scope. Requests
+// without valid credentials get 401, callers lacking the scope get 403. The
+// principal is in the context of the request next sees.
+func (a *Authenticator) Require(scope Scope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := a.Authenticate(r)
+		switch {
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		case p == nil:
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", a.realm))
+			w.Header().Add("WWW-Authenticate", fmt.Sprintf("Bearer realm=%q", a.realm))
+			writeError(w, http.StatusUnauthorized, "authentication required")
+			return
+		case !p.Can(scope):
+			writeError(w, http.StatusForbidden, fmt.Sprintf("the %s scope is required, %s has %s", scope, p.Name, joinScopes(p.Scopes)))
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	})
+}
+
+// writeError writes the same JSON error body as the server.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
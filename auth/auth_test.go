@@ -0,0 +1,190 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/auth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func hash(t *testing.T, password string) string {
+	t.Helper()
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(h)
+}
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "read", want: "read"},
+		{in: "read, download,read", want: "read,download"},
+		{in: "admin", want: "admin"},
+		{in: "", wantErr: true},
+		{in: "read,delete", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := auth.ParseScopes(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseScopes(%q) error = %v", tt.in, err)
+			}
+			var names []string
+			for _, s := range got {
+				names = append(names, string(s))
+			}
+			if strings.Join(names, ",") != tt.want {
+				t.Errorf("ParseScopes(%q) = %v, want %s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseUsers(t *testing.T) {
+	h := hash(t, "pw")
+	tests := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{
+		{name: "valid", in: "# admins\nalice:" + h + "\n\nbob:" + h + ":read,download\n"},
+		{name: "no hash", in: "alice\n", wantErr: "line 1"},
+		{name: "not bcrypt", in: "alice:{SHA}abc\n", wantErr: "line 1 (alice)"},
+		{name: "bad scope", in: "alice:" + h + ":everything\n", wantErr: `unknown scope "everything"`},
+		{name: "duplicate", in: "alice:" + h + "\nalice:" + h + "\n", wantErr: "duplicate user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := auth.ParseUsers([]byte(tt.in))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseUsers() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store := auth.NewKeyStore(path)
+
+	if keys, err := store.List(); err != nil || len(keys) != 0 {
+		t.Fatalf("List() of a missing file = %v, %v", keys, err)
+	}
+	token, key, err := store.Create("backup", []auth.Scope{auth.ScopeRead, auth.ScopeDownload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, "crk_"+key.ID+"_") {
+		t.Errorf("token %q does not carry the key ID %s", token, key.ID)
+	}
+	if _, _, err := store.Create("backup", []auth.Scope{auth.ScopeRead}); err == nil {
+		t.Error("Create() of a duplicate name succeeded")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) {
+		t.Error("the keys file contains the token")
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("keys file mode = %v", info.Mode().Perm())
+	}
+
+	// a second store sees the key, as a running server sees keys made by the CLI
+	other := auth.NewKeyStore(path)
+	if keys, err := other.List(); err != nil || len(keys) != 1 || keys[0].Name != "backup" {
+		t.Fatalf("List() = %v, %v", keys, err)
+	}
+	if _, err := other.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if keys, _ := store.List(); len(keys) != 0 {
+		t.Errorf("List() after revoking = %v", keys)
+	}
+	if _, err := store.Revoke("backup"); !errors.Is(err, auth.ErrUnknownKey) {
+		t.Errorf("Revoke() of a revoked key error = %v", err)
+	}
+}
+
+func TestAuthenticator_Require(t *testing.T) {
+	users, err := auth.ParseUsers([]byte("alice:" + hash(t, "secret") + "\nbob:" + hash(t, "hunter2") + ":read\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := auth.NewKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	readKey, _, err := store.Create("reader", []auth.Scope{auth.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminKey, _, err := store.Create("ops", []auth.Scope{auth.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedKey, _, err := store.Create("old", []auth.Scope{auth.ScopeWrite})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Revoke("old"); err != nil {
+		t.Fatal(err)
+	}
+
+	a := auth.NewAuthenticator(auth.WithUsers(users), auth.WithKeyStore(store))
+	var seen string
+	handler := a.Require(auth.ScopeWrite, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = auth.FromContext(r.Context()).Name
+	}))
+
+	tests := []struct {
+		name     string
+		setup    func(*http.Request)
+		want     int
+		wantName string
+	}{
+		{name: "anonymous", setup: func(*http.Request) {}, want: http.StatusUnauthorized},
+		{name: "basic with all scopes", setup: func(r *http.Request) { r.SetBasicAuth("alice", "secret") }, want: http.StatusOK, wantName: "alice"},
+		{name: "basic wrong password", setup: func(r *http.Request) { r.SetBasicAuth("alice", "guess") }, want: http.StatusUnauthorized},
+		{name: "basic unknown user", setup: func(r *http.Request) { r.SetBasicAuth("mallory", "secret") }, want: http.StatusUnauthorized},
+		{name: "basic lacking scope", setup: func(r *http.Request) { r.SetBasicAuth("bob", "hunter2") }, want: http.StatusForbidden},
+		{name: "key lacking scope", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+readKey) }, want: http.StatusForbidden},
+		{name: "admin key", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+adminKey) }, want: http.StatusOK, wantName: "ops"},
+		{name: "revoked key", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+revokedKey) }, want: http.StatusUnauthorized},
+		{name: "tampered key", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+adminKey+"x") }, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = ""
+			req := httptest.NewRequest(http.MethodPost, "/books", nil)
+			tt.setup(req)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if seen != tt.wantName {
+				t.Errorf("principal = %q, want %q", seen, tt.wantName)
+			}
+			if tt.want == http.StatusUnauthorized && len(rec.Header().Values("WWW-Authenticate")) != 2 {
+				t.Errorf("WWW-Authenticate = %v", rec.Header().Values("WWW-Authenticate"))
+			}
+		})
+	}
+}
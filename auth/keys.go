@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned by Revoke for a key that does not exist.
+var ErrUnknownKey = errors.New("auth: unknown API key")
+
+// keyPrefix starts every API key so that leaked keys are easy to grep for.
+const keyPrefix = "crk_"
+
+// Key is an API key as stored in the keys file. The key itself is only shown
+// once, when it is created; the file keeps its SHA-256.
+type Key struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Scopes  []Scope   `json:"scopes"`
+	Hash    string    `json:"hash"`
+	Created time.Time `json:"created"`
+}
+
+type keysFile struct {
+	Keys []Key `json:"keys"`
+}
+
+// KeyStore manages the API keys in a JSON file. The file is read again when
+// it changes, so keys created or revoked by the CLI take effect in a running
+// server.
+type KeyStore struct {
+	path string
+
+	mu      sync.Mutex
+	keys    []Key
+	modTime time.Time
+	size    int64
+}
+
+// NewKeyStore returns a store for the keys file at path, which is created
+// with the first key.
+func NewKeyStore(path string) *KeyStore {
+	return &KeyStore{path: path}
+}
+
+// List returns the keys.
+func (s *KeyStore) List() ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return append([]Key(nil), s.keys...), nil
+}
+
+// Create adds a key and returns it with the secret token to hand out.
+func (s *KeyStore) Create(name string, scopes []Scope) (string, Key, error) {
+	if name == "" {
+		return "", Key{}, errors.New("auth: a key needs a name")
+	}
+	if len(scopes) == 0 {
+		return "", Key{}, errors.New("auth: a key needs at least one scope")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return "", Key{}, err
+	}
+	for _, k := range s.keys {
+		if k.Name == name {
+			return "", Key{}, fmt.Errorf("auth: a key named %q exists", name)
+		}
+	}
+
+	id := make([]byte, 6)
+	_, _ = rand.Read(id)
+	key := Key{
+		ID:      hex.EncodeToString(id),
+		Name:    name,
+		Scopes:  scopes,
+		Created: time.Now().UTC().Truncate(time.Second),
+	}
+	token := keyPrefix + key.ID + "_" + rand.Text()
+	key.Hash = hashToken(token)
+	if err := s.save(append(s.keys, key)); err != nil {
+		return "", Key{}, err
+	}
+	return token, key, nil
+}
+
+// Revoke removes the key with the given ID or name.
+func (s *KeyStore) Revoke(idOrName string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return Key{}, err
+	}
+	for i, k := range s.keys {
+		if k.ID == idOrName || k.Name == idOrName {
+			keys := append(append([]Key(nil), s.keys[:i]...), s.keys[i+1:]...)
+			return k, s.save(keys)
+		}
+	}
+	return Key{}, fmt.Errorf("%w: %s", ErrUnknownKey, idOrName)
+}
+
+// verify returns the key a token belongs to.
+func (s *KeyStore) verify(token string) (Key, bool, error) {
+	rest, ok := strings.CutPrefix(token, keyPrefix)
+	if !ok {
+		return Key{}, false, nil
+	}
+	id, _, _ := strings.Cut(rest, "_")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.reload(); err != nil {
+		return Key{}, false, err
+	}
+	hash := hashToken(token)
+	for _, k := range s.keys {
+		if k.ID == id && subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash)) == 1 {
+			return k, true, nil
+		}
+	}
+	return Key{}, false, nil
+}
+
+// reload reads the file when it changed since it was last read. A missing
+// file holds no keys.
+func (s *KeyStore) reload() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		s.keys, s.modTime, s.size = nil, time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size && s.keys != nil {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	var f keysFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("auth: %s: %w", s.path, err)
+	}
+	if f.Keys == nil {
+		f.Keys = []Key{}
+	}
+	s.keys, s.modTime, s.size = f.Keys, info.ModTime(), info.Size()
+	return nil
+}
+
+// save replaces the file atomically; it is readable by its owner only.
+func (s *KeyStore) save(keys []Key) error {
+	data, err := json.MarshalIndent(keysFile{Keys: keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".keys-*.json")
+	if err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("auth: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	// force the next reload, the modification time may not have changed
+	s.keys, s.modTime = nil, time.Time{}
+	return s.reload()
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scope is a permission carried by a user or an API key. Every endpoint of
+// the REST server requires one scope.
+type Scope string
+
+const (
+	// ScopeRead allows listing and searching books and reading metadata.
+	ScopeRead Scope = "read"
+	// ScopeWrite allows adding books and changing metadata.
+	ScopeWrite Scope = "write"
+	// ScopeAdmin allows destructive and maintenance commands such as removing
+	// books or restoring the database. It grants every other scope.
+	ScopeAdmin Scope = "admin"
+	// ScopeDownload allows fetching book files, covers and exports.
+	ScopeDownload Scope = "download"
+)
+
+// AllScopes lists the scopes in the order they are documented.
+var AllScopes = []Scope{ScopeRead, ScopeWrite, ScopeAdmin, ScopeDownload}
+
+// ParseScopes parses a comma separated list such as "read,download".
+func ParseScopes(s string) ([]Scope, error) {
+	var scopes []Scope
+	for _, name := range strings.Split(s, ",") {
+		scope := Scope(strings.TrimSpace(name))
+		if scope == "" {
+			continue
+		}
+		if !slices.Contains(AllScopes, scope) {
+			return nil, fmt.Errorf("auth: unknown scope %q", scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("auth: no scope given")
+	}
+	return scopes, nil
+}
+
+// grants reports whether scopes include want, directly or through admin.
+func grants(scopes []Scope, want Scope) bool {
+	return slices.Contains(scopes, want) || slices.Contains(scopes, ScopeAdmin)
+}
+
+func joinScopes(scopes []Scope) string {
+	names := make([]string, len(scopes))
+	for i, s := range scopes {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// user is an HTTP Basic user.
+type user struct {
+	hash   []byte
+	scopes []Scope
+}
+
+// Users are the HTTP Basic users read from a users file.
+type Users map[string]user
+
+// LoadUsers reads a users file. Each line is
+//
+//	name:bcrypt-hash[:scopes]
+//
+// as written by "htpasswd -nB name", optionally followed by a comma separated
+// list of scopes. Users without scopes get all of them. Blank lines and lines
+// starting with # are ignored.
+func LoadUsers(path string) (Users, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("auth: %w", err)
+	}
+	return ParseUsers(data)
+}
+
+// ParseUsers parses the content of a users file, see LoadUsers.
+func ParseUsers(data []byte) (Users, error) {
+	users := Users{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("auth: users line %d: want name:bcrypt-hash", n)
+		}
+		// bcrypt hashes contain no colon, anything after one is the scopes
+		hash, scopeList, hasScopes := strings.Cut(rest, ":")
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("auth: users line %d (%s): %w", n, name, err)
+		}
+		u := user{hash: []byte(hash), scopes: AllScopes}
+		if hasScopes {
+			scopes, err := ParseScopes(scopeList)
+			if err != nil {
+				return nil, fmt.Errorf("auth: users line %d (%s): %w", n, name, err)
+			}
+			u.scopes = scopes
+		}
+		if _, dup := users[name]; dup {
+			return nil, fmt.Errorf("auth: users line %d: duplicate user %q", n, name)
+		}
+		users[name] = u
+	}
+	return users, scanner.Err()
+}
+
+// verify returns the scopes of the user when the password matches.
+func (u Users) verify(name, password string) ([]Scope, bool) {
+	usr, ok := u[name]
+	if !ok {
+		// compare anyway so that unknown users take as long as known ones
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil, false
+	}
+	if bcrypt.CompareHashAndPassword(usr.hash, []byte(password)) != nil {
+		return nil, false
+	}
+	return usr.scopes, true
+}
+
+// dummyHash is a bcrypt hash at the default cost that no password matches.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(rand.Text()), bcrypt.DefaultCost)
+	return hash
+})
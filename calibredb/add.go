@@ -15,23 +15,23 @@ import (
 
 type AddOptions struct {
 	// Command Line Arguments
-	Files []string `validate:"required"`
+	Files []string `validate:"required_without=Empty"`
 
 	// Command Line Options
-	Authors             string          // Set the authors of the added book(s)
-	Automerge           AutomergeChoice // If books with similar titles and authors are found, merge the incoming formats (files) automatically into existing book records. A value of " ignore " means duplicate formats are discarded. A value of " overwrite " means duplicate formats in the library are overwritten with the newly added files. A value of " new_record " means duplicate formats are placed into a new book record.
-	Cover               string          // Path to the cover to use for the added book
-	Duplicates          *bool           // Add books to database even if they already exist. Comparison is done based on book titles and authors. Note that the --automerge option takes precedence.
-	Empty               *bool           // Add an empty book (a book with no formats)
-	Identifier          []string        // Set the identifiers for this book, e.g. -I asin:XXX -I isbn:YYY
-	Isbn                string          // Set the ISBN of the added book(s)
-	Languages           string          // A comma separated list of languages (best to use ISO639 language codes, though some language names may also be recognized)
-	Series              string          // Set the series of the added book(s)
-	SeriesIndex         float64         // Set the series number of the added book(s)
-	Tags                string          // Set the tags of the added book(s)
-	Title               string          // Set the title of the added book(s)
-	OneBookPerDirectory *bool           // Assume that each folder has only a single logical book and that all files in it are different e-book formats of that book
-	Recurse             *bool           // Process folders recursively
+	Authors             string          `flag:"--authors"`                                                                   // Set the authors of the added book(s)
+	Automerge           AutomergeChoice `flag:"--automerge" validate:"omitempty,oneof=disabled ignore overwrite new_record"` // If books with similar titles and authors are found, merge the incoming formats (files) automatically into existing book records. A value of " ignore " means duplicate formats are discarded. A value of " overwrite " means duplicate formats in the library are overwritten with the newly added files. A value of " new_record " means duplicate formats are placed into a new book record.
+	Cover               string          `flag:"--cover"`                                                                     // Path to the cover to use for the added book
+	Duplicates          *bool           `flag:"--duplicates"`                                                                // Add books to database even if they already exist. Comparison is done based on book titles and authors. Note that the --automerge option takes precedence.
+	Empty               *bool           `flag:"--empty"`                                                                     // Add an empty book (a book with no formats)
+	Identifier          []string        `flag:"--identifier"`                                                                // Set the identifiers for this book, e.g. -I asin:XXX -I isbn:YYY
+	Isbn                string          `flag:"--isbn"`                                                                      // Set the ISBN of the added book(s)
+	Languages           string          `flag:"--languages"`                                                                 // A comma separated list of languages (best to use ISO639 language codes, though some language names may also be recognized)
+	Series              string          `flag:"--series"`                                                                    // Set the series of the added book(s)
+	SeriesIndex         float64         `flag:"--series-index" validate:"gte=0"`                                             // Set the series number of the added book(s)
+	Tags                string          `flag:"--tags"`                                                                      // Set the tags of the added book(s)
+	Title               string          `flag:"--title"`                                                                     // Set the title of the added book(s)
+	OneBookPerDirectory *bool           `flag:"--one-book-per-directory"`                                                    // Assume that each folder has only a single logical book and that all files in it are different e-book formats of that book
+	Recurse             *bool           `flag:"--recurse"`                                                                   // Process folders recursively
 }
 
 type AutomergeChoice string
@@ -84,9 +84,8 @@ func (c *Calibre) Add(opts AddOptions, args ...string) (string, error) {
 		argv = append(argv, "--empty")
 	}
 	// Handling []string
-	if len(opts.Identifier) > 0 {
-		argv = append(argv, "--identifier")
-		argv = append(argv, opts.Identifier...)
+	for _, v := range opts.Identifier {
+		argv = append(argv, "--identifier", v)
 	}
 	// Handling string
 	if opts.Isbn != "" {
@@ -120,6 +119,7 @@ func (c *Calibre) Add(opts AddOptions, args ...string) (string, error) {
 	if opts.Recurse != nil && *opts.Recurse {
 		argv = append(argv, "--recurse")
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
@@ -10,12 +10,12 @@ package calibredb
 
 type AddFormatOptions struct {
 	// Command Line Arguments
-	Id        string `validate:"required"`
+	Id        string `validate:"required,bookid"`
 	EbookFile string `validate:"required"`
 
 	// Command Line Options
-	AsExtraDataFile *bool // Add the file as an extra data file to the book, not an ebook format
-	DontReplace     *bool // Do not replace the format if it already exists
+	AsExtraDataFile *bool `flag:"--as-extra-data-file"` // Add the file as an extra data file to the book, not an ebook format
+	DontReplace     *bool `flag:"--dont-replace"`       // Do not replace the format if it already exists
 }
 
 func (c *Calibre) AddFormatHelp() string {
@@ -47,6 +47,7 @@ func (c *Calibre) AddFormat(opts AddFormatOptions, args ...string) (string, erro
 	if opts.DontReplace != nil && *opts.DontReplace {
 		argv = append(argv, "--dont-replace")
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
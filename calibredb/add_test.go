@@ -13,7 +13,7 @@ func TestCalibre_AddHelp(t *testing.T) {
 	defer func() { _ = os.RemoveAll(tempDir) }()
 	c := calibredb.NewCalibre(
 		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+		calibredb.WithCalibreDBLocation(calibredbPath),
 	)
 
 	got := c.AddHelp()
@@ -264,7 +264,7 @@ func TestCalibre_Add(t *testing.T) {
 			defer func() { _ = os.RemoveAll(tempDir) }()
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			got, gotErr := c.Add(tt.opts, tt.args...)
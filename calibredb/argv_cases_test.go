@@ -0,0 +1,435 @@
+// Code generated by generate.go; DO NOT EDIT.
+
+package calibredb_test
+
+import "github.com/veverkap/calibre-rest/calibredb"
+
+var argvCases = map[string][]argvCase{
+	"add": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}})
+		}},
+		{"--authors", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Authors: "value"})
+		}},
+		{"--automerge", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Automerge: calibredb.AutomergeChoice("disabled")})
+		}},
+		{"--cover", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Cover: "value"})
+		}},
+		{"--duplicates", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Duplicates: ptr(true)})
+		}},
+		{"--empty", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Empty: ptr(true)})
+		}},
+		{"--identifier", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Identifier: []string{"one", "two"}})
+		}},
+		{"--isbn", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Isbn: "value"})
+		}},
+		{"--languages", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Languages: "value"})
+		}},
+		{"--series", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Series: "value"})
+		}},
+		{"--series-index", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, SeriesIndex: 2.5})
+		}},
+		{"--tags", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Tags: "value"})
+		}},
+		{"--title", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Title: "value"})
+		}},
+		{"--one-book-per-directory", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, OneBookPerDirectory: ptr(true)})
+		}},
+		{"--recurse", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}, Recurse: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"files1", "files2"}}, "extra")
+		}},
+		{"without files", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{Empty: ptr(true)})
+		}},
+		{"without files or Empty", func(c *calibredb.Calibre) (string, error) {
+			return c.Add(calibredb.AddOptions{})
+		}},
+	},
+	"add_custom_column": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "label", Name: "name", Datatype: "text"})
+		}},
+		{"--display", func(c *calibredb.Calibre) (string, error) {
+			return c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "label", Name: "name", Datatype: "text", Display: "{}"})
+		}},
+		{"--is-multiple", func(c *calibredb.Calibre) (string, error) {
+			return c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "label", Name: "name", Datatype: "text", IsMultiple: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "label", Name: "name", Datatype: "text"}, "extra")
+		}},
+	},
+	"add_format": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.AddFormat(calibredb.AddFormatOptions{Id: "1", EbookFile: "ebook_file"})
+		}},
+		{"--as-extra-data-file", func(c *calibredb.Calibre) (string, error) {
+			return c.AddFormat(calibredb.AddFormatOptions{Id: "1", EbookFile: "ebook_file", AsExtraDataFile: ptr(true)})
+		}},
+		{"--dont-replace", func(c *calibredb.Calibre) (string, error) {
+			return c.AddFormat(calibredb.AddFormatOptions{Id: "1", EbookFile: "ebook_file", DontReplace: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.AddFormat(calibredb.AddFormatOptions{Id: "1", EbookFile: "ebook_file"}, "extra")
+		}},
+	},
+	"backup_metadata": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.BackupMetadata(calibredb.BackupMetadataOptions{})
+		}},
+		{"--all", func(c *calibredb.Calibre) (string, error) {
+			return c.BackupMetadata(calibredb.BackupMetadataOptions{All: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.BackupMetadata(calibredb.BackupMetadataOptions{}, "extra")
+		}},
+	},
+	"catalog": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.Catalog(calibredb.CatalogOptions{Path: "path"})
+		}},
+		{"--ids", func(c *calibredb.Calibre) (string, error) {
+			return c.Catalog(calibredb.CatalogOptions{Path: "path", Ids: calibredb.NewBookIDs(1, 2, 3, 5)})
+		}},
+		{"--search", func(c *calibredb.Calibre) (string, error) {
+			return c.Catalog(calibredb.CatalogOptions{Path: "path", Search: "value"})
+		}},
+		{"--verbose", func(c *calibredb.Calibre) (string, error) {
+			return c.Catalog(calibredb.CatalogOptions{Path: "path", Verbose: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.Catalog(calibredb.CatalogOptions{Path: "path"}, "extra")
+		}},
+	},
+	"check_library": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.CheckLibrary(calibredb.CheckLibraryOptions{})
+		}},
+		{"--csv", func(c *calibredb.Calibre) (string, error) {
+			return c.CheckLibrary(calibredb.CheckLibraryOptions{Csv: ptr(true)})
+		}},
+		{"--ignore_extensions", func(c *calibredb.Calibre) (string, error) {
+			return c.CheckLibrary(calibredb.CheckLibraryOptions{IgnoreExtensions: "value"})
+		}},
+		{"--ignore_names", func(c *calibredb.Calibre) (string, error) {
+			return c.CheckLibrary(calibredb.CheckLibraryOptions{IgnoreNames: "value"})
+		}},
+		{"--report", func(c *calibredb.Calibre) (string, error) {
+			return c.CheckLibrary(calibredb.CheckLibraryOptions{Report: "value"})
+		}},
+		{"--vacuum-fts-db", func(c *calibredb.Calibre) (string, error) {
+			return c.CheckLibrary(calibredb.CheckLibraryOptions{VacuumFtsDb: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.CheckLibrary(calibredb.CheckLibraryOptions{}, "extra")
+		}},
+	},
+	"clone": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.Clone(calibredb.CloneOptions{Path: "path"})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.Clone(calibredb.CloneOptions{Path: "path"}, "extra")
+		}},
+	},
+	"custom_columns": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.CustomColumns(calibredb.CustomColumnsOptions{})
+		}},
+		{"--details", func(c *calibredb.Calibre) (string, error) {
+			return c.CustomColumns(calibredb.CustomColumnsOptions{Details: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.CustomColumns(calibredb.CustomColumnsOptions{}, "extra")
+		}},
+	},
+	"embed_metadata": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.EmbedMetadata(calibredb.EmbedMetadataOptions{BookIds: calibredb.NewBookIDs(1, 2, 3, 5)})
+		}},
+		{"--only-formats", func(c *calibredb.Calibre) (string, error) {
+			return c.EmbedMetadata(calibredb.EmbedMetadataOptions{BookIds: calibredb.NewBookIDs(1, 2, 3, 5), OnlyFormats: []string{"one", "two"}})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.EmbedMetadata(calibredb.EmbedMetadataOptions{BookIds: calibredb.NewBookIDs(1, 2, 3, 5)}, "extra")
+		}},
+	},
+	"export": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5)})
+		}},
+		{"--all", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), All: ptr(true)})
+		}},
+		{"--dont-asciiize", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), DontAsciiize: ptr(true)})
+		}},
+		{"--dont-save-cover", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), DontSaveCover: ptr(true)})
+		}},
+		{"--dont-save-extra-files", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), DontSaveExtraFiles: ptr(true)})
+		}},
+		{"--dont-update-metadata", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), DontUpdateMetadata: ptr(true)})
+		}},
+		{"--dont-write-opf", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), DontWriteOpf: ptr(true)})
+		}},
+		{"--formats", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), Formats: "value"})
+		}},
+		{"--progress", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), Progress: ptr(true)})
+		}},
+		{"--replace-whitespace", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), ReplaceWhitespace: ptr(true)})
+		}},
+		{"--single-dir", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), SingleDir: ptr(true)})
+		}},
+		{"--template", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), Template: "value"})
+		}},
+		{"--timefmt", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), Timefmt: "value"})
+		}},
+		{"--to-dir", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), ToDir: "value"})
+		}},
+		{"--to-lowercase", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), ToLowercase: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5)}, "extra")
+		}},
+	},
+	"fts_index": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsIndex(calibredb.FtsIndexOptions{EnableDisableStatusReindex: "status"})
+		}},
+		{"--indexing-speed", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsIndex(calibredb.FtsIndexOptions{EnableDisableStatusReindex: "status", IndexingSpeed: calibredb.IndexingSpeedChoice("fast")})
+		}},
+		{"--wait-for-completion", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsIndex(calibredb.FtsIndexOptions{EnableDisableStatusReindex: "status", WaitForCompletion: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsIndex(calibredb.FtsIndexOptions{EnableDisableStatusReindex: "status"}, "extra")
+		}},
+	},
+	"fts_search": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "title:dune"})
+		}},
+		{"--do-not-match-on-related-words", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "title:dune", DoNotMatchOnRelatedWords: ptr(true)})
+		}},
+		{"--include-snippets", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "title:dune", IncludeSnippets: ptr(true)})
+		}},
+		{"--indexing-threshold", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "title:dune", IndexingThreshold: 2.5})
+		}},
+		{"--match-end-marker", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "title:dune", MatchEndMarker: "value"})
+		}},
+		{"--match-start-marker", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "title:dune", MatchStartMarker: "value"})
+		}},
+		{"--output-format", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "title:dune", OutputFormat: calibredb.OutputFormatChoice("text")})
+		}},
+		{"--restrict-to", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "title:dune", RestrictTo: "value"})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "title:dune"}, "extra")
+		}},
+	},
+	"list": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{})
+		}},
+		{"--ascending", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{Ascending: ptr(true)})
+		}},
+		{"--fields", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{Fields: "value"})
+		}},
+		{"--for-machine", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{ForMachine: ptr(true)})
+		}},
+		{"--limit", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{Limit: 3})
+		}},
+		{"--line-width", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{LineWidth: 3})
+		}},
+		{"--prefix", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{Prefix: "value"})
+		}},
+		{"--search", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{Search: "value"})
+		}},
+		{"--separator", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{Separator: "value"})
+		}},
+		{"--sort-by", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{SortBy: "value"})
+		}},
+		{"--template", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{Template: "value"})
+		}},
+		{"--template_file", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{TemplateFile: "value"})
+		}},
+		{"--template_heading", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{TemplateHeading: "value"})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.List(calibredb.ListOptions{}, "extra")
+		}},
+	},
+	"list_categories": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.ListCategories(calibredb.ListCategoriesOptions{})
+		}},
+		{"--categories", func(c *calibredb.Calibre) (string, error) {
+			return c.ListCategories(calibredb.ListCategoriesOptions{Categories: "value"})
+		}},
+		{"--csv", func(c *calibredb.Calibre) (string, error) {
+			return c.ListCategories(calibredb.ListCategoriesOptions{Csv: ptr(true)})
+		}},
+		{"--dialect", func(c *calibredb.Calibre) (string, error) {
+			return c.ListCategories(calibredb.ListCategoriesOptions{Dialect: calibredb.DialectChoice("excel")})
+		}},
+		{"--item_count", func(c *calibredb.Calibre) (string, error) {
+			return c.ListCategories(calibredb.ListCategoriesOptions{ItemCount: ptr(true)})
+		}},
+		{"--width", func(c *calibredb.Calibre) (string, error) {
+			return c.ListCategories(calibredb.ListCategoriesOptions{Width: 3})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.ListCategories(calibredb.ListCategoriesOptions{}, "extra")
+		}},
+	},
+	"remove": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.Remove(calibredb.RemoveOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5)})
+		}},
+		{"--permanent", func(c *calibredb.Calibre) (string, error) {
+			return c.Remove(calibredb.RemoveOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5), Permanent: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.Remove(calibredb.RemoveOptions{Ids: calibredb.NewBookIDs(1, 2, 3, 5)}, "extra")
+		}},
+	},
+	"remove_custom_column": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.RemoveCustomColumn(calibredb.RemoveCustomColumnOptions{Label: "label"})
+		}},
+		{"--force", func(c *calibredb.Calibre) (string, error) {
+			return c.RemoveCustomColumn(calibredb.RemoveCustomColumnOptions{Label: "label", Force: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.RemoveCustomColumn(calibredb.RemoveCustomColumnOptions{Label: "label"}, "extra")
+		}},
+	},
+	"remove_format": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.RemoveFormat(calibredb.RemoveFormatOptions{Id: "1", Fmt: "fmt"})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.RemoveFormat(calibredb.RemoveFormatOptions{Id: "1", Fmt: "fmt"}, "extra")
+		}},
+	},
+	"restore_database": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.RestoreDatabase(calibredb.RestoreDatabaseOptions{})
+		}},
+		{"--really-do-it", func(c *calibredb.Calibre) (string, error) {
+			return c.RestoreDatabase(calibredb.RestoreDatabaseOptions{ReallyDoIt: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.RestoreDatabase(calibredb.RestoreDatabaseOptions{}, "extra")
+		}},
+	},
+	"saved_searches": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.SavedSearches(calibredb.SavedSearchesOptions{})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.SavedSearches(calibredb.SavedSearchesOptions{}, "extra")
+		}},
+	},
+	"search": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.Search(calibredb.SearchOptions{Expression: "title:dune"})
+		}},
+		{"--limit", func(c *calibredb.Calibre) (string, error) {
+			return c.Search(calibredb.SearchOptions{Expression: "title:dune", Limit: 3})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.Search(calibredb.SearchOptions{Expression: "title:dune"}, "extra")
+		}},
+	},
+	"set_custom": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.SetCustom(calibredb.SetCustomOptions{Column: "column", Id: "1", Value: "value"})
+		}},
+		{"--append", func(c *calibredb.Calibre) (string, error) {
+			return c.SetCustom(calibredb.SetCustomOptions{Column: "column", Id: "1", Value: "value", Append: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.SetCustom(calibredb.SetCustomOptions{Column: "column", Id: "1", Value: "value"}, "extra")
+		}},
+	},
+	"set_metadata": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.SetMetadata(calibredb.SetMetadataOptions{BookId: "1", Path: "path"})
+		}},
+		{"--field", func(c *calibredb.Calibre) (string, error) {
+			return c.SetMetadata(calibredb.SetMetadataOptions{BookId: "1", Path: "path", Field: []string{"one", "two"}})
+		}},
+		{"--list-fields", func(c *calibredb.Calibre) (string, error) {
+			return c.SetMetadata(calibredb.SetMetadataOptions{BookId: "1", Path: "path", ListFields: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.SetMetadata(calibredb.SetMetadataOptions{BookId: "1", Path: "path"}, "extra")
+		}},
+		{"without path", func(c *calibredb.Calibre) (string, error) {
+			return c.SetMetadata(calibredb.SetMetadataOptions{BookId: "1", Field: []string{"one", "two"}})
+		}},
+		{"without path or Field", func(c *calibredb.Calibre) (string, error) {
+			return c.SetMetadata(calibredb.SetMetadataOptions{BookId: "1"})
+		}},
+	},
+	"show_metadata": {
+		{"required", func(c *calibredb.Calibre) (string, error) {
+			return c.ShowMetadata(calibredb.ShowMetadataOptions{Id: "1"})
+		}},
+		{"--as-opf", func(c *calibredb.Calibre) (string, error) {
+			return c.ShowMetadata(calibredb.ShowMetadataOptions{Id: "1", AsOpf: ptr(true)})
+		}},
+		{"args", func(c *calibredb.Calibre) (string, error) {
+			return c.ShowMetadata(calibredb.ShowMetadataOptions{Id: "1"}, "extra")
+		}},
+	},
+}
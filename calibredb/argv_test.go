@@ -0,0 +1,165 @@
+package calibredb_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/internal/fakecalibredb"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// argvRecorder is the name under which the test binary records its arguments
+// instead of running calibredb.
+const argvRecorder = "calibredb-argv"
+
+// argvLogEnv names the file the recorder appends each invocation to.
+const argvLogEnv = "CALIBREDB_ARGV_LOG"
+
+// argvLibrary is the library path passed to recorded invocations.
+const argvLibrary = "/library"
+
+// argvCase calls one generated wrapper; the table lives in argv_cases_test.go
+// and is written by generate.go.
+type argvCase struct {
+	name string
+	run  func(c *calibredb.Calibre) (string, error)
+}
+
+func recordArgv(args []string) int {
+	// answered like calibredb so that version detection is not recorded
+	if len(args) > 0 && args[0] == "--version" {
+		fmt.Printf("calibredb (calibre %s)\n", fakecalibredb.Version())
+		return 0
+	}
+	f, err := os.OpenFile(os.Getenv(argvLogEnv), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// newArgvRecorder returns a Calibre whose calibredb records the argv of every
+// invocation, and a function returning (and clearing) what was recorded.
+func newArgvRecorder(t *testing.T) (*calibredb.Calibre, func() []string) {
+	t.Helper()
+	dir := t.TempDir()
+	path, err := linkTestBinary(dir, argvRecorder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(dir, "argv.log")
+	t.Setenv(argvLogEnv, log)
+	c := calibredb.NewCalibre(
+		calibredb.WithLibraryPath(argvLibrary),
+		calibredb.WithCalibreDBLocation(path),
+	)
+	return c, func() []string {
+		data, err := os.ReadFile(log)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		_ = os.Remove(log)
+		if len(data) == 0 {
+			return nil
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+// TestArgv checks the exact command line every generated wrapper builds
+// against testdata/argv/<command>.golden. Run with -update after changing the
+// generator and review the diff.
+func TestArgv(t *testing.T) {
+	c, recorded := newArgvRecorder(t)
+	commands := make([]string, 0, len(argvCases))
+	for command := range argvCases {
+		commands = append(commands, command)
+	}
+	slices.Sort(commands)
+
+	for _, command := range commands {
+		t.Run(command, func(t *testing.T) {
+			var got strings.Builder
+			for _, tc := range argvCases[command] {
+				fmt.Fprintf(&got, "# %s\n", tc.name)
+				if _, err := tc.run(c); err != nil {
+					fmt.Fprintf(&got, "error: %v\n", err)
+				}
+				for _, argv := range recorded() {
+					if argv != "" {
+						got.WriteString(argv + "\n")
+					}
+				}
+				got.WriteString("\n")
+			}
+
+			golden := filepath.Join("testdata", "argv", command+".golden")
+			if *update {
+				if err := os.MkdirAll(filepath.Dir(golden), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(golden, []byte(got.String()), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run go test -run TestArgv -update to create it)", err)
+			}
+			if got.String() != string(want) {
+				t.Errorf("argv for %s differs from %s:\n%s", command, golden, lineDiff(string(want), got.String()))
+			}
+		})
+	}
+}
+
+// TestArgvCasesCoverEveryCommand guards against a regenerated command that is
+// missing from the generated table.
+func TestArgvCasesCoverEveryCommand(t *testing.T) {
+	files, err := filepath.Glob("testdata/argv/*.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		command := strings.TrimSuffix(filepath.Base(f), ".golden")
+		if _, ok := argvCases[command]; !ok {
+			t.Errorf("%s has no generated cases", f)
+		}
+	}
+	if len(files) != len(argvCases) {
+		t.Errorf("%d golden files for %d commands", len(files), len(argvCases))
+	}
+}
+
+// lineDiff lists the lines only present in want (-) or got (+).
+func lineDiff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	var b strings.Builder
+	for _, l := range wantLines {
+		if !slices.Contains(gotLines, l) {
+			b.WriteString("- " + l + "\n")
+		}
+	}
+	for _, l := range gotLines {
+		if !slices.Contains(wantLines, l) {
+			b.WriteString("+ " + l + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,207 @@
+package calibredb
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AuditEvent describes a calibredb command that changed a library. Calibre
+// reports one for every such command it runs when WithAudit is set.
+type AuditEvent struct {
+	Time time.Time `json:"time"`
+	// Actor is who asked for the command, see WithActor.
+	Actor   string `json:"actor,omitempty"`
+	Library string `json:"library"`
+	Command string `json:"command"`
+	// Argv is the command line without the global options, with passwords
+	// replaced.
+	Argv []string `json:"argv"`
+	// BookIDs are the books the command changed, when they are known.
+	BookIDs    BookIDs `json:"book_ids,omitzero"`
+	DurationMS int64   `json:"duration_ms"`
+	// ExitCode is the exit status of calibredb, -1 when it did not start.
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+}
+
+// WithAudit reports every command that changes the library to record, after
+// it ran. record must be safe for concurrent use.
+func WithAudit(record func(AuditEvent)) CalibreOption {
+	return func(c *Calibre) {
+		c.audit = record
+	}
+}
+
+// WithActor names who runs the commands in audit events, e.g. the user of the
+// CLI. The REST server uses As for each request instead.
+func WithActor(actor string) CalibreOption {
+	return func(c *Calibre) {
+		c.actor = actor
+	}
+}
+
+// As returns a Calibre that shares the configuration, cache and hooks of c and
+// runs commands on behalf of actor.
+func (c *Calibre) As(actor string) *Calibre {
+	clone := &Calibre{
+		CalibreDBLocation: c.CalibreDBLocation,
+		LibraryPath:       c.LibraryPath,
+		Username:          c.Username,
+		Password:          c.Password,
+		Timeout:           c.Timeout,
+		OnError:           c.OnError,
+		validate:          c.validate,
+		cache:             c.cache,
+		audit:             c.audit,
+		events:            c.events,
+		actor:             actor,
+		metrics:           c.metrics,
+		logger:            c.logger,
+		version:           c.version,
+	}
+	return clone
+}
+
+// auditedExec runs argv like exec and reports it to the audit hook when it
+// changes the library.
+func (c *Calibre) auditedExec(argv []string) (string, error) {
+	if c.audit == nil || !changesLibrary(argv) {
+		return c.exec(argv...)
+	}
+	start := time.Now()
+	out, err := c.exec(argv...)
+	e := AuditEvent{
+		Time:       start.UTC(),
+		Actor:      c.actor,
+		Library:    RedactURL(c.LibraryPath),
+		Command:    argv[0],
+		Argv:       redactArgv(argv),
+		BookIDs:    affectedBookIDs(argv, out),
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		e.ExitCode = -1
+		var calibreErr *CalibreError
+		if errors.As(err, &calibreErr) {
+			e.ExitCode = calibreErr.ExitCode
+		}
+		e.Error = err.Error()
+	}
+	c.audit(e)
+	return out, err
+}
+
+// changesLibrary reports whether argv changes the library: the write
+// commands and the subcommands of saved_searches and fts_index that modify
+// something, but never a request for help.
+func changesLibrary(argv []string) bool {
+	if slices.Contains(argv[1:], "-h") || slices.Contains(argv[1:], "--help") {
+		return false
+	}
+	switch argv[0] {
+	case "saved_searches":
+		args := positionalArgs(argv)
+		return len(args) > 0 && (args[0] == "add" || args[0] == "remove")
+	case "fts_index":
+		args := positionalArgs(argv)
+		return len(args) > 0 && args[0] != "status"
+	case "embed_metadata":
+		return true
+	}
+	return writeCommands[argv[0]]
+}
+
+// bookIDArgs says which positional argument of a command holds the ids of the
+// books it changes, and in which range style.
+var bookIDArgs = map[string]struct {
+	index int
+	style RangeStyle
+}{
+	"remove":         {0, RangeExclusive},
+	"embed_metadata": {0, RangeInclusive},
+	"set_metadata":   {0, RangeInclusive},
+	"add_format":     {0, RangeInclusive},
+	"remove_format":  {0, RangeInclusive},
+	"set_custom":     {1, RangeInclusive},
+}
+
+// addedRe matches the ids add prints.
+var addedRe = regexp.MustCompile(`(?m)^Added book ids: ([0-9, ]+)$`)
+
+// AddedBookIDs returns the ids of the books an Add call created, read from
+// its output. It is empty when add found only duplicates.
+func AddedBookIDs(out string) BookIDs {
+	if m := addedRe.FindStringSubmatch(out); m != nil {
+		ids, _ := ParseBookIDs(m[1], RangeInclusive)
+		return ids
+	}
+	return BookIDs{}
+}
+
+func affectedBookIDs(argv []string, out string) BookIDs {
+	if argv[0] == "add" {
+		return AddedBookIDs(out)
+	}
+	arg, ok := bookIDArgs[argv[0]]
+	if !ok {
+		return BookIDs{}
+	}
+	args := positionalArgs(argv)
+	if arg.index >= len(args) {
+		return BookIDs{}
+	}
+	ids, _ := ParseBookIDs(args[arg.index], arg.style)
+	return ids
+}
+
+// positionalArgs returns the arguments of argv that are not options or their
+// values. Which options take a value is known from the options struct of the
+// command.
+func positionalArgs(argv []string) []string {
+	var switches []string
+	if typ, ok := commandOptions[argv[0]]; ok {
+		for i := range typ.NumField() {
+			f := typ.Field(i)
+			if flag := f.Tag.Get("flag"); flag != "" && f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Bool {
+				switches = append(switches, flag)
+			}
+		}
+	}
+	var args []string
+	for i := 1; i < len(argv); i++ {
+		a := argv[i]
+		switch {
+		case a == "--":
+			return append(args, argv[i+1:]...)
+		case strings.HasPrefix(a, "-") && a != "-":
+			if !strings.Contains(a, "=") && !slices.Contains(switches, a) {
+				i++ // the value
+			}
+		default:
+			args = append(args, a)
+		}
+	}
+	return args
+}
+
+// redactArgv replaces the values of options whose name mentions a password.
+func redactArgv(argv []string) []string {
+	out := slices.Clone(argv)
+	for i := 1; i < len(out); i++ {
+		name, _, hasValue := strings.Cut(out[i], "=")
+		if !strings.HasPrefix(name, "-") || !strings.Contains(strings.ToLower(name), "password") {
+			continue
+		}
+		if hasValue {
+			out[i] = name + "=" + redacted
+		} else if i+1 < len(out) {
+			out[i+1] = redacted
+			i++
+		}
+	}
+	return out
+}
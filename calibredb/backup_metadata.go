@@ -13,7 +13,7 @@ package calibredb
 type BackupMetadataOptions struct {
 
 	// Command Line Options
-	All *bool // Normally, this command only operates on books that have out of date OPF files. This option makes it operate on all books.
+	All *bool `flag:"--all"` // Normally, this command only operates on books that have out of date OPF files. This option makes it operate on all books.
 }
 
 func (c *Calibre) BackupMetadataHelp() string {
@@ -38,6 +38,7 @@ func (c *Calibre) BackupMetadata(opts BackupMetadataOptions, args ...string) (st
 	if opts.All != nil && *opts.All {
 		argv = append(argv, "--all")
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
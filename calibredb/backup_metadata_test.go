@@ -13,7 +13,7 @@ func TestCalibre_BackupMetadataHelp(t *testing.T) {
 	defer func() { _ = os.RemoveAll(tempDir) }()
 	c := calibredb.NewCalibre(
 		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+		calibredb.WithCalibreDBLocation(calibredbPath),
 	)
 
 	// Call BackupMetadataHelp - this will fail if calibredb is not installed
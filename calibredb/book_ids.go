@@ -0,0 +1,285 @@
+package calibredb
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RangeStyle describes how a calibredb command interprets the end of an id
+// range such as 10-15.
+type RangeStyle int
+
+const (
+	// RangeExclusive is the calibredb default: 57-85 covers 57 through 84.
+	// remove, export and catalog --ids use it.
+	RangeExclusive RangeStyle = iota
+	// RangeInclusive treats 10-15 as 10 through 15. embed_metadata uses it.
+	RangeInclusive
+)
+
+// maxBookIDsChunk is the longest id list passed to calibredb in a single
+// argument. It is well below the Linux per-argument limit (128 KiB) and the
+// Windows command line limit (32 KiB) so large sets never fail to spawn.
+const maxBookIDsChunk = 8 * 1024
+
+var (
+	// ErrAllBookIDsUnsupported is returned when AllBookIDs is passed to a
+	// command that cannot operate on every book at once.
+	ErrAllBookIDsUnsupported = errors.New("calibredb: command does not accept all book ids")
+	// ErrBookIDsTooLong is returned when an id set must be passed as a single
+	// option value and does not fit in one argument.
+	ErrBookIDsTooLong = errors.New("calibredb: book id list is too long for a single argument")
+)
+
+// idRange is an inclusive range of book ids.
+type idRange struct {
+	lo, hi int
+}
+
+// BookIDs is a set of calibre book ids. It is stored as sorted, merged ranges
+// so that large contiguous sets stay small, and it is formatted into the range
+// syntax expected by each calibredb command. The zero value is an empty set.
+type BookIDs struct {
+	ranges []idRange
+	all    bool
+}
+
+// NewBookIDs returns a set containing the given ids.
+func NewBookIDs(ids ...int) BookIDs {
+	var b BookIDs
+	for _, id := range ids {
+		b.ranges = append(b.ranges, idRange{id, id})
+	}
+	b.normalize()
+	return b
+}
+
+// AllBookIDs returns the special set that stands for every book in the library.
+func AllBookIDs() BookIDs {
+	return BookIDs{all: true}
+}
+
+// ParseBookIDs parses a list of ids and ranges separated by commas or
+// whitespace, for example "1,2 10-15". The end of a range is interpreted
+// according to style. The special value "all" yields AllBookIDs.
+func ParseBookIDs(s string, style RangeStyle) (BookIDs, error) {
+	var b BookIDs
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+	for _, field := range fields {
+		if strings.EqualFold(field, "all") {
+			return AllBookIDs(), nil
+		}
+		lo, hi, isRange := strings.Cut(field, "-")
+		start, err := parseBookID(lo)
+		if err != nil {
+			return BookIDs{}, err
+		}
+		end := start
+		if isRange {
+			if end, err = parseBookID(hi); err != nil {
+				return BookIDs{}, err
+			}
+			if style == RangeExclusive {
+				end--
+			}
+			if end < start {
+				return BookIDs{}, fmt.Errorf("calibredb: empty book id range %q", field)
+			}
+		}
+		b.ranges = append(b.ranges, idRange{start, end})
+	}
+	b.normalize()
+	return b, nil
+}
+
+func parseBookID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("calibredb: invalid book id %q", s)
+	}
+	return id, nil
+}
+
+// All reports whether b stands for every book in the library.
+func (b BookIDs) All() bool {
+	return b.all
+}
+
+// IsEmpty reports whether b contains no ids and is not AllBookIDs.
+func (b BookIDs) IsEmpty() bool {
+	return !b.all && len(b.ranges) == 0
+}
+
+// Len returns the number of ids in b. It is zero for AllBookIDs.
+func (b BookIDs) Len() int {
+	n := 0
+	for _, r := range b.ranges {
+		n += r.hi - r.lo + 1
+	}
+	return n
+}
+
+// Contains reports whether id is in b.
+func (b BookIDs) Contains(id int) bool {
+	if b.all {
+		return true
+	}
+	for _, r := range b.ranges {
+		if id >= r.lo && id <= r.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// IDs returns every id in b in ascending order.
+func (b BookIDs) IDs() []int {
+	ids := make([]int, 0, b.Len())
+	for _, r := range b.ranges {
+		for id := r.lo; id <= r.hi; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Union returns the ids that are in b or other.
+func (b BookIDs) Union(other BookIDs) BookIDs {
+	if b.all || other.all {
+		return AllBookIDs()
+	}
+	u := BookIDs{ranges: append(slices.Clone(b.ranges), other.ranges...)}
+	u.normalize()
+	return u
+}
+
+// Format returns b as a comma separated list, compressing runs of three or
+// more consecutive ids into ranges written in the given style.
+func (b BookIDs) Format(style RangeStyle) string {
+	if b.all {
+		return "all"
+	}
+	parts := make([]string, 0, len(b.ranges))
+	for _, r := range b.ranges {
+		parts = append(parts, r.format(style))
+	}
+	return strings.Join(parts, ",")
+}
+
+// String formats b with inclusive ranges.
+func (b BookIDs) String() string {
+	return b.Format(RangeInclusive)
+}
+
+// Chunks splits b into comma separated lists in the given style, none longer
+// than maxLen bytes. A single range is never split, so a chunk may only exceed
+// maxLen when one range alone does.
+func (b BookIDs) Chunks(style RangeStyle, maxLen int) []string {
+	if b.all {
+		return []string{"all"}
+	}
+	var chunks []string
+	var current strings.Builder
+	for _, r := range b.ranges {
+		part := r.format(style)
+		if current.Len() > 0 && current.Len()+1+len(part) > maxLen {
+			chunks = append(chunks, current.String())
+			current.Reset()
+		}
+		if current.Len() > 0 {
+			current.WriteByte(',')
+		}
+		current.WriteString(part)
+	}
+	if current.Len() > 0 {
+		chunks = append(chunks, current.String())
+	}
+	return chunks
+}
+
+// MarshalText implements encoding.TextMarshaler using inclusive ranges.
+func (b BookIDs) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler using inclusive ranges.
+func (b *BookIDs) UnmarshalText(text []byte) error {
+	parsed, err := ParseBookIDs(string(text), RangeInclusive)
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
+
+func (r idRange) format(style RangeStyle) string {
+	switch {
+	case r.lo == r.hi:
+		return strconv.Itoa(r.lo)
+	case r.hi == r.lo+1:
+		return strconv.Itoa(r.lo) + "," + strconv.Itoa(r.hi)
+	case style == RangeExclusive:
+		return strconv.Itoa(r.lo) + "-" + strconv.Itoa(r.hi+1)
+	default:
+		return strconv.Itoa(r.lo) + "-" + strconv.Itoa(r.hi)
+	}
+}
+
+// normalize sorts the ranges and merges overlapping or adjacent ones.
+func (b *BookIDs) normalize() {
+	if len(b.ranges) == 0 {
+		b.ranges = nil
+		return
+	}
+	slices.SortFunc(b.ranges, func(x, y idRange) int {
+		return x.lo - y.lo
+	})
+	merged := b.ranges[:1]
+	for _, r := range b.ranges[1:] {
+		last := &merged[len(merged)-1]
+		if r.lo <= last.hi+1 {
+			last.hi = max(last.hi, r.hi)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	b.ranges = merged
+}
+
+// runBookIDs runs argv once per chunk of ids, appending the chunk as the final
+// positional argument, and joins the outputs. allArg is appended instead of the
+// ids for AllBookIDs; an empty allArg means the command does not support it.
+func (c *Calibre) runBookIDs(argv []string, ids BookIDs, style RangeStyle, allArg string) (string, error) {
+	if ids.All() {
+		if allArg == "" {
+			return "", ErrAllBookIDsUnsupported
+		}
+		return c.run(append(argv, allArg)...)
+	}
+	outs := make([]string, 0, 1)
+	for _, chunk := range ids.Chunks(style, maxBookIDsChunk) {
+		out, err := c.run(append(slices.Clip(argv), chunk)...)
+		if out != "" {
+			outs = append(outs, out)
+		}
+		if err != nil {
+			return strings.Join(outs, "\n"), err
+		}
+	}
+	return strings.Join(outs, "\n"), nil
+}
+
+// bookIDsOption formats ids as the value of a single option such as
+// catalog --ids, where splitting into several invocations is not possible.
+func bookIDsOption(ids BookIDs, style RangeStyle) (string, error) {
+	value := ids.Format(style)
+	if len(value) > maxBookIDsChunk {
+		return "", ErrBookIDsTooLong
+	}
+	return value, nil
+}
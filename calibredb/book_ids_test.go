@@ -0,0 +1,152 @@
+package calibredb_test
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func TestParseBookIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		style   calibredb.RangeStyle
+		want    []int
+		wantAll bool
+		wantErr bool
+	}{
+		{name: "Single id", input: "7", style: calibredb.RangeInclusive, want: []int{7}},
+		{name: "Comma separated", input: "3,1,2", style: calibredb.RangeInclusive, want: []int{1, 2, 3}},
+		{name: "Space separated", input: "1 2 10-12 23", style: calibredb.RangeInclusive, want: []int{1, 2, 10, 11, 12, 23}},
+		{name: "Exclusive range", input: "23,34,57-60", style: calibredb.RangeExclusive, want: []int{23, 34, 57, 58, 59}},
+		{name: "Duplicates and overlaps merge", input: "1-3,2-5,5", style: calibredb.RangeInclusive, want: []int{1, 2, 3, 4, 5}},
+		{name: "All", input: "all", style: calibredb.RangeInclusive, wantAll: true},
+		{name: "Empty", input: "", style: calibredb.RangeInclusive, want: []int{}},
+		{name: "Invalid id", input: "1,abc", style: calibredb.RangeInclusive, wantErr: true},
+		{name: "Zero id", input: "0", style: calibredb.RangeInclusive, wantErr: true},
+		{name: "Reversed range", input: "10-5", style: calibredb.RangeInclusive, wantErr: true},
+		{name: "Empty exclusive range", input: "5-5", style: calibredb.RangeExclusive, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := calibredb.ParseBookIDs(tt.input, tt.style)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBookIDs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.All() != tt.wantAll {
+				t.Errorf("All() = %v, want %v", got.All(), tt.wantAll)
+			}
+			if !tt.wantAll && !slices.Equal(got.IDs(), tt.want) {
+				t.Errorf("IDs() = %v, want %v", got.IDs(), tt.want)
+			}
+		})
+	}
+}
+
+func TestBookIDs_Format(t *testing.T) {
+	tests := []struct {
+		name  string
+		ids   calibredb.BookIDs
+		style calibredb.RangeStyle
+		want  string
+	}{
+		{name: "Empty", ids: calibredb.NewBookIDs(), style: calibredb.RangeExclusive, want: ""},
+		{name: "Singles", ids: calibredb.NewBookIDs(5, 1, 3), style: calibredb.RangeExclusive, want: "1,3,5"},
+		{name: "Pair is not a range", ids: calibredb.NewBookIDs(1, 2), style: calibredb.RangeExclusive, want: "1,2"},
+		{name: "Exclusive range end", ids: calibredb.NewBookIDs(57, 58, 59, 60), style: calibredb.RangeExclusive, want: "57-61"},
+		{name: "Inclusive range end", ids: calibredb.NewBookIDs(57, 58, 59, 60), style: calibredb.RangeInclusive, want: "57-60"},
+		{name: "Mixed", ids: calibredb.NewBookIDs(1, 2, 3, 7, 9, 10), style: calibredb.RangeExclusive, want: "1-4,7,9,10"},
+		{name: "All", ids: calibredb.AllBookIDs(), style: calibredb.RangeExclusive, want: "all"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ids.Format(tt.style); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookIDs_FormatRoundTrip(t *testing.T) {
+	ids := calibredb.NewBookIDs(1, 2, 3, 4, 10, 12, 13, 14, 99)
+	for _, style := range []calibredb.RangeStyle{calibredb.RangeExclusive, calibredb.RangeInclusive} {
+		parsed, err := calibredb.ParseBookIDs(ids.Format(style), style)
+		if err != nil {
+			t.Fatalf("ParseBookIDs() error = %v", err)
+		}
+		if !slices.Equal(parsed.IDs(), ids.IDs()) {
+			t.Errorf("style %v: round trip = %v, want %v", style, parsed.IDs(), ids.IDs())
+		}
+	}
+}
+
+func TestBookIDs_Chunks(t *testing.T) {
+	var many []int
+	for id := 1; id <= 5000; id += 2 {
+		many = append(many, id)
+	}
+	ids := calibredb.NewBookIDs(many...)
+
+	chunks := ids.Chunks(calibredb.RangeExclusive, 100)
+	if len(chunks) < 2 {
+		t.Fatalf("Chunks() returned %d chunks, want several", len(chunks))
+	}
+	var joined []int
+	for _, chunk := range chunks {
+		if len(chunk) > 100 {
+			t.Errorf("chunk of length %d exceeds limit", len(chunk))
+		}
+		parsed, err := calibredb.ParseBookIDs(chunk, calibredb.RangeExclusive)
+		if err != nil {
+			t.Fatalf("ParseBookIDs(%q) error = %v", chunk, err)
+		}
+		joined = append(joined, parsed.IDs()...)
+	}
+	if !slices.Equal(joined, many) {
+		t.Error("chunks do not cover the original set exactly")
+	}
+
+	if got := calibredb.NewBookIDs().Chunks(calibredb.RangeExclusive, 100); len(got) != 0 {
+		t.Errorf("Chunks() of empty set = %v, want none", got)
+	}
+}
+
+func TestBookIDs_JSON(t *testing.T) {
+	var payload struct {
+		Ids calibredb.BookIDs `json:"ids"`
+	}
+	if err := json.Unmarshal([]byte(`{"ids":"1-3,8"}`), &payload); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !slices.Equal(payload.Ids.IDs(), []int{1, 2, 3, 8}) {
+		t.Errorf("Unmarshal() ids = %v", payload.Ids.IDs())
+	}
+	out, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(out) != `{"ids":"1-3,8"}` {
+		t.Errorf("Marshal() = %s", out)
+	}
+}
+
+func TestBookIDs_Validation(t *testing.T) {
+	c, cleanup := getTestCalibre(t.Name())
+	defer cleanup()
+
+	_, err := c.Remove(calibredb.RemoveOptions{})
+	if err == nil || !strings.Contains(err.Error(), "required") {
+		t.Errorf("Remove() with no ids error = %v, want required validation error", err)
+	}
+
+	_, err = c.Remove(calibredb.RemoveOptions{Ids: calibredb.AllBookIDs()})
+	if err == nil || !strings.Contains(err.Error(), "'bookids' tag") {
+		t.Errorf("Remove() with all ids error = %v, want bookids validation error", err)
+	}
+}
@@ -0,0 +1,329 @@
+package calibredb
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// BookFields are the builtin fields calibredb list can return. Custom columns
+// are addressed as *label, for example *rating for the #rating column.
+var BookFields = []string{
+	"author_sort", "authors", "comments", "cover", "formats", "identifiers",
+	"isbn", "languages", "last_modified", "pubdate", "publisher", "rating",
+	"series", "series_index", "size", "tags", "template", "timestamp", "title",
+	"uuid",
+}
+
+var (
+	// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+	// or was issued for a different sort order.
+	ErrInvalidCursor = errors.New("calibredb: invalid cursor")
+	// ErrInvalidSortField is returned when ListBooks is asked to sort by a
+	// field that is neither builtin nor a custom column.
+	ErrInvalidSortField = errors.New("calibredb: invalid sort field")
+)
+
+// Book is a single entry of calibredb list --for-machine. Only the fields that
+// were requested are populated.
+type Book struct {
+	ID           int               `json:"id"`
+	Title        string            `json:"title,omitempty"`
+	Authors      string            `json:"authors,omitempty"`
+	AuthorSort   string            `json:"author_sort,omitempty"`
+	Comments     string            `json:"comments,omitempty"`
+	Cover        string            `json:"cover,omitempty"`
+	Formats      []string          `json:"formats,omitempty"`
+	Identifiers  map[string]string `json:"identifiers,omitempty"`
+	ISBN         string            `json:"isbn,omitempty"`
+	Languages    []string          `json:"languages,omitempty"`
+	LastModified time.Time         `json:"last_modified,omitzero"`
+	Pubdate      time.Time         `json:"pubdate,omitzero"`
+	Publisher    string            `json:"publisher,omitempty"`
+	Rating       float64           `json:"rating,omitempty"`
+	Series       string            `json:"series,omitempty"`
+	SeriesIndex  float64           `json:"series_index,omitempty"`
+	Size         int64             `json:"size,omitempty"`
+	Tags         []string          `json:"tags,omitempty"`
+	Template     string            `json:"template,omitempty"`
+	Timestamp    time.Time         `json:"timestamp,omitzero"`
+	UUID         string            `json:"uuid,omitempty"`
+	// Custom holds custom column values keyed by lookup name, e.g. #rating.
+	Custom map[string]any `json:"custom,omitempty"`
+}
+
+// UnmarshalJSON decodes a calibredb list entry, collecting custom column
+// values (keys starting with * or #) into Custom.
+func (b *Book) UnmarshalJSON(data []byte) error {
+	type plain Book
+	var p plain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for key, value := range raw {
+		if strings.HasPrefix(key, "*") || strings.HasPrefix(key, "#") {
+			if p.Custom == nil {
+				p.Custom = make(map[string]any)
+			}
+			p.Custom["#"+key[1:]] = value
+		}
+	}
+	*b = Book(p)
+	return nil
+}
+
+// ListBooksOptions selects and pages through books. Offset and Cursor are
+// mutually exclusive; a cursor keeps pages stable while the library changes.
+type ListBooksOptions struct {
+	Fields     []string // Fields to return. Default: title, authors
+	Search     string   // Calibre search expression. Default: all books
+	SortBy     string   // Single field to sort by. Default: id
+	Descending bool     // Sort in descending order
+	Offset     int      `validate:"gte=0"`
+	Limit      int      `validate:"gte=0"` // Page size. Default: all remaining books
+	Cursor     string   // Cursor returned in a previous BookPage
+}
+
+// BookPage is one page of ListBooks results.
+type BookPage struct {
+	Books      []Book `json:"books"`
+	Total      int    `json:"total"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// ListBooks runs calibredb list --for-machine and returns one page of typed
+// results. Books are ordered by SortBy and then by id, so cursors stay valid
+// when books are added or removed between requests.
+func (c *Calibre) ListBooks(opts ListBooksOptions) (BookPage, error) {
+	if err := c.validate.Struct(opts); err != nil {
+		return BookPage{}, err
+	}
+	if opts.SortBy != "" && opts.SortBy != "id" && !slices.Contains(BookFields, opts.SortBy) && !strings.HasPrefix(opts.SortBy, "*") {
+		return BookPage{}, fmt.Errorf("%w: %q", ErrInvalidSortField, opts.SortBy)
+	}
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = []string{"title", "authors"}
+	}
+	if opts.SortBy != "" && opts.SortBy != "id" && !slices.Contains(fields, opts.SortBy) {
+		fields = append(slices.Clip(fields), opts.SortBy)
+	}
+	books, err := c.listBooks(ListOptions{
+		Fields:     strings.Join(fields, ","),
+		ForMachine: lo.ToPtr(true),
+		Search:     opts.Search,
+	})
+	if err != nil {
+		return BookPage{}, err
+	}
+	return PaginateBooks(books, opts)
+}
+
+func (c *Calibre) listBooks(opts ListOptions) ([]Book, error) {
+	out, err := c.List(opts)
+	if err != nil {
+		return nil, err
+	}
+	var books []Book
+	if err := json.Unmarshal([]byte(out), &books); err != nil {
+		return nil, fmt.Errorf("calibredb: decoding list output: %w", err)
+	}
+	return books, nil
+}
+
+// bookCursor is the decoded form of a pagination cursor. It records the sort
+// key and id of the book on the edge of a page.
+type bookCursor struct {
+	SortBy     string `json:"s,omitempty"`
+	Descending bool   `json:"d,omitempty"`
+	Key        any    `json:"k"`
+	ID         int    `json:"i"`
+	Before     bool   `json:"b,omitempty"`
+}
+
+func (bc bookCursor) encode() string {
+	data, _ := json.Marshal(bc)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeBookCursor(s string) (bookCursor, error) {
+	var bc bookCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return bc, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &bc); err != nil {
+		return bc, ErrInvalidCursor
+	}
+	return bc, nil
+}
+
+// PaginateBooks sorts books by opts.SortBy and id and returns the page
+// selected by opts.Offset or opts.Cursor. It does not filter by opts.Search.
+func PaginateBooks(books []Book, opts ListBooksOptions) (BookPage, error) {
+	sortBy := opts.SortBy
+	if sortBy == "id" {
+		sortBy = ""
+	}
+	sorted := slices.Clone(books)
+	slices.SortStableFunc(sorted, func(a, b Book) int {
+		return compareBooks(a, b, sortBy, opts.Descending)
+	})
+
+	start, end := opts.Offset, len(sorted)
+	if opts.Cursor != "" {
+		if opts.Offset != 0 {
+			return BookPage{}, fmt.Errorf("%w: offset and cursor are mutually exclusive", ErrInvalidCursor)
+		}
+		bc, err := decodeBookCursor(opts.Cursor)
+		if err != nil {
+			return BookPage{}, err
+		}
+		if bc.SortBy != sortBy || bc.Descending != opts.Descending {
+			return BookPage{}, fmt.Errorf("%w: cursor was issued for a different sort order", ErrInvalidCursor)
+		}
+		// position of the cursor's edge book, or of the first book after it
+		// when the edge book has since been removed
+		pos, found := slices.BinarySearchFunc(sorted, bc, func(candidate Book, edge bookCursor) int {
+			return compareKeys(sortKey(candidate, sortBy), candidate.ID, edge.Key, edge.ID, opts.Descending)
+		})
+		if bc.Before {
+			end = pos
+			start = 0
+			if opts.Limit > 0 {
+				start = max(0, end-opts.Limit)
+			}
+		} else {
+			start = pos
+			if found {
+				start++
+			}
+		}
+	}
+	start = min(start, len(sorted))
+	if opts.Limit > 0 {
+		end = min(end, start+opts.Limit)
+	}
+
+	page := BookPage{
+		Books:  sorted[start:end],
+		Total:  len(sorted),
+		Offset: start,
+	}
+	if end < len(sorted) && end > start {
+		last := sorted[end-1]
+		page.NextCursor = bookCursor{SortBy: sortBy, Descending: opts.Descending, Key: sortKey(last, sortBy), ID: last.ID}.encode()
+	}
+	if start > 0 && end > start {
+		first := sorted[start]
+		page.PrevCursor = bookCursor{SortBy: sortBy, Descending: opts.Descending, Key: sortKey(first, sortBy), ID: first.ID, Before: true}.encode()
+	}
+	return page, nil
+}
+
+func compareBooks(a, b Book, sortBy string, descending bool) int {
+	return compareKeys(sortKey(a, sortBy), a.ID, sortKey(b, sortBy), b.ID, descending)
+}
+
+// compareKeys orders by sort key and then by id. Keys are normalised by
+// sortKey to strings or float64 so they survive a JSON round trip in a cursor.
+func compareKeys(keyA any, idA int, keyB any, idB int, descending bool) int {
+	var c int
+	numA, aIsNum := keyA.(float64)
+	numB, bIsNum := keyB.(float64)
+	switch {
+	case aIsNum && bIsNum:
+		c = cmp.Compare(numA, numB)
+	case aIsNum:
+		// numbers sort before missing or textual values
+		c = -1
+	case bIsNum:
+		c = 1
+	default:
+		strA, _ := keyA.(string)
+		strB, _ := keyB.(string)
+		c = strings.Compare(strA, strB)
+	}
+	if c == 0 {
+		c = cmp.Compare(idA, idB)
+	}
+	if descending {
+		return -c
+	}
+	return c
+}
+
+// sortKey returns the value of field used for ordering: a float64 for numeric
+// fields and a case folded string for everything else.
+func sortKey(b Book, field string) any {
+	switch field {
+	case "":
+		return nil
+	case "rating":
+		return b.Rating
+	case "series_index":
+		return b.SeriesIndex
+	case "size":
+		return float64(b.Size)
+	case "title":
+		return strings.ToLower(b.Title)
+	case "authors":
+		return strings.ToLower(b.Authors)
+	case "author_sort":
+		return strings.ToLower(b.AuthorSort)
+	case "comments":
+		return strings.ToLower(b.Comments)
+	case "cover":
+		return b.Cover
+	case "formats":
+		return strings.ToLower(strings.Join(b.Formats, ","))
+	case "identifiers":
+		keys := lo.Keys(b.Identifiers)
+		slices.Sort(keys)
+		return strings.Join(lo.Map(keys, func(k string, _ int) string { return k + ":" + b.Identifiers[k] }), ",")
+	case "isbn":
+		return b.ISBN
+	case "languages":
+		return strings.Join(b.Languages, ",")
+	case "last_modified":
+		return b.LastModified.UTC().Format(time.RFC3339Nano)
+	case "pubdate":
+		return b.Pubdate.UTC().Format(time.RFC3339Nano)
+	case "timestamp":
+		return b.Timestamp.UTC().Format(time.RFC3339Nano)
+	case "publisher":
+		return strings.ToLower(b.Publisher)
+	case "series":
+		return strings.ToLower(b.Series)
+	case "tags":
+		return strings.ToLower(strings.Join(b.Tags, ","))
+	case "template":
+		return b.Template
+	case "uuid":
+		return b.UUID
+	}
+	if value, ok := b.Custom["#"+strings.TrimLeft(field, "*#")]; ok {
+		switch v := value.(type) {
+		case float64:
+			return v
+		case nil:
+			return ""
+		default:
+			return strings.ToLower(fmt.Sprint(v))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,205 @@
+package calibredb_test
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func bookIDs(books []calibredb.Book) []int {
+	ids := make([]int, 0, len(books))
+	for _, b := range books {
+		ids = append(ids, b.ID)
+	}
+	return ids
+}
+
+func testBooks() []calibredb.Book {
+	return []calibredb.Book{
+		{ID: 1, Title: "Dune", Rating: 10},
+		{ID: 2, Title: "anathem", Rating: 8},
+		{ID: 3, Title: "Cryptonomicon", Rating: 8},
+		{ID: 4, Title: "Blindsight", Rating: 6},
+		{ID: 5, Title: "Emma"},
+	}
+}
+
+func TestBook_UnmarshalJSON(t *testing.T) {
+	data := `{"id": 3, "title": "Dune", "authors": "Frank Herbert", "tags": ["sf"],
+		"identifiers": {"isbn": "9780441013593"}, "last_modified": "2024-05-01T10:00:00+00:00",
+		"*genre": "Science Fiction", "#pages": 412}`
+	var b calibredb.Book
+	if err := json.Unmarshal([]byte(data), &b); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if b.ID != 3 || b.Title != "Dune" || b.Authors != "Frank Herbert" {
+		t.Errorf("Unmarshal() builtin fields = %+v", b)
+	}
+	if b.Identifiers["isbn"] != "9780441013593" || !slices.Equal(b.Tags, []string{"sf"}) {
+		t.Errorf("Unmarshal() identifiers/tags = %v %v", b.Identifiers, b.Tags)
+	}
+	if b.LastModified.Year() != 2024 {
+		t.Errorf("Unmarshal() last_modified = %v", b.LastModified)
+	}
+	if b.Custom["#genre"] != "Science Fiction" || b.Custom["#pages"] != float64(412) {
+		t.Errorf("Unmarshal() custom = %v", b.Custom)
+	}
+}
+
+func TestPaginateBooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      calibredb.ListBooksOptions
+		want      []int
+		wantTotal int
+		wantNext  bool
+		wantPrev  bool
+	}{
+		{
+			name:      "Default order is by id",
+			opts:      calibredb.ListBooksOptions{},
+			want:      []int{1, 2, 3, 4, 5},
+			wantTotal: 5,
+		},
+		{
+			name:      "First page",
+			opts:      calibredb.ListBooksOptions{Limit: 2},
+			want:      []int{1, 2},
+			wantTotal: 5,
+			wantNext:  true,
+		},
+		{
+			name:      "Middle page by offset",
+			opts:      calibredb.ListBooksOptions{Offset: 2, Limit: 2},
+			want:      []int{3, 4},
+			wantTotal: 5,
+			wantNext:  true,
+			wantPrev:  true,
+		},
+		{
+			name:      "Offset past the end",
+			opts:      calibredb.ListBooksOptions{Offset: 10, Limit: 2},
+			want:      []int{},
+			wantTotal: 5,
+		},
+		{
+			name:      "Sort by title is case insensitive",
+			opts:      calibredb.ListBooksOptions{SortBy: "title"},
+			want:      []int{2, 4, 3, 1, 5},
+			wantTotal: 5,
+		},
+		{
+			name:      "Sort by rating descending breaks ties by id",
+			opts:      calibredb.ListBooksOptions{SortBy: "rating", Descending: true},
+			want:      []int{1, 3, 2, 4, 5},
+			wantTotal: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := calibredb.PaginateBooks(testBooks(), tt.opts)
+			if err != nil {
+				t.Fatalf("PaginateBooks() error = %v", err)
+			}
+			if got := bookIDs(page.Books); !slices.Equal(got, tt.want) {
+				t.Errorf("PaginateBooks() ids = %v, want %v", got, tt.want)
+			}
+			if page.Total != tt.wantTotal {
+				t.Errorf("PaginateBooks() total = %d, want %d", page.Total, tt.wantTotal)
+			}
+			if (page.NextCursor != "") != tt.wantNext {
+				t.Errorf("PaginateBooks() next cursor = %q, want present %v", page.NextCursor, tt.wantNext)
+			}
+			if (page.PrevCursor != "") != tt.wantPrev {
+				t.Errorf("PaginateBooks() prev cursor = %q, want present %v", page.PrevCursor, tt.wantPrev)
+			}
+		})
+	}
+}
+
+func TestPaginateBooks_CursorWalk(t *testing.T) {
+	opts := calibredb.ListBooksOptions{SortBy: "title", Limit: 2}
+	var seen []int
+	for range 10 {
+		page, err := calibredb.PaginateBooks(testBooks(), opts)
+		if err != nil {
+			t.Fatalf("PaginateBooks() error = %v", err)
+		}
+		seen = append(seen, bookIDs(page.Books)...)
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if want := []int{2, 4, 3, 1, 5}; !slices.Equal(seen, want) {
+		t.Errorf("cursor walk = %v, want %v", seen, want)
+	}
+}
+
+func TestPaginateBooks_CursorStableAcrossChanges(t *testing.T) {
+	books := testBooks()
+	first, err := calibredb.PaginateBooks(books, calibredb.ListBooksOptions{SortBy: "title", Limit: 2})
+	if err != nil {
+		t.Fatalf("PaginateBooks() error = %v", err)
+	}
+
+	// remove the edge book of the first page and insert a book before it
+	changed := slices.DeleteFunc(slices.Clone(books), func(b calibredb.Book) bool { return b.ID == 4 })
+	changed = append(changed, calibredb.Book{ID: 6, Title: "Aardvark"})
+
+	next, err := calibredb.PaginateBooks(changed, calibredb.ListBooksOptions{SortBy: "title", Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("PaginateBooks() error = %v", err)
+	}
+	if got, want := bookIDs(next.Books), []int{3, 1}; !slices.Equal(got, want) {
+		t.Errorf("next page after changes = %v, want %v", got, want)
+	}
+
+	prev, err := calibredb.PaginateBooks(changed, calibredb.ListBooksOptions{SortBy: "title", Limit: 2, Cursor: next.PrevCursor})
+	if err != nil {
+		t.Fatalf("PaginateBooks() error = %v", err)
+	}
+	if got, want := bookIDs(prev.Books), []int{6, 2}; !slices.Equal(got, want) {
+		t.Errorf("previous page after changes = %v, want %v", got, want)
+	}
+}
+
+func TestPaginateBooks_InvalidCursor(t *testing.T) {
+	tests := []struct {
+		name string
+		opts calibredb.ListBooksOptions
+	}{
+		{name: "Garbage", opts: calibredb.ListBooksOptions{Cursor: "not a cursor"}},
+		{name: "Cursor with offset", opts: calibredb.ListBooksOptions{Cursor: "eyJpIjoxfQ", Offset: 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := calibredb.PaginateBooks(testBooks(), tt.opts)
+			if !errors.Is(err, calibredb.ErrInvalidCursor) {
+				t.Errorf("PaginateBooks() error = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+
+	page, err := calibredb.PaginateBooks(testBooks(), calibredb.ListBooksOptions{SortBy: "title", Limit: 2})
+	if err != nil {
+		t.Fatalf("PaginateBooks() error = %v", err)
+	}
+	_, err = calibredb.PaginateBooks(testBooks(), calibredb.ListBooksOptions{SortBy: "rating", Cursor: page.NextCursor})
+	if !errors.Is(err, calibredb.ErrInvalidCursor) {
+		t.Errorf("PaginateBooks() with mismatched sort error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestCalibre_ListBooks_InvalidSortField(t *testing.T) {
+	c, cleanup := getTestCalibre(t.Name())
+	defer cleanup()
+
+	_, err := c.ListBooks(calibredb.ListBooksOptions{SortBy: "nonsense"})
+	if !errors.Is(err, calibredb.ErrInvalidSortField) {
+		t.Errorf("ListBooks() error = %v, want ErrInvalidSortField", err)
+	}
+}
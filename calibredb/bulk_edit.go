@@ -0,0 +1,237 @@
+package calibredb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// ErrEmptyPatch is returned by BulkEdit for a patch that changes nothing.
+var ErrEmptyPatch = errors.New("calibredb: the patch changes nothing")
+
+// MetadataPatch is a change to the metadata of books. Fields left empty are
+// not touched.
+type MetadataPatch struct {
+	// SetTags replaces the tags; an empty list that is not nil clears them.
+	SetTags []string `json:"set_tags,omitempty"`
+	// AddTags and RemoveTags are applied after SetTags. Tags compare case
+	// insensitively, as in calibre.
+	AddTags    []string `json:"add_tags,omitempty"`
+	RemoveTags []string `json:"remove_tags,omitempty"`
+	// Series sets the series; an empty string clears it.
+	Series      *string       `json:"series,omitempty"`
+	SeriesIndex *float64      `json:"series_index,omitempty" validate:"omitempty,gte=0"`
+	Custom      []CustomPatch `json:"custom,omitempty" validate:"dive"`
+}
+
+// CustomPatch sets a custom column like SetCustom.
+type CustomPatch struct {
+	// Column is the label of the column, without the leading #.
+	Column string `json:"column" validate:"required"`
+	// Value is the new value; an empty one clears the column.
+	Value string `json:"value"`
+	// Append adds the comma separated values to those of a column with
+	// multiple values instead of replacing them, see SetCustomOptions.Append.
+	Append bool `json:"append,omitempty"`
+}
+
+func (p MetadataPatch) isEmpty() bool {
+	return p.SetTags == nil && len(p.AddTags) == 0 && len(p.RemoveTags) == 0 &&
+		p.Series == nil && p.SeriesIndex == nil && len(p.Custom) == 0
+}
+
+// BulkEditOptions selects the books of a bulk edit and the change to make.
+type BulkEditOptions struct {
+	// Search is a calibre search expression; every matching book is changed.
+	Search string        `json:"search" validate:"required"`
+	Patch  MetadataPatch `json:"patch"`
+	// DryRun computes the changes without making them.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Progress is called after each book with the number of books done and
+	// the number matched.
+	Progress func(done, total int) `json:"-"`
+}
+
+// BookChange is the change of one book in a bulk edit. Before and After hold
+// the patched fields only, keyed as in calibredb list: tags, series,
+// series_index and #label for custom columns.
+type BookChange struct {
+	ID     int            `json:"id"`
+	Title  string         `json:"title"`
+	Before map[string]any `json:"before"`
+	After  map[string]any `json:"after"`
+	// Changed is false when the book already had the values of the patch.
+	Changed bool   `json:"changed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkEditResult reports a bulk edit. Changed counts the books that were
+// changed, or would be in a dry run. A book that failed does not stop the
+// others; it counts as Failed instead and its change has the error.
+type BulkEditResult struct {
+	DryRun  bool         `json:"dry_run"`
+	Matched int          `json:"matched"`
+	Changed int          `json:"changed"`
+	Failed  int          `json:"failed"`
+	Books   []BookChange `json:"books"`
+}
+
+// BulkEdit applies the patch to every book matching the search, one book at a
+// time. Builtin fields are written with a single set_metadata --field call
+// per book and custom columns with set_custom, so the audit log and events
+// see every command. Books that already have the patched values are left
+// alone. The error is only set when the books could not be listed.
+func (c *Calibre) BulkEdit(opts BulkEditOptions) (BulkEditResult, error) {
+	if err := c.validate.Struct(opts); err != nil {
+		return BulkEditResult{}, err
+	}
+	if opts.Patch.isEmpty() {
+		return BulkEditResult{}, ErrEmptyPatch
+	}
+	fields := []string{"title", "tags", "series", "series_index"}
+	for _, cp := range opts.Patch.Custom {
+		fields = append(fields, "*"+strings.TrimPrefix(cp.Column, "#"))
+	}
+	books, err := c.listBooks(ListOptions{
+		Fields:     strings.Join(lo.Uniq(fields), ","),
+		ForMachine: lo.ToPtr(true),
+		Search:     opts.Search,
+	})
+	if err != nil {
+		return BulkEditResult{}, err
+	}
+	slices.SortFunc(books, func(a, b Book) int { return a.ID - b.ID })
+
+	result := BulkEditResult{DryRun: opts.DryRun, Matched: len(books), Books: []BookChange{}}
+	for i, b := range books {
+		change := opts.Patch.change(b)
+		if change.Changed {
+			var err error
+			if !opts.DryRun {
+				err = c.applyPatch(b.ID, opts.Patch, change)
+			}
+			if err != nil {
+				change.Error = err.Error()
+				result.Failed++
+			} else {
+				result.Changed++
+			}
+		}
+		result.Books = append(result.Books, change)
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(books))
+		}
+	}
+	return result, nil
+}
+
+// change computes the values the patch gives b.
+func (p MetadataPatch) change(b Book) BookChange {
+	change := BookChange{ID: b.ID, Title: b.Title, Before: map[string]any{}, After: map[string]any{}}
+	if p.SetTags != nil || len(p.AddTags) > 0 || len(p.RemoveTags) > 0 {
+		tags := slices.Clone(b.Tags)
+		if p.SetTags != nil {
+			tags = slices.Clone(p.SetTags)
+		}
+		for _, tag := range p.AddTags {
+			if !slices.ContainsFunc(tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+				tags = append(tags, tag)
+			}
+		}
+		tags = slices.DeleteFunc(tags, func(t string) bool {
+			return slices.ContainsFunc(p.RemoveTags, func(r string) bool { return strings.EqualFold(t, r) })
+		})
+		change.Before["tags"] = nonNil(b.Tags)
+		change.After["tags"] = nonNil(tags)
+	}
+	if p.Series != nil {
+		change.Before["series"] = b.Series
+		change.After["series"] = *p.Series
+	}
+	if p.SeriesIndex != nil {
+		change.Before["series_index"] = b.SeriesIndex
+		change.After["series_index"] = *p.SeriesIndex
+	}
+	for _, cp := range p.Custom {
+		key := "#" + strings.TrimPrefix(cp.Column, "#")
+		before := b.Custom[key]
+		change.Before[key] = before
+		change.After[key] = cp.after(before)
+	}
+	change.Changed = !reflect.DeepEqual(change.Before, change.After)
+	return change
+}
+
+// after returns the value the column has after the patch, in the form
+// calibredb list returns it: a list for columns with multiple values.
+func (cp CustomPatch) after(before any) any {
+	existing, multiple := before.([]any)
+	if !multiple && !cp.Append {
+		if cp.Value == "" {
+			// listed as null once cleared
+			return nil
+		}
+		if before != nil && fmt.Sprint(before) == cp.Value {
+			// the same number or bool as the one listed
+			return before
+		}
+		return cp.Value
+	}
+	var values []any
+	if cp.Append {
+		values = slices.Clone(existing)
+	}
+	for _, v := range strings.Split(cp.Value, ",") {
+		if v = strings.TrimSpace(v); v != "" && !slices.Contains(values, any(v)) {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// applyPatch writes a computed change of the book with the given id.
+func (c *Calibre) applyPatch(id int, p MetadataPatch, change BookChange) error {
+	var fields []string
+	if tags, ok := change.After["tags"].([]string); ok && !reflect.DeepEqual(tags, change.Before["tags"]) {
+		fields = append(fields, "tags:"+strings.Join(tags, ","))
+	}
+	if p.Series != nil && *p.Series != change.Before["series"] {
+		fields = append(fields, "series:"+*p.Series)
+	}
+	if p.SeriesIndex != nil && *p.SeriesIndex != change.Before["series_index"] {
+		fields = append(fields, "series_index:"+strconv.FormatFloat(*p.SeriesIndex, 'f', -1, 64))
+	}
+	if len(fields) > 0 {
+		if _, err := c.SetMetadata(SetMetadataOptions{BookId: strconv.Itoa(id), Field: fields}); err != nil {
+			return err
+		}
+	}
+	for _, cp := range p.Custom {
+		key := "#" + strings.TrimPrefix(cp.Column, "#")
+		if reflect.DeepEqual(change.Before[key], change.After[key]) {
+			continue
+		}
+		_, err := c.SetCustom(SetCustomOptions{
+			Column: strings.TrimPrefix(cp.Column, "#"),
+			Id:     strconv.Itoa(id),
+			Value:  cp.Value,
+			Append: lo.ToPtr(cp.Append),
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
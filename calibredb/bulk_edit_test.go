@@ -0,0 +1,167 @@
+package calibredb_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+// newBulkEditLibrary returns a library with Dune (tags scifi), Emma (tags
+// classic) and Anathem (tags scifi, Classic) and a #genre column with
+// multiple values.
+func newBulkEditLibrary(t *testing.T) *calibredb.Calibre {
+	t.Helper()
+	c, _ := newLibrary(t, []testBook{
+		{file: "Dune - Frank Herbert.epub", tags: "scifi"},
+		{file: "Emma - Jane Austen.epub", tags: "classic"},
+		{file: "Anathem - Neal Stephenson.epub", tags: "scifi,Classic"},
+	})
+	if _, err := c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "genre", Name: "Genre", Datatype: "text", IsMultiple: ptr(true)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetCustom(calibredb.SetCustomOptions{Column: "genre", Id: "1", Value: "space"}); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCalibre_BulkEdit(t *testing.T) {
+	patch := calibredb.MetadataPatch{
+		AddTags:    []string{"favorite"},
+		RemoveTags: []string{"classic"},
+		Series:     ptr("Picks"),
+		Custom:     []calibredb.CustomPatch{{Column: "genre", Value: "epic", Append: true}},
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		c := newBulkEditLibrary(t)
+		var progress []int
+		result, err := c.BulkEdit(calibredb.BulkEditOptions{
+			Search:   "tags:scifi",
+			Patch:    patch,
+			DryRun:   true,
+			Progress: func(done, total int) { progress = append(progress, done, total) },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Matched != 2 || result.Changed != 2 || result.Failed != 0 || !result.DryRun {
+			t.Errorf("result = %+v", result)
+		}
+		if !slices.Equal(progress, []int{1, 2, 2, 2}) {
+			t.Errorf("progress = %v", progress)
+		}
+		anathem := result.Books[1]
+		wantBefore := map[string]any{"tags": []string{"scifi", "Classic"}, "series": "", "#genre": nil}
+		wantAfter := map[string]any{"tags": []string{"scifi", "favorite"}, "series": "Picks", "#genre": []any{"epic"}}
+		if !reflect.DeepEqual(anathem.Before, wantBefore) || !reflect.DeepEqual(anathem.After, wantAfter) {
+			t.Errorf("Anathem before %v after %v, want %v and %v", anathem.Before, anathem.After, wantBefore, wantAfter)
+		}
+		if dune := result.Books[0]; !reflect.DeepEqual(dune.After["#genre"], []any{"space", "epic"}) {
+			t.Errorf("Dune #genre after = %v", dune.After["#genre"])
+		}
+
+		page, err := c.ListBooks(calibredb.ListBooksOptions{Fields: []string{"tags", "series"}, Search: "series:Picks"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 0 {
+			t.Errorf("the dry run changed %+v", page.Books)
+		}
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		c := newBulkEditLibrary(t)
+		result, err := c.BulkEdit(calibredb.BulkEditOptions{Search: "tags:scifi", Patch: patch})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Changed != 2 || result.Failed != 0 {
+			t.Fatalf("result = %+v", result)
+		}
+		page, err := c.ListBooks(calibredb.ListBooksOptions{Fields: []string{"tags", "series", "*genre"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[int]string{
+			1: "[scifi favorite] Picks [space epic]",
+			2: "[classic]  <nil>",
+			3: "[scifi favorite] Picks [epic]",
+		}
+		for _, b := range page.Books {
+			got := fmtBook(b)
+			if got != want[b.ID] {
+				t.Errorf("book %d = %q, want %q", b.ID, got, want[b.ID])
+			}
+		}
+
+		// a second run finds nothing to change
+		again, err := c.BulkEdit(calibredb.BulkEditOptions{Search: "tags:scifi", Patch: calibredb.MetadataPatch{Series: ptr("Picks")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.Matched != 2 || again.Changed != 0 {
+			t.Errorf("second run = %+v", again)
+		}
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		c := newBulkEditLibrary(t)
+		if _, err := c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "pages", Name: "Pages", Datatype: "int"}); err != nil {
+			t.Fatal(err)
+		}
+		// every book fails and the edit still goes through all of them
+		result, err := c.BulkEdit(calibredb.BulkEditOptions{
+			Search: "tags:scifi",
+			Patch:  calibredb.MetadataPatch{Custom: []calibredb.CustomPatch{{Column: "pages", Value: "many"}}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Matched != 2 || result.Changed != 0 || result.Failed != 2 || result.Books[0].Error == "" || result.Books[1].Error == "" {
+			t.Errorf("result = %+v", result)
+		}
+	})
+
+	t.Run("clear custom column", func(t *testing.T) {
+		c := newBulkEditLibrary(t)
+		clear := calibredb.BulkEditOptions{
+			Search: "tags:scifi",
+			Patch:  calibredb.MetadataPatch{Custom: []calibredb.CustomPatch{{Column: "genre"}}},
+		}
+		// only Dune has a genre to clear
+		result, err := c.BulkEdit(clear)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Changed != 1 || result.Failed != 0 || !result.Books[0].Changed || result.Books[1].Changed {
+			t.Fatalf("result = %+v", result)
+		}
+		clear.DryRun = true
+		again, err := c.BulkEdit(clear)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.Changed != 0 || again.Books[0].Before["#genre"] != nil {
+			t.Errorf("second run = %+v", again)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		c := newBulkEditLibrary(t)
+		if _, err := c.BulkEdit(calibredb.BulkEditOptions{Search: "tags:scifi"}); !errors.Is(err, calibredb.ErrEmptyPatch) {
+			t.Errorf("BulkEdit() of an empty patch = %v", err)
+		}
+		if _, err := c.BulkEdit(calibredb.BulkEditOptions{Patch: patch}); err == nil {
+			t.Error("BulkEdit() without a search succeeded")
+		}
+	})
+}
+
+func fmtBook(b calibredb.Book) string {
+	return fmt.Sprintf("%v %s %v", b.Tags, b.Series, b.Custom["#genre"])
+}
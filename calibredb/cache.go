@@ -0,0 +1,245 @@
+package calibredb
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readCommands are the calibredb commands whose output may be cached.
+var readCommands = map[string]bool{
+	"list":            true,
+	"list_categories": true,
+	"show_metadata":   true,
+	"custom_columns":  true,
+	"search":          true,
+}
+
+// writeCommands are the calibredb commands that change a library. A successful
+// run of any of them invalidates every cached result for that library.
+var writeCommands = map[string]bool{
+	"add":                  true,
+	"remove":               true,
+	"set_metadata":         true,
+	"set_custom":           true,
+	"add_format":           true,
+	"remove_format":        true,
+	"add_custom_column":    true,
+	"remove_custom_column": true,
+	"restore_database":     true,
+}
+
+// CacheStats is a snapshot of cache counters.
+type CacheStats struct {
+	Hits          uint64 `json:"hits"`
+	Misses        uint64 `json:"misses"`
+	Invalidations uint64 `json:"invalidations"`
+	Evictions     uint64 `json:"evictions"`
+	Entries       int    `json:"entries"`
+}
+
+// Cache stores the output of read commands. It is safe for concurrent use and
+// may be shared by several Calibre values; entries are partitioned by library
+// so a write through one Calibre invalidates reads through the others.
+type Cache struct {
+	MaxEntries int
+	TTL        time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	stamps  map[string]dbStamp
+	// generations counts the invalidations of each library, so that put can
+	// tell that a result read before a write finished is stale
+	generations map[string]uint64
+	stats       CacheStats
+}
+
+type CacheOption func(*Cache)
+
+// WithCacheMaxEntries bounds the number of cached results. Least recently used
+// results are evicted first. Zero means unbounded.
+func WithCacheMaxEntries(n int) CacheOption {
+	return func(c *Cache) {
+		c.MaxEntries = n
+	}
+}
+
+// WithCacheTTL expires results after d even if the library did not change.
+// Zero means results only expire through invalidation.
+func WithCacheTTL(d time.Duration) CacheOption {
+	return func(c *Cache) {
+		c.TTL = d
+	}
+}
+
+func NewCache(opts ...CacheOption) *Cache {
+	c := &Cache{
+		MaxEntries:  1024,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		stamps:      make(map[string]dbStamp),
+		generations: make(map[string]uint64),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithCache enables caching of read commands through cache.
+func WithCache(cache *Cache) CalibreOption {
+	return func(c *Calibre) {
+		c.cache = cache
+	}
+}
+
+// CacheStats returns the statistics of the cache used by c, or the zero value
+// when caching is disabled.
+func (c *Calibre) CacheStats() CacheStats {
+	if c.cache == nil {
+		return CacheStats{}
+	}
+	return c.cache.Stats()
+}
+
+type cacheEntry struct {
+	key     string
+	library string
+	output  string
+	stored  time.Time
+}
+
+// dbStamp identifies a version of metadata.db so that changes made outside
+// this process (the calibre GUI, another server) are noticed.
+type dbStamp struct {
+	modTime int64
+	size    int64
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+// Invalidate drops every cached result for library.
+func (c *Cache) Invalidate(library string) {
+	library = normalizeLibrary(library)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.invalidateLocked(library)
+}
+
+// get returns the cached output of argv. On a miss it returns the generation
+// of the library instead, which the caller hands to put with the output.
+func (c *Cache) get(library string, argv []string) (string, uint64, bool) {
+	library = normalizeLibrary(library)
+	stamp, watched := statMetadataDB(library)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if watched {
+		if previous, ok := c.stamps[library]; ok && previous != stamp {
+			c.invalidateLocked(library)
+		}
+		c.stamps[library] = stamp
+	}
+	generation := c.generations[library]
+	elem, ok := c.entries[cacheKey(library, argv)]
+	if !ok {
+		c.stats.Misses++
+		return "", generation, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if c.TTL > 0 && time.Since(entry.stored) > c.TTL {
+		c.removeLocked(elem)
+		c.stats.Misses++
+		return "", generation, false
+	}
+	c.lru.MoveToFront(elem)
+	c.stats.Hits++
+	return entry.output, generation, true
+}
+
+// put stores the output of argv read at generation, the one get returned. The
+// output is dropped when the library was invalidated since, because a write
+// may have finished while argv ran.
+func (c *Cache) put(library string, argv []string, output string, generation uint64) {
+	library = normalizeLibrary(library)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[library] != generation {
+		return
+	}
+	key := cacheKey(library, argv)
+	if elem, ok := c.entries[key]; ok {
+		c.removeLocked(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		library: library,
+		output:  output,
+		stored:  time.Now(),
+	})
+	for c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries {
+		c.removeLocked(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache) invalidateLocked(library string) {
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*cacheEntry).library == library {
+			c.removeLocked(elem)
+		}
+		elem = next
+	}
+	delete(c.stamps, library)
+	c.generations[library]++
+	c.stats.Invalidations++
+}
+
+func (c *Cache) removeLocked(elem *list.Element) {
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+	c.lru.Remove(elem)
+}
+
+// cacheKey turns a normalized library and argv into a lookup key. The library
+// is part of the key rather than the argv so that the same command against
+// different libraries never collides.
+func cacheKey(library string, argv []string) string {
+	return library + "\x00" + strings.Join(argv, "\x00")
+}
+
+// normalizeLibrary makes equivalent spellings of a local library path compare
+// equal so that Calibre values sharing a cache see each other's writes.
+func normalizeLibrary(library string) string {
+	if library == "" || strings.Contains(library, "://") {
+		return library
+	}
+	if abs, err := filepath.Abs(library); err == nil {
+		return abs
+	}
+	return filepath.Clean(library)
+}
+
+// statMetadataDB returns the stamp of the library's metadata.db. Remote
+// libraries (server URLs) cannot be watched and report false.
+func statMetadataDB(library string) (dbStamp, bool) {
+	if library == "" || strings.Contains(library, "://") {
+		return dbStamp{}, false
+	}
+	info, err := os.Stat(filepath.Join(library, "metadata.db"))
+	if err != nil {
+		return dbStamp{}, false
+	}
+	return dbStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}, true
+}
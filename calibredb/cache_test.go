@@ -0,0 +1,213 @@
+package calibredb_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func TestCache_ReadCommandsAreCached(t *testing.T) {
+	c, dir := newStubCalibre(t, calibredb.WithCache(calibredb.NewCache()))
+
+	for range 3 {
+		if _, err := c.List(calibredb.ListOptions{Fields: "title"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := stubCalls(t, dir); got != 1 {
+		t.Errorf("calibredb ran %d times, want 1", got)
+	}
+	if _, err := c.List(calibredb.ListOptions{Fields: "authors"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := stubCalls(t, dir); got != 2 {
+		t.Errorf("calibredb ran %d times after different argv, want 2", got)
+	}
+
+	stats := c.CacheStats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Errorf("CacheStats() = %+v", stats)
+	}
+}
+
+func TestCache_WriteInvalidates(t *testing.T) {
+	c, dir := newStubCalibre(t, calibredb.WithCache(calibredb.NewCache()))
+
+	opts := calibredb.ShowMetadataOptions{Id: "1"}
+	if _, err := c.ShowMetadata(opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetCustom(calibredb.SetCustomOptions{Column: "genre", Id: "1", Value: "sf"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ShowMetadata(opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := stubCalls(t, dir); got != 3 {
+		t.Errorf("calibredb ran %d times, want 3", got)
+	}
+	if stats := c.CacheStats(); stats.Invalidations != 1 {
+		t.Errorf("CacheStats().Invalidations = %d, want 1", stats.Invalidations)
+	}
+}
+
+func TestCache_SharedBetweenCalibres(t *testing.T) {
+	cache := calibredb.NewCache()
+	reader, dir := newStubCalibre(t, calibredb.WithCache(cache))
+	writer := calibredb.NewCalibre(
+		calibredb.WithLibraryPath(dir+"/."),
+		calibredb.WithCalibreDBLocation(reader.CalibreDBLocation),
+		calibredb.WithCache(cache),
+	)
+
+	if _, err := reader.CustomColumns(calibredb.CustomColumnsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "genre", Name: "Genre", Datatype: "text"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.CustomColumns(calibredb.CustomColumnsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := stubCalls(t, dir); got != 3 {
+		t.Errorf("calibredb ran %d times, want 3", got)
+	}
+}
+
+func TestCache_ExternalChangeInvalidates(t *testing.T) {
+	c, dir := newStubCalibre(t, calibredb.WithCache(calibredb.NewCache()))
+	db := filepath.Join(dir, "metadata.db")
+	if err := os.WriteFile(db, []byte("v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := calibredb.SearchOptions{Expression: "title:dune"}
+	for range 2 {
+		if _, err := c.Search(opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(db, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Search(opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := stubCalls(t, dir); got != 2 {
+		t.Errorf("calibredb ran %d times, want 2", got)
+	}
+}
+
+func TestCache_MaxEntriesAndTTL(t *testing.T) {
+	c, dir := newStubCalibre(t, calibredb.WithCache(calibredb.NewCache(
+		calibredb.WithCacheMaxEntries(1),
+		calibredb.WithCacheTTL(time.Hour),
+	)))
+
+	for _, fields := range []string{"title", "authors", "title"} {
+		if _, err := c.List(calibredb.ListOptions{Fields: fields}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := stubCalls(t, dir); got != 3 {
+		t.Errorf("calibredb ran %d times, want 3", got)
+	}
+	if stats := c.CacheStats(); stats.Evictions != 2 || stats.Entries != 1 {
+		t.Errorf("CacheStats() = %+v", stats)
+	}
+
+	expiring, dir := newStubCalibre(t, calibredb.WithCache(calibredb.NewCache(calibredb.WithCacheTTL(time.Nanosecond))))
+	for range 2 {
+		if _, err := expiring.List(calibredb.ListOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := stubCalls(t, dir); got != 2 {
+		t.Errorf("calibredb ran %d times with expired entries, want 2", got)
+	}
+}
+
+func TestCache_Disabled(t *testing.T) {
+	c, dir := newStubCalibre(t)
+	for range 2 {
+		if _, err := c.List(calibredb.ListOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := stubCalls(t, dir); got != 2 {
+		t.Errorf("calibredb ran %d times, want 2", got)
+	}
+	if stats := c.CacheStats(); stats != (calibredb.CacheStats{}) {
+		t.Errorf("CacheStats() = %+v, want zero", stats)
+	}
+}
+
+// TestCache_WriteDuringRead checks that a read that started before a write
+// finished is not cached: its output predates the write. Run it with -race.
+func TestCache_WriteDuringRead(t *testing.T) {
+	dir := t.TempDir()
+	state := filepath.Join(dir, "state")
+	started := filepath.Join(dir, "started")
+	proceed := filepath.Join(dir, "proceed")
+	// list reads the state, then waits for proceed before printing it;
+	// set_custom changes the state
+	script := filepath.Join(dir, "calibredb")
+	body := `#!/bin/sh
+if [ "$1" = list ]; then
+	value=$(cat "` + state + `")
+	touch "` + started + `"
+	while [ ! -e "` + proceed + `" ]; do sleep 0.01; done
+	echo "$value"
+	exit 0
+fi
+echo new > "` + state + `"
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(state, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := calibredb.NewCalibre(
+		calibredb.WithLibraryPath(dir),
+		calibredb.WithCalibreDBLocation(script),
+		calibredb.WithCache(calibredb.NewCache()),
+	)
+
+	read := make(chan string, 1)
+	go func() {
+		out, err := c.List(calibredb.ListOptions{})
+		if err != nil {
+			t.Error(err)
+		}
+		read <- out
+	}()
+	for {
+		if _, err := os.Stat(started); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := c.SetCustom(calibredb.SetCustomOptions{Column: "genre", Id: "1", Value: "sf"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(proceed, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out := <-read; out != "old" {
+		t.Fatalf("the read during the write = %q, want old", out)
+	}
+
+	out, err := c.List(calibredb.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "new" {
+		t.Errorf("the read after the write = %q, want new: the stale result was cached", out)
+	}
+}
@@ -1,9 +1,12 @@
 package calibredb
 
 import (
-	"errors"
+	"log/slog"
 	"os/exec"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	_ "github.com/samber/lo"
@@ -18,6 +21,13 @@ type Calibre struct {
 	OnError           func(error)
 
 	validate *validator.Validate
+	cache    *Cache
+	audit    func(AuditEvent)
+	events   func(Event)
+	actor    string
+	metrics  *Metrics
+	logger   *slog.Logger
+	version  *versionCache
 }
 
 type CalibreOption func(*Calibre)
@@ -63,10 +73,16 @@ func NewCalibre(opts ...CalibreOption) *Calibre {
 	for _, opt := range opts {
 		opt(c)
 	}
-	c.validate = validator.New(validator.WithRequiredStructEnabled())
+	c.validate = newValidator()
+	c.version = &versionCache{}
 	return c
 }
 
+// Version returns the output of calibredb --version, or the error text when
+// it fails.
+//
+// Deprecated: use CalibreVersion, which parses the version, reports errors
+// and detects it only once.
 func (c *Calibre) Version() string {
 	if out, err := c.run("--version"); err != nil {
 		return err.Error()
@@ -84,19 +100,91 @@ func (c *Calibre) Help() string {
 }
 
 func (c *Calibre) run(argv ...string) (string, error) {
-	argv = append(argv, "--with-library="+c.LibraryPath)
-	out, err := exec.Command(c.CalibreDBLocation, argv...).CombinedOutput()
+	if err := c.checkCapabilities(argv); err != nil {
+		return "", err
+	}
+	command := argv[0]
+	cacheable := c.cache != nil && readCommands[command]
+	var generation uint64
+	if cacheable {
+		out, gen, ok := c.cache.get(c.LibraryPath, argv)
+		if ok {
+			return out, nil
+		}
+		generation = gen
+	}
+	if changesLibrary(argv) {
+		l := libraryLock(c.LibraryPath)
+		c.metrics.lock(l, false)
+		defer l.RUnlock()
+	}
+	out, err := c.auditedExec(argv)
+	if err == nil && c.cache != nil {
+		if cacheable {
+			c.cache.put(c.LibraryPath, argv, out, generation)
+		} else if writeCommands[command] {
+			c.cache.Invalidate(c.LibraryPath)
+		}
+	}
+	if err == nil && c.events != nil {
+		if e, ok := c.eventFor(argv, out); ok {
+			c.events(e)
+		}
+	}
+	return out, err
+}
+
+func (c *Calibre) exec(argv ...string) (string, error) {
+	out, err := c.rawExec(argv...)
+	if err != nil {
+		return "", err
+	}
+	return filtered(out, false), nil
+}
+
+// rawExec runs calibredb and returns its output as is, blank lines included,
+// which the help parser needs to tell sections apart.
+func (c *Calibre) rawExec(argv ...string) ([]byte, error) {
+	command := argv[0]
+	start := time.Now()
+	c.metrics.startExec(command)
+	out, err := exec.Command(c.CalibreDBLocation, append(slices.Clip(argv), c.globalOptions()...)...).CombinedOutput()
 	if err != nil {
 		if c.OnError != nil {
 			c.OnError(err)
 		}
-		if out != nil {
-			// this is a stacktrace followed by the actual error message. We want to extract only the actual error message.
-			return "", errors.New(filtered(out, true))
-		}
-		return "", errors.New(err.Error())
+		calibreErr := newCalibreError(out, err)
+		c.metrics.observeExec(command, start, calibreErr)
+		c.logExec(argv, start, nil, calibreErr)
+		return nil, calibreErr
 	}
-	return filtered(out, false), nil
+	c.metrics.observeExec(command, start, nil)
+	c.logExec(argv, start, out, nil)
+	return out, nil
+}
+
+// globalOptions are the options every calibredb command accepts.
+func (c *Calibre) globalOptions() []string {
+	opts := []string{"--with-library=" + c.LibraryPath}
+	if c.Username != "" {
+		opts = append(opts, "--username="+c.Username)
+	}
+	if c.Password != "" {
+		opts = append(opts, "--password="+c.Password)
+	}
+	if c.Timeout != "" {
+		opts = append(opts, "--timeout="+timeoutSeconds(c.Timeout))
+	}
+	return opts
+}
+
+// timeoutSeconds converts a duration such as "2m" to the seconds calibredb
+// expects. Plain numbers are already seconds.
+func timeoutSeconds(timeout string) string {
+	if d, err := time.ParseDuration(timeout); err == nil {
+		return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+	}
+	return timeout
 }
 
 func filtered(output []byte, isError bool) string {
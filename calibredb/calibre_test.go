@@ -496,3 +496,17 @@ func TestCalibre_ErrorPathsWithoutOnError(t *testing.T) {
 		t.Errorf("Help() = %v, expected error message about missing file", help)
 	}
 }
+
+func TestCalibre_GlobalOptions(t *testing.T) {
+	c, recorded := newArgvRecorder(t)
+	c.Username = "reader"
+	c.Password = "secret"
+	c.Timeout = "2m"
+	if _, err := c.CustomColumns(calibredb.CustomColumnsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	want := `["custom_columns","--with-library=/library","--username=reader","--password=secret","--timeout=120"]`
+	if got := recorded(); len(got) != 1 || got[0] != want {
+		t.Errorf("recorded %q, want %s", got, want)
+	}
+}
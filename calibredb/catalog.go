@@ -17,9 +17,9 @@ type CatalogOptions struct {
 	Path string `validate:"required"`
 
 	// Command Line Options
-	Ids     string // Comma-separated list of database IDs to catalog. If declared, --search is ignored. Default: all
-	Search  string // Filter the results by the search query. For the format of the search query, please see the search-related documentation in the User Manual. Default: no filtering
-	Verbose *bool  // Show detailed output information. Useful for debugging
+	Ids     BookIDs `flag:"--ids" validate:"bookids"` // Comma-separated list of database IDs to catalog. If declared, --search is ignored. Default: all
+	Search  string  `flag:"--search"`                 // Filter the results by the search query. For the format of the search query, please see the search-related documentation in the User Manual. Default: no filtering
+	Verbose *bool   `flag:"--verbose"`                // Show detailed output information. Useful for debugging
 }
 
 func (c *Calibre) CatalogHelp() string {
@@ -42,9 +42,13 @@ func (c *Calibre) Catalog(opts CatalogOptions, args ...string) (string, error) {
 	argv = append(argv, opts.Path)
 
 	// Command Line Options
-	// Handling string
-	if opts.Ids != "" {
-		argv = append(argv, "--ids", opts.Ids)
+	// Handling book ids
+	if !opts.Ids.IsEmpty() && !opts.Ids.All() {
+		ids, err := bookIDsOption(opts.Ids, RangeExclusive)
+		if err != nil {
+			return "", err
+		}
+		argv = append(argv, "--ids", ids)
 	}
 	// Handling string
 	if opts.Search != "" {
@@ -54,6 +58,7 @@ func (c *Calibre) Catalog(opts CatalogOptions, args ...string) (string, error) {
 	if opts.Verbose != nil && *opts.Verbose {
 		argv = append(argv, "--verbose")
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
@@ -0,0 +1,223 @@
+package calibredb
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// CatalogFormats are the catalog formats calibre has plugins for, the
+// extensions of the destination file.
+var CatalogFormats = []string{"azw3", "csv", "epub", "mobi", "xml"}
+
+// GenerateCatalogOptions are the options of calibredb catalog together with
+// those of the catalog plugin for the format, which is the extension of
+// Path. At most one of CSV and EPUB may be set and it must match the format.
+type GenerateCatalogOptions struct {
+	CatalogOptions
+	// CSV holds the options of the CSV and XML catalogs.
+	CSV *CSVCatalogOptions `json:"csv,omitempty"`
+	// EPUB holds the options of the AZW3, EPUB and MOBI catalogs.
+	EPUB *EPUBCatalogOptions `json:"epub,omitempty"`
+}
+
+// CSVCatalogOptions are the options of the CSV and XML catalog plugin.
+type CSVCatalogOptions struct {
+	// Fields are the fields to output, all when empty: title, title_sort,
+	// author_sort, authors, comments, cover, formats, id, isbn,
+	// library_name, ondevice, pubdate, publisher, rating, series_index,
+	// series, size, tags, timestamp, uuid, languages, identifiers and
+	// custom columns as #label.
+	Fields []string `json:"fields,omitempty" validate:"dive,required"`
+	// SortBy is the field to sort on, id when empty.
+	SortBy string `json:"sort_by,omitempty" validate:"omitempty,oneof=author_sort id rating size timestamp title_sort"`
+}
+
+// CatalogRule selects books by a field for the exclusion and prefix rules of
+// EPUBCatalogOptions. Pattern is a regular expression, or "+" and "" for
+// the true and false values of a yes/no column.
+type CatalogRule struct {
+	Name    string `json:"name" validate:"required"`
+	Field   string `json:"field" validate:"required"`
+	Pattern string `json:"pattern"`
+}
+
+// CatalogPrefixRule puts Prefix before the titles of the books the rule
+// selects.
+type CatalogPrefixRule struct {
+	CatalogRule
+	Prefix string `json:"prefix" validate:"required"`
+}
+
+// CatalogMergeComments merges a custom column into the comments of the
+// book descriptions.
+type CatalogMergeComments struct {
+	// Field is the #label of the custom column.
+	Field string `json:"field" validate:"required,startswith=#"`
+	// Position is before or after the comments.
+	Position string `json:"position" validate:"required,oneof=before after"`
+	// Separator puts a horizontal rule between the column and the comments.
+	Separator bool `json:"separator,omitempty"`
+}
+
+// EPUBCatalogOptions are the options of the AZW3, EPUB and MOBI catalog
+// plugin. Without any Generate* section calibre generates all of them
+// except the recently added books.
+type EPUBCatalogOptions struct {
+	// CatalogTitle is the title of the catalog, "My Books" when empty.
+	CatalogTitle string `json:"catalog_title,omitempty"`
+	// CrossReferenceAuthors lists a book under each of its authors.
+	CrossReferenceAuthors bool `json:"cross_reference_authors,omitempty"`
+	// ExcludeGenre is a regular expression of the tags that are not genres,
+	// `\[.+\]|^\+$` when empty.
+	ExcludeGenre string `json:"exclude_genre,omitempty"`
+	// ExclusionRules leave out the books they select.
+	ExclusionRules []CatalogRule `json:"exclusion_rules,omitempty" validate:"dive"`
+	// PrefixRules mark the books they select, the first matching rule wins.
+	PrefixRules []CatalogPrefixRule `json:"prefix_rules,omitempty" validate:"dive"`
+
+	GenerateAuthors       bool `json:"generate_authors,omitempty"`
+	GenerateDescriptions  bool `json:"generate_descriptions,omitempty"`
+	GenerateGenres        bool `json:"generate_genres,omitempty"`
+	GenerateTitles        bool `json:"generate_titles,omitempty"`
+	GenerateSeries        bool `json:"generate_series,omitempty"`
+	GenerateRecentlyAdded bool `json:"generate_recently_added,omitempty"`
+
+	// GenreSourceField is the field genres come from, Tags when empty.
+	GenreSourceField string `json:"genre_source_field,omitempty"`
+	// HeaderNoteSourceField is a custom column shown as a note in the header
+	// of the book descriptions.
+	HeaderNoteSourceField string                `json:"header_note_source_field,omitempty" validate:"omitempty,startswith=#"`
+	MergeComments         *CatalogMergeComments `json:"merge_comments,omitempty"`
+	// OutputProfile is the calibre output profile, such as kindle or kobo.
+	OutputProfile string `json:"output_profile,omitempty"`
+	// Preset is the name of a preset saved in the calibre GUI; it replaces
+	// the other options.
+	Preset string `json:"preset,omitempty"`
+	// ThumbWidth is the width of the cover thumbnails in inches, 1 to 2.
+	ThumbWidth float64 `json:"thumb_width,omitempty" validate:"omitempty,gte=1,lte=2"`
+	// UseExistingCover keeps the cover of an existing catalog when it is
+	// replaced.
+	UseExistingCover bool `json:"use_existing_cover,omitempty"`
+}
+
+// catalogFormat returns the format of a catalog file, its extension.
+func catalogFormat(path string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// validateGenerateCatalogOptions rejects plugin options that do not match
+// the format of the catalog.
+func validateGenerateCatalogOptions(sl validator.StructLevel) {
+	opts := sl.Current().Interface().(GenerateCatalogOptions)
+	format := catalogFormat(opts.Path)
+	if opts.CSV != nil && format != "csv" && format != "xml" {
+		sl.ReportError(opts.CSV, "CSV", "CSV", "catalogformat", format)
+	}
+	if opts.EPUB != nil && format != "azw3" && format != "epub" && format != "mobi" {
+		sl.ReportError(opts.EPUB, "EPUB", "EPUB", "catalogformat", format)
+	}
+}
+
+// GenerateCatalog writes a catalog of the library to opts.Path.
+func (c *Calibre) GenerateCatalog(opts GenerateCatalogOptions) (string, error) {
+	if err := c.validate.Struct(opts); err != nil {
+		return "", err
+	}
+	var args []string
+	if opts.CSV != nil {
+		args = opts.CSV.args()
+	}
+	if opts.EPUB != nil {
+		args = opts.EPUB.args()
+	}
+	return c.Catalog(opts.CatalogOptions, args...)
+}
+
+func (o *CSVCatalogOptions) args() []string {
+	var args []string
+	if len(o.Fields) > 0 {
+		args = append(args, "--fields", strings.Join(o.Fields, ","))
+	}
+	if o.SortBy != "" {
+		args = append(args, "--sort-by", o.SortBy)
+	}
+	return args
+}
+
+func (o *EPUBCatalogOptions) args() []string {
+	var args []string
+	str := func(flag, value string) {
+		if value != "" {
+			args = append(args, flag, value)
+		}
+	}
+	boolean := func(flag string, value bool) {
+		if value {
+			args = append(args, flag)
+		}
+	}
+	str("--catalog-title", o.CatalogTitle)
+	boolean("--cross-reference-authors", o.CrossReferenceAuthors)
+	str("--exclude-genre", o.ExcludeGenre)
+	if len(o.ExclusionRules) > 0 {
+		rules := make([][]string, len(o.ExclusionRules))
+		for i, r := range o.ExclusionRules {
+			rules[i] = []string{r.Name, r.Field, r.Pattern}
+		}
+		str("--exclusion-rules", pythonTuples(rules))
+	}
+	if len(o.PrefixRules) > 0 {
+		rules := make([][]string, len(o.PrefixRules))
+		for i, r := range o.PrefixRules {
+			rules[i] = []string{r.Name, r.Field, r.Pattern, r.Prefix}
+		}
+		str("--prefix-rules", pythonTuples(rules))
+	}
+	boolean("--generate-authors", o.GenerateAuthors)
+	boolean("--generate-descriptions", o.GenerateDescriptions)
+	boolean("--generate-genres", o.GenerateGenres)
+	boolean("--generate-titles", o.GenerateTitles)
+	boolean("--generate-series", o.GenerateSeries)
+	boolean("--generate-recently-added", o.GenerateRecentlyAdded)
+	str("--genre-source-field", o.GenreSourceField)
+	str("--header-note-source-field", o.HeaderNoteSourceField)
+	if m := o.MergeComments; m != nil {
+		// calibre parses #field:before|after:True|False
+		separator := "False"
+		if m.Separator {
+			separator = "True"
+		}
+		str("--merge-comments-rule", m.Field+":"+m.Position+":"+separator)
+	}
+	str("--output-profile", o.OutputProfile)
+	str("--preset", o.Preset)
+	if o.ThumbWidth != 0 {
+		str("--thumb-width", strconv.FormatFloat(o.ThumbWidth, 'f', -1, 64))
+	}
+	boolean("--use-existing-cover", o.UseExistingCover)
+	return args
+}
+
+// pythonTuples formats rows as the tuple of string tuples calibre evaluates
+// for its rule options, e.g. (('Catalogs','Tags','Catalog'),).
+func pythonTuples(rows [][]string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, row := range rows {
+		b.WriteByte('(')
+		for i, s := range row {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\'')
+			b.WriteString(strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s))
+			b.WriteByte('\'')
+		}
+		b.WriteString("),")
+	}
+	b.WriteByte(')')
+	return b.String()
+}
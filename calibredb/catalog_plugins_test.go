@@ -0,0 +1,124 @@
+package calibredb_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func TestCalibre_GenerateCatalog_Args(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    calibredb.GenerateCatalogOptions
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "csv",
+			opts: calibredb.GenerateCatalogOptions{
+				CatalogOptions: calibredb.CatalogOptions{Path: "/tmp/catalog.csv", Search: "tags:sf"},
+				CSV:            &calibredb.CSVCatalogOptions{Fields: []string{"title", "authors"}, SortBy: "title_sort"},
+			},
+			want: "catalog /tmp/catalog.csv --search tags:sf --fields title,authors --sort-by title_sort",
+		},
+		{
+			name: "epub",
+			opts: calibredb.GenerateCatalogOptions{
+				CatalogOptions: calibredb.CatalogOptions{Path: "/tmp/catalog.EPUB"},
+				EPUB: &calibredb.EPUBCatalogOptions{
+					CatalogTitle:          "Shelf",
+					ExclusionRules:        []calibredb.CatalogRule{{Name: "Catalogs", Field: "Tags", Pattern: "Catalog"}},
+					PrefixRules:           []calibredb.CatalogPrefixRule{{CatalogRule: calibredb.CatalogRule{Name: "Read", Field: "#read", Pattern: "+"}, Prefix: "it's read"}},
+					GenerateSeries:        true,
+					GenerateRecentlyAdded: true,
+					MergeComments:         &calibredb.CatalogMergeComments{Field: "#notes", Position: "after", Separator: true},
+					ThumbWidth:            1.5,
+				},
+			},
+			want: `catalog /tmp/catalog.EPUB --catalog-title Shelf --exclusion-rules (('Catalogs','Tags','Catalog'),) --prefix-rules (('Read','#read','+','it\'s read'),) --generate-series --generate-recently-added --merge-comments-rule #notes:after:True --thumb-width 1.5`,
+		},
+		{
+			name: "epub options for a csv catalog",
+			opts: calibredb.GenerateCatalogOptions{
+				CatalogOptions: calibredb.CatalogOptions{Path: "/tmp/catalog.csv"},
+				EPUB:           &calibredb.EPUBCatalogOptions{GenerateSeries: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "csv options for a mobi catalog",
+			opts: calibredb.GenerateCatalogOptions{
+				CatalogOptions: calibredb.CatalogOptions{Path: "/tmp/catalog.mobi"},
+				CSV:            &calibredb.CSVCatalogOptions{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown sort field",
+			opts: calibredb.GenerateCatalogOptions{
+				CatalogOptions: calibredb.CatalogOptions{Path: "/tmp/catalog.xml"},
+				CSV:            &calibredb.CSVCatalogOptions{SortBy: "pubdate"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "thumb width out of range",
+			opts: calibredb.GenerateCatalogOptions{
+				CatalogOptions: calibredb.CatalogOptions{Path: "/tmp/catalog.azw3"},
+				EPUB:           &calibredb.EPUBCatalogOptions{ThumbWidth: 3},
+			},
+			wantErr: true,
+		},
+		{
+			name: "merge comments from a built-in field",
+			opts: calibredb.GenerateCatalogOptions{
+				CatalogOptions: calibredb.CatalogOptions{Path: "/tmp/catalog.epub"},
+				EPUB:           &calibredb.EPUBCatalogOptions{MergeComments: &calibredb.CatalogMergeComments{Field: "tags", Position: "before"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, dir := newStubCalibre(t)
+			got, err := c.GenerateCatalog(tt.opts)
+			if tt.wantErr {
+				if calibredb.ErrorKindOf(err) != calibredb.ErrorKindInvalid {
+					t.Errorf("GenerateCatalog() error = %v, want a validation error", err)
+				}
+				if n := stubCalls(t, dir); n != 0 {
+					t.Errorf("calibredb ran %d times", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(got, tt.want+" --with-library") {
+				t.Errorf("GenerateCatalog() ran\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalibre_GenerateCatalog(t *testing.T) {
+	c, dir := newLibrary(t, []testBook{{file: "Emma.txt"}, {file: "Dune.txt"}})
+	path := filepath.Join(dir, "catalog.csv")
+	_, err := c.GenerateCatalog(calibredb.GenerateCatalogOptions{
+		CatalogOptions: calibredb.CatalogOptions{Path: path},
+		CSV:            &calibredb.CSVCatalogOptions{Fields: []string{"id", "title"}, SortBy: "title_sort"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "id,title\n2,Dune\n1,Emma\n"; string(data) != want {
+		t.Errorf("catalog = %q, want %q", data, want)
+	}
+}
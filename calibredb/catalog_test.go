@@ -13,7 +13,7 @@ func TestCalibre_CatalogHelp(t *testing.T) {
 	defer func() { _ = os.RemoveAll(tempDir) }()
 	c := calibredb.NewCalibre(
 		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+		calibredb.WithCalibreDBLocation(calibredbPath),
 	)
 
 	got := c.CatalogHelp()
@@ -51,7 +51,7 @@ func TestCalibre_Catalog(t *testing.T) {
 			name: "Valid Path with Ids",
 			opts: calibredb.CatalogOptions{
 				Path: os.TempDir() + "/catalog.epub",
-				Ids:  "1,2,3",
+				Ids:  mustParseBookIDs("1,2,3", calibredb.RangeExclusive),
 			},
 			wantErr: false,
 		},
@@ -11,11 +11,11 @@ package calibredb
 type CheckLibraryOptions struct {
 
 	// Command Line Options
-	Csv              *bool  // Output in CSV
-	IgnoreExtensions string // Comma-separated list of extensions to ignore. Default: all
-	IgnoreNames      string // Comma-separated list of names to ignore. Default: all
-	Report           string // Comma-separated list of reports. Default: all
-	VacuumFtsDb      *bool  // Vacuum the full text search database. This can be very slow and memory intensive, depending on the size of the database.
+	Csv              *bool  `flag:"--csv"`               // Output in CSV
+	IgnoreExtensions string `flag:"--ignore_extensions"` // Comma-separated list of extensions to ignore. Default: all
+	IgnoreNames      string `flag:"--ignore_names"`      // Comma-separated list of names to ignore. Default: all
+	Report           string `flag:"--report"`            // Comma-separated list of reports. Default: all
+	VacuumFtsDb      *bool  `flag:"--vacuum-fts-db"`     // Vacuum the full text search database. This can be very slow and memory intensive, depending on the size of the database.
 }
 
 func (c *Calibre) CheckLibraryHelp() string {
@@ -56,6 +56,7 @@ func (c *Calibre) CheckLibrary(opts CheckLibraryOptions, args ...string) (string
 	if opts.VacuumFtsDb != nil && *opts.VacuumFtsDb {
 		argv = append(argv, "--vacuum-fts-db")
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
@@ -13,7 +13,7 @@ func TestCalibre_CheckLibraryHelp(t *testing.T) {
 
 	c := calibredb.NewCalibre(
 		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+		calibredb.WithCalibreDBLocation(calibredbPath),
 	)
 
 	help := c.CheckLibraryHelp()
@@ -125,7 +125,7 @@ func TestCalibre_CheckLibrary(t *testing.T) {
 
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			_, gotErr := c.CheckLibrary(tt.opts, tt.args...)
@@ -32,6 +32,7 @@ func (c *Calibre) Clone(opts CloneOptions, args ...string) (string, error) {
 	}
 	// Command Line Arguments
 	argv = append(argv, opts.Path)
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
@@ -21,7 +21,7 @@ func TestCalibre_CloneHelp(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := calibredb.NewCalibre(
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 			got := c.CloneHelp()
 			if !strings.Contains(got, tt.want) && !strings.Contains(got, "no such file or directory") {
@@ -78,7 +78,7 @@ func TestCalibre_Clone(t *testing.T) {
 			defer func() { _ = os.RemoveAll(tempDir) }()
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			got, gotErr := c.Clone(tt.opts, tt.args...)
@@ -0,0 +1,115 @@
+// Code generated by generate.go; DO NOT EDIT.
+
+package calibredb
+
+import "reflect"
+
+// commandOptions maps every wrapped calibredb command to its options struct.
+var commandOptions = map[string]reflect.Type{
+	"add":                  reflect.TypeFor[AddOptions](),
+	"add_custom_column":    reflect.TypeFor[AddCustomColumnOptions](),
+	"add_format":           reflect.TypeFor[AddFormatOptions](),
+	"backup_metadata":      reflect.TypeFor[BackupMetadataOptions](),
+	"catalog":              reflect.TypeFor[CatalogOptions](),
+	"check_library":        reflect.TypeFor[CheckLibraryOptions](),
+	"clone":                reflect.TypeFor[CloneOptions](),
+	"custom_columns":       reflect.TypeFor[CustomColumnsOptions](),
+	"embed_metadata":       reflect.TypeFor[EmbedMetadataOptions](),
+	"export":               reflect.TypeFor[ExportOptions](),
+	"fts_index":            reflect.TypeFor[FtsIndexOptions](),
+	"fts_search":           reflect.TypeFor[FtsSearchOptions](),
+	"list":                 reflect.TypeFor[ListOptions](),
+	"list_categories":      reflect.TypeFor[ListCategoriesOptions](),
+	"remove":               reflect.TypeFor[RemoveOptions](),
+	"remove_custom_column": reflect.TypeFor[RemoveCustomColumnOptions](),
+	"remove_format":        reflect.TypeFor[RemoveFormatOptions](),
+	"restore_database":     reflect.TypeFor[RestoreDatabaseOptions](),
+	"saved_searches":       reflect.TypeFor[SavedSearchesOptions](),
+	"search":               reflect.TypeFor[SearchOptions](),
+	"set_custom":           reflect.TypeFor[SetCustomOptions](),
+	"set_metadata":         reflect.TypeFor[SetMetadataOptions](),
+	"show_metadata":        reflect.TypeFor[ShowMetadataOptions](),
+}
+
+// commandRunners calls the wrapper of every command with its options struct
+// and extra arguments.
+var commandRunners = map[string]func(c *Calibre, opts any, args ...string) (string, error){
+	"add": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.Add(opts.(AddOptions), args...)
+	},
+	"add_custom_column": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.AddCustomColumn(opts.(AddCustomColumnOptions), args...)
+	},
+	"add_format": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.AddFormat(opts.(AddFormatOptions), args...)
+	},
+	"backup_metadata": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.BackupMetadata(opts.(BackupMetadataOptions), args...)
+	},
+	"catalog": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.Catalog(opts.(CatalogOptions), args...)
+	},
+	"check_library": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.CheckLibrary(opts.(CheckLibraryOptions), args...)
+	},
+	"clone": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.Clone(opts.(CloneOptions), args...)
+	},
+	"custom_columns": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.CustomColumns(opts.(CustomColumnsOptions), args...)
+	},
+	"embed_metadata": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.EmbedMetadata(opts.(EmbedMetadataOptions), args...)
+	},
+	"export": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.Export(opts.(ExportOptions), args...)
+	},
+	"fts_index": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.FtsIndex(opts.(FtsIndexOptions), args...)
+	},
+	"fts_search": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.FtsSearch(opts.(FtsSearchOptions), args...)
+	},
+	"list": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.List(opts.(ListOptions), args...)
+	},
+	"list_categories": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.ListCategories(opts.(ListCategoriesOptions), args...)
+	},
+	"remove": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.Remove(opts.(RemoveOptions), args...)
+	},
+	"remove_custom_column": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.RemoveCustomColumn(opts.(RemoveCustomColumnOptions), args...)
+	},
+	"remove_format": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.RemoveFormat(opts.(RemoveFormatOptions), args...)
+	},
+	"restore_database": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.RestoreDatabase(opts.(RestoreDatabaseOptions), args...)
+	},
+	"saved_searches": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.SavedSearches(opts.(SavedSearchesOptions), args...)
+	},
+	"search": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.Search(opts.(SearchOptions), args...)
+	},
+	"set_custom": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.SetCustom(opts.(SetCustomOptions), args...)
+	},
+	"set_metadata": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.SetMetadata(opts.(SetMetadataOptions), args...)
+	},
+	"show_metadata": func(c *Calibre, opts any, args ...string) (string, error) {
+		return c.ShowMetadata(opts.(ShowMetadataOptions), args...)
+	},
+}
+
+// commandRangeStyles maps the commands that take book ids to how they read
+// the end of a range.
+var commandRangeStyles = map[string]RangeStyle{
+	"catalog":        RangeExclusive,
+	"embed_metadata": RangeInclusive,
+	"export":         RangeExclusive,
+	"remove":         RangeExclusive,
+}
@@ -0,0 +1,183 @@
+package calibredb
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/veverkap/calibre-rest/internal/calibrehelp"
+)
+
+// CompatibilityReport lists the differences between the generated wrappers and
+// the calibredb binary they run.
+type CompatibilityReport struct {
+	// Version is the output of calibredb --version.
+	Version string
+	// Commands holds the wrapped commands whose options differ.
+	Commands []CommandDrift
+	// Unwrapped lists commands calibredb offers that have no wrapper.
+	Unwrapped []string
+	// Missing lists wrapped commands calibredb no longer knows.
+	Missing []string
+}
+
+// CommandDrift describes how one command's options differ.
+type CommandDrift struct {
+	Command string
+	// Added lists options calibredb accepts that the wrapper cannot pass.
+	Added []string
+	// Removed lists options the wrapper passes that calibredb rejects.
+	Removed []string
+	Changed []OptionChange
+}
+
+// OptionChange is an option whose kind differs, e.g. a flag that now takes a
+// value, or a choice whose set of values changed.
+type OptionChange struct {
+	Option    string
+	Wrapper   string
+	Calibredb string
+}
+
+// OK reports whether the wrappers match calibredb.
+func (r *CompatibilityReport) OK() bool {
+	return len(r.Commands) == 0 && len(r.Unwrapped) == 0 && len(r.Missing) == 0
+}
+
+func (r *CompatibilityReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "calibredb version: %s\n", r.Version)
+	if r.OK() {
+		b.WriteString("wrappers match calibredb\n")
+		return b.String()
+	}
+	for _, name := range r.Unwrapped {
+		fmt.Fprintf(&b, "%s: no wrapper\n", name)
+	}
+	for _, name := range r.Missing {
+		fmt.Fprintf(&b, "%s: not available in calibredb\n", name)
+	}
+	for _, d := range r.Commands {
+		for _, o := range d.Added {
+			fmt.Fprintf(&b, "%s: added option %s\n", d.Command, o)
+		}
+		for _, o := range d.Removed {
+			fmt.Fprintf(&b, "%s: removed option %s\n", d.Command, o)
+		}
+		for _, c := range d.Changed {
+			fmt.Fprintf(&b, "%s: option %s changed from %s to %s\n", d.Command, c.Option, c.Wrapper, c.Calibredb)
+		}
+	}
+	return b.String()
+}
+
+// CheckCompatibility runs calibredb --help and <command> -h for every command
+// and compares the options with the generated wrappers. Only differences that
+// the help output can show are reported: options that appeared or
+// disappeared, flags that became valued options (or the reverse), choice sets
+// that changed and options that became repeatable. calibredb's help does not
+// say whether a value is a number or a string, so such changes go unnoticed.
+func (c *Calibre) CheckCompatibility() (*CompatibilityReport, error) {
+	version, err := c.exec("--version")
+	if err != nil {
+		return nil, err
+	}
+	help, err := c.rawExec("--help")
+	if err != nil {
+		return nil, err
+	}
+	report := &CompatibilityReport{Version: strings.TrimSpace(version)}
+	available := calibrehelp.Commands(string(help))
+	for _, name := range available {
+		if _, ok := commandOptions[name]; !ok {
+			report.Unwrapped = append(report.Unwrapped, name)
+		}
+	}
+
+	names := make([]string, 0, len(commandOptions))
+	for name := range commandOptions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !slices.Contains(available, name) {
+			report.Missing = append(report.Missing, name)
+			continue
+		}
+		out, err := c.rawExec(name, "-h")
+		if err != nil {
+			return nil, fmt.Errorf("calibredb %s -h: %w", name, err)
+		}
+		drift := compareOptions(name, commandOptions[name], calibrehelp.Parse(string(out)).CommandOptions())
+		if len(drift.Added)+len(drift.Removed)+len(drift.Changed) > 0 {
+			report.Commands = append(report.Commands, drift)
+		}
+	}
+	return report, nil
+}
+
+func compareOptions(name string, typ reflect.Type, options []calibrehelp.Option) CommandDrift {
+	drift := CommandDrift{Command: name}
+	seen := map[string]bool{}
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		flag := field.Tag.Get("flag")
+		if flag == "" {
+			continue
+		}
+		idx := slices.IndexFunc(options, func(o calibrehelp.Option) bool { return o.Has(flag) })
+		if idx < 0 {
+			drift.Removed = append(drift.Removed, flag)
+			continue
+		}
+		opt := options[idx]
+		seen[opt.Long()] = true
+		if wrapper, calibredb := wrapperKind(field), optionKind(opt); wrapper != calibredb && !compatibleKinds(wrapper, calibredb) {
+			drift.Changed = append(drift.Changed, OptionChange{Option: flag, Wrapper: wrapper, Calibredb: calibredb})
+		}
+	}
+	for _, opt := range options {
+		if !seen[opt.Long()] {
+			drift.Added = append(drift.Added, opt.Long())
+		}
+	}
+	return drift
+}
+
+// wrapperKind describes a field the way optionKind describes a help entry.
+func wrapperKind(field reflect.StructField) string {
+	switch {
+	case field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Bool:
+		return "flag"
+	case field.Type.Kind() == reflect.Slice:
+		return "repeated value"
+	}
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		if values, ok := strings.CutPrefix(rule, "oneof="); ok {
+			return "choice of " + strings.Join(strings.Fields(values), ", ")
+		}
+	}
+	return "value"
+}
+
+func optionKind(opt calibrehelp.Option) string {
+	switch {
+	case opt.IsFlag():
+		return "flag"
+	case len(opt.Choices()) > 0:
+		return "choice of " + strings.Join(opt.Choices(), ", ")
+	case opt.Repeatable():
+		return "repeated value"
+	}
+	return "value"
+}
+
+// compatibleKinds accepts the differences the help output cannot settle: a
+// repeatable option or a choice whose help does not say so.
+func compatibleKinds(wrapper, calibredb string) bool {
+	if calibredb != "value" {
+		return false
+	}
+	return wrapper == "repeated value" || strings.HasPrefix(wrapper, "choice of ")
+}
@@ -0,0 +1,33 @@
+package calibredb_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	c := calibredb.NewCalibre(
+		calibredb.WithCalibreDBLocation(calibredbPath),
+		calibredb.WithLibraryPath(t.TempDir()),
+	)
+	report, err := c.CheckCompatibility()
+	if err != nil {
+		t.Fatalf("CheckCompatibility() error = %v", err)
+	}
+	if !strings.Contains(report.Version, "calibre") {
+		t.Errorf("Version = %q", report.Version)
+	}
+	if len(report.Missing) > 0 {
+		t.Errorf("wrapped commands missing from calibredb: %v", report.Missing)
+	}
+	for _, d := range report.Commands {
+		if len(d.Removed) > 0 || len(d.Changed) > 0 || len(d.Added) > 0 {
+			t.Errorf("%s: added %v, removed %v, changed %+v", d.Command, d.Added, d.Removed, d.Changed)
+		}
+	}
+	if !report.OK() {
+		t.Errorf("OK() = false:\n%s", report)
+	}
+}
@@ -11,7 +11,7 @@ package calibredb
 type CustomColumnsOptions struct {
 
 	// Command Line Options
-	Details *bool // Show details for each column.
+	Details *bool `flag:"--details"` // Show details for each column.
 }
 
 func (c *Calibre) CustomColumnsHelp() string {
@@ -36,6 +36,7 @@ func (c *Calibre) CustomColumns(opts CustomColumnsOptions, args ...string) (stri
 	if opts.Details != nil && *opts.Details {
 		argv = append(argv, "--details")
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
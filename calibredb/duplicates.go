@@ -0,0 +1,338 @@
+package calibredb
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/samber/lo"
+)
+
+// DefaultArticles are the leading articles NormalizeOptions removes from
+// titles unless told otherwise.
+var DefaultArticles = []string{"a", "an", "the", "der", "die", "das", "le", "la", "les", "el", "los", "las", "il", "lo", "gli"}
+
+// NormalizeOptions says how titles and authors are normalized before they are
+// compared. The zero value removes leading articles, punctuation and
+// subtitles, and ignores case.
+type NormalizeOptions struct {
+	// Articles replaces DefaultArticles.
+	Articles        []string `json:"articles,omitempty"`
+	KeepArticles    bool     `json:"keep_articles,omitempty"`
+	KeepPunctuation bool     `json:"keep_punctuation,omitempty"`
+	// KeepSubtitles compares the whole title instead of the part before the
+	// first colon.
+	KeepSubtitles bool `json:"keep_subtitles,omitempty"`
+}
+
+// Title normalizes a title: "The Hobbit: or There and Back Again" becomes
+// "hobbit".
+func (n NormalizeOptions) Title(title string) string {
+	if !n.KeepSubtitles {
+		title, _, _ = strings.Cut(title, ":")
+	}
+	words := n.words(title)
+	if !n.KeepArticles && len(words) > 1 {
+		articles := DefaultArticles
+		if len(n.Articles) > 0 {
+			articles = n.Articles
+		}
+		if slices.ContainsFunc(articles, func(a string) bool { return strings.EqualFold(a, words[0]) }) {
+			words = words[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Authors normalizes an authors string such as "Neil Gaiman & Terry
+// Pratchett", independent of the order of the authors.
+func (n NormalizeOptions) Authors(authors string) string {
+	var names []string
+	for _, a := range strings.Split(authors, "&") {
+		if name := strings.Join(n.words(a), " "); name != "" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return strings.Join(names, " & ")
+}
+
+func (n NormalizeOptions) words(s string) []string {
+	s = strings.ToLower(s)
+	if n.KeepPunctuation {
+		return strings.Fields(s)
+	}
+	return strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// DuplicatesOptions configures FindDuplicates.
+type DuplicatesOptions struct {
+	// Search limits the books compared, all when empty.
+	Search    string           `json:"search,omitempty"`
+	Normalize NormalizeOptions `json:"normalize"`
+	// ByFormats also groups books with a format file of the same size and
+	// SHA-256. The files are read, so this needs a local library.
+	ByFormats bool `json:"by_formats,omitempty"`
+}
+
+// Reasons books are found to be duplicates, with their weights in the score
+// of a cluster.
+const (
+	ReasonTitleAuthor = "title_author"
+	// ReasonIdentifier is followed by the identifier type, e.g.
+	// identifier:isbn.
+	ReasonIdentifier = "identifier"
+	ReasonFormatHash = "format_hash"
+)
+
+var reasonWeights = map[string]float64{
+	ReasonTitleAuthor: 0.6,
+	ReasonIdentifier:  0.8,
+	ReasonFormatHash:  1,
+}
+
+// Recommended actions for a cluster.
+const (
+	// DuplicateMerge means the books are almost certainly the same: merge the
+	// others into Keep.
+	DuplicateMerge = "merge"
+	// DuplicateReview means they may be different editions or books.
+	DuplicateReview = "review"
+)
+
+// mergeScore is the score from which clusters are recommended for merging.
+const mergeScore = 0.9
+
+// DuplicateCluster is a group of books that look like the same book.
+type DuplicateCluster struct {
+	Books []Book `json:"books"`
+	// Reasons lists what the books share, e.g. title_author or
+	// identifier:isbn.
+	Reasons []string `json:"reasons"`
+	// Score is the confidence from 0 to 1 that the books are duplicates.
+	Score float64 `json:"score"`
+	// Action is merge or review.
+	Action string `json:"action"`
+	// Keep is the book to keep: the one with the most formats, then the
+	// most identifiers, then the oldest.
+	Keep int `json:"keep"`
+	// Merge are the other books, to merge into Keep.
+	Merge []int `json:"merge"`
+}
+
+// FindDuplicates groups the books that share a normalized title and authors,
+// an identifier or, with ByFormats, a format file. Books linked through
+// different signals end up in one cluster. Clusters are ordered by score,
+// highest first.
+func (c *Calibre) FindDuplicates(opts DuplicatesOptions) ([]DuplicateCluster, error) {
+	if err := c.validate.Struct(opts); err != nil {
+		return nil, err
+	}
+	books, err := c.listBooks(ListOptions{
+		Fields:     "title,authors,identifiers,formats",
+		ForMachine: lo.ToPtr(true),
+		Search:     opts.Search,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return FindDuplicates(books, opts), nil
+}
+
+// FindDuplicates clusters books listed with the title, authors, identifiers
+// and formats fields, see Calibre.FindDuplicates. opts.Search is ignored.
+func FindDuplicates(books []Book, opts DuplicatesOptions) []DuplicateCluster {
+	books = slices.Clone(books)
+	slices.SortFunc(books, func(a, b Book) int { return a.ID - b.ID })
+	u := newUnionFind(len(books))
+	// reasons[i] are the signals that linked book i to others
+	reasons := make([]map[string]bool, len(books))
+	for i := range reasons {
+		reasons[i] = map[string]bool{}
+	}
+	link := func(reason string, keys map[string][]int) {
+		for _, group := range keys {
+			for _, i := range group[1:] {
+				u.union(group[0], i)
+				reasons[group[0]][reason] = true
+				reasons[i][reason] = true
+			}
+		}
+	}
+
+	byTitle := map[string][]int{}
+	for i, b := range books {
+		title := opts.Normalize.Title(b.Title)
+		if title == "" {
+			continue
+		}
+		key := title + "\x00" + opts.Normalize.Authors(b.Authors)
+		byTitle[key] = append(byTitle[key], i)
+	}
+	link(ReasonTitleAuthor, byTitle)
+
+	byIdentifier := map[string]map[string][]int{}
+	for i, b := range books {
+		for typ, value := range b.Identifiers {
+			typ = strings.ToLower(typ)
+			value = normalizeIdentifier(typ, value)
+			if value == "" {
+				continue
+			}
+			if byIdentifier[typ] == nil {
+				byIdentifier[typ] = map[string][]int{}
+			}
+			byIdentifier[typ][value] = append(byIdentifier[typ][value], i)
+		}
+	}
+	for typ, keys := range byIdentifier {
+		link(ReasonIdentifier+":"+typ, keys)
+	}
+
+	if opts.ByFormats {
+		link(ReasonFormatHash, formatHashes(books))
+	}
+
+	clusters := map[int][]int{}
+	for i := range books {
+		root := u.find(i)
+		clusters[root] = append(clusters[root], i)
+	}
+	var out []DuplicateCluster
+	for _, members := range clusters {
+		if len(members) < 2 {
+			continue
+		}
+		cluster := DuplicateCluster{Reasons: []string{}, Merge: []int{}}
+		shared := map[string]bool{}
+		for _, i := range members {
+			cluster.Books = append(cluster.Books, books[i])
+			for r := range reasons[i] {
+				shared[r] = true
+			}
+		}
+		miss := 1.0
+		for _, r := range slices.Sorted(maps.Keys(shared)) {
+			cluster.Reasons = append(cluster.Reasons, r)
+			kind, _, _ := strings.Cut(r, ":")
+			miss *= 1 - reasonWeights[kind]
+		}
+		cluster.Score = float64(int((1-miss)*100+0.5)) / 100
+		cluster.Action = DuplicateReview
+		if cluster.Score >= mergeScore {
+			cluster.Action = DuplicateMerge
+		}
+		keep := slices.MaxFunc(cluster.Books, func(a, b Book) int {
+			return cmp.Or(
+				cmp.Compare(len(a.Formats), len(b.Formats)),
+				cmp.Compare(len(a.Identifiers), len(b.Identifiers)),
+				cmp.Compare(b.ID, a.ID),
+			)
+		})
+		cluster.Keep = keep.ID
+		for _, b := range cluster.Books {
+			if b.ID != keep.ID {
+				cluster.Merge = append(cluster.Merge, b.ID)
+			}
+		}
+		out = append(out, cluster)
+	}
+	slices.SortFunc(out, func(a, b DuplicateCluster) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Books[0].ID, b.Books[0].ID))
+	})
+	return out
+}
+
+// normalizeIdentifier makes identifiers comparable: ISBNs lose their hyphens
+// and spaces, everything ignores case.
+func normalizeIdentifier(typ, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if typ == "isbn" {
+		value = strings.Map(func(r rune) rune {
+			if r == '-' || unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, value)
+	}
+	return value
+}
+
+// formatHashes groups the books by the SHA-256 of their format files. Only
+// files of a size that occurs more than once are read; unreadable files are
+// skipped.
+func formatHashes(books []Book) map[string][]int {
+	bySize := map[int64][]int{}
+	paths := map[int64][]string{}
+	for i, b := range books {
+		for _, path := range b.Formats {
+			info, err := os.Stat(path)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			bySize[info.Size()] = append(bySize[info.Size()], i)
+			paths[info.Size()] = append(paths[info.Size()], path)
+		}
+	}
+	byHash := map[string][]int{}
+	for size, members := range bySize {
+		if len(lo.Uniq(members)) < 2 {
+			continue
+		}
+		for j, i := range members {
+			sum, err := hashFile(paths[size][j])
+			if err != nil {
+				continue
+			}
+			if !slices.Contains(byHash[sum], i) {
+				byHash[sum] = append(byHash[sum], i)
+			}
+		}
+	}
+	return byHash
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// unionFind joins the books linked by any signal into clusters.
+type unionFind []int
+
+func newUnionFind(n int) unionFind {
+	u := make(unionFind, n)
+	for i := range u {
+		u[i] = i
+	}
+	return u
+}
+
+func (u unionFind) find(i int) int {
+	for u[i] != i {
+		u[i] = u[u[i]]
+		i = u[i]
+	}
+	return i
+}
+
+func (u unionFind) union(a, b int) {
+	ra, rb := u.find(a), u.find(b)
+	if ra != rb {
+		u[max(ra, rb)] = min(ra, rb)
+	}
+}
@@ -0,0 +1,116 @@
+package calibredb_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func TestNormalizeOptions(t *testing.T) {
+	tests := []struct {
+		name      string
+		normalize calibredb.NormalizeOptions
+		title     string
+		want      string
+	}{
+		{name: "defaults", title: "The Hobbit: or There and Back Again", want: "hobbit"},
+		{name: "punctuation", title: "Dr. Jekyll & Mr. Hyde", want: "dr jekyll mr hyde"},
+		{name: "only an article", title: "The", want: "the"},
+		{name: "german article", title: "Die Verwandlung", want: "verwandlung"},
+		{name: "keep articles", normalize: calibredb.NormalizeOptions{KeepArticles: true}, title: "The Hobbit", want: "the hobbit"},
+		{name: "own articles", normalize: calibredb.NormalizeOptions{Articles: []string{"der"}}, title: "The Hobbit", want: "the hobbit"},
+		{name: "keep subtitles", normalize: calibredb.NormalizeOptions{KeepSubtitles: true}, title: "Dune: Deluxe Edition", want: "dune deluxe edition"},
+		{name: "keep punctuation", normalize: calibredb.NormalizeOptions{KeepPunctuation: true}, title: "Dr. Jekyll", want: "dr. jekyll"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.normalize.Title(tt.title); got != tt.want {
+				t.Errorf("Title(%q) = %q, want %q", tt.title, got, tt.want)
+			}
+		})
+	}
+
+	n := calibredb.NormalizeOptions{}
+	if a, b := n.Authors("Terry Pratchett & Neil Gaiman"), n.Authors("neil gaiman&Terry  Pratchett"); a != b {
+		t.Errorf("Authors() = %q and %q, want them equal", a, b)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	same1, same2, other := write("a.epub", "same bytes"), write("b.epub", "same bytes"), write("c.epub", "diff bytes")
+
+	books := []calibredb.Book{
+		{ID: 1, Title: "Dune", Authors: "Frank Herbert", Identifiers: map[string]string{"isbn": "978-0-441-17271-9"}},
+		{ID: 2, Title: "Dune: Deluxe Edition", Authors: "Frank Herbert", Formats: []string{same1}},
+		{ID: 3, Title: "Arrakis", Authors: "Someone", Identifiers: map[string]string{"isbn": "9780441172719"}},
+		{ID: 4, Title: "The Emma", Authors: "Jane Austen", Formats: []string{same2}},
+		{ID: 5, Title: "Emma", Authors: "Jane Austen", Formats: []string{other}},
+		{ID: 6, Title: "Anathem", Authors: "Neal Stephenson", Formats: []string{other + ".missing"}},
+	}
+
+	clusters := calibredb.FindDuplicates(slices.Clone(books), calibredb.DuplicatesOptions{})
+	if len(clusters) != 2 {
+		t.Fatalf("FindDuplicates() = %+v, want two clusters", clusters)
+	}
+	dune := clusters[0]
+	if ids := bookIDs(dune.Books); !slices.Equal(ids, []int{1, 2, 3}) {
+		t.Errorf("first cluster = %v", ids)
+	}
+	if !slices.Equal(dune.Reasons, []string{"identifier:isbn", "title_author"}) || dune.Score != 0.92 || dune.Action != calibredb.DuplicateMerge {
+		t.Errorf("first cluster = %+v", dune)
+	}
+	// the book with a format is kept
+	if dune.Keep != 2 || !slices.Equal(dune.Merge, []int{1, 3}) {
+		t.Errorf("first cluster keeps %d and merges %v", dune.Keep, dune.Merge)
+	}
+	emma := clusters[1]
+	if ids := bookIDs(emma.Books); !slices.Equal(ids, []int{4, 5}) || emma.Action != calibredb.DuplicateReview || emma.Keep != 4 {
+		t.Errorf("second cluster = %+v", emma)
+	}
+
+	// with formats, the two files with the same bytes link Dune to Emma
+	clusters = calibredb.FindDuplicates(slices.Clone(books), calibredb.DuplicatesOptions{ByFormats: true})
+	if len(clusters) != 1 || len(clusters[0].Books) != 5 || !slices.Contains(clusters[0].Reasons, calibredb.ReasonFormatHash) {
+		t.Errorf("FindDuplicates() by formats = %+v", clusters)
+	}
+
+	// keeping subtitles splits Dune from its deluxe edition
+	clusters = calibredb.FindDuplicates(slices.Clone(books), calibredb.DuplicatesOptions{Normalize: calibredb.NormalizeOptions{KeepSubtitles: true, KeepArticles: true}})
+	if len(clusters) != 1 || !slices.Equal(bookIDs(clusters[0].Books), []int{1, 3}) {
+		t.Errorf("FindDuplicates() keeping subtitles = %+v", clusters)
+	}
+
+	// the books of the caller are left in their order
+	reversed := slices.Clone(books)
+	slices.Reverse(reversed)
+	calibredb.FindDuplicates(reversed, calibredb.DuplicatesOptions{})
+	if ids := bookIDs(reversed); !slices.Equal(ids, []int{6, 5, 4, 3, 2, 1}) {
+		t.Errorf("FindDuplicates() reordered the books to %v", ids)
+	}
+}
+
+func TestCalibre_FindDuplicates(t *testing.T) {
+	c, _ := newLibrary(t, []testBook{
+		{file: "Dune - Frank Herbert.epub"},
+		{file: "The Dune - Frank Herbert.txt"},
+		{file: "Emma - Jane Austen.epub"},
+	})
+	clusters, err := c.FindDuplicates(calibredb.DuplicatesOptions{ByFormats: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || !slices.Equal(bookIDs(clusters[0].Books), []int{1, 2}) || !slices.Equal(clusters[0].Reasons, []string{"title_author"}) {
+		t.Errorf("FindDuplicates() = %+v", clusters)
+	}
+}
@@ -16,10 +16,10 @@ package calibredb
 
 type EmbedMetadataOptions struct {
 	// Command Line Arguments
-	BookId string `validate:"required"`
+	BookIds BookIDs `validate:"required,bookids=all"`
 
 	// Command Line Options
-	OnlyFormats []string // Only update metadata in files of the specified format. Specify it multiple times for multiple formats. By default, all formats are updated.
+	OnlyFormats []string `flag:"--only-formats"` // Only update metadata in files of the specified format. Specify it multiple times for multiple formats. By default, all formats are updated.
 }
 
 func (c *Calibre) EmbedMetadataHelp() string {
@@ -38,15 +38,13 @@ func (c *Calibre) EmbedMetadata(opts EmbedMetadataOptions, args ...string) (stri
 	if err != nil {
 		return "", err
 	}
-	// Command Line Arguments
-	argv = append(argv, opts.BookId)
 
 	// Command Line Options
 	// Handling []string
-	if len(opts.OnlyFormats) > 0 {
-		argv = append(argv, "--only-formats")
-		argv = append(argv, opts.OnlyFormats...)
+	for _, v := range opts.OnlyFormats {
+		argv = append(argv, "--only-formats", v)
 	}
-	out, err := c.run(argv...)
-	return out, err
+	argv = append(argv, args...)
+	// Handling book ids
+	return c.runBookIDs(argv, opts.BookIds, RangeInclusive, "all")
 }
@@ -15,13 +15,13 @@ func TestCalibre_EmbedMetadataHelp(t *testing.T) {
 	}{
 		{
 			name: "EmbedMetadataHelp returns help text",
-			want: "usage: calibredb embed_metadata",
+			want: "Usage: calibredb embed_metadata",
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := calibredb.NewCalibre(
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 			got := c.EmbedMetadataHelp()
 			if !strings.Contains(got, tt.want) && !strings.Contains(got, "no such file or directory") {
@@ -41,9 +41,9 @@ func TestCalibre_EmbedMetadata(t *testing.T) {
 		wantValidation bool // true if we expect a validation error
 	}{
 		{
-			name: "Invalid - empty BookId",
+			name: "Invalid - empty BookIds",
 			opts: calibredb.EmbedMetadataOptions{
-				BookId: "",
+				BookIds: calibredb.BookIDs{},
 			},
 			wantErr:        true,
 			wantValidation: true,
@@ -51,35 +51,35 @@ func TestCalibre_EmbedMetadata(t *testing.T) {
 		{
 			name: "Valid - with single BookId",
 			opts: calibredb.EmbedMetadataOptions{
-				BookId: "1",
+				BookIds: mustParseBookIDs("1", calibredb.RangeInclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "Valid - with multiple BookIds",
 			opts: calibredb.EmbedMetadataOptions{
-				BookId: "1 2 3",
+				BookIds: mustParseBookIDs("1 2 3", calibredb.RangeInclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "Valid - with BookId range",
 			opts: calibredb.EmbedMetadataOptions{
-				BookId: "1-10",
+				BookIds: mustParseBookIDs("1-10", calibredb.RangeInclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "Valid - with 'all' BookId",
 			opts: calibredb.EmbedMetadataOptions{
-				BookId: "all",
+				BookIds: mustParseBookIDs("all", calibredb.RangeInclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "Valid - with single OnlyFormats",
 			opts: calibredb.EmbedMetadataOptions{
-				BookId:      "1",
+				BookIds:     mustParseBookIDs("1", calibredb.RangeInclusive),
 				OnlyFormats: []string{"EPUB"},
 			},
 			wantErr: false,
@@ -87,7 +87,7 @@ func TestCalibre_EmbedMetadata(t *testing.T) {
 		{
 			name: "Valid - with multiple OnlyFormats",
 			opts: calibredb.EmbedMetadataOptions{
-				BookId:      "1",
+				BookIds:     mustParseBookIDs("1", calibredb.RangeInclusive),
 				OnlyFormats: []string{"EPUB", "PDF", "MOBI"},
 			},
 			wantErr: false,
@@ -95,7 +95,7 @@ func TestCalibre_EmbedMetadata(t *testing.T) {
 		{
 			name: "Valid - with empty OnlyFormats slice",
 			opts: calibredb.EmbedMetadataOptions{
-				BookId:      "1",
+				BookIds:     mustParseBookIDs("1", calibredb.RangeInclusive),
 				OnlyFormats: []string{},
 			},
 			wantErr: false,
@@ -103,15 +103,15 @@ func TestCalibre_EmbedMetadata(t *testing.T) {
 		{
 			name: "Valid - with additional args",
 			opts: calibredb.EmbedMetadataOptions{
-				BookId: "1",
+				BookIds: mustParseBookIDs("1", calibredb.RangeInclusive),
 			},
 			args:    []string{"extra", "args"},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name: "Valid - complex scenario with all options",
 			opts: calibredb.EmbedMetadataOptions{
-				BookId:      "1 5-10 15",
+				BookIds:     mustParseBookIDs("1 5-10 15", calibredb.RangeInclusive),
 				OnlyFormats: []string{"EPUB", "PDF"},
 			},
 			wantErr: false,
@@ -123,7 +123,7 @@ func TestCalibre_EmbedMetadata(t *testing.T) {
 			defer func() { _ = os.RemoveAll(tempDir) }()
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			got, gotErr := c.EmbedMetadata(tt.opts, tt.args...)
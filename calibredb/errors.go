@@ -0,0 +1,113 @@
+package calibredb
+
+import (
+	"errors"
+	"os/exec"
+	"regexp"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ErrorKind classifies why a call failed, e.g. to pick an exit status or an
+// HTTP status.
+type ErrorKind int
+
+const (
+	// ErrorKindFailed is any failure of calibredb not covered below, usually
+	// a Python exception.
+	ErrorKindFailed ErrorKind = iota
+	// ErrorKindNotInstalled means calibredb could not be started.
+	ErrorKindNotInstalled
+	// ErrorKindUsage means calibredb rejected the command line.
+	ErrorKindUsage
+	// ErrorKindNotFound means a book, column or search does not exist.
+	ErrorKindNotFound
+	// ErrorKindInvalid means the options failed validation and calibredb was
+	// not run.
+	ErrorKindInvalid
+	// ErrorKindUnsupported means the installed calibre is too old, see
+	// ErrUnsupportedByCalibreVersion.
+	ErrorKindUnsupported
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindNotInstalled:
+		return "not_installed"
+	case ErrorKindUsage:
+		return "usage"
+	case ErrorKindNotFound:
+		return "not_found"
+	case ErrorKindInvalid:
+		return "invalid"
+	case ErrorKindUnsupported:
+		return "unsupported"
+	}
+	return "failed"
+}
+
+// CalibreError is returned when calibredb cannot be started or exits with a
+// non-zero status.
+type CalibreError struct {
+	Kind ErrorKind
+	// ExitCode is the exit status of calibredb, -1 when it did not start.
+	ExitCode int
+	// Message is the last line of the output, which for a Python exception
+	// is the exception itself.
+	Message string
+	// Output is everything calibredb printed.
+	Output string
+	// Err is the error of os/exec.
+	Err error
+}
+
+func (e *CalibreError) Error() string {
+	return e.Message
+}
+
+func (e *CalibreError) Unwrap() error {
+	return e.Err
+}
+
+// notFoundRe matches the messages calibredb exits with when the book, column
+// or search it was given does not exist.
+var notFoundRe = regexp.MustCompile(`(?i)(no book|no books matching|not present in database|no column|not found)`)
+
+func newCalibreError(out []byte, err error) *CalibreError {
+	e := &CalibreError{Kind: ErrorKindFailed, ExitCode: -1, Output: string(out), Err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.ExitCode = exitErr.ExitCode()
+	} else {
+		e.Kind = ErrorKindNotInstalled
+	}
+	if out != nil {
+		// this is a stacktrace followed by the actual error message. We want to extract only the actual error message.
+		e.Message = filtered(out, true)
+	} else {
+		e.Message = err.Error()
+	}
+	switch {
+	case e.ExitCode == 2:
+		// optparse exits with 2 on bad options and arguments
+		e.Kind = ErrorKindUsage
+	case e.Kind == ErrorKindFailed && notFoundRe.MatchString(e.Message):
+		e.Kind = ErrorKindNotFound
+	}
+	return e
+}
+
+// ErrorKindOf returns the kind of an error returned by this package.
+func ErrorKindOf(err error) ErrorKind {
+	var calibreErr *CalibreError
+	var validationErrors validator.ValidationErrors
+	switch {
+	case errors.As(err, &calibreErr):
+		return calibreErr.Kind
+	case errors.As(err, &validationErrors):
+		return ErrorKindInvalid
+	case errors.Is(err, ErrUnsupportedByCalibreVersion):
+		return ErrorKindUnsupported
+	}
+	return ErrorKindFailed
+}
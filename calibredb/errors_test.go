@@ -0,0 +1,82 @@
+package calibredb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func TestErrorKindOf(t *testing.T) {
+	library := t.TempDir()
+	c := calibredb.NewCalibre(
+		calibredb.WithCalibreDBLocation(calibredbPath),
+		calibredb.WithLibraryPath(library),
+	)
+	if _, err := c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "genre", Name: "Genre", Datatype: "text"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		run      func(c *calibredb.Calibre) (string, error)
+		want     calibredb.ErrorKind
+		wantExit int
+	}{
+		{
+			name: "not installed",
+			run: func(*calibredb.Calibre) (string, error) {
+				return calibredb.NewCalibre(calibredb.WithCalibreDBLocation("/nonexistent/calibredb")).List(calibredb.ListOptions{})
+			},
+			want:     calibredb.ErrorKindNotInstalled,
+			wantExit: -1,
+		},
+		{
+			name:     "usage",
+			run:      func(c *calibredb.Calibre) (string, error) { return c.List(calibredb.ListOptions{}, "--frobnicate") },
+			want:     calibredb.ErrorKindUsage,
+			wantExit: 2,
+		},
+		{
+			name: "not found",
+			run: func(c *calibredb.Calibre) (string, error) {
+				return c.ShowMetadata(calibredb.ShowMetadataOptions{Id: "42"})
+			},
+			want:     calibredb.ErrorKindNotFound,
+			wantExit: 1,
+		},
+		{
+			name: "exception",
+			run: func(c *calibredb.Calibre) (string, error) {
+				return c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "genre", Name: "Genre", Datatype: "text"})
+			},
+			want:     calibredb.ErrorKindFailed,
+			wantExit: 1,
+		},
+		{
+			name: "invalid",
+			run: func(c *calibredb.Calibre) (string, error) {
+				return c.ShowMetadata(calibredb.ShowMetadataOptions{Id: "x"})
+			},
+			want: calibredb.ErrorKindInvalid,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.run(c)
+			if err == nil {
+				t.Fatal("error = nil")
+			}
+			if got := calibredb.ErrorKindOf(err); got != tt.want {
+				t.Errorf("ErrorKindOf(%q) = %v, want %v", err, got, tt.want)
+			}
+			var calibreErr *calibredb.CalibreError
+			if errors.As(err, &calibreErr) != (tt.wantExit != 0) {
+				t.Fatalf("errors.As(%T) mismatch", err)
+			}
+			if calibreErr != nil && calibreErr.ExitCode != tt.wantExit {
+				t.Errorf("ExitCode = %d, want %d", calibreErr.ExitCode, tt.wantExit)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package calibredb
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EventType names a change to a library.
+type EventType string
+
+const (
+	EventBookAdded           EventType = "book.added"
+	EventBookRemoved         EventType = "book.removed"
+	EventMetadataUpdated     EventType = "metadata.updated"
+	EventFormatAdded         EventType = "format.added"
+	EventFormatRemoved       EventType = "format.removed"
+	EventCustomColumnCreated EventType = "custom_column.created"
+	EventCustomColumnRemoved EventType = "custom_column.removed"
+	// The import events report the files of a watch folder, see package
+	// watchfolder.
+	EventImportCompleted EventType = "import.completed"
+	EventImportFailed    EventType = "import.failed"
+)
+
+// EventTypes lists every event type.
+var EventTypes = []EventType{
+	EventBookAdded,
+	EventBookRemoved,
+	EventMetadataUpdated,
+	EventFormatAdded,
+	EventFormatRemoved,
+	EventCustomColumnCreated,
+	EventCustomColumnRemoved,
+	EventImportCompleted,
+	EventImportFailed,
+}
+
+// Event describes a change made by a successful wrapper call, see WithEvents,
+// or the outcome of an import.
+type Event struct {
+	Type    EventType `json:"type"`
+	Time    time.Time `json:"time"`
+	Library string    `json:"library"`
+	Actor   string    `json:"actor,omitempty"`
+	BookIDs BookIDs   `json:"book_ids,omitzero"`
+	// Format is set for format events, e.g. EPUB.
+	Format string `json:"format,omitempty"`
+	// Column is the label of the column for custom column events and
+	// set_custom.
+	Column string `json:"column,omitempty"`
+	// Files are the names of the imported files for import events.
+	Files []string `json:"files,omitempty"`
+	// Error says why an import failed.
+	Error string `json:"error,omitempty"`
+}
+
+// WithEvents reports the changes of every successful command to publish. It
+// is called synchronously, so it should only queue the event.
+func WithEvents(publish func(Event)) CalibreOption {
+	return func(c *Calibre) {
+		c.events = publish
+	}
+}
+
+// NewEvent returns an event of type t that happened now in the library of c,
+// on behalf of its actor.
+func (c *Calibre) NewEvent(t EventType) Event {
+	return Event{
+		Type:    t,
+		Time:    time.Now().UTC(),
+		Library: RedactURL(c.LibraryPath),
+		Actor:   c.actor,
+	}
+}
+
+// eventFor returns the event for a successful run of argv that printed out.
+func (c *Calibre) eventFor(argv []string, out string) (Event, bool) {
+	if !changesLibrary(argv) {
+		return Event{}, false
+	}
+	e := c.NewEvent("")
+	e.BookIDs = affectedBookIDs(argv, out)
+	args := positionalArgs(argv)
+	arg := func(i int) string {
+		if i < len(args) {
+			return args[i]
+		}
+		return ""
+	}
+	switch argv[0] {
+	case "add":
+		e.Type = EventBookAdded
+	case "remove":
+		e.Type = EventBookRemoved
+	case "set_metadata":
+		e.Type = EventMetadataUpdated
+	case "set_custom":
+		e.Type = EventMetadataUpdated
+		e.Column = arg(0)
+	case "add_format":
+		e.Type = EventFormatAdded
+		e.Format = strings.ToUpper(strings.TrimPrefix(filepath.Ext(arg(1)), "."))
+	case "remove_format":
+		e.Type = EventFormatRemoved
+		e.Format = strings.ToUpper(arg(1))
+	case "add_custom_column":
+		e.Type = EventCustomColumnCreated
+		e.Column = arg(0)
+	case "remove_custom_column":
+		e.Type = EventCustomColumnRemoved
+		e.Column = arg(0)
+	default:
+		return Event{}, false
+	}
+	if e.Type == EventBookAdded && e.BookIDs.IsEmpty() {
+		// add found only duplicates
+		return Event{}, false
+	}
+	return e, true
+}
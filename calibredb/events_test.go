@@ -0,0 +1,86 @@
+package calibredb_test
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func TestCalibre_Events(t *testing.T) {
+	var mu sync.Mutex
+	var events []calibredb.Event
+	c, dir := newLibrary(t, nil, calibredb.WithEvents(func(e calibredb.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}))
+	c = c.As("alice")
+	epub := filepath.Join(dir, "dune.epub")
+	txt := filepath.Join(dir, "dune.txt")
+	for _, name := range []string{epub, txt} {
+		if err := os.WriteFile(name, []byte("dune"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []func() (string, error){
+		func() (string, error) { return c.Add(calibredb.AddOptions{Files: []string{epub}}) },
+		func() (string, error) {
+			return c.AddFormat(calibredb.AddFormatOptions{Id: "1", EbookFile: txt})
+		},
+		func() (string, error) {
+			return c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "genre", Name: "Genre", Datatype: "text"})
+		},
+		func() (string, error) {
+			return c.SetCustom(calibredb.SetCustomOptions{Column: "genre", Id: "1", Value: "scifi"})
+		},
+		func() (string, error) { return c.RemoveFormat(calibredb.RemoveFormatOptions{Id: "1", Fmt: "txt"}) },
+		// writing the metadata into the files changes no metadata
+		func() (string, error) {
+			return c.EmbedMetadata(calibredb.EmbedMetadataOptions{BookIds: calibredb.NewBookIDs(1)})
+		},
+		func() (string, error) {
+			return c.RemoveCustomColumn(calibredb.RemoveCustomColumnOptions{Label: "genre", Force: ptr(true)})
+		},
+		func() (string, error) { return c.Remove(calibredb.RemoveOptions{Ids: calibredb.NewBookIDs(1)}) },
+		// neither failures nor reads are events
+		func() (string, error) {
+			_, err := c.AddFormat(calibredb.AddFormatOptions{Id: "7", EbookFile: txt})
+			if err == nil {
+				t.Error("AddFormat() of a missing book succeeded")
+			}
+			return "", nil
+		},
+		func() (string, error) { return c.List(calibredb.ListOptions{}) },
+	}
+	for i, step := range steps {
+		if _, err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	want := []calibredb.Event{
+		{Type: calibredb.EventBookAdded, BookIDs: calibredb.NewBookIDs(1)},
+		{Type: calibredb.EventFormatAdded, BookIDs: calibredb.NewBookIDs(1), Format: "TXT"},
+		{Type: calibredb.EventCustomColumnCreated, Column: "genre"},
+		{Type: calibredb.EventMetadataUpdated, BookIDs: calibredb.NewBookIDs(1), Column: "genre"},
+		{Type: calibredb.EventFormatRemoved, BookIDs: calibredb.NewBookIDs(1), Format: "TXT"},
+		{Type: calibredb.EventCustomColumnRemoved, Column: "genre"},
+		{Type: calibredb.EventBookRemoved, BookIDs: calibredb.NewBookIDs(1)},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("events = %+v, want %d", events, len(want))
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Type != w.Type || e.BookIDs.String() != w.BookIDs.String() || e.Format != w.Format || e.Column != w.Column {
+			t.Errorf("event %d = %+v, want %+v", i, e, w)
+		}
+		if e.Actor != "alice" || e.Library != filepath.Join(dir, "library") || e.Time.IsZero() {
+			t.Errorf("event %d = %+v", i, e)
+		}
+	}
+}
@@ -13,13 +13,23 @@ package calibredb
 
 type ExportOptions struct {
 	// Command Line Arguments
-	Ids []string `validate:"required"`
+	Ids BookIDs `validate:"required,bookids=all"`
 
 	// Command Line Options
-	All       *bool  // Export all books in database, ignoring the list of ids.
-	Progress  *bool  // Report progress
-	SingleDir *bool  // Export all books into a single folder
-	ToDir     string // Export books to the specified folder. Default is .
+	All                *bool  `flag:"--all"`                   // Export all books in database, ignoring the list of ids.
+	DontAsciiize       *bool  `flag:"--dont-asciiize"`         // Have calibre convert all non English characters into English equivalents for the file names. This is useful if saving to a legacy filesystem without full support for Unicode filenames. Specifying this switch will turn this behavior off.
+	DontSaveCover      *bool  `flag:"--dont-save-cover"`       // Normally, calibre will save the cover in a separate file along with the actual e-book files. Specifying this switch will turn this behavior off.
+	DontSaveExtraFiles *bool  `flag:"--dont-save-extra-files"` // Save any data files associated with the book when saving the book Specifying this switch will turn this behavior off.
+	DontUpdateMetadata *bool  `flag:"--dont-update-metadata"`  // Normally, calibre will update the metadata in the saved files from what is in the calibre library. Makes saving to disk slower. Specifying this switch will turn this behavior off.
+	DontWriteOpf       *bool  `flag:"--dont-write-opf"`        // Normally, calibre will write the metadata into a separate OPF file along with the actual e-book files. Specifying this switch will turn this behavior off.
+	Formats            string `flag:"--formats"`               // Comma separated list of formats to save for each book. By default all available formats are saved.
+	Progress           *bool  `flag:"--progress"`              // Report progress
+	ReplaceWhitespace  *bool  `flag:"--replace-whitespace"`    // Replace whitespace with underscores.
+	SingleDir          *bool  `flag:"--single-dir"`            // Export all books into a single folder
+	Template           string `flag:"--template"`              // The template to control the filename and folder structure of the saved files. Default is "{author_sort}/{title}/{title} - {authors}" which will save books into a per-author subfolder with filenames containing title and author. Available controls are: {author_sort, authors, id, isbn, languages, last_modified, pubdate, publisher, rating, series, series_index, tags, timestamp, title}
+	Timefmt            string `flag:"--timefmt"`               // The format in which to display dates. %d - day, %b - month, %m - month number, %Y - year. Default is: %b, %Y
+	ToDir              string `flag:"--to-dir"`                // Export books to the specified folder. Default is .
+	ToLowercase        *bool  `flag:"--to-lowercase"`          // Convert paths to lowercase.
 }
 
 func (c *Calibre) ExportHelp() string {
@@ -38,8 +48,6 @@ func (c *Calibre) Export(opts ExportOptions, args ...string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	// Command Line Arguments
-	argv = append(argv, opts.Ids...)
 
 	// Command Line Options
 	// Handling bool
@@ -47,17 +55,58 @@ func (c *Calibre) Export(opts ExportOptions, args ...string) (string, error) {
 		argv = append(argv, "--all")
 	}
 	// Handling bool
+	if opts.DontAsciiize != nil && *opts.DontAsciiize {
+		argv = append(argv, "--dont-asciiize")
+	}
+	// Handling bool
+	if opts.DontSaveCover != nil && *opts.DontSaveCover {
+		argv = append(argv, "--dont-save-cover")
+	}
+	// Handling bool
+	if opts.DontSaveExtraFiles != nil && *opts.DontSaveExtraFiles {
+		argv = append(argv, "--dont-save-extra-files")
+	}
+	// Handling bool
+	if opts.DontUpdateMetadata != nil && *opts.DontUpdateMetadata {
+		argv = append(argv, "--dont-update-metadata")
+	}
+	// Handling bool
+	if opts.DontWriteOpf != nil && *opts.DontWriteOpf {
+		argv = append(argv, "--dont-write-opf")
+	}
+	// Handling string
+	if opts.Formats != "" {
+		argv = append(argv, "--formats", opts.Formats)
+	}
+	// Handling bool
 	if opts.Progress != nil && *opts.Progress {
 		argv = append(argv, "--progress")
 	}
 	// Handling bool
+	if opts.ReplaceWhitespace != nil && *opts.ReplaceWhitespace {
+		argv = append(argv, "--replace-whitespace")
+	}
+	// Handling bool
 	if opts.SingleDir != nil && *opts.SingleDir {
 		argv = append(argv, "--single-dir")
 	}
 	// Handling string
+	if opts.Template != "" {
+		argv = append(argv, "--template", opts.Template)
+	}
+	// Handling string
+	if opts.Timefmt != "" {
+		argv = append(argv, "--timefmt", opts.Timefmt)
+	}
+	// Handling string
 	if opts.ToDir != "" {
 		argv = append(argv, "--to-dir", opts.ToDir)
 	}
-	out, err := c.run(argv...)
-	return out, err
+	// Handling bool
+	if opts.ToLowercase != nil && *opts.ToLowercase {
+		argv = append(argv, "--to-lowercase")
+	}
+	argv = append(argv, args...)
+	// Handling book ids
+	return c.runBookIDs(argv, opts.Ids, RangeExclusive, "--all")
 }
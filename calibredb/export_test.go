@@ -13,7 +13,7 @@ func TestCalibre_ExportHelp(t *testing.T) {
 	defer func() { _ = os.RemoveAll(tempDir) }()
 	c := calibredb.NewCalibre(
 		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+		calibredb.WithCalibreDBLocation(calibredbPath),
 	)
 
 	got := c.ExportHelp()
@@ -33,35 +33,35 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "Missing required Ids field",
 			opts: calibredb.ExportOptions{
-				Ids: []string{},
+				Ids: calibredb.BookIDs{},
 			},
 			wantErr: true,
 		},
 		{
 			name: "Valid with single ID",
 			opts: calibredb.ExportOptions{
-				Ids: []string{"1"},
+				Ids: mustParseBookIDs("1", calibredb.RangeExclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "Valid with multiple IDs",
 			opts: calibredb.ExportOptions{
-				Ids: []string{"1", "2", "3"},
+				Ids: mustParseBookIDs("1,2,3", calibredb.RangeExclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "Valid with comma-separated IDs",
 			opts: calibredb.ExportOptions{
-				Ids: []string{"1,2,3"},
+				Ids: mustParseBookIDs("1,2,3", calibredb.RangeExclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "With All option true",
 			opts: calibredb.ExportOptions{
-				Ids: []string{"1"},
+				Ids: mustParseBookIDs("1", calibredb.RangeExclusive),
 				All: func(b bool) *bool { return &b }(true),
 			},
 			wantErr: false,
@@ -69,7 +69,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With All option false",
 			opts: calibredb.ExportOptions{
-				Ids: []string{"1"},
+				Ids: mustParseBookIDs("1", calibredb.RangeExclusive),
 				All: func(b bool) *bool { return &b }(false),
 			},
 			wantErr: false,
@@ -77,7 +77,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With Progress option true",
 			opts: calibredb.ExportOptions{
-				Ids:      []string{"1"},
+				Ids:      mustParseBookIDs("1", calibredb.RangeExclusive),
 				Progress: func(b bool) *bool { return &b }(true),
 			},
 			wantErr: false,
@@ -85,7 +85,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With Progress option false",
 			opts: calibredb.ExportOptions{
-				Ids:      []string{"1"},
+				Ids:      mustParseBookIDs("1", calibredb.RangeExclusive),
 				Progress: func(b bool) *bool { return &b }(false),
 			},
 			wantErr: false,
@@ -93,7 +93,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With SingleDir option true",
 			opts: calibredb.ExportOptions{
-				Ids:       []string{"1"},
+				Ids:       mustParseBookIDs("1", calibredb.RangeExclusive),
 				SingleDir: func(b bool) *bool { return &b }(true),
 			},
 			wantErr: false,
@@ -101,7 +101,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With SingleDir option false",
 			opts: calibredb.ExportOptions{
-				Ids:       []string{"1"},
+				Ids:       mustParseBookIDs("1", calibredb.RangeExclusive),
 				SingleDir: func(b bool) *bool { return &b }(false),
 			},
 			wantErr: false,
@@ -109,7 +109,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With ToDir option",
 			opts: calibredb.ExportOptions{
-				Ids:   []string{"1"},
+				Ids:   mustParseBookIDs("1", calibredb.RangeExclusive),
 				ToDir: "/tmp/export",
 			},
 			wantErr: false,
@@ -117,7 +117,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With empty ToDir",
 			opts: calibredb.ExportOptions{
-				Ids:   []string{"1"},
+				Ids:   mustParseBookIDs("1", calibredb.RangeExclusive),
 				ToDir: "",
 			},
 			wantErr: false,
@@ -125,7 +125,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With ToDir as current directory",
 			opts: calibredb.ExportOptions{
-				Ids:   []string{"1"},
+				Ids:   mustParseBookIDs("1", calibredb.RangeExclusive),
 				ToDir: ".",
 			},
 			wantErr: false,
@@ -133,7 +133,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With ToDir as relative path",
 			opts: calibredb.ExportOptions{
-				Ids:   []string{"1"},
+				Ids:   mustParseBookIDs("1", calibredb.RangeExclusive),
 				ToDir: "./exports",
 			},
 			wantErr: false,
@@ -141,7 +141,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With ToDir as absolute path",
 			opts: calibredb.ExportOptions{
-				Ids:   []string{"1"},
+				Ids:   mustParseBookIDs("1", calibredb.RangeExclusive),
 				ToDir: "/tmp/calibre/exports",
 			},
 			wantErr: false,
@@ -149,7 +149,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With All and Progress options",
 			opts: calibredb.ExportOptions{
-				Ids:      []string{"1"},
+				Ids:      mustParseBookIDs("1", calibredb.RangeExclusive),
 				All:      func(b bool) *bool { return &b }(true),
 				Progress: func(b bool) *bool { return &b }(true),
 			},
@@ -158,7 +158,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With All and SingleDir options",
 			opts: calibredb.ExportOptions{
-				Ids:       []string{"1"},
+				Ids:       mustParseBookIDs("1", calibredb.RangeExclusive),
 				All:       func(b bool) *bool { return &b }(true),
 				SingleDir: func(b bool) *bool { return &b }(true),
 			},
@@ -167,7 +167,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With Progress and SingleDir options",
 			opts: calibredb.ExportOptions{
-				Ids:       []string{"1"},
+				Ids:       mustParseBookIDs("1", calibredb.RangeExclusive),
 				Progress:  func(b bool) *bool { return &b }(true),
 				SingleDir: func(b bool) *bool { return &b }(true),
 			},
@@ -176,7 +176,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With SingleDir and ToDir options",
 			opts: calibredb.ExportOptions{
-				Ids:       []string{"1"},
+				Ids:       mustParseBookIDs("1", calibredb.RangeExclusive),
 				SingleDir: func(b bool) *bool { return &b }(true),
 				ToDir:     "/tmp/single-export",
 			},
@@ -185,7 +185,7 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With all options combined",
 			opts: calibredb.ExportOptions{
-				Ids:       []string{"1", "2", "3"},
+				Ids:       mustParseBookIDs("1,2,3", calibredb.RangeExclusive),
 				All:       func(b bool) *bool { return &b }(true),
 				Progress:  func(b bool) *bool { return &b }(true),
 				SingleDir: func(b bool) *bool { return &b }(true),
@@ -196,14 +196,14 @@ func TestCalibre_Export(t *testing.T) {
 		{
 			name: "With many IDs",
 			opts: calibredb.ExportOptions{
-				Ids: []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10"},
+				Ids: mustParseBookIDs("1,2,3,4,5,6,7,8,9,10", calibredb.RangeExclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "With comma-separated and individual IDs",
 			opts: calibredb.ExportOptions{
-				Ids: []string{"1,2,3", "4", "5,6"},
+				Ids: mustParseBookIDs("1,2,3,4,5,6", calibredb.RangeExclusive),
 			},
 			wantErr: false,
 		},
@@ -215,7 +215,7 @@ func TestCalibre_Export(t *testing.T) {
 			defer func() { _ = os.RemoveAll(tempDir) }()
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			got, gotErr := c.Export(tt.opts, tt.args...)
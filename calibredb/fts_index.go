@@ -10,11 +10,11 @@ package calibredb
 
 type FtsIndexOptions struct {
 	// Command Line Arguments
-	EnableDisableStatusReindex string `validate:"required"`
+	EnableDisableStatusReindex string `validate:"required,oneof=enable disable status reindex"`
 
 	// Command Line Options
-	IndexingSpeed     IndexingSpeedChoice // The speed of indexing. Use fast for fast indexing using all your computers resources and slow for less resource intensive indexing. Note that the speed is reset to slow after every invocation.
-	WaitForCompletion *bool               // Wait till all books are indexed, showing indexing progress periodically
+	IndexingSpeed     IndexingSpeedChoice `flag:"--indexing-speed" validate:"omitempty,oneof=fast slow"` // The speed of indexing. Use fast for fast indexing using all your computers resources and slow for less resource intensive indexing. Note that the speed is reset to slow after every invocation.
+	WaitForCompletion *bool               `flag:"--wait-for-completion"`                                 // Wait till all books are indexed, showing indexing progress periodically
 }
 
 type IndexingSpeedChoice string
@@ -52,6 +52,7 @@ func (c *Calibre) FtsIndex(opts FtsIndexOptions, args ...string) (string, error)
 	if opts.WaitForCompletion != nil && *opts.WaitForCompletion {
 		argv = append(argv, "--wait-for-completion")
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
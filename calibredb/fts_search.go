@@ -14,17 +14,16 @@ import (
 
 type FtsSearchOptions struct {
 	// Command Line Arguments
-	Search     string `validate:"required"`
 	Expression string `validate:"required"`
 
 	// Command Line Options
-	DoNotMatchOnRelatedWords *bool              // Only match on exact words not related words. So correction will not match correcting.
-	IncludeSnippets          *bool              // Include snippets of the text surrounding each match. Note that this makes searching much slower.
-	IndexingThreshold        float64            // How much of the library must be indexed before searching is allowed, as a percentage. Defaults to 90
-	MatchEndMarker           string             // The marker used to indicate the end of a matched word inside a snippet
-	MatchStartMarker         string             // The marker used to indicate the start of a matched word inside a snippet
-	OutputFormat             OutputFormatChoice // The format to output the search results in. Either " text " for plain text or " json " for JSON output.
-	RestrictTo               string             // Restrict the searched books, either using a search expression or ids. For example: ids:1,2,3 to restrict by ids or search:tag:foo to restrict to books having the tag foo.
+	DoNotMatchOnRelatedWords *bool              `flag:"--do-not-match-on-related-words"`                      // Only match on exact words not related words. So correction will not match correcting.
+	IncludeSnippets          *bool              `flag:"--include-snippets"`                                   // Include snippets of the text surrounding each match. Note that this makes searching much slower.
+	IndexingThreshold        float64            `flag:"--indexing-threshold" validate:"gte=0,lte=100"`        // How much of the library must be indexed before searching is allowed, as a percentage. Defaults to 90
+	MatchEndMarker           string             `flag:"--match-end-marker"`                                   // The marker used to indicate the end of a matched word inside a snippet
+	MatchStartMarker         string             `flag:"--match-start-marker"`                                 // The marker used to indicate the start of a matched word inside a snippet
+	OutputFormat             OutputFormatChoice `flag:"--output-format" validate:"omitempty,oneof=text json"` // The format to output the search results in. Either " text " for plain text or " json " for JSON output.
+	RestrictTo               string             `flag:"--restrict-to"`                                        // Restrict the searched books, either using a search expression or ids. For example: ids:1,2,3 to restrict by ids or search:tag:foo to restrict to books having the tag foo.
 }
 
 type OutputFormatChoice string
@@ -51,7 +50,6 @@ func (c *Calibre) FtsSearch(opts FtsSearchOptions, args ...string) (string, erro
 		return "", err
 	}
 	// Command Line Arguments
-	argv = append(argv, opts.Search)
 	argv = append(argv, opts.Expression)
 
 	// Command Line Options
@@ -83,6 +81,7 @@ func (c *Calibre) FtsSearch(opts FtsSearchOptions, args ...string) (string, erro
 	if opts.RestrictTo != "" {
 		argv = append(argv, "--restrict-to", opts.RestrictTo)
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
@@ -13,7 +13,7 @@ func TestCalibre_FtsSearchHelp(t *testing.T) {
 	defer func() { _ = os.RemoveAll(tempDir) }()
 	c := calibredb.NewCalibre(
 		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+		calibredb.WithCalibreDBLocation(calibredbPath),
 	)
 
 	got := c.FtsSearchHelp()
@@ -32,34 +32,16 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		want    string
 		wantErr bool
 	}{
-		{
-			name: "Missing required Search",
-			opts: calibredb.FtsSearchOptions{
-				Search:     "",
-				Expression: "test",
-			},
-			wantErr: true,
-		},
 		{
 			name: "Missing required Expression",
 			opts: calibredb.FtsSearchOptions{
-				Search:     "test",
-				Expression: "",
-			},
-			wantErr: true,
-		},
-		{
-			name: "Missing both required fields",
-			opts: calibredb.FtsSearchOptions{
-				Search:     "",
 				Expression: "",
 			},
 			wantErr: true,
 		},
 		{
-			name: "Valid Search and Expression only",
+			name: "Valid Expression only",
 			opts: calibredb.FtsSearchOptions{
-				Search:     "test",
 				Expression: "author:Smith",
 			},
 			wantErr: true, // Will fail because calibredb is not installed, but validation passes
@@ -67,7 +49,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With DoNotMatchOnRelatedWords true",
 			opts: calibredb.FtsSearchOptions{
-				Search:                   "test",
 				Expression:               "correction",
 				DoNotMatchOnRelatedWords: func(b bool) *bool { return &b }(true),
 			},
@@ -76,7 +57,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With DoNotMatchOnRelatedWords false",
 			opts: calibredb.FtsSearchOptions{
-				Search:                   "test",
 				Expression:               "correction",
 				DoNotMatchOnRelatedWords: func(b bool) *bool { return &b }(false),
 			},
@@ -85,7 +65,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With IncludeSnippets true",
 			opts: calibredb.FtsSearchOptions{
-				Search:          "test",
 				Expression:      "important",
 				IncludeSnippets: func(b bool) *bool { return &b }(true),
 			},
@@ -94,7 +73,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With IncludeSnippets false",
 			opts: calibredb.FtsSearchOptions{
-				Search:          "test",
 				Expression:      "important",
 				IncludeSnippets: func(b bool) *bool { return &b }(false),
 			},
@@ -103,7 +81,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With IndexingThreshold",
 			opts: calibredb.FtsSearchOptions{
-				Search:            "test",
 				Expression:        "query",
 				IndexingThreshold: 95.5,
 			},
@@ -112,7 +89,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With IndexingThreshold zero value",
 			opts: calibredb.FtsSearchOptions{
-				Search:            "test",
 				Expression:        "query",
 				IndexingThreshold: 0,
 			},
@@ -121,7 +97,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With MatchEndMarker",
 			opts: calibredb.FtsSearchOptions{
-				Search:         "test",
 				Expression:     "word",
 				MatchEndMarker: "</match>",
 			},
@@ -130,7 +105,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With MatchStartMarker",
 			opts: calibredb.FtsSearchOptions{
-				Search:           "test",
 				Expression:       "word",
 				MatchStartMarker: "<match>",
 			},
@@ -139,7 +113,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With both MatchStartMarker and MatchEndMarker",
 			opts: calibredb.FtsSearchOptions{
-				Search:           "test",
 				Expression:       "word",
 				MatchStartMarker: "<match>",
 				MatchEndMarker:   "</match>",
@@ -149,7 +122,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With OutputFormat Text",
 			opts: calibredb.FtsSearchOptions{
-				Search:       "test",
 				Expression:   "query",
 				OutputFormat: calibredb.Text,
 			},
@@ -158,7 +130,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With OutputFormat Json",
 			opts: calibredb.FtsSearchOptions{
-				Search:       "test",
 				Expression:   "query",
 				OutputFormat: calibredb.Json,
 			},
@@ -167,7 +138,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With RestrictTo",
 			opts: calibredb.FtsSearchOptions{
-				Search:     "test",
 				Expression: "query",
 				RestrictTo: "ids:1,2,3",
 			},
@@ -176,7 +146,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With RestrictTo search expression",
 			opts: calibredb.FtsSearchOptions{
-				Search:     "test",
 				Expression: "query",
 				RestrictTo: "search:tag:fiction",
 			},
@@ -185,7 +154,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With all options",
 			opts: calibredb.FtsSearchOptions{
-				Search:                   "test",
 				Expression:               "comprehensive query",
 				DoNotMatchOnRelatedWords: func(b bool) *bool { return &b }(true),
 				IncludeSnippets:          func(b bool) *bool { return &b }(true),
@@ -200,7 +168,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With empty RestrictTo",
 			opts: calibredb.FtsSearchOptions{
-				Search:     "test",
 				Expression: "query",
 				RestrictTo: "",
 			},
@@ -209,7 +176,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With empty MatchStartMarker",
 			opts: calibredb.FtsSearchOptions{
-				Search:           "test",
 				Expression:       "query",
 				MatchStartMarker: "",
 			},
@@ -218,7 +184,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With empty MatchEndMarker",
 			opts: calibredb.FtsSearchOptions{
-				Search:         "test",
 				Expression:     "query",
 				MatchEndMarker: "",
 			},
@@ -227,7 +192,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "Complex search expression",
 			opts: calibredb.FtsSearchOptions{
-				Search:     "advanced",
 				Expression: "title:\"Harry Potter\" AND author:Rowling",
 			},
 			wantErr: true, // Will fail because calibredb is not installed, but validation passes
@@ -235,7 +199,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With multiple options combination 1",
 			opts: calibredb.FtsSearchOptions{
-				Search:            "test",
 				Expression:        "query",
 				IncludeSnippets:   func(b bool) *bool { return &b }(true),
 				IndexingThreshold: 85.5,
@@ -246,7 +209,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With multiple options combination 2",
 			opts: calibredb.FtsSearchOptions{
-				Search:                   "test",
 				Expression:               "query",
 				DoNotMatchOnRelatedWords: func(b bool) *bool { return &b }(true),
 				MatchStartMarker:         "[[",
@@ -257,7 +219,6 @@ func TestCalibre_FtsSearch(t *testing.T) {
 		{
 			name: "With multiple options combination 3",
 			opts: calibredb.FtsSearchOptions{
-				Search:          "test",
 				Expression:      "query",
 				IncludeSnippets: func(b bool) *bool { return &b }(true),
 				RestrictTo:      "search:format:epub",
@@ -271,7 +232,7 @@ func TestCalibre_FtsSearch(t *testing.T) {
 			defer func() { _ = os.RemoveAll(tempDir) }()
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			got, gotErr := c.FtsSearch(tt.opts, tt.args...)
@@ -280,7 +241,7 @@ func TestCalibre_FtsSearch(t *testing.T) {
 					t.Errorf("FtsSearch() failed: %v", gotErr)
 				}
 				// For validation errors, check that it's the right type of error
-				if (tt.opts.Search == "" || tt.opts.Expression == "") && !strings.Contains(gotErr.Error(), "required") {
+				if tt.opts.Expression == "" && !strings.Contains(gotErr.Error(), "required") {
 					t.Errorf("FtsSearch() error for missing required field should mention 'required', got: %v", gotErr)
 				}
 				return
@@ -16,11 +16,11 @@ import (
 type ListCategoriesOptions struct {
 
 	// Command Line Options
-	Categories string        // Comma-separated list of category lookup names. Default: all
-	Csv        *bool         // Output in CSV
-	Dialect    DialectChoice // The type of CSV file to produce. Choices: excel, excel-tab, unix
-	ItemCount  *bool         // Output only the number of items in a category instead of the counts per item within the category
-	Width      int           // The maximum width of a single line in the output. Defaults to detecting screen size.
+	Categories string        `flag:"--categories"`                                              // Comma-separated list of category lookup names. Default: all
+	Csv        *bool         `flag:"--csv"`                                                     // Output in CSV
+	Dialect    DialectChoice `flag:"--dialect" validate:"omitempty,oneof=excel excel-tab unix"` // The type of CSV file to produce. Choices: excel, excel-tab, unix
+	ItemCount  *bool         `flag:"--item_count"`                                              // Output only the number of items in a category instead of the counts per item within the category
+	Width      int           `flag:"--width" validate:"gte=0"`                                  // The maximum width of a single line in the output. Defaults to detecting screen size.
 }
 
 type DialectChoice string
@@ -69,6 +69,7 @@ func (c *Calibre) ListCategories(opts ListCategoriesOptions, args ...string) (st
 	if opts.Width != 0 {
 		argv = append(argv, "--width", fmt.Sprint(opts.Width))
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
@@ -13,7 +13,7 @@ func TestCalibre_ListHelp(t *testing.T) {
 	defer func() { _ = os.RemoveAll(tempDir) }()
 	c := calibredb.NewCalibre(
 		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+		calibredb.WithCalibreDBLocation(calibredbPath),
 	)
 
 	got := c.ListHelp()
@@ -263,7 +263,7 @@ func TestCalibre_List(t *testing.T) {
 			opts: calibredb.ListOptions{
 				Fields: "title,*rating,*genre",
 			},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name: "Custom field in SortBy",
@@ -281,7 +281,7 @@ func TestCalibre_List(t *testing.T) {
 
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			_, err := c.List(tt.opts, tt.args...)
@@ -341,14 +341,14 @@ func TestCalibre_List_ValidationErrors(t *testing.T) {
 			opts: calibredb.ListOptions{
 				Limit: -1,
 			},
-			wantErr: false, // The validation doesn't check for negative values, only for required fields
+			wantErr: true,
 		},
 		{
 			name: "Negative LineWidth value",
 			opts: calibredb.ListOptions{
 				LineWidth: -1,
 			},
-			wantErr: false, // The validation doesn't check for negative values, only for required fields
+			wantErr: true,
 		},
 	}
 
@@ -359,7 +359,7 @@ func TestCalibre_List_ValidationErrors(t *testing.T) {
 
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			_, err := c.List(tt.opts)
@@ -504,7 +504,7 @@ func TestCalibre_List_ArgumentHandling(t *testing.T) {
 
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			_, err := c.List(tt.opts, tt.args...)
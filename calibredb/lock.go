@@ -0,0 +1,32 @@
+package calibredb
+
+import "sync"
+
+// libraryLocks holds a *sync.RWMutex per library, shared by every Calibre of
+// the process. Commands that change a library hold it for reading, so they
+// still run concurrently; Exclusive holds it for writing.
+var libraryLocks sync.Map
+
+func libraryLock(library string) *sync.RWMutex {
+	l, _ := libraryLocks.LoadOrStore(normalizeLibrary(library), &sync.RWMutex{})
+	return l.(*sync.RWMutex)
+}
+
+// Exclusive runs fn while no command that changes the library runs through
+// any Calibre of this process, and holds such commands back until fn
+// returns. Commands that only read keep running. It is meant for copying or
+// replacing metadata.db, e.g. for snapshots; fn must not run write commands
+// itself. The cached results of the library are dropped afterwards since fn
+// may have replaced the database.
+//
+// Writers outside the process, such as the calibre GUI, are not held back.
+func (c *Calibre) Exclusive(fn func() error) error {
+	l := libraryLock(c.LibraryPath)
+	c.metrics.lock(l, true)
+	defer l.Unlock()
+	err := fn()
+	if c.cache != nil {
+		c.cache.Invalidate(c.LibraryPath)
+	}
+	return err
+}
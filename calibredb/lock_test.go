@@ -0,0 +1,47 @@
+package calibredb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func TestCalibre_Exclusive(t *testing.T) {
+	c, dir := newStubCalibre(t, calibredb.WithCache(calibredb.NewCache()))
+	// another Calibre of the same library shares the lock
+	writer := c.As("someone")
+
+	if _, err := c.List(calibredb.ListOptions{Fields: "title"}); err != nil {
+		t.Fatal(err)
+	}
+	written := make(chan error, 1)
+	err := c.Exclusive(func() error {
+		go func() {
+			_, err := writer.SetCustom(calibredb.SetCustomOptions{Column: "genre", Id: "1", Value: "sf"})
+			written <- err
+		}()
+		// reads go on while writes wait
+		if _, err := c.ShowMetadata(calibredb.ShowMetadataOptions{Id: "1"}); err != nil {
+			return err
+		}
+		select {
+		case <-written:
+			t.Error("a write ran during Exclusive")
+		case <-time.After(100 * time.Millisecond):
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	if got := stubCalls(t, dir); got != 3 {
+		t.Errorf("calibredb ran %d times, want 3", got)
+	}
+	if stats := c.CacheStats(); stats.Invalidations != 2 {
+		t.Errorf("CacheStats().Invalidations = %d, want 2 (Exclusive and the write)", stats.Invalidations)
+	}
+}
@@ -0,0 +1,95 @@
+package calibredb
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// WithLogger logs every calibredb process to l: at debug level with the
+// command line when it succeeds, at error level with the Python exception
+// when it fails. Passwords are replaced in everything logged.
+func WithLogger(l *slog.Logger) CalibreOption {
+	return func(c *Calibre) {
+		c.logger = l
+	}
+}
+
+// LogWith returns a Calibre like c whose log records carry args, such as
+// the id of the request it runs commands for. See slog.Logger.With.
+func (c *Calibre) LogWith(args ...any) *Calibre {
+	clone := c.As(c.actor)
+	if clone.logger != nil {
+		clone.logger = clone.logger.With(args...)
+	}
+	return clone
+}
+
+// pythonExceptionRe matches the last line of a Python traceback, such as
+// "apsw.ConstraintError: UNIQUE constraint failed: custom_columns.label".
+var pythonExceptionRe = regexp.MustCompile(`^([A-Za-z_][\w.]*): (.*)$`)
+
+// PythonException returns the type and the message of the exception that
+// made calibredb fail, or empty strings when it did not print a traceback.
+func (e *CalibreError) PythonException() (typ, message string) {
+	if !strings.Contains(e.Output, "Traceback (most recent call last):") {
+		return "", ""
+	}
+	m := pythonExceptionRe.FindStringSubmatch(e.Message)
+	if m == nil {
+		// an exception raised without arguments, e.g. KeyboardInterrupt
+		return e.Message, ""
+	}
+	return m[1], m[2]
+}
+
+// logExec logs a calibredb process that ran argv, without the global
+// options, and printed out or failed with err after start.
+func (c *Calibre) logExec(argv []string, start time.Time, out []byte, err error) {
+	if c.logger == nil {
+		return
+	}
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.String("command", argv[0]),
+		slog.String("library", RedactURL(c.LibraryPath)),
+		slog.Duration("duration", time.Since(start)),
+	}
+	var calibreErr *CalibreError
+	switch {
+	case err == nil:
+		attrs = append(attrs, slog.Int("exit_code", 0), slog.Int("output_bytes", len(out)))
+	case errors.As(err, &calibreErr):
+		level = slog.LevelError
+		attrs = append(attrs,
+			slog.Int("exit_code", calibreErr.ExitCode),
+			slog.Int("output_bytes", len(calibreErr.Output)),
+			slog.String("kind", calibreErr.Kind.String()),
+		)
+		if typ, _ := calibreErr.PythonException(); typ != "" {
+			attrs = append(attrs, slog.String("exception", typ))
+		}
+		attrs = append(attrs, slog.String("error", c.redactPassword(calibreErr.Message)))
+	}
+	if c.actor != "" {
+		attrs = append(attrs, slog.String("actor", c.actor))
+	}
+	attrs = append(attrs, slog.Any("argv", redactArgv(argv)))
+	msg := "calibredb ran"
+	if level == slog.LevelError {
+		msg = "calibredb failed"
+	}
+	c.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// redactPassword replaces the password of c in s, in case calibredb echoes
+// it back.
+func (c *Calibre) redactPassword(s string) string {
+	if c.Password == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, c.Password, redacted)
+}
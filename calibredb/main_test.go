@@ -0,0 +1,65 @@
+package calibredb_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/internal/fakecalibredb"
+)
+
+// calibredbPath is the calibredb the tests run. It is CALIBREDB_PATH when set
+// and otherwise the fake calibredb, served by re-executing the test binary.
+var calibredbPath string
+
+func TestMain(m *testing.M) {
+	switch filepath.Base(os.Args[0]) {
+	case "calibredb":
+		os.Exit(fakecalibredb.Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	case argvRecorder:
+		os.Exit(recordArgv(os.Args[1:]))
+	}
+	calibredbPath = os.Getenv("CALIBREDB_PATH")
+	if calibredbPath == "" {
+		dir, err := os.MkdirTemp("", "fakecalibredb")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		calibredbPath, err = linkFakeCalibredb(dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Setenv("CALIBREDB_PATH", calibredbPath)
+		code := m.Run()
+		_ = os.RemoveAll(dir)
+		os.Exit(code)
+	}
+	os.Exit(m.Run())
+}
+
+// linkFakeCalibredb makes the test binary reachable as dir/calibredb so that
+// running it dispatches to the fake.
+func linkFakeCalibredb(dir string) (string, error) {
+	return linkTestBinary(dir, "calibredb")
+}
+
+// linkTestBinary makes the test binary reachable as dir/name; TestMain
+// dispatches on the name it was started under.
+func linkTestBinary(dir, name string) (string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := os.Symlink(self, path); err == nil {
+		return path, nil
+	}
+	data, err := os.ReadFile(self)
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, data, 0o755)
+}
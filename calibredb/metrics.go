@@ -0,0 +1,90 @@
+package calibredb
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/veverkap/calibre-rest/metrics"
+)
+
+// Metrics count the calibredb processes a Calibre runs and how long commands
+// wait for the library lock, see WithMetrics.
+type Metrics struct {
+	invocations *metrics.Counter
+	duration    *metrics.Histogram
+	inFlight    *metrics.Gauge
+	lockWait    *metrics.Histogram
+}
+
+// NewMetrics registers the calibredb metrics in r:
+//
+//   - calibredb_invocations_total{command,exit_code}
+//   - calibredb_invocation_duration_seconds{command,exit_code}
+//   - calibredb_invocations_in_flight{command}
+//   - calibredb_library_lock_wait_seconds{mode}, mode being shared for
+//     commands that change the library and exclusive for Exclusive
+//
+// The exit code is -1 when calibredb did not start. Results served from the
+// cache run no process and are not counted.
+func NewMetrics(r *metrics.Registry) *Metrics {
+	return &Metrics{
+		invocations: r.NewCounter("calibredb_invocations_total", "calibredb processes run, by command and exit code.", "command", "exit_code"),
+		duration:    r.NewHistogram("calibredb_invocation_duration_seconds", "Run time of calibredb processes.", nil, "command", "exit_code"),
+		inFlight:    r.NewGauge("calibredb_invocations_in_flight", "calibredb processes running.", "command"),
+		lockWait:    r.NewHistogram("calibredb_library_lock_wait_seconds", "Time spent waiting for the library lock.", nil, "mode"),
+	}
+}
+
+// WithMetrics records every calibredb process and library lock wait in m.
+// One Metrics may be shared by several Calibre values.
+func WithMetrics(m *Metrics) CalibreOption {
+	return func(c *Calibre) {
+		c.metrics = m
+	}
+}
+
+// observeExec records a calibredb process that ran command and ended with
+// err after start. It is called with the process already running, see
+// startExec.
+func (m *Metrics) observeExec(command string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	code := 0
+	if err != nil {
+		code = -1
+		var calibreErr *CalibreError
+		if errors.As(err, &calibreErr) {
+			code = calibreErr.ExitCode
+		}
+	}
+	exitCode := strconv.Itoa(code)
+	m.inFlight.Add(-1, command)
+	m.invocations.Inc(command, exitCode)
+	m.duration.Observe(time.Since(start).Seconds(), command, exitCode)
+}
+
+// startExec counts a calibredb process running command.
+func (m *Metrics) startExec(command string) {
+	if m != nil {
+		m.inFlight.Add(1, command)
+	}
+}
+
+// lock takes l for reading, or for writing when exclusive, and records how
+// long that took.
+func (m *Metrics) lock(l *sync.RWMutex, exclusive bool) {
+	start := time.Now()
+	mode := "shared"
+	if exclusive {
+		l.Lock()
+		mode = "exclusive"
+	} else {
+		l.RLock()
+	}
+	if m != nil {
+		m.lockWait.Observe(time.Since(start).Seconds(), mode)
+	}
+}
@@ -0,0 +1,68 @@
+package calibredb_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/metrics"
+)
+
+func TestCalibre_Metrics(t *testing.T) {
+	reg := metrics.NewRegistry()
+	c := calibredb.NewCalibre(
+		calibredb.WithLibraryPath(filepath.Join(t.TempDir(), "library")),
+		calibredb.WithCalibreDBLocation(calibredbPath),
+		calibredb.WithMetrics(calibredb.NewMetrics(reg)),
+	)
+
+	for range 2 {
+		if _, err := c.List(calibredb.ListOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.As("alice").SetCustom(calibredb.SetCustomOptions{Column: "genre", Id: "9", Value: "sf"}); err == nil {
+		t.Fatal("SetCustom() of a missing column succeeded")
+	}
+	if err := c.Exclusive(func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	missingReg := metrics.NewRegistry()
+	missing := calibredb.NewCalibre(
+		calibredb.WithCalibreDBLocation(filepath.Join(t.TempDir(), "calibredb")),
+		calibredb.WithMetrics(calibredb.NewMetrics(missingReg)),
+	)
+	if _, err := missing.List(calibredb.ListOptions{}); err == nil {
+		t.Fatal("List() with a missing calibredb succeeded")
+	}
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		`calibredb_invocations_total{command="list",exit_code="0"} 2`,
+		`calibredb_invocation_duration_seconds_count{command="list",exit_code="0"} 2`,
+		`calibredb_invocations_in_flight{command="list"} 0`,
+		`calibredb_library_lock_wait_seconds_count{mode="shared"} 1`,
+		`calibredb_library_lock_wait_seconds_count{mode="exclusive"} 1`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("metrics lack %s:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(out, `calibredb_invocations_total{command="set_custom",exit_code="1"} 1`) {
+		t.Errorf("metrics lack the failed set_custom:\n%s", out)
+	}
+
+	b.Reset()
+	if _, err := missingReg.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	// calibredb did not start
+	if want := `calibredb_invocations_total{command="list",exit_code="-1"} 1`; !strings.Contains(b.String(), want) {
+		t.Errorf("metrics lack %s:\n%s", want, b.String())
+	}
+}
@@ -0,0 +1,96 @@
+package calibredb
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// ErrInvalidOPF is returned for a document that is not an OPF package.
+var ErrInvalidOPF = errors.New("calibredb: invalid OPF")
+
+// ValidateOPF checks that data is a well-formed OPF 2 or 3 document: XML
+// whose root is a package element holding a metadata element.
+func ValidateOPF(data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	root, metadata := false, false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidOPF, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 1 && t.Name.Local != "package":
+				return fmt.Errorf("%w: the root element is %s, not package", ErrInvalidOPF, t.Name.Local)
+			case depth == 1:
+				root = true
+				for _, a := range t.Attr {
+					if a.Name.Local == "version" && !strings.HasPrefix(a.Value, "2") && !strings.HasPrefix(a.Value, "3") {
+						return fmt.Errorf("%w: unsupported version %q", ErrInvalidOPF, a.Value)
+					}
+				}
+			case depth == 2 && t.Name.Local == "metadata":
+				metadata = true
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	switch {
+	case !root:
+		return fmt.Errorf("%w: no package element", ErrInvalidOPF)
+	case !metadata:
+		return fmt.Errorf("%w: no metadata element", ErrInvalidOPF)
+	}
+	return nil
+}
+
+// SetMetadataFromOPF sets the metadata of book id from an OPF document, see
+// ValidateOPF, and returns the book with all its fields afterwards.
+func (c *Calibre) SetMetadataFromOPF(id int, opf []byte) (Book, error) {
+	if err := ValidateOPF(opf); err != nil {
+		return Book{}, err
+	}
+	f, err := os.CreateTemp("", "calibredb-*.opf")
+	if err != nil {
+		return Book{}, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(opf)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return Book{}, err
+	}
+	if _, err := c.SetMetadata(SetMetadataOptions{BookId: strconv.Itoa(id), Path: f.Name()}); err != nil {
+		return Book{}, err
+	}
+	books, err := c.listBooks(ListOptions{Fields: "all", ForMachine: lo.ToPtr(true), Search: "id:" + strconv.Itoa(id)})
+	if err != nil {
+		return Book{}, err
+	}
+	if len(books) == 0 {
+		return Book{}, &CalibreError{Kind: ErrorKindNotFound, ExitCode: -1, Message: fmt.Sprintf("No book with id %d", id)}
+	}
+	return books[0], nil
+}
+
+// OPF returns the metadata of book id as an OPF document.
+func (c *Calibre) OPF(id int) (string, error) {
+	return c.ShowMetadata(ShowMetadataOptions{Id: strconv.Itoa(id), AsOpf: lo.ToPtr(true)})
+}
@@ -0,0 +1,68 @@
+package calibredb_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func TestValidateOPF(t *testing.T) {
+	tests := []struct {
+		name    string
+		opf     string
+		wantErr bool
+	}{
+		{name: "opf 2", opf: `<?xml version="1.0"?><package xmlns="http://www.idpf.org/2007/opf" version="2.0"><metadata><dc:title xmlns:dc="http://purl.org/dc/elements/1.1/">Dune</dc:title></metadata></package>`},
+		{name: "opf 3", opf: `<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="id"><metadata/><manifest/></package>`},
+		{name: "no version", opf: `<package><metadata/></package>`},
+		{name: "not xml", opf: `title: Dune`, wantErr: true},
+		{name: "unclosed", opf: `<package><metadata></package>`, wantErr: true},
+		{name: "other root", opf: `<html><metadata/></html>`, wantErr: true},
+		{name: "no metadata", opf: `<package version="2.0"><manifest/></package>`, wantErr: true},
+		{name: "nested metadata", opf: `<package><manifest><metadata/></manifest></package>`, wantErr: true},
+		{name: "opf 1", opf: `<package version="1.0"><metadata/></package>`, wantErr: true},
+		{name: "empty", opf: ``, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := calibredb.ValidateOPF([]byte(tt.opf))
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("ValidateOPF() = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, calibredb.ErrInvalidOPF) {
+				t.Errorf("ValidateOPF() = %v, want ErrInvalidOPF", err)
+			}
+		})
+	}
+}
+
+func TestCalibre_SetMetadataFromOPF(t *testing.T) {
+	c, _ := newLibrary(t, []testBook{{file: "Dune - Frank Herbert.epub", tags: "sf"}})
+
+	opf, err := c.OPF(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := calibredb.ValidateOPF([]byte(opf)); err != nil {
+		t.Fatalf("OPF() is not valid: %v\n%s", err, opf)
+	}
+	got, err := c.SetMetadataFromOPF(1, []byte(strings.Replace(opf, ">Dune<", ">Dune Messiah<", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 1 || got.Title != "Dune Messiah" || got.Authors != "Frank Herbert" || len(got.Tags) != 1 || got.UUID == "" {
+		t.Errorf("SetMetadataFromOPF() = %+v", got)
+	}
+
+	if _, err := c.SetMetadataFromOPF(1, []byte("<package/>")); !errors.Is(err, calibredb.ErrInvalidOPF) {
+		t.Errorf("SetMetadataFromOPF() of an invalid OPF = %v", err)
+	}
+	if _, err := c.SetMetadataFromOPF(7, []byte(opf)); calibredb.ErrorKindOf(err) != calibredb.ErrorKindNotFound {
+		t.Errorf("SetMetadataFromOPF() of a missing book = %v", err)
+	}
+	if _, err := c.OPF(7); calibredb.ErrorKindOf(err) != calibredb.ErrorKindNotFound {
+		t.Errorf("OPF() of a missing book = %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package calibredb
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// ErrUnknownCommand is returned by Run for a command without a wrapper.
+var ErrUnknownCommand = errors.New("calibredb: unknown command")
+
+// Commands returns the names of the wrapped calibredb commands, sorted.
+func Commands() []string {
+	return slices.Sorted(maps.Keys(commandOptions))
+}
+
+// NewOptions returns a pointer to the zero options struct of command, e.g. an
+// *AddOptions for "add", or nil when the command has no wrapper.
+func NewOptions(command string) any {
+	typ, ok := commandOptions[command]
+	if !ok {
+		return nil
+	}
+	return reflect.New(typ).Interface()
+}
+
+// Run calls the wrapper of command with opts, the command's options struct or
+// a pointer to it, and args, which follow the options like the extra
+// arguments of the wrappers, e.g. the subcommand of saved_searches. It lets
+// callers such as the CLI pick commands by name.
+func (c *Calibre) Run(command string, opts any, args ...string) (string, error) {
+	run, ok := commandRunners[command]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownCommand, command)
+	}
+	v := reflect.ValueOf(opts)
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	want := commandOptions[command]
+	if !v.IsValid() {
+		return "", fmt.Errorf("calibredb: %s needs %s, got nil", command, want)
+	}
+	if v.Type() != want {
+		return "", fmt.Errorf("calibredb: %s needs %s, got %T", command, want, opts)
+	}
+	return run(c, v.Interface(), args...)
+}
+
+// RangeStyleOf returns how command reads the end of a range of book ids, for
+// callers that parse the ids of a command given by name. Commands without a
+// BookIDs field get RangeInclusive, the style of their single ids.
+func RangeStyleOf(command string) RangeStyle {
+	if style, ok := commandRangeStyles[command]; ok {
+		return style
+	}
+	return RangeInclusive
+}
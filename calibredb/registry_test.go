@@ -0,0 +1,73 @@
+package calibredb_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func TestRun(t *testing.T) {
+	commands := calibredb.Commands()
+	if !slices.IsSorted(commands) || !slices.Contains(commands, "list") || !slices.Contains(commands, "add_custom_column") {
+		t.Errorf("Commands() = %v", commands)
+	}
+	for _, name := range commands {
+		if calibredb.NewOptions(name) == nil {
+			t.Errorf("NewOptions(%q) = nil", name)
+		}
+	}
+	if calibredb.NewOptions("frobnicate") != nil {
+		t.Error("NewOptions() of an unknown command is not nil")
+	}
+
+	c, recorded := newArgvRecorder(t)
+	opts := calibredb.NewOptions("search").(*calibredb.SearchOptions)
+	opts.Expression = "title:dune"
+	if _, err := c.Run("search", opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Run("search", *opts); err != nil {
+		t.Fatal(err)
+	}
+	want := `["search","title:dune","--with-library=/library"]`
+	if got := recorded(); len(got) != 2 || got[0] != want || got[1] != want {
+		t.Errorf("recorded %q, want %s twice", got, want)
+	}
+	// extra arguments follow the options, like those of the wrappers
+	if _, err := c.Run("saved_searches", calibredb.SavedSearchesOptions{}, "add", "sf", "tags:sf"); err != nil {
+		t.Fatal(err)
+	}
+	want = `["saved_searches","add","sf","tags:sf","--with-library=/library"]`
+	if got := recorded(); len(got) != 1 || got[0] != want {
+		t.Errorf("recorded %q, want %s", got, want)
+	}
+
+	if _, err := c.Run("frobnicate", opts); !errors.Is(err, calibredb.ErrUnknownCommand) {
+		t.Errorf("Run(unknown) error = %v", err)
+	}
+	if _, err := c.Run("list", opts); err == nil {
+		t.Error("Run() with the options of another command succeeded")
+	}
+	if _, err := c.Run("list", nil); err == nil {
+		t.Error("Run() without options succeeded")
+	}
+	if _, err := c.Run("list", (*calibredb.ListOptions)(nil)); err == nil {
+		t.Error("Run() with a nil pointer succeeded")
+	}
+}
+
+func TestRangeStyleOf(t *testing.T) {
+	for command, want := range map[string]calibredb.RangeStyle{
+		"remove":         calibredb.RangeExclusive,
+		"export":         calibredb.RangeExclusive,
+		"catalog":        calibredb.RangeExclusive,
+		"embed_metadata": calibredb.RangeInclusive,
+		"list":           calibredb.RangeInclusive,
+	} {
+		if got := calibredb.RangeStyleOf(command); got != want {
+			t.Errorf("RangeStyleOf(%q) = %v, want %v", command, got, want)
+		}
+	}
+}
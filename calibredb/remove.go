@@ -10,10 +10,10 @@ package calibredb
 
 type RemoveOptions struct {
 	// Command Line Arguments
-	Ids []string `validate:"required"`
+	Ids BookIDs `validate:"required,bookids"`
 
 	// Command Line Options
-	Permanent *bool // Do not use the Recycle Bin
+	Permanent *bool `flag:"--permanent"` // Do not use the Recycle Bin
 }
 
 func (c *Calibre) RemoveHelp() string {
@@ -32,14 +32,13 @@ func (c *Calibre) Remove(opts RemoveOptions, args ...string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	// Command Line Arguments
-	argv = append(argv, opts.Ids...)
 
 	// Command Line Options
 	// Handling bool
 	if opts.Permanent != nil && *opts.Permanent {
 		argv = append(argv, "--permanent")
 	}
-	out, err := c.run(argv...)
-	return out, err
+	argv = append(argv, args...)
+	// Handling book ids
+	return c.runBookIDs(argv, opts.Ids, RangeExclusive, "")
 }
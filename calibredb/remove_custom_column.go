@@ -14,7 +14,7 @@ type RemoveCustomColumnOptions struct {
 	Label string `validate:"required"`
 
 	// Command Line Options
-	Force *bool // Do not ask for confirmation
+	Force *bool `flag:"--force"` // Do not ask for confirmation
 }
 
 func (c *Calibre) RemoveCustomColumnHelp() string {
@@ -41,6 +41,7 @@ func (c *Calibre) RemoveCustomColumn(opts RemoveCustomColumnOptions, args ...str
 	if opts.Force != nil && *opts.Force {
 		argv = append(argv, "--force")
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
@@ -10,7 +10,7 @@ package calibredb
 
 type RemoveFormatOptions struct {
 	// Command Line Arguments
-	Id  string `validate:"required"`
+	Id  string `validate:"required,bookid"`
 	Fmt string `validate:"required"`
 }
 
@@ -33,6 +33,7 @@ func (c *Calibre) RemoveFormat(opts RemoveFormatOptions, args ...string) (string
 	// Command Line Arguments
 	argv = append(argv, opts.Id)
 	argv = append(argv, opts.Fmt)
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
@@ -13,7 +13,7 @@ func TestCalibre_RemoveHelp(t *testing.T) {
 	defer func() { _ = os.RemoveAll(tempDir) }()
 	c := calibredb.NewCalibre(
 		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+		calibredb.WithCalibreDBLocation(calibredbPath),
 	)
 
 	got := c.RemoveHelp()
@@ -35,49 +35,49 @@ func TestCalibre_Remove(t *testing.T) {
 		{
 			name: "Missing required Ids field",
 			opts: calibredb.RemoveOptions{
-				Ids: []string{},
+				Ids: calibredb.BookIDs{},
 			},
 			wantErr: true,
 		},
 		{
 			name: "Valid with single ID",
 			opts: calibredb.RemoveOptions{
-				Ids: []string{"1"},
+				Ids: mustParseBookIDs("1", calibredb.RangeExclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "Valid with multiple IDs",
 			opts: calibredb.RemoveOptions{
-				Ids: []string{"1", "2", "3"},
+				Ids: mustParseBookIDs("1,2,3", calibredb.RangeExclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "Valid with ID range",
 			opts: calibredb.RemoveOptions{
-				Ids: []string{"1-10"},
+				Ids: mustParseBookIDs("1-10", calibredb.RangeExclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "Valid with mixed IDs and ranges",
 			opts: calibredb.RemoveOptions{
-				Ids: []string{"1", "5-10", "15"},
+				Ids: mustParseBookIDs("1,5-10,15", calibredb.RangeExclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "Valid with comma separated IDs",
 			opts: calibredb.RemoveOptions{
-				Ids: []string{"23,34,57-85"},
+				Ids: mustParseBookIDs("23,34,57-85", calibredb.RangeExclusive),
 			},
 			wantErr: false,
 		},
 		{
 			name: "Valid with Permanent true",
 			opts: calibredb.RemoveOptions{
-				Ids:       []string{"1"},
+				Ids:       mustParseBookIDs("1", calibredb.RangeExclusive),
 				Permanent: func(b bool) *bool { return &b }(true),
 			},
 			wantErr: false,
@@ -85,7 +85,7 @@ func TestCalibre_Remove(t *testing.T) {
 		{
 			name: "Valid with Permanent false",
 			opts: calibredb.RemoveOptions{
-				Ids:       []string{"1"},
+				Ids:       mustParseBookIDs("1", calibredb.RangeExclusive),
 				Permanent: func(b bool) *bool { return &b }(false),
 			},
 			wantErr: false,
@@ -93,7 +93,7 @@ func TestCalibre_Remove(t *testing.T) {
 		{
 			name: "Valid with Permanent nil (default)",
 			opts: calibredb.RemoveOptions{
-				Ids:       []string{"1"},
+				Ids:       mustParseBookIDs("1", calibredb.RangeExclusive),
 				Permanent: nil,
 			},
 			wantErr: false,
@@ -101,7 +101,7 @@ func TestCalibre_Remove(t *testing.T) {
 		{
 			name: "Valid with multiple IDs and Permanent true",
 			opts: calibredb.RemoveOptions{
-				Ids:       []string{"1", "2", "3", "4", "5"},
+				Ids:       mustParseBookIDs("1,2,3,4,5", calibredb.RangeExclusive),
 				Permanent: func(b bool) *bool { return &b }(true),
 			},
 			wantErr: false,
@@ -109,7 +109,7 @@ func TestCalibre_Remove(t *testing.T) {
 		{
 			name: "Valid with range and Permanent true",
 			opts: calibredb.RemoveOptions{
-				Ids:       []string{"10-20"},
+				Ids:       mustParseBookIDs("10-20", calibredb.RangeExclusive),
 				Permanent: func(b bool) *bool { return &b }(true),
 			},
 			wantErr: false,
@@ -117,7 +117,7 @@ func TestCalibre_Remove(t *testing.T) {
 		{
 			name: "Valid with mixed format and Permanent true",
 			opts: calibredb.RemoveOptions{
-				Ids:       []string{"1,2,3", "10-15", "20"},
+				Ids:       mustParseBookIDs("1,2,3,10-15,20", calibredb.RangeExclusive),
 				Permanent: func(b bool) *bool { return &b }(true),
 			},
 			wantErr: false,
@@ -125,7 +125,7 @@ func TestCalibre_Remove(t *testing.T) {
 		{
 			name: "Valid with all options combined",
 			opts: calibredb.RemoveOptions{
-				Ids:       []string{"1", "5", "10-20", "25,26,27"},
+				Ids:       mustParseBookIDs("1,5,10-20,25,26,27", calibredb.RangeExclusive),
 				Permanent: func(b bool) *bool { return &b }(true),
 			},
 			wantErr: false,
@@ -138,7 +138,7 @@ func TestCalibre_Remove(t *testing.T) {
 			defer func() { _ = os.RemoveAll(tempDir) }()
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			got, gotErr := c.Remove(tt.opts, tt.args...)
@@ -13,7 +13,7 @@ package calibredb
 type RestoreDatabaseOptions struct {
 
 	// Command Line Options
-	ReallyDoIt *bool // Really do the recovery. The command will not run unless this option is specified.
+	ReallyDoIt *bool `flag:"--really-do-it"` // Really do the recovery. The command will not run unless this option is specified.
 }
 
 func (c *Calibre) RestoreDatabaseHelp() string {
@@ -38,6 +38,7 @@ func (c *Calibre) RestoreDatabase(opts RestoreDatabaseOptions, args ...string) (
 	if opts.ReallyDoIt != nil && *opts.ReallyDoIt {
 		argv = append(argv, "--really-do-it")
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
@@ -13,7 +13,7 @@ func TestCalibre_RestoreDatabaseHelp(t *testing.T) {
 	defer func() { _ = os.RemoveAll(tempDir) }()
 	c := calibredb.NewCalibre(
 		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+		calibredb.WithCalibreDBLocation(calibredbPath),
 	)
 
 	// Call RestoreDatabaseHelp - this will fail if calibredb is not installed
@@ -36,7 +36,7 @@ func TestCalibre_RestoreDatabase(t *testing.T) {
 		{
 			name:    "No options - ReallyDoIt not set",
 			opts:    calibredb.RestoreDatabaseOptions{},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name: "ReallyDoIt set to true",
@@ -50,7 +50,7 @@ func TestCalibre_RestoreDatabase(t *testing.T) {
 			opts: calibredb.RestoreDatabaseOptions{
 				ReallyDoIt: func(b bool) *bool { return &b }(false),
 			},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name: "ReallyDoIt true with additional args",
@@ -67,7 +67,7 @@ func TestCalibre_RestoreDatabase(t *testing.T) {
 			defer func() { _ = os.RemoveAll(tempDir) }()
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			got, gotErr := c.RestoreDatabase(tt.opts, tt.args...)
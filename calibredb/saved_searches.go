@@ -29,6 +29,7 @@ func (c *Calibre) SavedSearches(opts SavedSearchesOptions, args ...string) (stri
 	if err != nil {
 		return "", err
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
@@ -13,7 +13,7 @@ func TestCalibre_SavedSearchesHelp(t *testing.T) {
 	defer func() { _ = os.RemoveAll(tempDir) }()
 	c := calibredb.NewCalibre(
 		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+		calibredb.WithCalibreDBLocation(calibredbPath),
 	)
 
 	got := c.SavedSearchesHelp()
@@ -36,7 +36,7 @@ func TestCalibre_SavedSearches(t *testing.T) {
 			name:    "Empty options with no args",
 			opts:    calibredb.SavedSearchesOptions{},
 			args:    []string{},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name:    "Empty options with list command",
@@ -48,13 +48,13 @@ func TestCalibre_SavedSearches(t *testing.T) {
 			name:    "Empty options with add command",
 			opts:    calibredb.SavedSearchesOptions{},
 			args:    []string{"add"},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name:    "Empty options with remove command",
 			opts:    calibredb.SavedSearchesOptions{},
 			args:    []string{"remove"},
-			wantErr: false,
+			wantErr: true,
 		},
 		{
 			name:    "Empty options with add and search name",
@@ -82,7 +82,7 @@ func TestCalibre_SavedSearches(t *testing.T) {
 			defer func() { _ = os.RemoveAll(tempDir) }()
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			got, gotErr := c.SavedSearches(tt.opts, tt.args...)
@@ -122,7 +122,7 @@ func TestCalibre_SavedSearches_ValidationSuccess(t *testing.T) {
 
 	c := calibredb.NewCalibre(
 		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+		calibredb.WithCalibreDBLocation(calibredbPath),
 	)
 
 	opts := calibredb.SavedSearchesOptions{}
@@ -332,7 +332,7 @@ func TestCalibre_SavedSearches_MultipleCommands(t *testing.T) {
 
 			c := calibredb.NewCalibre(
 				calibredb.WithLibraryPath(tempDir),
-				calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
+				calibredb.WithCalibreDBLocation(calibredbPath),
 			)
 
 			opts := calibredb.SavedSearchesOptions{}
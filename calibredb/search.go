@@ -16,11 +16,10 @@ import (
 
 type SearchOptions struct {
 	// Command Line Arguments
-	Search     string `validate:"required"`
 	Expression string `validate:"required"`
 
 	// Command Line Options
-	Limit int // The maximum number of results to return. Default is all results.
+	Limit int `flag:"--limit" validate:"gte=0"` // The maximum number of results to return. Default is all results.
 }
 
 func (c *Calibre) SearchHelp() string {
@@ -40,7 +39,6 @@ func (c *Calibre) Search(opts SearchOptions, args ...string) (string, error) {
 		return "", err
 	}
 	// Command Line Arguments
-	argv = append(argv, opts.Search)
 	argv = append(argv, opts.Expression)
 
 	// Command Line Options
@@ -48,6 +46,7 @@ func (c *Calibre) Search(opts SearchOptions, args ...string) (string, error) {
 	if opts.Limit != 0 {
 		argv = append(argv, "--limit", fmt.Sprint(opts.Limit))
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
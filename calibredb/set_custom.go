@@ -14,11 +14,11 @@ package calibredb
 type SetCustomOptions struct {
 	// Command Line Arguments
 	Column string `validate:"required"`
-	Id     string `validate:"required"`
-	Value  string `validate:"required"`
+	Id     string `validate:"required,bookid"`
+	Value  string `validate:"omitempty"`
 
 	// Command Line Options
-	Append *bool // If the column stores multiple values, append the specified values to the existing ones, instead of replacing them.
+	Append *bool `flag:"--append"` // If the column stores multiple values, append the specified values to the existing ones, instead of replacing them.
 }
 
 func (c *Calibre) SetCustomHelp() string {
@@ -47,6 +47,7 @@ func (c *Calibre) SetCustom(opts SetCustomOptions, args ...string) (string, erro
 	if opts.Append != nil && *opts.Append {
 		argv = append(argv, "--append")
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
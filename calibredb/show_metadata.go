@@ -11,10 +11,10 @@ package calibredb
 
 type ShowMetadataOptions struct {
 	// Command Line Arguments
-	Id string `validate:"required"`
+	Id string `validate:"required,bookid"`
 
 	// Command Line Options
-	AsOpf *bool // Print metadata in OPF form (XML)
+	AsOpf *bool `flag:"--as-opf"` // Print metadata in OPF form (XML)
 }
 
 func (c *Calibre) ShowMetadataHelp() string {
@@ -41,6 +41,7 @@ func (c *Calibre) ShowMetadata(opts ShowMetadataOptions, args ...string) (string
 	if opts.AsOpf != nil && *opts.AsOpf {
 		argv = append(argv, "--as-opf")
 	}
+	argv = append(argv, args...)
 	out, err := c.run(argv...)
 	return out, err
 }
@@ -0,0 +1,383 @@
+package calibredb
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// SyncIdentifier is the identifier type SyncTo gives the books it adds, with
+// the uuid of the source book as the value. Calibre gives every added book a
+// new uuid, so without it later syncs could only match books by their other
+// identifiers.
+const SyncIdentifier = "calibre-uuid"
+
+// ConflictPolicy says what SyncTo does with a book that was changed in the
+// target after it was last changed in the source.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip leaves the target book alone and reports the conflict.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the metadata of the target book with that of
+	// the source book.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+)
+
+// SyncOptions configures a one-way sync, see SyncTo.
+type SyncOptions struct {
+	// Search limits the source books synced, all when empty.
+	Search string `json:"search,omitempty"`
+	// Conflict is the conflict policy, ConflictSkip when empty.
+	Conflict ConflictPolicy `json:"conflict,omitempty" validate:"omitempty,oneof=skip overwrite"`
+	// RemoveExtra moves the target books that match no source book to the
+	// recycle bin, making the target a mirror of the selected source books.
+	RemoveExtra bool `json:"remove_extra,omitempty"`
+	// DryRun computes the diff without changing the target.
+	DryRun bool `json:"dry_run,omitempty"`
+	// Progress is called after each change with the number of changes done
+	// and the number to do.
+	Progress func(done, total int) `json:"-"`
+}
+
+// SyncItem is a book in the diff of two libraries.
+type SyncItem struct {
+	// SourceID is zero for extra books, TargetID for missing ones.
+	SourceID int    `json:"source_id,omitempty"`
+	TargetID int    `json:"target_id,omitempty"`
+	Title    string `json:"title"`
+	// MatchedBy is how the books were matched: uuid or identifier:<type>.
+	MatchedBy string `json:"matched_by,omitempty"`
+	// Fields are the metadata fields that differ, for changed books and
+	// conflicts.
+	Fields []string `json:"fields,omitempty"`
+	// Applied is set when the change was made in the target.
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+// SyncResult is the diff of two libraries and what a sync did with it.
+type SyncResult struct {
+	DryRun bool `json:"dry_run"`
+	// Missing are the source books the target lacks; they are added.
+	Missing []SyncItem `json:"missing"`
+	// Changed are the books whose metadata differs and was changed last in
+	// the source; the target books are updated.
+	Changed []SyncItem `json:"changed"`
+	// Conflicts are the books whose metadata differs and was changed last in
+	// the target; they are handled by the conflict policy.
+	Conflicts []SyncItem `json:"conflicts"`
+	// Extra are the target books that match no source book; they are only
+	// removed with RemoveExtra.
+	Extra []SyncItem `json:"extra"`
+	// Unchanged counts the matched books with the same metadata.
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+}
+
+// syncFields are the fields listed from both libraries. The ones after
+// last_modified are compared to tell whether a book changed.
+var syncFields = []string{
+	"uuid", "identifiers", "last_modified",
+	"title", "authors", "tags", "series", "series_index", "publisher", "languages", "comments", "rating",
+}
+
+// Diff compares the books of c matching opts.Search with all books of
+// target, see SyncTo. It changes nothing.
+func (c *Calibre) Diff(target *Calibre, opts SyncOptions) (SyncResult, error) {
+	opts.DryRun = true
+	return c.SyncTo(target, opts)
+}
+
+// SyncTo makes target follow c, one way. Books are matched by uuid, which
+// includes the SyncIdentifier of books added by an earlier sync, and then by
+// any identifier such as the ISBN. Missing books are exported from c and
+// added to target with set_metadata from the exported OPF. Matched books
+// whose metadata differs are updated from the OPF of the source book when
+// the source changed last, by last_modified; otherwise the conflict policy
+// applies. Both libraries may be local folders or Content server URLs.
+//
+// A book that fails does not stop the others; it counts as Failed and its
+// item has the error. The error is only set when the books could not be
+// listed.
+func (c *Calibre) SyncTo(target *Calibre, opts SyncOptions) (SyncResult, error) {
+	if err := c.validate.Struct(opts); err != nil {
+		return SyncResult{}, err
+	}
+	fields := strings.Join(syncFields, ",")
+	sources, err := c.listBooks(ListOptions{Fields: fields, ForMachine: lo.ToPtr(true), Search: opts.Search})
+	if err != nil {
+		return SyncResult{}, err
+	}
+	targets, err := target.listBooks(ListOptions{Fields: fields, ForMachine: lo.ToPtr(true)})
+	if err != nil {
+		return SyncResult{}, err
+	}
+	result, pairs := diffBooks(sources, targets)
+	result.DryRun = opts.DryRun
+	if opts.DryRun {
+		return result, nil
+	}
+
+	s := syncer{source: c, target: target, pairs: pairs}
+	total := len(result.Missing) + len(result.Changed)
+	if opts.Conflict == ConflictOverwrite {
+		total += len(result.Conflicts)
+	}
+	if opts.RemoveExtra {
+		total += len(result.Extra)
+	}
+	done := 0
+	apply := func(items []SyncItem, fn func(*SyncItem) error) {
+		for i := range items {
+			if err := fn(&items[i]); err != nil {
+				items[i].Error = err.Error()
+				result.Failed++
+			} else {
+				items[i].Applied = true
+			}
+			done++
+			if opts.Progress != nil {
+				opts.Progress(done, total)
+			}
+		}
+	}
+	apply(result.Missing, s.add)
+	apply(result.Changed, s.update)
+	if opts.Conflict == ConflictOverwrite {
+		apply(result.Conflicts, s.update)
+	}
+	if opts.RemoveExtra {
+		apply(result.Extra, s.remove)
+	}
+	return result, nil
+}
+
+// diffBooks matches the source books with the target books and sorts them
+// into the parts of the result. pairs maps the source ids to their books.
+func diffBooks(sources, targets []Book) (SyncResult, map[int]Book) {
+	sources, targets = slices.Clone(sources), slices.Clone(targets)
+	slices.SortFunc(sources, func(a, b Book) int { return a.ID - b.ID })
+	slices.SortFunc(targets, func(a, b Book) int { return a.ID - b.ID })
+	byUUID := map[string]int{}
+	byIdentifier := map[string]int{}
+	for i, b := range targets {
+		for _, uuid := range []string{b.UUID, b.Identifiers[SyncIdentifier]} {
+			if _, ok := byUUID[uuid]; uuid != "" && !ok {
+				byUUID[uuid] = i
+			}
+		}
+		for typ, value := range syncIdentifiers(b) {
+			if _, ok := byIdentifier[typ+":"+value]; !ok {
+				byIdentifier[typ+":"+value] = i
+			}
+		}
+	}
+
+	result := SyncResult{Missing: []SyncItem{}, Changed: []SyncItem{}, Conflicts: []SyncItem{}, Extra: []SyncItem{}}
+	pairs := map[int]Book{}
+	claimed := map[int]bool{}
+	for _, src := range sources {
+		pairs[src.ID] = src
+		match, by := -1, ""
+		if i, ok := byUUID[src.UUID]; ok && src.UUID != "" && !claimed[i] {
+			match, by = i, "uuid"
+		} else {
+			ids := syncIdentifiers(src)
+			for _, typ := range slices.Sorted(maps.Keys(ids)) {
+				if i, ok := byIdentifier[typ+":"+ids[typ]]; ok && !claimed[i] {
+					match, by = i, "identifier:"+typ
+					break
+				}
+			}
+		}
+		if match < 0 {
+			result.Missing = append(result.Missing, SyncItem{SourceID: src.ID, Title: src.Title})
+			continue
+		}
+		claimed[match] = true
+		dst := targets[match]
+		item := SyncItem{SourceID: src.ID, TargetID: dst.ID, Title: src.Title, MatchedBy: by, Fields: changedFields(src, dst)}
+		switch {
+		case len(item.Fields) == 0:
+			result.Unchanged++
+		case src.LastModified.After(dst.LastModified):
+			result.Changed = append(result.Changed, item)
+		default:
+			result.Conflicts = append(result.Conflicts, item)
+		}
+	}
+	for i, dst := range targets {
+		if !claimed[i] {
+			result.Extra = append(result.Extra, SyncItem{TargetID: dst.ID, Title: dst.Title})
+		}
+	}
+	return result, pairs
+}
+
+// syncIdentifiers returns the identifiers of b that match books across
+// libraries, normalized as for FindDuplicates.
+func syncIdentifiers(b Book) map[string]string {
+	ids := map[string]string{}
+	for typ, value := range b.Identifiers {
+		typ = strings.ToLower(typ)
+		if typ == SyncIdentifier {
+			continue
+		}
+		if value = normalizeIdentifier(typ, value); value != "" {
+			ids[typ] = value
+		}
+	}
+	return ids
+}
+
+// changedFields lists the compared fields that differ between the books.
+func changedFields(src, dst Book) []string {
+	var fields []string
+	differ := func(name string, a, b any) {
+		if fmt.Sprint(a) != fmt.Sprint(b) {
+			fields = append(fields, name)
+		}
+	}
+	differ("title", src.Title, dst.Title)
+	differ("authors", src.Authors, dst.Authors)
+	differ("tags", nonNil(src.Tags), nonNil(dst.Tags))
+	differ("series", src.Series, dst.Series)
+	if src.Series != "" {
+		differ("series_index", src.SeriesIndex, dst.SeriesIndex)
+	}
+	differ("publisher", src.Publisher, dst.Publisher)
+	differ("languages", nonNil(src.Languages), nonNil(dst.Languages))
+	differ("comments", src.Comments, dst.Comments)
+	differ("rating", src.Rating, dst.Rating)
+	differ("identifiers", syncIdentifiers(src), syncIdentifiers(dst))
+	return fields
+}
+
+// syncer applies the items of a sync.
+type syncer struct {
+	source, target *Calibre
+	pairs          map[int]Book
+}
+
+// add exports the source book and adds it to the target.
+func (s syncer) add(item *SyncItem) error {
+	dir, err := os.MkdirTemp("", "calibredb-sync-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	if _, err := s.source.Export(ExportOptions{Ids: NewBookIDs(item.SourceID), SingleDir: lo.ToPtr(true), ToDir: dir}); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var opf, cover string
+	var formats []string
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".opf":
+			opf = path
+		case ".jpg":
+			cover = path
+		default:
+			formats = append(formats, path)
+		}
+	}
+	if opf == "" {
+		return fmt.Errorf("export of book %d wrote no OPF", item.SourceID)
+	}
+
+	src := s.pairs[item.SourceID]
+	var out string
+	if len(formats) == 0 {
+		out, err = s.target.Add(AddOptions{Empty: lo.ToPtr(true), Title: src.Title, Authors: src.Authors})
+	} else {
+		out, err = s.target.Add(AddOptions{Files: formats[:1], Cover: cover})
+	}
+	if err != nil {
+		return fmt.Errorf("add: %w", err)
+	}
+	added := AddedBookIDs(out)
+	if added.IsEmpty() {
+		return fmt.Errorf("the target has a book with the same title and authors; give both the same identifier to sync them")
+	}
+	item.TargetID = added.IDs()[0]
+	id := strconv.Itoa(item.TargetID)
+	for _, f := range formats[min(1, len(formats)):] {
+		if _, err := s.target.AddFormat(AddFormatOptions{Id: id, EbookFile: f}); err != nil {
+			return fmt.Errorf("add_format: %w", err)
+		}
+	}
+	return s.setMetadata(item.TargetID, opf, src)
+}
+
+// update sets the metadata of the target book from the OPF of the source
+// book.
+func (s syncer) update(item *SyncItem) error {
+	out, err := s.source.ShowMetadata(ShowMetadataOptions{Id: strconv.Itoa(item.SourceID), AsOpf: lo.ToPtr(true)})
+	if err != nil {
+		return fmt.Errorf("show_metadata: %w", err)
+	}
+	f, err := os.CreateTemp("", "calibredb-sync-*.opf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(out)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return s.setMetadata(item.TargetID, f.Name(), s.pairs[item.SourceID])
+}
+
+// setMetadata applies the OPF of src to the target book. Identifiers are set
+// explicitly to those of src plus the SyncIdentifier, and the fields that
+// are empty in src are cleared since an OPF cannot express an empty field.
+func (s syncer) setMetadata(id int, opf string, src Book) error {
+	ids := []string{SyncIdentifier + ":" + src.UUID}
+	for _, typ := range slices.Sorted(maps.Keys(src.Identifiers)) {
+		if !strings.EqualFold(typ, SyncIdentifier) {
+			ids = append(ids, typ+":"+src.Identifiers[typ])
+		}
+	}
+	fields := []string{"identifiers:" + strings.Join(ids, ",")}
+	for _, f := range []struct {
+		name  string
+		empty bool
+	}{
+		{"tags", len(src.Tags) == 0},
+		{"series", src.Series == ""},
+		{"publisher", src.Publisher == ""},
+		{"languages", len(src.Languages) == 0},
+		{"comments", src.Comments == ""},
+		{"rating", src.Rating == 0},
+	} {
+		if f.empty {
+			fields = append(fields, f.name+":")
+		}
+	}
+	_, err := s.target.SetMetadata(SetMetadataOptions{BookId: strconv.Itoa(id), Path: opf, Field: fields})
+	if err != nil {
+		return fmt.Errorf("set_metadata: %w", err)
+	}
+	return nil
+}
+
+// remove moves an extra book of the target to the recycle bin.
+func (s syncer) remove(item *SyncItem) error {
+	_, err := s.target.Remove(RemoveOptions{Ids: NewBookIDs(item.TargetID)})
+	return err
+}
@@ -0,0 +1,130 @@
+package calibredb_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func syncIDs(items []calibredb.SyncItem) [][2]int {
+	ids := [][2]int{}
+	for _, item := range items {
+		ids = append(ids, [2]int{item.SourceID, item.TargetID})
+	}
+	return ids
+}
+
+func TestCalibre_SyncTo(t *testing.T) {
+	source, _ := newLibrary(t, []testBook{
+		{"Dune - Frank Herbert.epub", "travel", "9780441172719"},
+		{"Emma - Jane Austen.epub", "travel", ""},
+		{"Anathem - Neal Stephenson.epub", "", ""},
+	})
+	target, _ := newLibrary(t, []testBook{
+		{"Dune - Frank Herbert.epub", "", "978-0-441-17271-9"},
+		{"Old - Someone.txt", "", ""},
+	})
+	opts := calibredb.SyncOptions{Search: "tags:travel", RemoveExtra: true}
+
+	diff, err := source.Diff(target, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.DryRun || !slices.Equal(syncIDs(diff.Missing), [][2]int{{2, 0}}) || len(diff.Changed) != 0 ||
+		!slices.Equal(syncIDs(diff.Conflicts), [][2]int{{1, 1}}) || !slices.Equal(syncIDs(diff.Extra), [][2]int{{0, 2}}) {
+		t.Fatalf("Diff() = %+v", diff)
+	}
+	// the target Dune was added after the source one
+	if dune := diff.Conflicts[0]; dune.MatchedBy != "identifier:isbn" || !slices.Equal(dune.Fields, []string{"tags"}) {
+		t.Errorf("conflict = %+v", dune)
+	}
+
+	result, err := source.SyncTo(target, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed != 0 || !result.Missing[0].Applied || result.Missing[0].TargetID != 3 || result.Conflicts[0].Applied || !result.Extra[0].Applied {
+		t.Fatalf("SyncTo() = %+v", result)
+	}
+	page, err := target.ListBooks(calibredb.ListBooksOptions{Fields: []string{"title", "tags", "identifiers"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || page.Books[1].Title != "Emma" || !slices.Equal(page.Books[1].Tags, []string{"travel"}) || page.Books[1].Identifiers[calibredb.SyncIdentifier] == "" {
+		t.Errorf("target books = %+v", page.Books)
+	}
+
+	// changed in the source after the sync: Emma is matched by its uuid and
+	// updated, and Dune is overwritten by the policy
+	time.Sleep(time.Second)
+	if _, err := source.SetMetadata(calibredb.SetMetadataOptions{BookId: "2", Path: writeOPF(t, "Emma"), Field: []string{"series:Classics"}}); err != nil {
+		t.Fatal(err)
+	}
+	var progress []int
+	opts.Conflict = calibredb.ConflictOverwrite
+	opts.Progress = func(done, total int) { progress = append(progress, done, total) }
+	result, err = source.SyncTo(target, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed != 0 || !slices.Equal(syncIDs(result.Changed), [][2]int{{2, 3}}) || result.Changed[0].MatchedBy != "uuid" ||
+		!slices.Equal(result.Changed[0].Fields, []string{"series"}) || !result.Conflicts[0].Applied {
+		t.Fatalf("second SyncTo() = %+v", result)
+	}
+	if !slices.Equal(progress, []int{1, 2, 2, 2}) {
+		t.Errorf("progress = %v", progress)
+	}
+
+	diff, err = source.Diff(target, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Unchanged != 2 || len(diff.Missing)+len(diff.Changed)+len(diff.Conflicts)+len(diff.Extra) != 0 {
+		t.Errorf("Diff() after the syncs = %+v", diff)
+	}
+
+	if _, err := source.SyncTo(target, calibredb.SyncOptions{Conflict: "merge"}); err == nil {
+		t.Error("SyncTo() with an unknown conflict policy succeeded")
+	}
+}
+
+func TestCalibre_SyncTo_ClearsRating(t *testing.T) {
+	// the target Dune has a rating the source one lacks, and the source one
+	// was changed last
+	target, _ := newLibrary(t, []testBook{{"Dune - Frank Herbert.epub", "", "9780441172719"}})
+	if _, err := target.SetMetadata(calibredb.SetMetadataOptions{BookId: "1", Field: []string{"rating:4"}}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+	source, _ := newLibrary(t, []testBook{{"Dune - Frank Herbert.epub", "", "9780441172719"}})
+
+	result, err := source.SyncTo(target, calibredb.SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed != 0 || len(result.Changed) != 1 || !slices.Equal(result.Changed[0].Fields, []string{"rating"}) || !result.Changed[0].Applied {
+		t.Fatalf("SyncTo() = %+v", result)
+	}
+	diff, err := source.Diff(target, calibredb.SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.Unchanged != 1 || len(diff.Changed)+len(diff.Conflicts) != 0 {
+		t.Errorf("Diff() after the sync = %+v", diff)
+	}
+}
+
+// writeOPF writes a minimal OPF that sets the title.
+func writeOPF(t *testing.T, title string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "metadata.opf")
+	opf := `<?xml version="1.0"?><package xmlns="http://www.idpf.org/2007/opf" version="2.0"><metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>` + title + `</dc:title></metadata></package>`
+	if err := os.WriteFile(path, []byte(opf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
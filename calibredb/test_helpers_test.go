@@ -1,16 +1,17 @@
 package calibredb_test
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
+	"testing"
 
 	"github.com/veverkap/calibre-rest/calibredb"
 )
 
 func getTestCalibre(name string) (*calibredb.Calibre, func()) {
-	path := os.Getenv("CALIBREDB_PATH")
-	if path == "" {
-		path = "/Applications/calibre.app/Contents/MacOS/calibredb"
-	}
+	path := calibredbPath
 	tempDir := os.TempDir() + "/" + name
 
 	c := calibredb.NewCalibre(
@@ -21,3 +22,75 @@ func getTestCalibre(name string) (*calibredb.Calibre, func()) {
 		_ = os.RemoveAll(tempDir)
 	}
 }
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func mustParseBookIDs(s string, style calibredb.RangeStyle) calibredb.BookIDs {
+	ids, err := calibredb.ParseBookIDs(s, style)
+	if err != nil {
+		panic(err)
+	}
+	return ids
+}
+
+// newStubCalibre returns a Calibre whose calibredb is a shell script that
+// records each invocation in calls.log inside the library and prints its
+// arguments.
+func newStubCalibre(t *testing.T, opts ...calibredb.CalibreOption) (*calibredb.Calibre, string) {
+	t.Helper()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "calibredb")
+	body := "#!/bin/sh\necho \"$@\" >> \"" + filepath.Join(dir, "calls.log") + "\"\necho \"$@\"\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	opts = append([]calibredb.CalibreOption{
+		calibredb.WithLibraryPath(dir),
+		calibredb.WithCalibreDBLocation(script),
+	}, opts...)
+	return calibredb.NewCalibre(opts...), dir
+}
+
+// testBook is a book for newLibrary: the file name gives calibredb the title
+// and the author, and the tags and the ISBN are set when it is added.
+type testBook struct {
+	file, tags, isbn string
+}
+
+// newLibrary returns a Calibre for a new library run by the fake calibredb,
+// with the books added in order so that the first one has id 1, and the
+// temporary directory that holds the library and the book files.
+func newLibrary(t *testing.T, books []testBook, opts ...calibredb.CalibreOption) (*calibredb.Calibre, string) {
+	t.Helper()
+	dir := t.TempDir()
+	opts = append([]calibredb.CalibreOption{
+		calibredb.WithLibraryPath(filepath.Join(dir, "library")),
+		calibredb.WithCalibreDBLocation(calibredbPath),
+	}, opts...)
+	c := calibredb.NewCalibre(opts...)
+	for _, b := range books {
+		path := filepath.Join(dir, b.file)
+		if err := os.WriteFile(path, []byte(b.file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Add(calibredb.AddOptions{Files: []string{path}, Tags: b.tags, Isbn: b.isbn}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c, dir
+}
+
+// stubCalls returns the number of times the stub calibredb in dir ran.
+func stubCalls(t *testing.T, dir string) int {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "calls.log"))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
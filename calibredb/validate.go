@@ -0,0 +1,63 @@
+package calibredb
+
+import (
+	"reflect"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// newValidator returns the validator used for all *Options structs. Besides
+// the built-in rules the generated structs use:
+//
+//   - bookid: a single positive book id, e.g. "42"
+//   - bookids: a list of ids and ranges as accepted by ParseBookIDs, e.g.
+//     "1,2,10-15". The special value "all" is only accepted as bookids=all.
+//
+// Both work on strings and on BookIDs. GenerateCatalogOptions is also checked
+// as a whole, see validateGenerateCatalogOptions.
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	// BookIDs is validated through its string form so that required rejects
+	// empty sets
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		if ids, ok := field.Interface().(BookIDs); ok && !ids.IsEmpty() {
+			return ids.String()
+		}
+		return ""
+	}, BookIDs{})
+	_ = v.RegisterValidation("bookid", validateBookID)
+	_ = v.RegisterValidation("bookids", validateBookIDs)
+	v.RegisterStructValidation(validateGenerateCatalogOptions, GenerateCatalogOptions{})
+	return v
+}
+
+func validateBookID(fl validator.FieldLevel) bool {
+	if fl.Field().Kind() != reflect.String {
+		return false
+	}
+	_, err := parseBookID(fl.Field().String())
+	return err == nil
+}
+
+func validateBookIDs(fl validator.FieldLevel) bool {
+	if fl.Field().Kind() != reflect.String {
+		return false
+	}
+	s := fl.Field().String()
+	if s == "" {
+		// leave empty values to required
+		return true
+	}
+	ids, err := ParseBookIDs(s, RangeInclusive)
+	if err != nil {
+		return false
+	}
+	return !ids.All() || fl.Param() == "all"
+}
+
+// Validate checks options such as GenerateCatalogOptions the way the method
+// taking them does before it runs calibredb, for callers that report
+// invalid options before starting the work in the background.
+func (c *Calibre) Validate(opts any) error {
+	return c.validate.Struct(opts)
+}
@@ -0,0 +1,161 @@
+package calibredb_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+// TestValidationRules checks that invalid options are rejected before
+// calibredb is started; the recorder would log any invocation.
+func TestValidationRules(t *testing.T) {
+	c, recorded := newArgvRecorder(t)
+	tests := []struct {
+		name    string
+		run     func() (string, error)
+		wantTag string
+	}{
+		{
+			name: "unknown automerge choice",
+			run: func() (string, error) {
+				return c.Add(calibredb.AddOptions{Files: []string{"a.epub"}, Automerge: "always"})
+			},
+			wantTag: "oneof",
+		},
+		{
+			name: "unknown dialect",
+			run: func() (string, error) {
+				return c.ListCategories(calibredb.ListCategoriesOptions{Dialect: "tsv"})
+			},
+			wantTag: "oneof",
+		},
+		{
+			name: "unknown indexing speed",
+			run: func() (string, error) {
+				return c.FtsIndex(calibredb.FtsIndexOptions{EnableDisableStatusReindex: "status", IndexingSpeed: "medium"})
+			},
+			wantTag: "oneof",
+		},
+		{
+			name: "unknown fts_index action",
+			run: func() (string, error) {
+				return c.FtsIndex(calibredb.FtsIndexOptions{EnableDisableStatusReindex: "rebuild"})
+			},
+			wantTag: "oneof",
+		},
+		{
+			name: "unknown output format",
+			run: func() (string, error) {
+				return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "dune", OutputFormat: "xml"})
+			},
+			wantTag: "oneof",
+		},
+		{
+			name: "unknown datatype",
+			run: func() (string, error) {
+				return c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "l", Name: "n", Datatype: "string"})
+			},
+			wantTag: "oneof",
+		},
+		{
+			name: "display is not JSON",
+			run: func() (string, error) {
+				return c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "l", Name: "n", Datatype: "text", Display: "{"})
+			},
+			wantTag: "json",
+		},
+		{
+			name: "indexing threshold above 100",
+			run: func() (string, error) {
+				return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "dune", IndexingThreshold: 101})
+			},
+			wantTag: "lte",
+		},
+		{
+			name: "negative search limit",
+			run: func() (string, error) {
+				return c.Search(calibredb.SearchOptions{Expression: "dune", Limit: -1})
+			},
+			wantTag: "gte",
+		},
+		{
+			name: "negative series index",
+			run: func() (string, error) {
+				return c.Add(calibredb.AddOptions{Files: []string{"a.epub"}, SeriesIndex: -2})
+			},
+			wantTag: "gte",
+		},
+		{
+			name: "book id is not a number",
+			run: func() (string, error) {
+				return c.ShowMetadata(calibredb.ShowMetadataOptions{Id: "one"})
+			},
+			wantTag: "bookid",
+		},
+		{
+			name: "book id is a list",
+			run: func() (string, error) {
+				return c.SetCustom(calibredb.SetCustomOptions{Column: "genre", Id: "1,2", Value: "x"})
+			},
+			wantTag: "bookid",
+		},
+		{
+			name: "book id zero",
+			run: func() (string, error) {
+				return c.RemoveFormat(calibredb.RemoveFormatOptions{Id: "0", Fmt: "EPUB"})
+			},
+			wantTag: "bookid",
+		},
+		{
+			name: "all where calibredb has no all",
+			run: func() (string, error) {
+				return c.Catalog(calibredb.CatalogOptions{Path: "out.csv", Ids: calibredb.AllBookIDs()})
+			},
+			wantTag: "bookids",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.run()
+			if err == nil || !strings.Contains(err.Error(), "'"+tt.wantTag+"' tag") {
+				t.Errorf("error = %v, want failure on the %q tag", err, tt.wantTag)
+			}
+			if calls := recorded(); len(calls) != 0 {
+				t.Errorf("calibredb ran: %q", calls)
+			}
+		})
+	}
+}
+
+func TestValidationRules_Accept(t *testing.T) {
+	c, recorded := newArgvRecorder(t)
+	calls := []func() (string, error){
+		func() (string, error) {
+			return c.Add(calibredb.AddOptions{Files: []string{"a.epub"}, Automerge: calibredb.Overwrite})
+		},
+		func() (string, error) {
+			return c.ListCategories(calibredb.ListCategoriesOptions{Dialect: calibredb.DialectExcelTab})
+		},
+		func() (string, error) {
+			return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "dune", IndexingThreshold: 100})
+		},
+		func() (string, error) {
+			return c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "l", Name: "n", Datatype: "enumeration", Display: `{"enum_values": ["a"]}`})
+		},
+		func() (string, error) {
+			return c.Export(calibredb.ExportOptions{Ids: calibredb.AllBookIDs()})
+		},
+		func() (string, error) {
+			return c.ShowMetadata(calibredb.ShowMetadataOptions{Id: "42"})
+		},
+	}
+	for i, call := range calls {
+		if _, err := call(); err != nil {
+			t.Errorf("call %d: unexpected error %v", i, err)
+		}
+	}
+	if got := len(recorded()); got != len(calls) {
+		t.Errorf("calibredb ran %d times, want %d", got, len(calls))
+	}
+}
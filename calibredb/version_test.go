@@ -0,0 +1,191 @@
+package calibredb_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func TestParseCalibreVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    calibredb.CalibreVersion
+		wantErr bool
+	}{
+		{in: "calibredb (calibre 8.14.0)\n", want: calibredb.CalibreVersion{8, 14, 0}},
+		{in: "calibredb (calibre 6.18)", want: calibredb.CalibreVersion{6, 18, 0}},
+		{in: "calibredb2.1 (calibre 5.44.1)", want: calibredb.CalibreVersion{5, 44, 1}},
+		{in: "7.2.0", want: calibredb.CalibreVersion{7, 2, 0}},
+		{in: "calibredb (calibre)", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := calibredb.ParseCalibreVersion(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCalibreVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCalibreVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalibreVersion_Compare(t *testing.T) {
+	tests := []struct {
+		v, w calibredb.CalibreVersion
+		want int
+	}{
+		{calibredb.CalibreVersion{6, 0, 0}, calibredb.CalibreVersion{6, 0, 0}, 0},
+		{calibredb.CalibreVersion{5, 44, 0}, calibredb.CalibreVersion{6, 0, 0}, -1},
+		{calibredb.CalibreVersion{6, 18, 0}, calibredb.CalibreVersion{6, 9, 3}, 1},
+		{calibredb.CalibreVersion{8, 14, 1}, calibredb.CalibreVersion{8, 14, 0}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Compare(tt.w); got != tt.want {
+			t.Errorf("%v.Compare(%v) = %d, want %d", tt.v, tt.w, got, tt.want)
+		}
+		if got := tt.v.AtLeast(tt.w); got != (tt.want >= 0) {
+			t.Errorf("%v.AtLeast(%v) = %v", tt.v, tt.w, got)
+		}
+	}
+}
+
+func TestCalibre_CalibreVersion(t *testing.T) {
+	t.Setenv("FAKECALIBREDB_VERSION", "6.29.0")
+	c := calibredb.NewCalibre(
+		calibredb.WithCalibreDBLocation(calibredbPath),
+		calibredb.WithLibraryPath(t.TempDir()),
+	)
+	v, err := c.CalibreVersion()
+	if err != nil {
+		t.Fatalf("CalibreVersion() error = %v", err)
+	}
+	if want := (calibredb.CalibreVersion{6, 29, 0}); v != want {
+		t.Errorf("CalibreVersion() = %v, want %v", v, want)
+	}
+
+	// detected once per Calibre
+	t.Setenv("FAKECALIBREDB_VERSION", "7.0.0")
+	if v, _ := c.CalibreVersion(); v.Major != 6 {
+		t.Errorf("CalibreVersion() = %v after a change, want the cached 6.29.0", v)
+	}
+
+	// copies share the detected version both ways
+	c = calibredb.NewCalibre(
+		calibredb.WithCalibreDBLocation(calibredbPath),
+		calibredb.WithLibraryPath(t.TempDir()),
+	)
+	clone := c.As("alice")
+	if v, _ := clone.CalibreVersion(); v.Major != 7 {
+		t.Errorf("CalibreVersion() of a copy = %v, want 7.0.0", v)
+	}
+	t.Setenv("FAKECALIBREDB_VERSION", "8.0.0")
+	if v, _ := c.CalibreVersion(); v.Major != 7 {
+		t.Errorf("CalibreVersion() = %v after a copy detected it, want the cached 7.0.0", v)
+	}
+	if v, _ := c.As("bob").CalibreVersion(); v.Major != 7 {
+		t.Errorf("CalibreVersion() of another copy = %v, want the cached 7.0.0", v)
+	}
+
+	c = calibredb.NewCalibre(calibredb.WithCalibreDBLocation("/nonexistent/calibredb"))
+	if _, err := c.CalibreVersion(); err == nil {
+		t.Error("CalibreVersion() error = nil for a missing calibredb")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		version string
+		run     func(c *calibredb.Calibre) (string, error)
+		wantErr bool
+	}{
+		{
+			name:    "fts_index before 6.0",
+			version: "5.44.0",
+			run: func(c *calibredb.Calibre) (string, error) {
+				return c.FtsIndex(calibredb.FtsIndexOptions{EnableDisableStatusReindex: "status"})
+			},
+			wantErr: true,
+		},
+		{
+			name:    "fts_search on 6.0",
+			version: "6.0.0",
+			run: func(c *calibredb.Calibre) (string, error) {
+				return c.FtsSearch(calibredb.FtsSearchOptions{Expression: "dune"})
+			},
+		},
+		{
+			name:    "check_library --vacuum-fts-db before 6.0",
+			version: "5.44.0",
+			run: func(c *calibredb.Calibre) (string, error) {
+				return c.CheckLibrary(calibredb.CheckLibraryOptions{VacuumFtsDb: ptr(true)})
+			},
+			wantErr: true,
+		},
+		{
+			name:    "check_library before 6.0",
+			version: "5.44.0",
+			run: func(c *calibredb.Calibre) (string, error) {
+				return c.CheckLibrary(calibredb.CheckLibraryOptions{})
+			},
+		},
+		{
+			name:    "add_format --as-extra-data-file before 6.18",
+			version: "6.17.0",
+			run: func(c *calibredb.Calibre) (string, error) {
+				return c.AddFormat(calibredb.AddFormatOptions{Id: "1", EbookFile: "notes.txt", AsExtraDataFile: ptr(true)})
+			},
+			wantErr: true,
+		},
+		{
+			name:    "add_format --as-extra-data-file on 8.14",
+			version: "8.14.0",
+			run: func(c *calibredb.Calibre) (string, error) {
+				return c.AddFormat(calibredb.AddFormatOptions{Id: "1", EbookFile: "notes.txt", AsExtraDataFile: ptr(true)})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FAKECALIBREDB_VERSION", tt.version)
+			c, recorded := newArgvRecorder(t)
+			_, err := tt.run(c)
+			if got := errors.Is(err, calibredb.ErrUnsupportedByCalibreVersion); got != tt.wantErr {
+				t.Fatalf("error = %v, want ErrUnsupportedByCalibreVersion: %v", err, tt.wantErr)
+			}
+			if calls := recorded(); (len(calls) == 0) != tt.wantErr {
+				t.Errorf("recorded %q", calls)
+			}
+		})
+	}
+}
+
+func TestCalibre_Supports(t *testing.T) {
+	t.Setenv("FAKECALIBREDB_VERSION", "6.10.0")
+	c := calibredb.NewCalibre(
+		calibredb.WithCalibreDBLocation(calibredbPath),
+		calibredb.WithLibraryPath(t.TempDir()),
+	)
+	tests := []struct {
+		command, option string
+		want            bool
+	}{
+		{"fts_search", "", true},
+		{"add_format", "--as-extra-data-file", false},
+		{"add_format", "", true},
+		{"list", "--fields", true},
+	}
+	for _, tt := range tests {
+		got, err := c.Supports(tt.command, tt.option)
+		if err != nil {
+			t.Fatalf("Supports(%q, %q) error = %v", tt.command, tt.option, err)
+		}
+		if got != tt.want {
+			t.Errorf("Supports(%q, %q) = %v, want %v", tt.command, tt.option, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package calibredb_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+// TestCalibre_Workflow drives a library through the common commands end to
+// end so that the wrappers are exercised against calibredb's real behaviour
+// rather than only its argument validation.
+func TestCalibre_Workflow(t *testing.T) {
+	dir := t.TempDir()
+	c := calibredb.NewCalibre(
+		calibredb.WithLibraryPath(filepath.Join(dir, "library")),
+		calibredb.WithCalibreDBLocation(calibredbPath),
+	)
+	file := filepath.Join(dir, "Dune - Frank Herbert.epub")
+	if err := os.WriteFile(file, []byte("epub"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := c.Add(calibredb.AddOptions{Files: []string{file}, Tags: "scifi"})
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if !strings.Contains(out, "Added book ids: 1") {
+		t.Fatalf("Add() = %q, want added book 1", out)
+	}
+
+	if _, err := c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "genre", Name: "Genre", Datatype: "text"}); err != nil {
+		t.Fatalf("AddCustomColumn() error = %v", err)
+	}
+	_, err = c.AddCustomColumn(calibredb.AddCustomColumnOptions{Label: "genre", Name: "Genre", Datatype: "text"})
+	if err == nil || err.Error() != "apsw.ConstraintError: UNIQUE constraint failed: custom_columns.label" {
+		t.Fatalf("AddCustomColumn() duplicate error = %v", err)
+	}
+	if _, err := c.SetCustom(calibredb.SetCustomOptions{Column: "genre", Id: "1", Value: "Space Opera"}); err != nil {
+		t.Fatalf("SetCustom() error = %v", err)
+	}
+
+	books, err := c.ListBooks(calibredb.ListBooksOptions{Fields: []string{"title", "authors", "tags", "*genre"}})
+	if err != nil {
+		t.Fatalf("ListBooks() error = %v", err)
+	}
+	if len(books.Books) != 1 {
+		t.Fatalf("ListBooks() returned %d books, want 1", len(books.Books))
+	}
+	b := books.Books[0]
+	if b.Title != "Dune" || b.Authors != "Frank Herbert" || b.Custom["#genre"] != "Space Opera" {
+		t.Errorf("ListBooks() = %+v", b)
+	}
+
+	out, err = c.ShowMetadata(calibredb.ShowMetadataOptions{Id: "1"})
+	if err != nil || !strings.Contains(out, "Frank Herbert") {
+		t.Errorf("ShowMetadata() = %q, %v", out, err)
+	}
+	_, err = c.ShowMetadata(calibredb.ShowMetadataOptions{Id: "7"})
+	if err == nil || err.Error() != "Id #7 is not present in database." {
+		t.Errorf("ShowMetadata() missing book error = %v", err)
+	}
+
+	if _, err := c.SavedSearches(calibredb.SavedSearchesOptions{}, "add", "dune", "title:dune"); err != nil {
+		t.Fatalf("SavedSearches() add error = %v", err)
+	}
+	out, err = c.SavedSearches(calibredb.SavedSearchesOptions{}, "list")
+	if err != nil || !strings.Contains(out, "Search: title:dune") {
+		t.Errorf("SavedSearches() list = %q, %v", out, err)
+	}
+
+	if _, err := c.Remove(calibredb.RemoveOptions{Ids: calibredb.NewBookIDs(1)}); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	books, err = c.ListBooks(calibredb.ListBooksOptions{})
+	if err != nil {
+		t.Fatalf("ListBooks() error = %v", err)
+	}
+	if books.Total != 0 {
+		t.Errorf("ListBooks() after Remove() total = %d, want 0", books.Total)
+	}
+}
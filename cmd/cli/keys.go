@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/veverkap/calibre-rest/auth"
+	"github.com/veverkap/calibre-rest/config"
+)
+
+const keysUsage = `Usage:
+  cli keys create [--scopes read,...] <name>
+  cli keys list
+  cli keys revoke <id or name>
+
+Manages the API keys of the REST server in auth.keys_file. Scopes are
+read, write, admin and download; admin grants all of them.
+`
+
+// runKeys runs the keys command. It does not call calibredb.
+func runKeys(cfg *config.Config, args []string, out *printer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(out.stderr, keysUsage)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+	if cfg.Auth.KeysFile == "" {
+		fmt.Fprintln(out.stderr, "error: no auth.keys_file configured")
+		return exitUsage
+	}
+	store := auth.NewKeyStore(cfg.Auth.KeysFile)
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ContinueOnError)
+	fs.SetOutput(out.stderr)
+	fs.Usage = func() { fmt.Fprint(out.stderr, keysUsage) }
+	scopeList := "read"
+	if args[0] == "create" {
+		fs.StringVar(&scopeList, "scopes", scopeList, "comma separated `scopes` of the key")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	switch {
+	case args[0] == "list" && fs.NArg() == 0:
+		keys, err := store.List()
+		if err != nil {
+			return out.fail(err)
+		}
+		if out.json {
+			out.encode(out.stdout, keys)
+			return exitOK
+		}
+		for _, k := range keys {
+			fmt.Fprintf(out.stdout, "%s  %-20s  %-24s  %s\n", k.ID, k.Name, scopeNames(k.Scopes), k.Created.Format("2006-01-02"))
+		}
+	case args[0] == "create" && fs.NArg() == 1:
+		scopes, err := auth.ParseScopes(scopeList)
+		if err != nil {
+			fmt.Fprintln(out.stderr, "error:", err)
+			return exitUsage
+		}
+		token, key, err := store.Create(fs.Arg(0), scopes)
+		if err != nil {
+			return out.fail(err)
+		}
+		if out.json {
+			out.encode(out.stdout, struct {
+				auth.Key
+				Token string `json:"token"`
+			}{key, token})
+			return exitOK
+		}
+		fmt.Fprintln(out.stdout, token)
+		fmt.Fprintf(out.stderr, "created key %s (%s) with scopes %s; the key is not shown again\n", key.ID, key.Name, scopeNames(key.Scopes))
+	case args[0] == "revoke" && fs.NArg() == 1:
+		key, err := store.Revoke(fs.Arg(0))
+		if errors.Is(err, auth.ErrUnknownKey) {
+			fmt.Fprintln(out.stderr, "error:", err)
+			return exitNotFound
+		}
+		if err != nil {
+			return out.fail(err)
+		}
+		if out.json {
+			out.encode(out.stdout, key)
+			return exitOK
+		}
+		fmt.Fprintf(out.stderr, "revoked key %s (%s)\n", key.ID, key.Name)
+	default:
+		fmt.Fprint(out.stderr, keysUsage)
+		return exitUsage
+	}
+	return exitOK
+}
+
+func scopeNames(scopes []auth.Scope) string {
+	names := make([]string, len(scopes))
+	for i, s := range scopes {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ",")
+}
@@ -1,63 +1,224 @@
+// Command cli runs calibredb commands through the calibredb package. Every
+// generated wrapper is a subcommand named like the calibredb command, with
+// flags derived from its options struct:
+//
+//	cli [global options] <command> [options] [arguments]
+//	cli --library ~/Books --output json list --fields title,authors
+//
+// Global options can also be set in the config file and CALIBRE_REST_*
+// environment variables described in package config; flags override both.
+// --print-config shows the result.
+//
+// The keys command manages the API keys of the REST server instead of running
+// calibredb, see cli keys -h. The snapshot command takes and restores
+// snapshots of the library, see cli snapshot -h. The sync command copies
+// books and metadata from the library to another one, see cli sync -h.
+//
+// The exit status tells scripts what went wrong:
+//
+//	0    success
+//	1    calibredb failed
+//	2    bad command line, of this program or of calibredb
+//	3    invalid options, calibredb was not run
+//	4    book, column or search not found
+//	5    not supported by the installed calibre version
+//	127  calibredb could not be started
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/user"
+	"strings"
 
 	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/config"
 )
 
+const (
+	exitOK           = 0
+	exitFailed       = 1
+	exitUsage        = 2
+	exitInvalid      = 3
+	exitNotFound     = 4
+	exitUnsupported  = 5
+	exitNotInstalled = 127
+)
+
+// exitCodes maps the kind of a calibredb error to the exit status.
+var exitCodes = map[calibredb.ErrorKind]int{
+	calibredb.ErrorKindFailed:       exitFailed,
+	calibredb.ErrorKindUsage:        exitUsage,
+	calibredb.ErrorKindInvalid:      exitInvalid,
+	calibredb.ErrorKindNotFound:     exitNotFound,
+	calibredb.ErrorKindUnsupported:  exitUnsupported,
+	calibredb.ErrorKindNotInstalled: exitNotInstalled,
+}
+
 func main() {
-	// get temp directory
-	tempDir := os.TempDir()
-	c := calibredb.NewCalibre(
-		calibredb.WithLibraryPath(tempDir),
-		calibredb.WithCalibreDBLocation("/Applications/calibre.app/Contents/MacOS/calibredb"),
-	)
-	out := c.Help()
-	fmt.Println(out)
-
-	output, err := c.AddCustomColumn(
-		calibredb.AddCustomColumnOptions{
-			Label:    "sdfassaddafsdsdfdsfsd",
-			Name:     "My Column",
-			Datatype: "text",
-		},
-	)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run is main without the process: it returns the exit status.
+func run(args []string, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("cli", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	configPath := global.String("config", "", "path of the JSON config file (default $CALIBRE_REST_CONFIG or "+config.DefaultPath()+")")
+	library := global.String("library", "", "name of a configured library, or the path or server URL of a library")
+	calibredbPath := global.String("calibredb", "", "path to the calibredb binary (default calibredb)")
+	timeout := global.String("timeout", "", "timeout for calibre Content server connections, in seconds or as a duration such as 2m")
+	output := global.String("output", "", "output format: json or table (default table)")
+	printConfig := global.Bool("print-config", false, "print the configuration with secrets redacted and exit")
+	global.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: cli [global options] <command> [options] [arguments]\n\nCommands:\n  keys (API keys of the REST server, see cli keys -h)\n  snapshot (snapshots of the library, see cli snapshot -h)\n  sync (one-way sync to another library, see cli sync -h)\n")
+		for _, name := range calibredb.Commands() {
+			fmt.Fprintf(stderr, "  %s\n", name)
+		}
+		fmt.Fprintf(stderr, "\nGlobal options:\n")
+		global.PrintDefaults()
+	}
+	if err := global.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	// flags override the config file and the environment
+	cfg, err := config.Load(*configPath, config.WithOverride(func(cfg *config.Config) {
+		global.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "library":
+				cfg.SelectLibrary(*library)
+			case "calibredb":
+				cfg.Calibredb = *calibredbPath
+			case "timeout":
+				cfg.Timeout = *timeout
+			case "output":
+				cfg.CLI.Output = *output
+			}
+		})
+	}))
 	if err != nil {
-		panic(err)
-	}
-	fmt.Println(output)
-}
-
-// c.Help()
-// c.SetMetadata(
-// 	calibredb.SetMetadataOptions{
-// 		BookId:     "1",
-// 		Path:       "fsad",
-// 		ListFields: lo.ToPtr(false),
-// 	},
-// )
-// output, err := c.AddCustomColumn(
-// 	calibredb.AddCustomColumnOptions{
-// 		Label:    "sdfassaddafsdsdfdsfsd",
-// 		Name:     "My Column",
-// 		Datatype: "text",
-// 	},
-// )
-// fmt.Println(output, err)
-// os.Exit(1)
-
-// func test() {
-// 	c := calibredb.NewCalibre(calibredb.WithLibraryPath("/Users/veverkap/Code/personal/calibre-rest"))
-
-// 	s := c.List(
-// 		calibredb.ListOptions{
-// 			Ascending:  true,
-// 			Fields:     "author_sort, authors, comments",
-// 			ForMachine: lo.ToPtr(false),
-// 			Limit:      2,
-// 		},
-// 	)
-// 	fmt.Println(s)
-// }
+		fmt.Fprintln(stderr, "error:", err)
+		return exitUsage
+	}
+	if *printConfig {
+		if err := cfg.Print(stdout); err != nil {
+			fmt.Fprintln(stderr, "error:", err)
+			return exitFailed
+		}
+		return exitOK
+	}
+	out := &printer{json: cfg.CLI.Output == "json", stdout: stdout, stderr: stderr}
+
+	if global.NArg() == 0 {
+		global.Usage()
+		return exitUsage
+	}
+	if global.Arg(0) == "keys" {
+		return runKeys(cfg, global.Args()[1:], out)
+	}
+	if global.Arg(0) == "snapshot" {
+		return runSnapshot(cfg, global.Args()[1:], out)
+	}
+	if global.Arg(0) == "sync" {
+		return runSync(cfg, global.Args()[1:], out)
+	}
+	command := strings.ReplaceAll(global.Arg(0), "-", "_")
+	opts := calibredb.NewOptions(command)
+	if opts == nil {
+		fmt.Fprintf(stderr, "error: unknown command %q, run cli -h for the list\n", global.Arg(0))
+		return exitUsage
+	}
+	extra, err := parseOptions(command, opts, global.Args()[1:], stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		fmt.Fprintln(stderr, "error:", err)
+		return exitUsage
+	}
+	if out.json {
+		preferJSON(opts)
+	}
+
+	calibreOpts := []calibredb.CalibreOption{calibredb.WithActor(currentUser())}
+	auditLog, err := cfg.OpenAudit()
+	if err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return exitFailed
+	}
+	if auditLog != nil {
+		defer auditLog.Close()
+		calibreOpts = append(calibreOpts, calibredb.WithAudit(auditLog.Record))
+	}
+	c, err := cfg.NewCalibre("", calibreOpts...)
+	if err != nil {
+		fmt.Fprintln(stderr, "error:", err)
+		return exitUsage
+	}
+	result, err := c.Run(command, opts, extra...)
+	if err != nil {
+		kind := calibredb.ErrorKindOf(err)
+		out.error(err, kind)
+		return exitCodes[kind]
+	}
+	out.result(result)
+	return exitOK
+}
+
+// currentUser names the actor of audit events.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// printer writes results and errors as plain text or as JSON.
+type printer struct {
+	json           bool
+	stdout, stderr io.Writer
+}
+
+func (p *printer) result(s string) {
+	if !p.json {
+		if s != "" {
+			fmt.Fprintln(p.stdout, s)
+		}
+		return
+	}
+	// output that already is JSON, e.g. list --for-machine, is passed through
+	var buf bytes.Buffer
+	if json.Valid([]byte(s)) && json.Indent(&buf, []byte(s), "", "  ") == nil {
+		fmt.Fprintln(p.stdout, buf.String())
+		return
+	}
+	p.encode(p.stdout, map[string]string{"output": s})
+}
+
+// fail prints an error that is not from calibredb.
+func (p *printer) fail(err error) int {
+	p.error(err, calibredb.ErrorKindFailed)
+	return exitFailed
+}
+
+func (p *printer) error(err error, kind calibredb.ErrorKind) {
+	if !p.json {
+		fmt.Fprintln(p.stderr, "error:", err)
+		return
+	}
+	p.encode(p.stderr, map[string]string{"error": err.Error(), "kind": kind.String()})
+}
+
+func (p *printer) encode(w io.Writer, v any) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/internal/fakecalibredb"
+)
+
+var calibredbPath string
+
+// TestMain runs the fake calibredb when the test binary is started as
+// calibredb, see the calibredb package tests.
+func TestMain(m *testing.M) {
+	if filepath.Base(os.Args[0]) == "calibredb" {
+		os.Exit(fakecalibredb.Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	dir, err := os.MkdirTemp("", "fakecalibredb")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// keep the user's config out of the tests
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	for _, kv := range os.Environ() {
+		if name, _, _ := strings.Cut(kv, "="); strings.HasPrefix(name, "CALIBRE_REST_") {
+			os.Unsetenv(name)
+		}
+	}
+	self, err := os.Executable()
+	if err == nil {
+		calibredbPath = filepath.Join(dir, "calibredb")
+		err = os.Symlink(self, calibredbPath)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func cli(t *testing.T, library string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"--calibredb", calibredbPath, "--library", library}, args...)
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	library := t.TempDir()
+	book := filepath.Join(t.TempDir(), "dune.txt")
+	if err := os.WriteFile(book, []byte("dune"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, out, errOut := cli(t, library, "add", book, "--title", "Dune", "--tags", "sf"); code != exitOK {
+		t.Fatalf("add: exit %d, %s%s", code, out, errOut)
+	}
+
+	code, out, errOut := cli(t, library, "--output", "json", "list", "--fields", "title,tags")
+	if code != exitOK {
+		t.Fatalf("list: exit %d, %s", code, errOut)
+	}
+	var books []map[string]any
+	if err := json.Unmarshal([]byte(out), &books); err != nil {
+		t.Fatalf("list output is not JSON: %v\n%s", err, out)
+	}
+	if len(books) != 1 || books[0]["title"] != "Dune" {
+		t.Errorf("list = %v", books)
+	}
+
+	code, out, _ = cli(t, library, "--output", "json", "custom_columns")
+	if code != exitOK || !strings.Contains(out, `"output"`) {
+		t.Errorf("custom_columns: exit %d, %s", code, out)
+	}
+
+	code, out, _ = cli(t, library, "search", "title:dune")
+	if code != exitOK || strings.TrimSpace(out) != "1" {
+		t.Errorf("search: exit %d, %q", code, out)
+	}
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	library := t.TempDir()
+	tests := []struct {
+		name     string
+		args     []string
+		want     int
+		wantKind string
+	}{
+		{name: "unknown command", args: []string{"frobnicate"}, want: exitUsage},
+		{name: "unknown flag", args: []string{"list", "--frobnicate"}, want: exitUsage},
+		{name: "too many arguments", args: []string{"show_metadata", "1", "2"}, want: exitUsage},
+		{name: "bad output", args: []string{"--output", "xml", "list"}, want: exitUsage},
+		{name: "validation", args: []string{"--output", "json", "show_metadata", "x"}, want: exitInvalid, wantKind: "invalid"},
+		{name: "not found", args: []string{"--output", "json", "show_metadata", "42"}, want: exitNotFound, wantKind: "not_found"},
+		{name: "argument after --", args: []string{"fts_index", "status", "--", "--extra"}, want: exitUsage},
+		{name: "help", args: []string{"list", "-h"}, want: exitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, errOut := cli(t, library, tt.args...)
+			if code != tt.want {
+				t.Fatalf("exit %d, want %d: %s", code, tt.want, errOut)
+			}
+			if tt.wantKind != "" {
+				var e struct{ Error, Kind string }
+				if err := json.Unmarshal([]byte(errOut), &e); err != nil || e.Kind != tt.wantKind {
+					t.Errorf("stderr = %s, want kind %s", errOut, tt.wantKind)
+				}
+			}
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		t.Setenv("FAKECALIBREDB_VERSION", "5.44.0")
+		if code, _, errOut := cli(t, library, "fts_search", "dune"); code != exitUnsupported {
+			t.Errorf("exit %d, want %d: %s", code, exitUnsupported, errOut)
+		}
+	})
+
+	t.Run("not installed", func(t *testing.T) {
+		var stderr bytes.Buffer
+		if code := run([]string{"--calibredb", "/nonexistent/calibredb", "--library", library, "list"}, &bytes.Buffer{}, &stderr); code != exitNotInstalled {
+			t.Errorf("exit %d, want %d: %s", code, exitNotInstalled, stderr.String())
+		}
+	})
+}
+
+func TestRun_Config(t *testing.T) {
+	library := t.TempDir()
+	path := filepath.Join(t.TempDir(), "cli.json")
+	cfg := fmt.Sprintf(`{"libraries": [{"name": "books", "path": %q}], "calibredb": %q, "cli": {"output": "json"}}`, library, calibredbPath)
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--config", path, "list"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if strings.TrimSpace(stdout.String()) != "[]" {
+		t.Errorf("list = %q, want the JSON of an empty library", stdout.String())
+	}
+
+	// flags override the file
+	stdout.Reset()
+	if code := run([]string{"--config", path, "--output", "table", "search", "--limit", "1", "title:x"}, &stdout, &stderr); code != exitNotFound {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+
+	if code := run([]string{"--config", filepath.Join(t.TempDir(), "missing.json"), "list"}, &stdout, &stderr); code != exitUsage {
+		t.Errorf("missing config: exit %d, want %d", code, exitUsage)
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	type options struct {
+		Ids     string   `validate:"required"`
+		Files   []string `validate:"required"`
+		Verbose *bool    `flag:"--verbose"`
+		Limit   int      `flag:"--limit"`
+		Index   float64  `flag:"--series-index"`
+		Field   []string `flag:"--field"`
+	}
+	var opts options
+	args := []string{"1,2", "--verbose", "a.epub", "--limit", "3", "--field", "x:1", "--series-index=1.5", "--field", "y:2", "--", "--b.epub"}
+	extra, err := parseOptions("test", &opts, args, &bytes.Buffer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extra) != 0 {
+		t.Errorf("parseOptions() left %q", extra)
+	}
+	if opts.Ids != "1,2" || strings.Join(opts.Files, " ") != "a.epub --b.epub" || opts.Verbose == nil || !*opts.Verbose ||
+		opts.Limit != 3 || opts.Index != 1.5 || strings.Join(opts.Field, " ") != "x:1 y:2" {
+		t.Errorf("parseOptions() = %+v", opts)
+	}
+}
+
+func TestRun_SavedSearches(t *testing.T) {
+	library := t.TempDir()
+	if code, out, errOut := cli(t, library, "saved_searches", "add", "sf", "tags:sf"); code != exitOK {
+		t.Fatalf("saved_searches add: exit %d, %s%s", code, out, errOut)
+	}
+	code, out, errOut := cli(t, library, "saved_searches", "list")
+	if code != exitOK {
+		t.Fatalf("saved_searches list: exit %d, %s", code, errOut)
+	}
+	if !strings.Contains(out, "sf") || !strings.Contains(out, "tags:sf") {
+		t.Errorf("saved_searches list = %q", out)
+	}
+}
+
+func TestRun_RemoveRange(t *testing.T) {
+	library := t.TempDir()
+	for _, title := range []string{"Dune", "Anathem", "Hyperion"} {
+		book := filepath.Join(t.TempDir(), title+".txt")
+		if err := os.WriteFile(book, []byte(title), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if code, out, errOut := cli(t, library, "add", book, "--title", title); code != exitOK {
+			t.Fatalf("add: exit %d, %s%s", code, out, errOut)
+		}
+	}
+
+	// like calibredb, remove leaves out the end of a range
+	if code, out, errOut := cli(t, library, "remove", "1-3"); code != exitOK {
+		t.Fatalf("remove: exit %d, %s%s", code, out, errOut)
+	}
+	code, out, errOut := cli(t, library, "--output", "json", "list", "--fields", "title")
+	if code != exitOK {
+		t.Fatalf("list: exit %d, %s", code, errOut)
+	}
+	var books []map[string]any
+	if err := json.Unmarshal([]byte(out), &books); err != nil {
+		t.Fatalf("list output is not JSON: %v\n%s", err, out)
+	}
+	if len(books) != 1 || books[0]["id"] != float64(3) {
+		t.Errorf("after remove 1-3, list = %v, want book 3", books)
+	}
+}
+
+func TestRun_PrintConfig(t *testing.T) {
+	t.Setenv("CALIBRE_REST_PASSWORD", "hunter2")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--library", "http://localhost:8080/#books", "--print-config"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("exit %d: %s", code, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "hunter2") || !strings.Contains(stdout.String(), `"password": "REDACTED"`) {
+		t.Errorf("--print-config = %s", stdout.String())
+	}
+}
+
+func TestRun_Keys(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+	t.Setenv("CALIBRE_REST_AUTH_KEYS_FILE", keysFile)
+	keys := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := run(append([]string{"--output", "json", "keys"}, args...), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	code, out, errOut := keys("create", "--scopes", "read,download", "backup")
+	if code != exitOK {
+		t.Fatalf("create: exit %d, %s", code, errOut)
+	}
+	var created struct {
+		ID     string
+		Scopes []string
+		Token  string
+	}
+	if err := json.Unmarshal([]byte(out), &created); err != nil || created.Token == "" {
+		t.Fatalf("create output = %s, %v", out, err)
+	}
+	if strings.Join(created.Scopes, ",") != "read,download" {
+		t.Errorf("scopes = %v", created.Scopes)
+	}
+
+	if code, out, _ := keys("list"); code != exitOK || !strings.Contains(out, created.ID) || strings.Contains(out, created.Token) {
+		t.Errorf("list: exit %d, %s", code, out)
+	}
+	if code, _, _ := keys("create", "--scopes", "everything", "x"); code != exitUsage {
+		t.Errorf("create with a bad scope: exit %d", code)
+	}
+	if code, _, errOut := keys("revoke", "backup"); code != exitOK {
+		t.Errorf("revoke: exit %d, %s", code, errOut)
+	}
+	if code, _, _ := keys("revoke", "backup"); code != exitNotFound {
+		t.Errorf("second revoke: exit %d", code)
+	}
+	if code, _, _ := keys("rotate"); code != exitUsage {
+		t.Errorf("unknown subcommand: exit %d", code)
+	}
+}
+
+func TestRun_Snapshot(t *testing.T) {
+	t.Setenv("CALIBRE_REST_SNAPSHOTS_DIR", t.TempDir())
+	library := filepath.Join(t.TempDir(), "library")
+	book := filepath.Join(t.TempDir(), "dune.txt")
+	if err := os.WriteFile(book, []byte("dune"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, errOut := cli(t, library, "add", book); code != exitOK {
+		t.Fatalf("add: exit %d, %s", code, errOut)
+	}
+	snapshot := func(args ...string) (int, string, string) {
+		return cli(t, library, append([]string{"--output", "json", "snapshot"}, args...)...)
+	}
+
+	code, out, errOut := snapshot("create", "--books")
+	if code != exitOK {
+		t.Fatalf("create: exit %d, %s", code, errOut)
+	}
+	var created struct {
+		ID    string
+		Books bool
+		Files int
+	}
+	if err := json.Unmarshal([]byte(out), &created); err != nil || !created.Books || created.Files != 2 {
+		t.Fatalf("create output = %s, %v", out, err)
+	}
+	if code, out, _ := snapshot("list"); code != exitOK || !strings.Contains(out, created.ID) {
+		t.Errorf("list: exit %d, %s", code, out)
+	}
+	if code, _, errOut := snapshot("verify", created.ID); code != exitOK {
+		t.Errorf("verify: exit %d, %s", code, errOut)
+	}
+
+	if code, _, errOut := cli(t, library, "remove", "1"); code != exitOK {
+		t.Fatalf("remove: exit %d, %s", code, errOut)
+	}
+	if code, _, errOut := snapshot("restore", created.ID); code != exitOK {
+		t.Fatalf("restore: exit %d, %s", code, errOut)
+	}
+	if code, out, _ := cli(t, library, "list"); code != exitOK || !strings.Contains(out, "dune") {
+		t.Errorf("list after restore: exit %d, %s", code, out)
+	}
+
+	if code, _, errOut := snapshot("delete", created.ID); code != exitOK {
+		t.Errorf("delete: exit %d, %s", code, errOut)
+	}
+	if code, _, _ := snapshot("restore", created.ID); code != exitNotFound {
+		t.Errorf("restore of a deleted snapshot: exit %d", code)
+	}
+	if code, _, _ := snapshot("create", "extra"); code != exitUsage {
+		t.Errorf("create with an argument: exit %d", code)
+	}
+}
+
+func TestRun_Sync(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "source")
+	target := filepath.Join(t.TempDir(), "target")
+	book := filepath.Join(t.TempDir(), "dune.txt")
+	if err := os.WriteFile(book, []byte("dune"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, errOut := cli(t, source, "add", book, "--title", "Dune"); code != exitOK {
+		t.Fatalf("add: exit %d, %s", code, errOut)
+	}
+
+	code, out, errOut := cli(t, source, "sync", "--dry-run", target)
+	if code != exitOK || !strings.Contains(out, "Dune") || !strings.Contains(errOut, "would sync: 1 to add") {
+		t.Errorf("sync --dry-run: exit %d, %s%s", code, out, errOut)
+	}
+	if code, out, _ := cli(t, target, "list"); code != exitOK || strings.Contains(out, "Dune") {
+		t.Errorf("target after a dry run: exit %d, %s", code, out)
+	}
+
+	code, out, errOut = cli(t, source, "--output", "json", "sync", target)
+	if code != exitOK {
+		t.Fatalf("sync: exit %d, %s", code, errOut)
+	}
+	var result struct {
+		Missing []struct {
+			TargetID int  `json:"target_id"`
+			Applied  bool `json:"applied"`
+		} `json:"missing"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil || len(result.Missing) != 1 || !result.Missing[0].Applied {
+		t.Fatalf("sync output = %s, %v", out, err)
+	}
+	if code, out, _ := cli(t, target, "list"); code != exitOK || !strings.Contains(out, "Dune") {
+		t.Errorf("target after sync: exit %d, %s", code, out)
+	}
+	if code, _, errOut := cli(t, source, "sync", target); code != exitOK || !strings.Contains(errOut, "1 unchanged") {
+		t.Errorf("second sync: exit %d, %s", code, errOut)
+	}
+
+	if code, _, _ := cli(t, source, "sync", "--conflict", "merge", target); code != exitInvalid {
+		t.Errorf("sync --conflict merge: exit %d, want %d", code, exitInvalid)
+	}
+	if code, _, _ := cli(t, source, "sync"); code != exitUsage {
+		t.Errorf("sync without a target: exit %d", code)
+	}
+}
+
+func TestRun_Audit(t *testing.T) {
+	auditFile := filepath.Join(t.TempDir(), "audit.jsonl")
+	t.Setenv("CALIBRE_REST_AUDIT_FILE", auditFile)
+	library := t.TempDir()
+	book := filepath.Join(t.TempDir(), "dune.txt")
+	if err := os.WriteFile(book, []byte("dune"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code, _, errOut := cli(t, library, "add", book); code != exitOK {
+		t.Fatalf("add: exit %d, %s", code, errOut)
+	}
+	if code, _, errOut := cli(t, library, "list"); code != exitOK {
+		t.Fatalf("list: exit %d, %s", code, errOut)
+	}
+
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("audit log = %s, want only the add", data)
+	}
+	var e struct {
+		Actor, Command string
+		BookIDs        string `json:"book_ids"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Actor == "" || e.Command != "add" || e.BookIDs != "1" {
+		t.Errorf("event = %+v", e)
+	}
+}
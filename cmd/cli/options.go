@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+// parseOptions fills opts, a pointer to a calibredb options struct, from the
+// command line. Fields with a flag tag become flags, the others are the
+// positional arguments in field order. Flags may follow arguments. A command
+// without such fields gets its arguments back for the wrapper to pass on,
+// e.g. the subcommand of saved_searches.
+func parseOptions(command string, opts any, args []string, stderr io.Writer) ([]string, error) {
+	v := reflect.ValueOf(opts).Elem()
+	style := calibredb.RangeStyleOf(command)
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var positional []reflect.Value
+	var names []string
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		name, ok := strings.CutPrefix(field.Tag.Get("flag"), "--")
+		if !ok {
+			positional = append(positional, v.Field(i))
+			names = append(names, argName(field))
+			continue
+		}
+		fs.Var(fieldValue{v.Field(i), style}, name, usage(field, style))
+	}
+	if len(positional) == 0 {
+		names = append(names, "[arguments...]")
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: cli %s\n\nOptions:\n", strings.Join(append([]string{command, "[options]"}, names...), " "))
+		fs.PrintDefaults()
+	}
+
+	var values []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			break
+		}
+		// everything after "--" is an argument
+		if consumed := args[:len(args)-len(rest)]; len(consumed) > 0 && consumed[len(consumed)-1] == "--" {
+			values = append(values, rest...)
+			break
+		}
+		values = append(values, rest[0])
+		args = rest[1:]
+	}
+
+	for i, field := range positional {
+		if len(values) == 0 {
+			break
+		}
+		if field.Kind() == reflect.Slice {
+			field.Set(reflect.ValueOf(values))
+			values = nil
+			break
+		}
+		if err := (fieldValue{field, style}).Set(values[0]); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", names[i], values[0], err)
+		}
+		values = values[1:]
+	}
+	// commands whose arguments have fields take no others
+	if len(values) > 0 && len(positional) > 0 {
+		return nil, fmt.Errorf("too many arguments: %s", strings.Join(values, " "))
+	}
+	return values, nil
+}
+
+// preferJSON asks calibredb for machine readable output when the command can
+// produce it and the caller did not choose a format.
+func preferJSON(opts any) {
+	v := reflect.ValueOf(opts).Elem()
+	for i := range v.NumField() {
+		field := v.Field(i)
+		switch v.Type().Field(i).Tag.Get("flag") {
+		case "--for-machine":
+			if field.IsNil() {
+				t := true
+				field.Set(reflect.ValueOf(&t))
+			}
+		case "--output-format":
+			if field.String() == "" {
+				field.SetString("json")
+			}
+		}
+	}
+}
+
+func argName(field reflect.StructField) string {
+	var b strings.Builder
+	for i, r := range field.Name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('_')
+		}
+		b.WriteRune(r)
+	}
+	name := strings.ToLower(b.String())
+	if field.Type.Kind() == reflect.Slice {
+		return name + "..."
+	}
+	return name
+}
+
+// usage describes the value a flag takes; the back quoted word is the
+// placeholder flag.PrintDefaults shows.
+func usage(field reflect.StructField, style calibredb.RangeStyle) string {
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		if values, ok := strings.CutPrefix(rule, "oneof="); ok {
+			return "one of `" + strings.Join(strings.Fields(values), "|") + "`"
+		}
+	}
+	if field.Type == reflect.TypeFor[calibredb.BookIDs]() {
+		if style == calibredb.RangeExclusive {
+			return "`ids` such as 1,3-6, ranges exclude the end"
+		}
+		return "`ids` such as 1,3-5, ranges include both ends"
+	}
+	switch field.Type.Kind() {
+	case reflect.Pointer:
+		return "switch"
+	case reflect.Slice:
+		return "a `string`, may be repeated"
+	case reflect.Int:
+		return "an `integer`"
+	case reflect.Float64:
+		return "a `number`"
+	}
+	return "a `string`"
+}
+
+// fieldValue is a flag.Value that sets a field of an options struct. Book
+// ids are parsed in the range style of the command.
+type fieldValue struct {
+	v     reflect.Value
+	style calibredb.RangeStyle
+}
+
+func (f fieldValue) String() string {
+	if !f.v.IsValid() {
+		return ""
+	}
+	if s, ok := f.v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	if f.v.Kind() == reflect.Pointer {
+		if f.v.IsNil() {
+			return ""
+		}
+		return fmt.Sprint(f.v.Elem().Interface())
+	}
+	if f.v.IsZero() {
+		return ""
+	}
+	return fmt.Sprint(f.v.Interface())
+}
+
+func (f fieldValue) IsBoolFlag() bool {
+	return f.v.IsValid() && f.v.Kind() == reflect.Pointer && f.v.Type().Elem().Kind() == reflect.Bool
+}
+
+func (f fieldValue) Set(s string) error {
+	if ids, ok := f.v.Addr().Interface().(*calibredb.BookIDs); ok {
+		parsed, err := calibredb.ParseBookIDs(s, f.style)
+		if err != nil {
+			return err
+		}
+		*ids = parsed
+		return nil
+	}
+	if u, ok := f.v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch f.v.Kind() {
+	case reflect.Pointer:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.v.Set(reflect.ValueOf(&b))
+	case reflect.String:
+		f.v.SetString(s)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		f.v.SetInt(int64(n))
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f.v.SetFloat(n)
+	case reflect.Slice:
+		f.v.Set(reflect.Append(f.v, reflect.ValueOf(s)))
+	default:
+		return errors.New("unsupported option type " + f.v.Type().String())
+	}
+	return nil
+}
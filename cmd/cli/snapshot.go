@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/config"
+	"github.com/veverkap/calibre-rest/snapshot"
+)
+
+const snapshotUsage = `Usage:
+  cli snapshot create [--fts] [--books]
+  cli snapshot list
+  cli snapshot verify <id>
+  cli snapshot restore <id>
+  cli snapshot delete <id>
+  cli snapshot prune
+
+Manages the snapshots of the library in snapshots.dir. A snapshot holds
+metadata.db and, with --fts and --books, full-text-search.db and the book
+folders. Creating a snapshot prunes the old ones by the snapshots.keep_*
+settings. A restore checks every file of the snapshot before it replaces
+the library and keeps what it replaced.
+`
+
+// runSnapshot runs the snapshot command.
+func runSnapshot(cfg *config.Config, args []string, out *printer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(out.stderr, snapshotUsage)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+	store, err := cfg.SnapshotStore()
+	if err != nil {
+		return out.fail(err)
+	}
+	if store == nil {
+		fmt.Fprintln(out.stderr, "error: no snapshots.dir configured")
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("snapshot "+args[0], flag.ContinueOnError)
+	fs.SetOutput(out.stderr)
+	fs.Usage = func() { fmt.Fprint(out.stderr, snapshotUsage) }
+	var opts snapshot.Options
+	if args[0] == "create" {
+		fs.BoolVar(&opts.FullTextSearch, "fts", false, "include full-text-search.db")
+		fs.BoolVar(&opts.Books, "books", false, "include the book folders")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	calibre := func() (*calibredb.Calibre, error) {
+		return cfg.NewCalibre("", calibredb.WithActor(currentUser()))
+	}
+
+	switch {
+	case args[0] == "create" && fs.NArg() == 0:
+		c, err := calibre()
+		if err != nil {
+			fmt.Fprintln(out.stderr, "error:", err)
+			return exitUsage
+		}
+		snap, err := store.Create(c, opts)
+		if snap.ID == "" {
+			return snapshotFailed(out, err)
+		}
+		if err != nil {
+			fmt.Fprintln(out.stderr, "warning:", err)
+		}
+		if out.json {
+			out.encode(out.stdout, snap)
+			return exitOK
+		}
+		fmt.Fprintln(out.stdout, snap.ID)
+		fmt.Fprintf(out.stderr, "created snapshot %s of %s: %d files, %d bytes\n", snap.ID, contents(snap.Options), snap.Files, snap.Size)
+	case args[0] == "list" && fs.NArg() == 0:
+		snaps, err := store.List()
+		if err != nil {
+			return out.fail(err)
+		}
+		if out.json {
+			out.encode(out.stdout, snaps)
+			return exitOK
+		}
+		for _, s := range snaps {
+			fmt.Fprintf(out.stdout, "%s  %-46s  %6d files  %12d bytes\n", s.ID, contents(s.Options), s.Files, s.Size)
+		}
+	case args[0] == "verify" && fs.NArg() == 1:
+		m, err := store.Verify(fs.Arg(0))
+		if err != nil {
+			return snapshotFailed(out, err)
+		}
+		if out.json {
+			out.encode(out.stdout, m)
+			return exitOK
+		}
+		fmt.Fprintf(out.stderr, "snapshot %s is intact: %d files\n", m.ID, len(m.Files))
+	case args[0] == "restore" && fs.NArg() == 1:
+		c, err := calibre()
+		if err != nil {
+			fmt.Fprintln(out.stderr, "error:", err)
+			return exitUsage
+		}
+		restored, err := store.Restore(c, fs.Arg(0))
+		if err != nil {
+			return snapshotFailed(out, err)
+		}
+		if out.json {
+			out.encode(out.stdout, restored)
+			return exitOK
+		}
+		fmt.Fprintf(out.stderr, "restored snapshot %s to %s\n", restored.Snapshot.ID, c.LibraryPath)
+		if restored.Previous != "" {
+			fmt.Fprintf(out.stderr, "the replaced files are in %s\n", restored.Previous)
+		}
+	case args[0] == "delete" && fs.NArg() == 1:
+		if err := store.Delete(fs.Arg(0)); err != nil {
+			return snapshotFailed(out, err)
+		}
+		fmt.Fprintf(out.stderr, "deleted snapshot %s\n", fs.Arg(0))
+	case args[0] == "prune" && fs.NArg() == 0:
+		removed, err := store.Prune()
+		if err != nil {
+			return out.fail(err)
+		}
+		if out.json {
+			out.encode(out.stdout, removed)
+			return exitOK
+		}
+		for _, id := range removed {
+			fmt.Fprintln(out.stdout, id)
+		}
+	default:
+		fmt.Fprint(out.stderr, snapshotUsage)
+		return exitUsage
+	}
+	return exitOK
+}
+
+// snapshotFailed prints err and returns the exit status for it.
+func snapshotFailed(out *printer, err error) int {
+	switch {
+	case errors.Is(err, snapshot.ErrNotFound):
+		out.error(err, calibredb.ErrorKindNotFound)
+		return exitNotFound
+	case errors.Is(err, snapshot.ErrRemoteLibrary):
+		out.error(err, calibredb.ErrorKindInvalid)
+		return exitInvalid
+	}
+	return out.fail(err)
+}
+
+// contents lists the parts of the library a snapshot holds.
+func contents(opts snapshot.Options) string {
+	parts := []string{"metadata.db"}
+	if opts.FullTextSearch {
+		parts = append(parts, "full-text-search.db")
+	}
+	if opts.Books {
+		parts = append(parts, "books")
+	}
+	return strings.Join(parts, ", ")
+}
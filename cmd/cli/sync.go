@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/config"
+)
+
+const syncUsage = `Usage:
+  cli [--library SOURCE] sync [--search EXPR] [--conflict skip|overwrite] [--remove-extra] [--dry-run] <target>
+
+Copies the books of the source library that the target lacks or has older
+metadata for to the target, a configured library name or the path or server
+URL of a library. Books are matched by uuid, then by identifiers. A book
+changed in both libraries since they matched is a conflict, skipped unless
+--conflict overwrite. --remove-extra removes the target books the source
+lacks. --dry-run prints the changes without making them.
+`
+
+// runSync runs the sync command.
+func runSync(cfg *config.Config, args []string, out *printer) int {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	fs.SetOutput(out.stderr)
+	fs.Usage = func() { fmt.Fprint(out.stderr, syncUsage) }
+	var opts calibredb.SyncOptions
+	fs.StringVar(&opts.Search, "search", "", "sync only the source books matching this search")
+	conflict := fs.String("conflict", string(calibredb.ConflictSkip), "what to do with books changed in both libraries: skip or overwrite")
+	fs.BoolVar(&opts.RemoveExtra, "remove-extra", false, "remove the target books the source lacks")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "print the changes without making them")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprint(out.stderr, syncUsage)
+		return exitUsage
+	}
+	opts.Conflict = calibredb.ConflictPolicy(*conflict)
+
+	calibreOpts := []calibredb.CalibreOption{calibredb.WithActor(currentUser())}
+	auditLog, err := cfg.OpenAudit()
+	if err != nil {
+		return out.fail(err)
+	}
+	if auditLog != nil {
+		defer auditLog.Close()
+		calibreOpts = append(calibreOpts, calibredb.WithAudit(auditLog.Record))
+	}
+	source, err := cfg.NewCalibre("", calibreOpts...)
+	if err != nil {
+		fmt.Fprintln(out.stderr, "error:", err)
+		return exitUsage
+	}
+	targetCfg := *cfg
+	targetCfg.Libraries = slices.Clone(cfg.Libraries)
+	targetCfg.SelectLibrary(fs.Arg(0))
+	target, err := targetCfg.NewCalibre("", calibreOpts...)
+	if err != nil {
+		fmt.Fprintln(out.stderr, "error:", err)
+		return exitUsage
+	}
+
+	result, err := source.SyncTo(target, opts)
+	if err != nil {
+		kind := calibredb.ErrorKindOf(err)
+		out.error(err, kind)
+		return exitCodes[kind]
+	}
+	if out.json {
+		out.encode(out.stdout, result)
+	} else {
+		printSync(out, result)
+	}
+	if result.Failed > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// printSync prints a line for each book of result and a summary.
+func printSync(out *printer, result calibredb.SyncResult) {
+	groups := []struct {
+		name  string
+		items []calibredb.SyncItem
+	}{
+		{"add", result.Missing},
+		{"update", result.Changed},
+		{"conflict", result.Conflicts},
+		{"remove", result.Extra},
+	}
+	for _, g := range groups {
+		for _, item := range g.items {
+			line := fmt.Sprintf("%-8s %d -> %d %s", g.name, item.SourceID, item.TargetID, item.Title)
+			if len(item.Fields) > 0 {
+				line += " (" + strings.Join(item.Fields, ", ") + ")"
+			}
+			switch {
+			case item.Error != "":
+				line += ": " + item.Error
+			case !item.Applied && !result.DryRun:
+				line += ": skipped"
+			}
+			fmt.Fprintln(out.stdout, line)
+		}
+	}
+	verb := "synced"
+	if result.DryRun {
+		verb = "would sync"
+	}
+	fmt.Fprintf(out.stderr, "%s: %d to add, %d to update, %d conflicts, %d extra, %d unchanged, %d failed\n",
+		verb, len(result.Missing), len(result.Changed), len(result.Conflicts), len(result.Extra), result.Unchanged, result.Failed)
+}
@@ -0,0 +1,33 @@
+// Command compatcheck compares the generated calibredb wrappers with an
+// installed calibredb and exits with status 1 when they differ. Run it before
+// upgrading calibre:
+//
+//	go run ./cmd/compatcheck -calibredb /opt/calibre/calibredb
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+func main() {
+	calibredbPath := flag.String("calibredb", "calibredb", "path to the calibredb binary")
+	flag.Parse()
+
+	c := calibredb.NewCalibre(
+		calibredb.WithCalibreDBLocation(*calibredbPath),
+		calibredb.WithLibraryPath(os.TempDir()),
+	)
+	report, err := c.CheckCompatibility()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(2)
+	}
+	fmt.Print(report)
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,14 @@
+// Command fakecalibredb is a stand-in for calibre's calibredb that keeps the
+// library in a JSON file. Point calibredb.WithCalibreDBLocation (or
+// CALIBREDB_PATH for the test suite) at it to run without calibre installed.
+package main
+
+import (
+	"os"
+
+	"github.com/veverkap/calibre-rest/internal/fakecalibredb"
+)
+
+func main() {
+	os.Exit(fakecalibredb.Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
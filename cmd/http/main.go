@@ -1,22 +1,150 @@
-// Package main has the entry point for the limiter run
+// Package main has the entry point for the calibre REST server
 package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/config"
+	"github.com/veverkap/calibre-rest/metrics"
+	"github.com/veverkap/calibre-rest/server"
+	"github.com/veverkap/calibre-rest/watchfolder"
 )
 
 func main() {
-	cancelCtx, cancelAll := context.WithCancel(context.Background())
+	cancelCtx, cancelAll := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancelAll()
 
 	if err := realMain(cancelCtx); err != nil {
 		fmt.Println(fmt.Errorf("\nerror: %w", err))
 		cancelAll()
+		os.Exit(1)
 	}
 }
 
 // This is the real main function. That's why it's called realMain.
 func realMain(cancelCtx context.Context) error { //nolint:contextcheck // The newctx context comes from the StartTracer function, so it's already wrapped.
+	configPath := flag.String("config", "", "path of the JSON config file (default $CALIBRE_REST_CONFIG or "+config.DefaultPath()+")")
+	addr := flag.String("addr", "", "address to listen on (default :8080)")
+	library := flag.String("library", "", "name of a configured library, or the path or server URL of a library")
+	calibredbPath := flag.String("calibredb", "", "path to the calibredb binary (default calibredb)")
+	cache := flag.Bool("cache", false, "cache read commands until the library changes")
+	cacheTTL := flag.Duration("cache-ttl", 0, "also expire cached results after this long (0 keeps them until the library changes)")
+	printConfig := flag.Bool("print-config", false, "print the configuration with secrets redacted and exit")
+	var logLevel slog.Level
+	flag.TextVar(&logLevel, "log-level", slog.LevelInfo, "log requests at info and calibredb commands at debug `level`")
+	flag.Parse()
+
+	// flags override the config file and the environment
+	cfg, err := config.Load(*configPath, config.WithOverride(func(cfg *config.Config) {
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "addr":
+				cfg.Server.Addr = *addr
+			case "library":
+				cfg.SelectLibrary(*library)
+			case "calibredb":
+				cfg.Calibredb = *calibredbPath
+			case "cache":
+				cfg.Cache.Enabled = *cache
+			case "cache-ttl":
+				cfg.Cache.TTL = config.Duration(*cacheTTL)
+			}
+		})
+	}))
+	if err != nil {
+		return err
+	}
+	if *printConfig {
+		return cfg.Print(os.Stdout)
+	}
+
+	auditLog, err := cfg.OpenAudit()
+	if err != nil {
+		return err
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+	reg := metrics.NewRegistry()
+	calibreOpts := []calibredb.CalibreOption{
+		calibredb.WithMetrics(calibredb.NewMetrics(reg)),
+		calibredb.WithLogger(logger),
+	}
+	opts := []server.ServerOption{server.WithMetrics(reg), server.WithLogger(logger)}
+	if auditLog != nil {
+		defer auditLog.Close()
+		calibreOpts = append(calibreOpts, calibredb.WithAudit(auditLog.Record))
+		opts = append(opts, server.WithAuditLog(auditLog))
+	}
+	dispatcher, err := cfg.NewDispatcher()
+	if err != nil {
+		return err
+	}
+	if dispatcher != nil {
+		go dispatcher.Run(cancelCtx)
+		calibreOpts = append(calibreOpts, calibredb.WithEvents(dispatcher.Publish))
+	}
+	c, err := cfg.NewCalibre("", calibreOpts...)
+	if err != nil {
+		return err
+	}
+	watchCalibre := c
+	if cfg.Watch.Library != "" {
+		if watchCalibre, err = cfg.NewCalibre(cfg.Watch.Library, calibreOpts...); err != nil {
+			return err
+		}
+	}
+	var watchOpts []watchfolder.Option
+	if dispatcher != nil {
+		watchOpts = append(watchOpts, watchfolder.WithEvents(dispatcher.Publish))
+	}
+	watcher, err := cfg.NewWatcher(watchCalibre, watchOpts...)
+	if err != nil {
+		return err
+	}
+	if watcher != nil {
+		go watcher.Run(cancelCtx)
+	}
+	snapshots, err := cfg.SnapshotStore()
+	if err != nil {
+		return err
+	}
+	if snapshots != nil {
+		opts = append(opts, server.WithSnapshots(snapshots))
+	}
+	authenticator, err := cfg.Authenticator()
+	if err != nil {
+		return err
+	}
+	if authenticator != nil {
+		opts = append(opts, server.WithAuthenticator(authenticator))
+	} else {
+		logger.Warn("no auth.users_file or auth.keys_file configured, the API is open to anyone who can reach it", "addr", cfg.Server.Addr)
+	}
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           server.New(c, opts...),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
-	return nil
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-cancelCtx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
 }
@@ -0,0 +1,583 @@
+// Package config loads the runtime configuration shared by cmd/http and
+// cmd/cli: the calibre libraries, the calibredb binary, the listen address,
+// authentication, caching, the audit log, webhooks, watch folders and
+// snapshots.
+//
+// Settings come from three places, each overriding the one before: a JSON
+// config file, CALIBRE_REST_* environment variables, and explicit overrides
+// such as command line flags passed to Load with WithOverride.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/veverkap/calibre-rest/audit"
+	"github.com/veverkap/calibre-rest/auth"
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/snapshot"
+	"github.com/veverkap/calibre-rest/watchfolder"
+	"github.com/veverkap/calibre-rest/webhook"
+)
+
+// Config is the whole configuration.
+type Config struct {
+	// Calibredb is the path of the calibredb binary.
+	Calibredb string `json:"calibredb,omitempty"`
+	// Timeout applies to connections to a calibre Content server, e.g. "2m".
+	Timeout string `json:"timeout,omitempty"`
+	// Libraries are the libraries that can be selected by name.
+	Libraries []Library `json:"libraries,omitempty"`
+	// DefaultLibrary names the library used when none is selected. It may be
+	// left empty when there is a single library.
+	DefaultLibrary string    `json:"default_library,omitempty"`
+	Server         Server    `json:"server"`
+	Auth           Auth      `json:"auth"`
+	Cache          Cache     `json:"cache"`
+	Audit          Audit     `json:"audit"`
+	Webhooks       Webhooks  `json:"webhooks"`
+	Watch          Watch     `json:"watch"`
+	Snapshots      Snapshots `json:"snapshots"`
+	CLI            CLI       `json:"cli"`
+
+	// cache is shared by the Calibres of NewCalibre, so that a change made
+	// through one drops what the others cached.
+	cache *calibredb.Cache
+}
+
+// Library is a calibre library, a local folder or a Content server URL such
+// as http://localhost:8080/#mylibrary.
+type Library struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Server configures cmd/http.
+type Server struct {
+	Addr string `json:"addr,omitempty"`
+}
+
+// Auth configures the authentication of the REST server.
+type Auth struct {
+	// UsersFile holds "user:bcrypt-hash" lines for HTTP Basic.
+	UsersFile string `json:"users_file,omitempty"`
+	// KeysFile holds the API keys.
+	KeysFile string `json:"keys_file,omitempty"`
+}
+
+// Cache configures the read cache, which is off unless Enabled. Cached
+// results are dropped when the library changes; TTL also expires them after a
+// while, zero keeping them until then.
+type Cache struct {
+	Enabled    bool     `json:"enabled,omitempty"`
+	TTL        Duration `json:"ttl,omitempty"`
+	MaxEntries int      `json:"max_entries,omitempty"`
+}
+
+// Audit configures the log of commands that change a library; an empty File
+// disables it.
+type Audit struct {
+	File string `json:"file,omitempty"`
+	// MaxSizeMB rotates the file at this size, 10 when zero.
+	MaxSizeMB int `json:"max_size_mb,omitempty"`
+	// MaxFiles is the number of rotated files kept, 5 when zero.
+	MaxFiles int `json:"max_files,omitempty"`
+}
+
+// Webhooks configures the endpoints library changes are POSTed to.
+type Webhooks struct {
+	// QueueDir keeps pending deliveries across restarts.
+	QueueDir  string             `json:"queue_dir,omitempty"`
+	Endpoints []webhook.Endpoint `json:"endpoints,omitempty"`
+}
+
+// Watch configures the folders cmd/http imports e-books from; no Dirs
+// disables them.
+type Watch struct {
+	Dirs []string `json:"dirs,omitempty"`
+	// Library names the library to import into, the default one when empty.
+	Library string `json:"library,omitempty"`
+	// Interval between scans, 5s when zero.
+	Interval Duration `json:"interval,omitempty"`
+	// Settle is how long a file must stay unchanged, 10s when zero.
+	Settle    Duration                  `json:"settle,omitempty"`
+	Automerge calibredb.AutomergeChoice `json:"automerge,omitempty"`
+	Tags      string                    `json:"tags,omitempty"`
+	Languages string                    `json:"languages,omitempty"`
+}
+
+// Snapshots configures the store of library snapshots; an empty Dir disables
+// them. Without any Keep setting every snapshot is kept.
+type Snapshots struct {
+	Dir string `json:"dir,omitempty"`
+	// KeepLast keeps the newest snapshots.
+	KeepLast int `json:"keep_last,omitempty"`
+	// KeepDaily keeps the newest snapshot of as many days.
+	KeepDaily int `json:"keep_daily,omitempty"`
+	// KeepWeekly keeps the newest snapshot of as many weeks.
+	KeepWeekly int `json:"keep_weekly,omitempty"`
+}
+
+// CLI configures cmd/cli.
+type CLI struct {
+	// Output is json or table.
+	Output string `json:"output,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "5m" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Defaults are the settings before the file, environment and overrides.
+func Defaults() Config {
+	return Config{
+		Calibredb: "calibredb",
+		Server:    Server{Addr: ":8080"},
+		CLI:       CLI{Output: "table"},
+	}
+}
+
+// DefaultPath returns the config file read when no path is given and
+// CALIBRE_REST_CONFIG is not set: calibre-rest/config.json in the user config
+// directory.
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "calibre-rest", "config.json")
+}
+
+type loader struct {
+	lookupEnv func(string) (string, bool)
+	overrides []func(*Config)
+}
+
+// Option configures Load.
+type Option func(*loader)
+
+// WithLookupEnv replaces os.LookupEnv, mainly for tests.
+func WithLookupEnv(lookupEnv func(string) (string, bool)) Option {
+	return func(l *loader) {
+		l.lookupEnv = lookupEnv
+	}
+}
+
+// WithOverride applies fn after the file and the environment were read, e.g.
+// to apply the command line flags that were set.
+func WithOverride(fn func(*Config)) Option {
+	return func(l *loader) {
+		l.overrides = append(l.overrides, fn)
+	}
+}
+
+// Load reads the config file at path, applies the environment and the
+// overrides and validates the result. With an empty path CALIBRE_REST_CONFIG
+// or DefaultPath is read; only a file that was asked for must exist.
+func Load(path string, opts ...Option) (*Config, error) {
+	l := &loader{lookupEnv: os.LookupEnv}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	cfg := Defaults()
+	required := path != ""
+	if !required {
+		path, required = l.lookupEnv("CALIBRE_REST_CONFIG")
+	}
+	if !required {
+		path = DefaultPath()
+	}
+	if path != "" {
+		if err := readFile(&cfg, path, required); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(&cfg, l.lookupEnv); err != nil {
+		return nil, err
+	}
+	for _, fn := range l.overrides {
+		fn(&cfg)
+	}
+	applyCredentials(&cfg, l.lookupEnv)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func readFile(cfg *Config, path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv applies the CALIBRE_REST_* variables but the credentials.
+// CALIBRE_REST_LIBRARY selects a library, see SelectLibrary.
+func applyEnv(cfg *Config, lookupEnv func(string) (string, bool)) error {
+	vars := map[string]*string{
+		"CALIBRE_REST_CALIBREDB":       &cfg.Calibredb,
+		"CALIBRE_REST_TIMEOUT":         &cfg.Timeout,
+		"CALIBRE_REST_ADDR":            &cfg.Server.Addr,
+		"CALIBRE_REST_AUTH_USERS_FILE": &cfg.Auth.UsersFile,
+		"CALIBRE_REST_AUTH_KEYS_FILE":  &cfg.Auth.KeysFile,
+		"CALIBRE_REST_AUDIT_FILE":      &cfg.Audit.File,
+		"CALIBRE_REST_SNAPSHOTS_DIR":   &cfg.Snapshots.Dir,
+		"CALIBRE_REST_OUTPUT":          &cfg.CLI.Output,
+	}
+	for name, field := range vars {
+		if v, ok := lookupEnv(name); ok {
+			*field = v
+		}
+	}
+	if v, ok := lookupEnv("CALIBRE_REST_CACHE"); ok {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("config: CALIBRE_REST_CACHE: %w", err)
+		}
+		cfg.Cache.Enabled = enabled
+	}
+	if v, ok := lookupEnv("CALIBRE_REST_CACHE_TTL"); ok {
+		if err := cfg.Cache.TTL.UnmarshalText([]byte(v)); err != nil {
+			return fmt.Errorf("config: CALIBRE_REST_CACHE_TTL: %w", err)
+		}
+	}
+	if v, ok := lookupEnv("CALIBRE_REST_CACHE_MAX_ENTRIES"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("config: CALIBRE_REST_CACHE_MAX_ENTRIES: %w", err)
+		}
+		cfg.Cache.MaxEntries = n
+	}
+	if v, ok := lookupEnv("CALIBRE_REST_WATCH_DIRS"); ok {
+		cfg.Watch.Dirs = filepath.SplitList(v)
+	}
+	if v, ok := lookupEnv("CALIBRE_REST_LIBRARY"); ok {
+		cfg.SelectLibrary(v)
+	}
+	return nil
+}
+
+// applyCredentials sets CALIBRE_REST_USERNAME and _PASSWORD on the default
+// library. It runs after the overrides so that they follow a library picked
+// on the command line.
+func applyCredentials(cfg *Config, lookupEnv func(string) (string, bool)) {
+	i := cfg.defaultIndex()
+	if i < 0 {
+		return
+	}
+	if v, ok := lookupEnv("CALIBRE_REST_USERNAME"); ok {
+		cfg.Libraries[i].Username = v
+	}
+	if v, ok := lookupEnv("CALIBRE_REST_PASSWORD"); ok {
+		cfg.Libraries[i].Password = v
+	}
+}
+
+// SelectLibrary makes the library named nameOrPath the default. When no
+// library has that name it is taken as a path and becomes the "default"
+// library.
+func (c *Config) SelectLibrary(nameOrPath string) {
+	for _, l := range c.Libraries {
+		if l.Name == nameOrPath {
+			c.DefaultLibrary = nameOrPath
+			return
+		}
+	}
+	for i, l := range c.Libraries {
+		if l.Name == "default" {
+			c.Libraries[i].Path = nameOrPath
+			c.DefaultLibrary = "default"
+			return
+		}
+	}
+	c.Libraries = append(c.Libraries, Library{Name: "default", Path: nameOrPath})
+	c.DefaultLibrary = "default"
+}
+
+// defaultIndex returns the index of the default library, or -1.
+func (c *Config) defaultIndex() int {
+	if c.DefaultLibrary == "" && len(c.Libraries) == 1 {
+		return 0
+	}
+	for i, l := range c.Libraries {
+		if l.Name == c.DefaultLibrary {
+			return i
+		}
+	}
+	return -1
+}
+
+// Validate reports every problem of the configuration at once.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("config: "+format, args...))
+	}
+	if c.Calibredb == "" {
+		fail("calibredb must not be empty")
+	}
+	if c.Timeout != "" {
+		if _, err := strconv.ParseFloat(c.Timeout, 64); err != nil {
+			if _, err := time.ParseDuration(c.Timeout); err != nil {
+				fail("timeout %q is neither seconds nor a duration such as 2m", c.Timeout)
+			}
+		}
+	}
+	names := map[string]bool{}
+	for i, l := range c.Libraries {
+		switch {
+		case l.Name == "":
+			fail("libraries[%d]: name is required", i)
+		case names[l.Name]:
+			fail("libraries[%d]: duplicate name %q", i, l.Name)
+		}
+		names[l.Name] = true
+		if l.Path == "" {
+			fail("libraries[%d] (%s): path is required", i, l.Name)
+		}
+	}
+	if c.DefaultLibrary != "" && !names[c.DefaultLibrary] {
+		fail("default_library %q is not one of the libraries", c.DefaultLibrary)
+	}
+	if c.Cache.TTL < 0 {
+		fail("cache.ttl must not be negative")
+	}
+	if c.Cache.MaxEntries < 0 {
+		fail("cache.max_entries must not be negative")
+	}
+	if !c.Cache.Enabled && (c.Cache.TTL > 0 || c.Cache.MaxEntries > 0) {
+		fail("cache.ttl and cache.max_entries need cache.enabled")
+	}
+	if c.Audit.MaxSizeMB < 0 || c.Audit.MaxFiles < 0 {
+		fail("audit.max_size_mb and audit.max_files must not be negative")
+	}
+	for i, e := range c.Webhooks.Endpoints {
+		if u, err := url.Parse(e.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("webhooks.endpoints[%d]: url %q is not an http or https URL", i, e.URL)
+		}
+		for _, t := range e.Events {
+			if !slices.Contains(calibredb.EventTypes, t) {
+				fail("webhooks.endpoints[%d]: unknown event %q", i, t)
+			}
+		}
+	}
+	if c.Watch.Library != "" && !names[c.Watch.Library] {
+		fail("watch.library %q is not one of the libraries", c.Watch.Library)
+	}
+	if c.Watch.Interval < 0 || c.Watch.Settle < 0 {
+		fail("watch.interval and watch.settle must not be negative")
+	}
+	switch c.Watch.Automerge {
+	case "", calibredb.Disabled, calibredb.Ignore, calibredb.Overwrite, calibredb.NewRecord:
+	default:
+		fail("watch.automerge must be disabled, ignore, overwrite or new_record, not %q", c.Watch.Automerge)
+	}
+	if c.Snapshots.KeepLast < 0 || c.Snapshots.KeepDaily < 0 || c.Snapshots.KeepWeekly < 0 {
+		fail("snapshots.keep_last, keep_daily and keep_weekly must not be negative")
+	}
+	if c.CLI.Output != "json" && c.CLI.Output != "table" {
+		fail("cli.output must be json or table, not %q", c.CLI.Output)
+	}
+	return errors.Join(errs...)
+}
+
+// Library returns the library with the given name, or the default library
+// when name is empty.
+func (c *Config) Library(name string) (Library, error) {
+	if name == "" {
+		if i := c.defaultIndex(); i >= 0 {
+			return c.Libraries[i], nil
+		}
+		if len(c.Libraries) == 0 {
+			return Library{}, errors.New("config: no library configured")
+		}
+		return Library{}, errors.New("config: several libraries and no default_library")
+	}
+	for _, l := range c.Libraries {
+		if l.Name == name {
+			return l, nil
+		}
+	}
+	return Library{}, fmt.Errorf("config: unknown library %q", name)
+}
+
+// NewCalibre returns a Calibre for the named library (the default one when
+// name is empty), with the cache when one is configured. The Calibres of a
+// Config share the cache, which keeps the results of each library apart.
+// opts are applied last.
+func (c *Config) NewCalibre(name string, opts ...calibredb.CalibreOption) (*calibredb.Calibre, error) {
+	l, err := c.Library(name)
+	if err != nil {
+		return nil, err
+	}
+	all := []calibredb.CalibreOption{
+		calibredb.WithCalibreDBLocation(c.Calibredb),
+		calibredb.WithLibraryPath(l.Path),
+		calibredb.WithUsername(l.Username),
+		calibredb.WithPassword(l.Password),
+		calibredb.WithTimeout(c.Timeout),
+	}
+	if c.Cache.Enabled {
+		if c.cache == nil {
+			cacheOpts := []calibredb.CacheOption{calibredb.WithCacheTTL(time.Duration(c.Cache.TTL))}
+			if c.Cache.MaxEntries > 0 {
+				cacheOpts = append(cacheOpts, calibredb.WithCacheMaxEntries(c.Cache.MaxEntries))
+			}
+			c.cache = calibredb.NewCache(cacheOpts...)
+		}
+		all = append(all, calibredb.WithCache(c.cache))
+	}
+	return calibredb.NewCalibre(append(all, opts...)...), nil
+}
+
+// Authenticator returns the authenticator for the users and keys files, or nil
+// when neither is configured and the server is open.
+func (c *Config) Authenticator() (*auth.Authenticator, error) {
+	if c.Auth.UsersFile == "" && c.Auth.KeysFile == "" {
+		return nil, nil
+	}
+	var opts []auth.AuthenticatorOption
+	if c.Auth.UsersFile != "" {
+		users, err := auth.LoadUsers(c.Auth.UsersFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, auth.WithUsers(users))
+	}
+	if c.Auth.KeysFile != "" {
+		opts = append(opts, auth.WithKeyStore(auth.NewKeyStore(c.Auth.KeysFile)))
+	}
+	return auth.NewAuthenticator(opts...), nil
+}
+
+// OpenAudit opens the audit log, or returns nil when none is configured.
+// Pass its Record method to NewCalibre with calibredb.WithAudit.
+func (c *Config) OpenAudit() (*audit.Log, error) {
+	if c.Audit.File == "" {
+		return nil, nil
+	}
+	var opts []audit.Option
+	if c.Audit.MaxSizeMB > 0 {
+		opts = append(opts, audit.WithMaxSize(int64(c.Audit.MaxSizeMB)<<20))
+	}
+	if c.Audit.MaxFiles > 0 {
+		opts = append(opts, audit.WithMaxFiles(c.Audit.MaxFiles))
+	}
+	return audit.Open(c.Audit.File, opts...)
+}
+
+// NewDispatcher returns the webhook dispatcher, or nil when no endpoint is
+// configured. Pass its Publish method to NewCalibre with calibredb.WithEvents
+// and start its Run method.
+func (c *Config) NewDispatcher() (*webhook.Dispatcher, error) {
+	if len(c.Webhooks.Endpoints) == 0 {
+		return nil, nil
+	}
+	var opts []webhook.Option
+	if c.Webhooks.QueueDir != "" {
+		opts = append(opts, webhook.WithQueueDir(c.Webhooks.QueueDir))
+	}
+	return webhook.New(c.Webhooks.Endpoints, opts...)
+}
+
+// NewWatcher returns the watcher of the watch folders, or nil when none is
+// configured. calibre should be made with NewCalibre for Watch.Library; start
+// the Run method of the watcher.
+func (c *Config) NewWatcher(calibre *calibredb.Calibre, opts ...watchfolder.Option) (*watchfolder.Watcher, error) {
+	if len(c.Watch.Dirs) == 0 {
+		return nil, nil
+	}
+	all := []watchfolder.Option{
+		watchfolder.WithAutomerge(c.Watch.Automerge),
+		watchfolder.WithTags(c.Watch.Tags),
+		watchfolder.WithLanguages(c.Watch.Languages),
+	}
+	if c.Watch.Interval > 0 {
+		all = append(all, watchfolder.WithInterval(time.Duration(c.Watch.Interval)))
+	}
+	if c.Watch.Settle > 0 {
+		all = append(all, watchfolder.WithSettle(time.Duration(c.Watch.Settle)))
+	}
+	return watchfolder.New(calibre, c.Watch.Dirs, append(all, opts...)...)
+}
+
+// SnapshotStore returns the store of library snapshots, or nil when none is
+// configured.
+func (c *Config) SnapshotStore() (*snapshot.Store, error) {
+	if c.Snapshots.Dir == "" {
+		return nil, nil
+	}
+	return snapshot.NewStore(c.Snapshots.Dir,
+		snapshot.WithKeepLast(c.Snapshots.KeepLast),
+		snapshot.WithKeepDaily(c.Snapshots.KeepDaily),
+		snapshot.WithKeepWeekly(c.Snapshots.KeepWeekly),
+	)
+}
+
+// redacted replaces every secret.
+const redacted = "REDACTED"
+
+// Redacted returns a copy of the configuration with the secrets replaced.
+func (c *Config) Redacted() Config {
+	out := *c
+	out.Libraries = make([]Library, len(c.Libraries))
+	for i, l := range c.Libraries {
+		if l.Password != "" {
+			l.Password = redacted
+		}
+		l.Path = calibredb.RedactURL(l.Path)
+		out.Libraries[i] = l
+	}
+	out.Webhooks.Endpoints = make([]webhook.Endpoint, len(c.Webhooks.Endpoints))
+	for i, e := range c.Webhooks.Endpoints {
+		if e.Secret != "" {
+			e.Secret = redacted
+		}
+		e.URL = calibredb.RedactURL(e.URL)
+		out.Webhooks.Endpoints[i] = e
+	}
+	return out
+}
+
+// Print writes the configuration as JSON with the secrets redacted, for the
+// --print-config mode of the commands.
+func (c *Config) Print(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.Redacted())
+}
@@ -1,16 +1,27 @@
 //go:build ignore
 
 // To run: go run generate.go
+//
+// To rebuild combined_calibredb_options.json from the help output of the
+// installed calibredb first, without network access:
+//
+//	go run generate.go -from-help [-calibredb /path/to/calibredb]
 package main
 
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"go/format"
+	"maps"
 	"os"
+	"os/exec"
+	"slices"
 	"strings"
 
 	"github.com/samber/lo"
+	"github.com/veverkap/calibre-rest/internal/calibrehelp"
 )
 
 type Options struct {
@@ -19,11 +30,21 @@ type Options struct {
 	Default     any      `json:"default"`
 	Type        string   `json:"type"`
 	Choices     string   `json:"choices"`
+	Range       string   `json:"range"`
+	Validate    string   `json:"validate"`
 }
 type Args struct {
 	Name    string `json:"name"`
 	Type    string `json:"type"`
-	Choices any    `json:"choices"`
+	Choices string `json:"choices"`
+	Range   string `json:"range"`
+	All     string `json:"all"`
+	// Validate holds extra validator rules, e.g. "bookid" or "gte=0"
+	Validate string `json:"validate"`
+	// Required replaces the "required" rule, e.g. "required_without=Field"
+	// for an argument an option can stand in for, which is left out when
+	// empty, or "omitempty" for one that may be passed empty.
+	Required string `json:"required"`
 }
 type Combined struct {
 	Name        string    `json:"name"`
@@ -34,6 +55,16 @@ type Combined struct {
 }
 
 func main() {
+	fromHelp := flag.Bool("from-help", false, "rebuild combined_calibredb_options.json from calibredb's help output")
+	calibredb := flag.String("calibredb", "calibredb", "calibredb binary to read the help output of")
+	flag.Parse()
+
+	if *fromHelp {
+		if err := specFromHelp(*calibredb); err != nil {
+			panic(err)
+		}
+	}
+
 	jsonData, err := os.ReadFile("combined_calibredb_options.json")
 	if err != nil {
 		panic(err)
@@ -80,7 +111,7 @@ func main() {
 
 		fmt.Println("--Generating struct", structName)
 		out.WriteString("type " + structName + " struct {\n")
-		choices := make(map[string]string)
+		choices := make(map[string][]string)
 
 		if len(cmd.Args) > 0 {
 			out.WriteString("\t// Command Line Arguments\n")
@@ -90,7 +121,18 @@ func main() {
 				if argType == "bool" {
 					argType = "*bool"
 				}
-				out.WriteString(fmt.Sprintf("\t%s %s  `validate:\"required\"`\n", fieldName, argType))
+				if argType == "ids" {
+					argType = "BookIDs"
+				}
+				required := "required"
+				if arg.Required != "" {
+					required = arg.Required
+				}
+				rules := append([]string{required}, oneOf(arg.Choices)...)
+				if arg.Validate != "" {
+					rules = append(rules, arg.Validate)
+				}
+				out.WriteString(fmt.Sprintf("\t%s %s  `validate:\"%s\"`\n", fieldName, argType, strings.Join(rules, ",")))
 			}
 		}
 
@@ -116,34 +158,54 @@ func main() {
 					fieldType = "float64"
 				case "bool":
 					fieldType = "*bool"
+				case "ids":
+					fieldType = "BookIDs"
 				case "choice":
 					fieldType = fmt.Sprintf("%sChoice", fieldName)
-					choices[fieldType] = option.Choices
+					// choices computed at runtime by calibre, e.g.
+					// csv.list_dialects(), come from the oneof rule and
+					// get the field name as prefix
+					if values := choiceValues(option.Choices); len(values) > 0 {
+						choices[fieldType] = lo.Map(values, func(v string, _ int) string { return lo.PascalCase(v) + "=" + v })
+					} else {
+						values = strings.Fields(strings.TrimPrefix(lo.FindOrElse(strings.Split(option.Validate, ","), "", func(r string) bool {
+							return strings.HasPrefix(r, "oneof=")
+						}), "oneof="))
+						choices[fieldType] = lo.Map(values, func(v string, _ int) string { return fieldName + lo.PascalCase(v) + "=" + v })
+					}
 				default:
 					panic("unknown type: " + option.Type)
 					fieldType = "string"
 				}
-				out.WriteString(fmt.Sprintf("\t%s %s  // %s\n", fieldName, fieldType, strings.ReplaceAll(option.Description, "\n", " ")))
+				var rules []string
+				if option.Type == "choice" {
+					if rule := oneOf(option.Choices); len(rule) > 0 {
+						rules = append(rules, "omitempty")
+						rules = append(rules, rule...)
+					}
+				}
+				if option.Validate != "" {
+					rules = append(rules, option.Validate)
+				}
+				tag := fmt.Sprintf(" `flag:\"%s\"", columnName)
+				if len(rules) > 0 {
+					tag += fmt.Sprintf(" validate:\"%s\"", strings.Join(rules, ","))
+				}
+				tag += "`"
+				out.WriteString(fmt.Sprintf("\t%s %s%s  // %s\n", fieldName, fieldType, tag, strings.ReplaceAll(option.Description, "\n", " ")))
 			}
 		}
 		out.WriteString("}\n")
 
 		// generate choice types
 		if len(choices) > 0 {
-			for choiceType, choiceValues := range choices {
+			for _, choiceType := range slices.Sorted(maps.Keys(choices)) {
 				out.WriteString("\n")
 				out.WriteString(fmt.Sprintf("type %s string\n\n", choiceType))
 				out.WriteString("const (\n")
-				// parse choiceValues which is in format "('disabled', 'ignore', 'overwrite', 'new_record')"
-				trimmed := strings.Trim(choiceValues, "()")
-				split := strings.Split(trimmed, ",")
-				for _, val := range split {
-					cleaned := strings.Trim(val, " '\"")
-					if cleaned == "" {
-						continue
-					}
-					constName := lo.PascalCase(cleaned)
-					out.WriteString(fmt.Sprintf("\t%s %s = \"%s\"\n", constName, choiceType, cleaned))
+				for _, c := range choices[choiceType] {
+					constName, value, _ := strings.Cut(c, "=")
+					out.WriteString(fmt.Sprintf("\t%s %s = \"%s\"\n", constName, choiceType, value))
 				}
 				out.WriteString(")\n")
 			}
@@ -165,15 +227,27 @@ func main() {
 		out.WriteString("\t\treturn \"\", err\n")
 		out.WriteString("\t}\n")
 
-		if len(cmd.Args) > 0 {
+		// book ids are passed last so that large sets can be split across
+		// several invocations
+		var idsArg *Args
+		positional := lo.Filter(cmd.Args, func(arg Args, _ int) bool {
+			if arg.Type == "ids" {
+				idsArg = &arg
+				return false
+			}
+			return true
+		})
+		if len(positional) > 0 {
 			out.WriteString("\t// Command Line Arguments\n")
-			for _, arg := range cmd.Args {
+			for _, arg := range positional {
 				fieldName := lo.PascalCase(arg.Name)
 
-				switch arg.Type {
-				case "string":
+				switch {
+				case arg.Type == "string" && strings.HasPrefix(arg.Required, "required_without"):
+					out.WriteString(fmt.Sprintf("\tif opts.%s != \"\" {\n\t\targv = append(argv, opts.%s)\n\t}\n", fieldName, fieldName))
+				case arg.Type == "string":
 					out.WriteString(fmt.Sprintf("\targv = append(argv, opts.%s)\n", fieldName))
-				case "[]string":
+				case arg.Type == "[]string":
 					out.WriteString(fmt.Sprintf("\targv = append(argv, opts.%s...)\n", fieldName))
 				}
 			}
@@ -195,9 +269,8 @@ func main() {
 					out.WriteString("\t}\n")
 				case "[]string":
 					out.WriteString("\t// Handling []string\n")
-					out.WriteString(fmt.Sprintf("\tif len(opts.%s) > 0 {\n", fieldName))
-					out.WriteString(fmt.Sprintf("\t\targv = append(argv, \"%s\")\n", columnName))
-					out.WriteString(fmt.Sprintf("\t\targv = append(argv, opts.%s...)\n", fieldName))
+					out.WriteString(fmt.Sprintf("\tfor _, v := range opts.%s {\n", fieldName))
+					out.WriteString(fmt.Sprintf("\t\targv = append(argv, \"%s\", v)\n", columnName))
 					out.WriteString("\t}\n")
 				case "bool":
 					out.WriteString("\t// Handling bool\n")
@@ -214,6 +287,15 @@ func main() {
 					out.WriteString(fmt.Sprintf("\tif opts.%s != 0 {\n", fieldName))
 					out.WriteString(fmt.Sprintf("\t\targv = append(argv, \"%s\", fmt.Sprint(opts.%s))\n", columnName, fieldName))
 					out.WriteString("\t}\n")
+				case "ids":
+					out.WriteString("\t// Handling book ids\n")
+					out.WriteString(fmt.Sprintf("\tif !opts.%s.IsEmpty() && !opts.%s.All() {\n", fieldName, fieldName))
+					out.WriteString(fmt.Sprintf("\t\tids, err := bookIDsOption(opts.%s, %s)\n", fieldName, rangeStyle(option.Range)))
+					out.WriteString("\t\tif err != nil {\n")
+					out.WriteString("\t\t\treturn \"\", err\n")
+					out.WriteString("\t\t}\n")
+					out.WriteString(fmt.Sprintf("\t\targv = append(argv, \"%s\", ids)\n", columnName))
+					out.WriteString("\t}\n")
 				case "choice":
 					out.WriteString("\t// Handling choice\n")
 					out.WriteString(fmt.Sprintf("\tif opts.%s != \"\" {\n", fieldName))
@@ -229,8 +311,14 @@ func main() {
 			}
 		}
 
-		out.WriteString("\tout, err := c.run(argv...)\n")
-		out.WriteString("\treturn out, err\n")
+		out.WriteString("\targv = append(argv, args...)\n")
+		if idsArg != nil {
+			out.WriteString("\t// Handling book ids\n")
+			out.WriteString(fmt.Sprintf("\treturn c.runBookIDs(argv, opts.%s, %s, \"%s\")\n", lo.PascalCase(idsArg.Name), rangeStyle(idsArg.Range), idsArg.All))
+		} else {
+			out.WriteString("\tout, err := c.run(argv...)\n")
+			out.WriteString("\treturn out, err\n")
+		}
 		out.WriteString("}\n")
 
 		err = os.WriteFile(fmt.Sprintf("calibredb/%s.go", name), out.Bytes(), 0644)
@@ -238,6 +326,195 @@ func main() {
 			panic(err)
 		}
 	}
+
+	writeCommands(combined)
+	writeArgvCases(combined)
+}
+
+// writeCommands emits calibredb/commands.go, the registry of wrapped commands
+// used to compare the wrappers with an installed calibredb.
+func writeCommands(combined map[string]Combined) {
+	var out bytes.Buffer
+	out.WriteString("// Code generated by generate.go; DO NOT EDIT.\n\n")
+	out.WriteString("package calibredb\n\n")
+	out.WriteString("import \"reflect\"\n\n")
+	out.WriteString("// commandOptions maps every wrapped calibredb command to its options struct.\n")
+	out.WriteString("var commandOptions = map[string]reflect.Type{\n")
+	names := make([]string, 0, len(combined))
+	styles := map[string]string{}
+	for key, cmd := range combined {
+		name := strings.Replace(key, "cmd_", "", 1)
+		names = append(names, name)
+		for _, arg := range cmd.Args {
+			if arg.Type == "ids" {
+				styles[name] = rangeStyle(arg.Range)
+			}
+		}
+		for _, option := range cmd.Options {
+			if option.Type == "ids" {
+				styles[name] = rangeStyle(option.Range)
+			}
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		out.WriteString(fmt.Sprintf("\t%q: reflect.TypeFor[%sOptions](),\n", name, lo.PascalCase(name)))
+	}
+	out.WriteString("}\n\n")
+	out.WriteString("// commandRunners calls the wrapper of every command with its options struct\n// and extra arguments.\n")
+	out.WriteString("var commandRunners = map[string]func(c *Calibre, opts any, args ...string) (string, error){\n")
+	for _, name := range names {
+		pascal := lo.PascalCase(name)
+		out.WriteString(fmt.Sprintf("\t%q: func(c *Calibre, opts any, args ...string) (string, error) {\n\t\treturn c.%s(opts.(%sOptions), args...)\n\t},\n", name, pascal, pascal))
+	}
+	out.WriteString("}\n\n")
+	out.WriteString("// commandRangeStyles maps the commands that take book ids to how they read\n// the end of a range.\n")
+	out.WriteString("var commandRangeStyles = map[string]RangeStyle{\n")
+	for _, name := range names {
+		if style, ok := styles[name]; ok {
+			out.WriteString(fmt.Sprintf("\t%q: %s,\n", name, style))
+		}
+	}
+	out.WriteString("}\n")
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile("calibredb/commands.go", src, 0644); err != nil {
+		panic(err)
+	}
+}
+
+// sampleArgs are the values used for positional arguments in the argv test
+// table when the argument name alone would not be a plausible value.
+var sampleArgs = map[string]string{
+	"id":                            "1",
+	"book_id":                       "1",
+	"enable/disable/status/reindex": "status",
+	"datatype":                      "text",
+	"expression":                    "title:dune",
+}
+
+// sampleOptions override the sample value of string and choice options.
+var sampleOptions = map[string]string{
+	"--dialect": "excel",
+	"--display": "{}",
+}
+
+// writeArgvCases emits calibredb/argv_cases_test.go: for every command a case
+// with only the required arguments, one case per option and one passing extra
+// arguments. argv_test.go runs them and compares the argv against golden files.
+func writeArgvCases(combined map[string]Combined) {
+	var out bytes.Buffer
+	out.WriteString("// Code generated by generate.go; DO NOT EDIT.\n\n")
+	out.WriteString("package calibredb_test\n\n")
+	out.WriteString("import \"github.com/veverkap/calibre-rest/calibredb\"\n\n")
+	out.WriteString("var argvCases = map[string][]argvCase{\n")
+
+	names := make([]string, 0, len(combined))
+	for name := range combined {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, key := range names {
+		cmd := combined[key]
+		name := strings.Replace(key, "cmd_", "", 1)
+		pascalCmd := lo.PascalCase(name)
+		structName := "calibredb." + pascalCmd + "Options"
+
+		var required []string
+		for _, arg := range cmd.Args {
+			required = append(required, fmt.Sprintf("%s: %s", lo.PascalCase(arg.Name), sampleArg(arg)))
+		}
+		writeCase := func(caseName string, fields []string, args string) {
+			out.WriteString(fmt.Sprintf("\t\t{%q, func(c *calibredb.Calibre) (string, error) {\n", caseName))
+			out.WriteString(fmt.Sprintf("\t\t\treturn c.%s(%s{%s}%s)\n", pascalCmd, structName, strings.Join(fields, ", "), args))
+			out.WriteString("\t\t}},\n")
+		}
+
+		out.WriteString(fmt.Sprintf("\t%q: {\n", name))
+		writeCase("required", required, "")
+		for _, option := range cmd.Options {
+			if len(option.Names) == 0 {
+				continue
+			}
+			columnName := loadColumnName(option)
+			fieldName := lo.PascalCase(strings.TrimLeft(columnName, "-"))
+			field := fmt.Sprintf("%s: %s", fieldName, sampleOption(option, fieldName))
+			writeCase(columnName, append(slices.Clone(required), field), "")
+		}
+		writeCase("args", required, `, "extra"`)
+		// an argument an option stands in for is left out with the option
+		// set, and fails validation without either
+		for i, arg := range cmd.Args {
+			other, ok := strings.CutPrefix(arg.Required, "required_without=")
+			if !ok {
+				continue
+			}
+			without := slices.Delete(slices.Clone(required), i, i+1)
+			for _, option := range cmd.Options {
+				if len(option.Names) == 0 {
+					continue
+				}
+				fieldName := lo.PascalCase(strings.TrimLeft(loadColumnName(option), "-"))
+				if fieldName == other {
+					field := fmt.Sprintf("%s: %s", fieldName, sampleOption(option, fieldName))
+					writeCase("without "+arg.Name, append(slices.Clone(without), field), "")
+				}
+			}
+			writeCase("without "+arg.Name+" or "+other, without, "")
+		}
+		out.WriteString("\t},\n")
+	}
+	out.WriteString("}\n")
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile("calibredb/argv_cases_test.go", src, 0644); err != nil {
+		panic(err)
+	}
+}
+
+func sampleArg(arg Args) string {
+	switch arg.Type {
+	case "ids":
+		return "calibredb.NewBookIDs(1, 2, 3, 5)"
+	case "[]string":
+		return fmt.Sprintf("[]string{%q, %q}", arg.Name+"1", arg.Name+"2")
+	}
+	if v, ok := sampleArgs[arg.Name]; ok {
+		return fmt.Sprintf("%q", v)
+	}
+	return fmt.Sprintf("%q", arg.Name)
+}
+
+func sampleOption(option Options, fieldName string) string {
+	columnName := loadColumnName(option)
+	switch option.Type {
+	case "[]string":
+		return `[]string{"one", "two"}`
+	case "bool":
+		return "ptr(true)"
+	case "int":
+		return "3"
+	case "float":
+		return "2.5"
+	case "ids":
+		return "calibredb.NewBookIDs(1, 2, 3, 5)"
+	case "choice":
+		value, ok := sampleOptions[columnName]
+		if !ok {
+			value = strings.Trim(strings.Split(strings.Trim(option.Choices, "()"), ",")[0], " '\"")
+		}
+		return fmt.Sprintf("calibredb.%sChoice(%q)", fieldName, value)
+	}
+	if v, ok := sampleOptions[columnName]; ok {
+		return fmt.Sprintf("%q", v)
+	}
+	return `"value"`
 }
 
 func loadColumnName(option Options) string {
@@ -259,3 +536,88 @@ func loadColumnName(option Options) string {
 	}
 	return columnName
 }
+
+// choiceValues parses a Python tuple literal such as "('fast', 'slow', '')".
+// Anything else, e.g. a call like csv.list_dialects(), yields nil.
+func choiceValues(choices string) []string {
+	if !strings.HasPrefix(choices, "(") {
+		return nil
+	}
+	var values []string
+	for _, val := range strings.Split(strings.Trim(choices, "()"), ",") {
+		if cleaned := strings.Trim(val, " '\""); cleaned != "" {
+			values = append(values, cleaned)
+		}
+	}
+	return values
+}
+
+// oneOf returns the oneof rule for a choices literal, or nothing when the
+// values are not known statically.
+func oneOf(choices string) []string {
+	values := choiceValues(choices)
+	if len(values) == 0 {
+		return nil
+	}
+	return []string{"oneof=" + strings.Join(values, " ")}
+}
+
+// rangeStyle maps the "range" metadata of an ids argument to the RangeStyle
+// constant understood by the calibredb package.
+func rangeStyle(style string) string {
+	if style == "inclusive" {
+		return "RangeInclusive"
+	}
+	return "RangeExclusive"
+}
+
+// specFromHelp writes combined_calibredb_options.json from the help of every
+// command calibredb lists, with calibredb_overrides.json applied on top. The
+// output depends only on the help output and the overrides.
+func specFromHelp(calibredb string) error {
+	help := func(args ...string) (string, error) {
+		out, err := exec.Command(calibredb, args...).Output()
+		if err != nil {
+			return "", fmt.Errorf("%s %s: %w", calibredb, strings.Join(args, " "), err)
+		}
+		return string(out), nil
+	}
+
+	overrides := map[string]calibrehelp.Override{}
+	data, err := os.ReadFile("calibredb_overrides.json")
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return err
+	}
+
+	main, err := help("--help")
+	if err != nil {
+		return err
+	}
+	specs := map[string]calibrehelp.Spec{}
+	for _, name := range calibrehelp.Commands(main) {
+		out, err := help(name, "-h")
+		if err != nil {
+			return err
+		}
+		spec := calibrehelp.Parse(out).Spec(name)
+		for _, option := range spec.Apply(overrides[name]) {
+			fmt.Printf("  - override of %s %s matches no option\n", name, option)
+		}
+		specs[name] = spec
+	}
+	for name := range overrides {
+		if _, ok := specs[name]; !ok {
+			fmt.Println("  - override of", name, "matches no command")
+		}
+	}
+
+	out, err := calibrehelp.Marshal(specs)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Writing combined_calibredb_options.json from the help of", len(specs), "commands")
+	return os.WriteFile("combined_calibredb_options.json", out, 0644)
+}
@@ -1,6 +1,11 @@
 #!/usr/bin/env bash
 set -euo pipefail
 
+# This script needs network access, python3 and jq. To regenerate offline
+# from the installed calibredb instead, run:
+#
+#   go run generate.go -from-help -calibredb /path/to/calibredb
+
 FOLDER_URL="https://api.github.com/repos/kovidgoyal/calibre/contents/src/calibre/db/cli"
 OUTPUT_JSON="$(pwd)/parsed.json"
 
@@ -184,4 +189,7 @@ rm -f parsed.json scraped.json
 
 echo "==> Generating Go structs from combined JSON ..."
 go run generate.go
+
+echo "==> Refreshing golden argv files (review the diff) ..."
+go test ./calibredb -run 'TestArgv$' -update
 echo "✓ Done."
\ No newline at end of file
@@ -5,6 +5,7 @@ go 1.24.4
 require (
 	github.com/go-playground/validator/v10 v10.28.0
 	github.com/samber/lo v1.52.0
+	golang.org/x/crypto v0.42.0
 )
 
 require (
@@ -12,7 +13,6 @@ require (
 	github.com/go-playground/locales v0.14.1 // indirect
 	github.com/go-playground/universal-translator v0.18.1 // indirect
 	github.com/leodido/go-urn v1.4.0 // indirect
-	golang.org/x/crypto v0.42.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 	golang.org/x/text v0.29.0 // indirect
 )
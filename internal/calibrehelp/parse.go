@@ -0,0 +1,220 @@
+// Package calibrehelp parses the optparse help output of calibredb, both the
+// command list printed by "calibredb --help" and the per command help printed
+// by "calibredb <command> -h".
+package calibrehelp
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Command is the parsed help of one calibredb command.
+type Command struct {
+	// Usage is the usage line without the "Usage: " prefix, e.g.
+	// "calibredb add [options] file1 file2 file3 ...".
+	Usage string
+	// Description is the text between the usage and the option list, with
+	// the boilerplate about quoting arguments removed.
+	Description string
+	Options     []Option
+}
+
+// Option is one entry of the option list.
+type Option struct {
+	// Names lists the option strings in the order shown, e.g. ["-a", "--authors"].
+	Names []string
+	// Metavar is the placeholder of the option value, empty for flags.
+	Metavar string
+	Help    string
+	// Group is the heading of the option group, e.g. "GLOBAL OPTIONS", or
+	// empty for the command's own options.
+	Group string
+}
+
+// Long returns the first long option name, or the first name when there is
+// no long form.
+func (o Option) Long() string {
+	for _, n := range o.Names {
+		if strings.HasPrefix(n, "--") {
+			return n
+		}
+	}
+	if len(o.Names) > 0 {
+		return o.Names[0]
+	}
+	return ""
+}
+
+// Has reports whether name is one of the option's names.
+func (o Option) Has(name string) bool {
+	for _, n := range o.Names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFlag reports whether the option takes no value.
+func (o Option) IsFlag() bool {
+	return o.Metavar == ""
+}
+
+var choicesRe = regexp.MustCompile(`Choices: ([^.]*?)\s*$`)
+
+// Choices returns the values listed in a trailing "Choices: a, b" sentence.
+func (o Option) Choices() []string {
+	m := choicesRe.FindStringSubmatch(o.Help)
+	if m == nil {
+		return nil
+	}
+	var choices []string
+	for _, c := range strings.Split(m[1], ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			choices = append(choices, c)
+		}
+	}
+	return choices
+}
+
+// Repeatable reports whether the help says the option may be given several
+// times, either in words or by example ("-I asin:XXX -I isbn:YYY").
+func (o Option) Repeatable() bool {
+	if strings.Contains(o.Help, "multiple times") {
+		return true
+	}
+	for _, n := range o.Names {
+		if strings.Count(o.Help, n+" ") >= 2 {
+			return true
+		}
+	}
+	return false
+}
+
+// quotingNote is printed by calibredb in the help of every command.
+const quotingNote = "Whenever you pass arguments to calibredb that have spaces in them"
+
+// Parse parses the output of "calibredb <command> -h".
+func Parse(help string) Command {
+	lines := strings.Split(strings.ReplaceAll(help, "\r\n", "\n"), "\n")
+	var cmd Command
+	i := 0
+	for ; i < len(lines); i++ {
+		if rest, ok := strings.CutPrefix(lines[i], "Usage: "); ok {
+			cmd.Usage = strings.TrimSpace(rest)
+			i++
+			break
+		}
+	}
+	// the usage may continue on the following lines up to a blank line
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		cmd.Usage += "\n" + lines[i]
+	}
+
+	var paragraphs []string
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			text := strings.Join(paragraph, "\n")
+			if !strings.HasPrefix(text, quotingNote) {
+				paragraphs = append(paragraphs, text)
+			}
+			paragraph = nil
+		}
+	}
+	for ; i < len(lines) && lines[i] != "Options:"; i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			flush()
+			continue
+		}
+		paragraph = append(paragraph, strings.TrimRight(lines[i], " "))
+	}
+	flush()
+	cmd.Description = strings.Join(paragraphs, "\n\n")
+
+	var current *Option
+	group := ""
+	for i++; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " ")
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		switch {
+		case trimmed == "":
+			current = nil
+		case strings.HasPrefix(trimmed, "-") && indent <= 4:
+			invocation, help, _ := strings.Cut(trimmed, "  ")
+			cmd.Options = append(cmd.Options, parseInvocation(invocation, strings.TrimSpace(help), group))
+			current = &cmd.Options[len(cmd.Options)-1]
+		case indent == 2 && strings.HasSuffix(trimmed, ":"):
+			group = strings.TrimSuffix(trimmed, ":")
+			current = nil
+		case current != nil:
+			if current.Help == "" {
+				current.Help = trimmed
+			} else {
+				current.Help += " " + trimmed
+			}
+		}
+	}
+	cmd.Options = filterBuiltin(cmd.Options)
+	return cmd
+}
+
+func parseInvocation(invocation, help, group string) Option {
+	opt := Option{Help: help, Group: group}
+	for _, form := range strings.Split(invocation, ", ") {
+		name, metavar, ok := strings.Cut(form, "=")
+		if !ok {
+			name, metavar, _ = strings.Cut(form, " ")
+		}
+		opt.Names = append(opt.Names, name)
+		if metavar != "" {
+			opt.Metavar = metavar
+		}
+	}
+	return opt
+}
+
+// filterBuiltin drops -h/--help and --version which every command has.
+func filterBuiltin(options []Option) []Option {
+	out := options[:0]
+	for _, o := range options {
+		if o.Has("--help") || o.Has("--version") {
+			continue
+		}
+		out = append(out, o)
+	}
+	return out
+}
+
+// CommandOptions returns the options that belong to the command itself,
+// leaving out the global options shared by all commands.
+func (c Command) CommandOptions() []Option {
+	var out []Option
+	for _, o := range c.Options {
+		if o.Group != "GLOBAL OPTIONS" {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// Commands parses the command list printed by "calibredb --help".
+func Commands(help string) []string {
+	var commands []string
+	in := false
+	for _, line := range strings.Split(help, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "command is one of"):
+			in = true
+		case in && trimmed == "":
+			if len(commands) > 0 {
+				return commands
+			}
+		case in:
+			commands = append(commands, trimmed)
+		}
+	}
+	return commands
+}
@@ -0,0 +1,239 @@
+package calibrehelp_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/internal/calibrehelp"
+)
+
+const mainHelp = `Usage: calibredb command [options] [arguments]
+
+calibredb is the command line interface to the calibre books database.
+
+command is one of:
+  add
+  list
+  remove
+
+For help on an individual command: calibredb command --help
+
+Options:
+  -h, --help            show this help message and exit
+  --version             show program's version number and exit
+`
+
+const addHelp = `Usage: calibredb add [options] file1 file2 file3 ...
+
+Add the specified files as books to the database. You can also specify
+folders, see the folder related options below.
+
+Whenever you pass arguments to calibredb that have spaces in them, enclose the arguments in quotation marks. For example: "/some path/with spaces"
+
+Options:
+  -h, --help            show this help message and exit
+  --version             show program's version number and exit
+  -d, --duplicates      Add books to database even if they already exist.
+  -a AUTHORS, --authors=AUTHORS
+                        Set the authors of the added book(s)
+  -I IDENTIFIER, --identifier=IDENTIFIER
+                        Set the identifiers for this book, e.g. -I asin:XXX -I
+                        isbn:YYY
+  --automerge=AUTOMERGE
+                        Choices: ignore, overwrite, new_record
+
+  ADDING FROM FOLDERS:
+    -r, --recurse       Process folders recursively
+
+  GLOBAL OPTIONS:
+    --library-path=LIBRARY_PATH, --with-library=LIBRARY_PATH
+                        Path to the calibre library.
+    --timeout=TIMEOUT   The timeout, in seconds.
+`
+
+func TestCommands(t *testing.T) {
+	got := calibrehelp.Commands(mainHelp)
+	want := []string{"add", "list", "remove"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Commands() = %q, want %q", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	cmd := calibrehelp.Parse(addHelp)
+	if want := "calibredb add [options] file1 file2 file3 ..."; cmd.Usage != want {
+		t.Errorf("Usage = %q, want %q", cmd.Usage, want)
+	}
+	if want := "Add the specified files as books to the database. You can also specify\nfolders, see the folder related options below."; cmd.Description != want {
+		t.Errorf("Description = %q, want %q", cmd.Description, want)
+	}
+
+	tests := []struct {
+		long       string
+		names      []string
+		flag       bool
+		choices    []string
+		repeatable bool
+		group      string
+	}{
+		{long: "--duplicates", names: []string{"-d", "--duplicates"}, flag: true},
+		{long: "--authors", names: []string{"-a", "--authors"}},
+		{long: "--identifier", names: []string{"-I", "--identifier"}, repeatable: true},
+		{long: "--automerge", names: []string{"--automerge"}, choices: []string{"ignore", "overwrite", "new_record"}},
+		{long: "--recurse", names: []string{"-r", "--recurse"}, flag: true, group: "ADDING FROM FOLDERS"},
+		{long: "--library-path", names: []string{"--library-path", "--with-library"}, group: "GLOBAL OPTIONS"},
+		{long: "--timeout", names: []string{"--timeout"}, group: "GLOBAL OPTIONS"},
+	}
+	if len(cmd.Options) != len(tests) {
+		t.Fatalf("got %d options, want %d: %+v", len(cmd.Options), len(tests), cmd.Options)
+	}
+	for i, tt := range tests {
+		t.Run(tt.long, func(t *testing.T) {
+			opt := cmd.Options[i]
+			if opt.Long() != tt.long {
+				t.Errorf("Long() = %q, want %q", opt.Long(), tt.long)
+			}
+			if !reflect.DeepEqual(opt.Names, tt.names) {
+				t.Errorf("Names = %q, want %q", opt.Names, tt.names)
+			}
+			if opt.IsFlag() != tt.flag {
+				t.Errorf("IsFlag() = %v, want %v", opt.IsFlag(), tt.flag)
+			}
+			if !reflect.DeepEqual(opt.Choices(), tt.choices) {
+				t.Errorf("Choices() = %q, want %q", opt.Choices(), tt.choices)
+			}
+			if opt.Repeatable() != tt.repeatable {
+				t.Errorf("Repeatable() = %v, want %v", opt.Repeatable(), tt.repeatable)
+			}
+			if opt.Group != tt.group {
+				t.Errorf("Group = %q, want %q", opt.Group, tt.group)
+			}
+		})
+	}
+
+	if got := len(cmd.CommandOptions()); got != 5 {
+		t.Errorf("CommandOptions() has %d options, want 5", got)
+	}
+}
+
+func TestSpec(t *testing.T) {
+	spec := calibrehelp.Parse(addHelp).Spec("add")
+	want := calibrehelp.Spec{
+		Name:        "add",
+		Description: "Add the specified files as books to the database. You can also specify\nfolders, see the folder related options below.",
+		Usage:       "calibredb add [options] file1 file2 file3 ...",
+		Options: []calibrehelp.SpecOption{
+			{Names: []string{"--duplicates", "-d"}, Description: "Add books to database even if they already exist.", Default: false, Type: "bool"},
+			{Names: []string{"--authors", "-a"}, Description: "Set the authors of the added book(s)", Type: "string"},
+			{Names: []string{"--identifier", "-I"}, Description: "Set the identifiers for this book, e.g. -I asin:XXX -I isbn:YYY", Default: "[]", Type: "[]string"},
+			{Names: []string{"--automerge"}, Description: "Choices: ignore, overwrite, new_record", Type: "choice", Choices: "('ignore', 'overwrite', 'new_record')"},
+			{Names: []string{"--recurse", "-r"}, Description: "Process folders recursively", Default: false, Type: "bool"},
+		},
+		Args: []calibrehelp.SpecArg{{Name: "files", Type: "[]string"}},
+	}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("Spec() =\n%+v\nwant\n%+v", spec, want)
+	}
+}
+
+func TestSpec_Args(t *testing.T) {
+	tests := []struct {
+		usage string
+		want  []calibrehelp.SpecArg
+	}{
+		{usage: "calibredb list [options]"},
+		{usage: "calibredb add_format [options] id ebook_file", want: []calibrehelp.SpecArg{
+			{Name: "id", Type: "string"}, {Name: "ebook_file", Type: "string"},
+		}},
+		{usage: "calibredb search [options] search expression", want: []calibrehelp.SpecArg{
+			{Name: "expression", Type: "string"},
+		}},
+		{usage: "calibredb set_metadata [options] book_id [/path/to/metadata.opf]", want: []calibrehelp.SpecArg{
+			{Name: "book_id", Type: "string"}, {Name: "path", Type: "string"},
+		}},
+		{usage: "calibredb catalog /path/to/destination.(csv|epub|mobi|xml...) [options]", want: []calibrehelp.SpecArg{
+			{Name: "path", Type: "string"},
+		}},
+		{usage: "calibredb saved_searches [options] (list|add|remove)"},
+		{usage: "calibredb fts_index [options] enable/disable/status/reindex", want: []calibrehelp.SpecArg{
+			{Name: "enable/disable/status/reindex", Type: "string", Choices: "('enable', 'disable', 'status', 'reindex')"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.usage, func(t *testing.T) {
+			got := calibrehelp.Command{Usage: tt.usage}.Spec("cmd").Args
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Args = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpec_NumericOptions(t *testing.T) {
+	tests := []struct {
+		help        string
+		wantType    string
+		wantDefault any
+	}{
+		{help: "How much of the library must be indexed, as a percentage. Defaults to 90", wantType: "int", wantDefault: 90},
+		{help: "Set the series number. Default: 1.5", wantType: "float", wantDefault: 1.5},
+		{help: "The maximum number of results to display. Default: all", wantType: "int"},
+		{help: "The string used to separate fields. Default is a space.", wantType: "string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.help, func(t *testing.T) {
+			cmd := calibrehelp.Command{Options: []calibrehelp.Option{{Names: []string{"--x"}, Metavar: "X", Help: tt.help}}}
+			opt := cmd.Spec("cmd").Options[0]
+			if opt.Type != tt.wantType || opt.Default != tt.wantDefault {
+				t.Errorf("got %s %v, want %s %v", opt.Type, opt.Default, tt.wantType, tt.wantDefault)
+			}
+		})
+	}
+}
+
+func TestSpec_Apply(t *testing.T) {
+	spec := calibrehelp.Parse(addHelp).Spec("add")
+	unknown := spec.Apply(calibrehelp.Override{
+		Args: []calibrehelp.SpecArg{{Name: "files", Type: "[]string", Validate: "min=1"}},
+		Options: map[string]calibrehelp.SpecOption{
+			"--authors": {Validate: "max=100"},
+			"--gone":    {Type: "int"},
+		},
+	})
+	if !reflect.DeepEqual(unknown, []string{"--gone"}) {
+		t.Errorf("Apply() = %q, want [--gone]", unknown)
+	}
+	if spec.Args[0].Validate != "min=1" {
+		t.Errorf("Args = %+v", spec.Args)
+	}
+	if opt := spec.Options[1]; opt.Validate != "max=100" || opt.Type != "string" {
+		t.Errorf("--authors = %+v", opt)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	specs := map[string]calibrehelp.Spec{
+		"list": calibrehelp.Command{Usage: "calibredb list [options]"}.Spec("list"),
+		"add":  calibrehelp.Parse(addHelp).Spec("add"),
+	}
+	first, err := calibrehelp.Marshal(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 5 {
+		again, err := calibrehelp.Marshal(specs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(again) != string(first) {
+			t.Fatal("Marshal() output differs between runs")
+		}
+	}
+	if !strings.HasPrefix(string(first), "{\n  \"add\": {") || strings.HasSuffix(string(first), "\n") {
+		t.Errorf("Marshal() = %s", first)
+	}
+	if strings.Contains(string(first), `<`) {
+		t.Error("Marshal() escapes HTML")
+	}
+}
@@ -0,0 +1,213 @@
+package calibrehelp
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Spec is one command of combined_calibredb_options.json, the input of
+// generate.go.
+type Spec struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Usage       string       `json:"usage"`
+	Options     []SpecOption `json:"options"`
+	Args        []SpecArg    `json:"args,omitempty"`
+}
+
+// SpecOption describes one option of a command.
+type SpecOption struct {
+	Names       []string `json:"names"`
+	Description string   `json:"description,omitempty"`
+	Default     any      `json:"default,omitempty"`
+	// Type is one of bool, string, int, float, []string, choice or ids.
+	Type string `json:"type"`
+	// Choices is a Python tuple such as "('text', 'json')", or the Python
+	// expression calibre computes them with.
+	Choices  string `json:"choices,omitempty"`
+	Range    string `json:"range,omitempty"`
+	Validate string `json:"validate,omitempty"`
+}
+
+// SpecArg describes one positional argument of a command.
+type SpecArg struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Choices  string `json:"choices,omitempty"`
+	Range    string `json:"range,omitempty"`
+	All      string `json:"all,omitempty"`
+	Validate string `json:"validate,omitempty"`
+	// Required replaces the required rule of an argument that an option can
+	// stand in for, e.g. required_without=Field, or of one that may be empty,
+	// omitempty.
+	Required string `json:"required,omitempty"`
+}
+
+// Override holds what the help output cannot tell about a command: the
+// validation rules, numeric types and book id arguments.
+type Override struct {
+	// Args replaces the arguments inferred from the usage line.
+	Args []SpecArg `json:"args,omitempty"`
+	// Options is keyed by the long option name; set fields replace the
+	// inferred ones.
+	Options map[string]SpecOption `json:"options,omitempty"`
+}
+
+var (
+	numericDefaultRe = regexp.MustCompile(`Defaults?(?: to| is|:) ?(-?[0-9]+(\.[0-9]+)?)\b`)
+	numericHelpRe    = regexp.MustCompile(`\b(number of|width|percentage|seconds)\b`)
+	choiceArgRe      = regexp.MustCompile(`^[a-z_]+(/[a-z_]+)+$`)
+)
+
+// Spec infers the spec of the command from its help. Flags are bools,
+// options listing their choices are choices, options the help says may be
+// repeated are string lists and options with a numeric default or a help
+// about numbers are ints or floats. Everything else is a string.
+func (c Command) Spec(name string) Spec {
+	spec := Spec{
+		Name:        name,
+		Description: c.Description,
+		Usage:       c.Usage,
+		Options:     []SpecOption{},
+		Args:        usageArgs(c.Usage),
+	}
+	for _, o := range c.CommandOptions() {
+		spec.Options = append(spec.Options, specOption(o))
+	}
+	return spec
+}
+
+func specOption(o Option) SpecOption {
+	opt := SpecOption{Description: o.Help, Type: "string"}
+	// long names first, as the generator names fields after the first one
+	for _, n := range o.Names {
+		if strings.HasPrefix(n, "--") {
+			opt.Names = append(opt.Names, n)
+		}
+	}
+	for _, n := range o.Names {
+		if !strings.HasPrefix(n, "--") {
+			opt.Names = append(opt.Names, n)
+		}
+	}
+
+	switch {
+	case o.IsFlag():
+		opt.Type, opt.Default = "bool", false
+	case len(o.Choices()) > 0:
+		opt.Type, opt.Choices = "choice", pythonTuple(o.Choices())
+	case o.Repeatable():
+		opt.Type, opt.Default = "[]string", "[]"
+	default:
+		if m := numericDefaultRe.FindStringSubmatch(o.Help); m != nil {
+			if m[2] != "" {
+				opt.Type = "float"
+				opt.Default, _ = strconv.ParseFloat(m[1], 64)
+			} else {
+				opt.Type = "int"
+				opt.Default, _ = strconv.Atoi(m[1])
+			}
+		} else if numericHelpRe.MatchString(o.Help) {
+			opt.Type = "int"
+		}
+	}
+	return opt
+}
+
+// usageArgs infers the positional arguments from the usage line, e.g.
+// "calibredb add_format [options] id ebook_file".
+func usageArgs(usage string) []SpecArg {
+	line, _, _ := strings.Cut(usage, "\n")
+	words := strings.Fields(line)
+	if len(words) <= 2 {
+		return nil
+	}
+	words = words[2:]
+	// file1 file2 file3 ...
+	if slices.Contains(words, "...") {
+		return []SpecArg{{Name: "files", Type: "[]string"}}
+	}
+	var args []SpecArg
+	for i, w := range words {
+		w = strings.TrimSuffix(strings.TrimPrefix(w, "["), "]")
+		switch {
+		case w == "options":
+		case w == "search" && i+1 < len(words) && words[i+1] == "expression":
+			// "search expression" names a single argument
+		case strings.HasPrefix(w, "(") && strings.HasSuffix(w, ")"):
+			// a sub command such as (list|add|remove), not an argument
+		case strings.Contains(w, "path/to"):
+			args = append(args, SpecArg{Name: "path", Type: "string"})
+		case choiceArgRe.MatchString(w):
+			args = append(args, SpecArg{Name: w, Type: "string", Choices: pythonTuple(strings.Split(w, "/"))})
+		default:
+			args = append(args, SpecArg{Name: w, Type: "string"})
+		}
+	}
+	return args
+}
+
+func pythonTuple(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "'" + v + "'"
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+// Apply merges the override into the spec and returns the overridden
+// options the command no longer has.
+func (s *Spec) Apply(o Override) (unknown []string) {
+	if o.Args != nil {
+		s.Args = o.Args
+	}
+	for name := range o.Options {
+		if !slices.ContainsFunc(s.Options, func(opt SpecOption) bool { return opt.Names[0] == name }) {
+			unknown = append(unknown, name)
+		}
+	}
+	slices.Sort(unknown)
+	for i, opt := range s.Options {
+		patch, ok := o.Options[opt.Names[0]]
+		if !ok {
+			continue
+		}
+		if patch.Description != "" {
+			opt.Description = patch.Description
+		}
+		if patch.Default != nil {
+			opt.Default = patch.Default
+		}
+		if patch.Type != "" {
+			opt.Type = patch.Type
+		}
+		if patch.Choices != "" {
+			opt.Choices = patch.Choices
+		}
+		if patch.Range != "" {
+			opt.Range = patch.Range
+		}
+		if patch.Validate != "" {
+			opt.Validate = patch.Validate
+		}
+		s.Options[i] = opt
+	}
+	return unknown
+}
+
+// Marshal encodes the specs as combined_calibredb_options.json. The output
+// only depends on the specs: keys are sorted and nothing is escaped.
+func Marshal(specs map[string]Spec) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(specs); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,1489 @@
+package fakecalibredb
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var handlers = map[string]func(*env, parsed) error{
+	"add":                  cmdAdd,
+	"add_custom_column":    cmdAddCustomColumn,
+	"add_format":           cmdAddFormat,
+	"backup_metadata":      cmdBackupMetadata,
+	"catalog":              cmdCatalog,
+	"check_library":        cmdCheckLibrary,
+	"clone":                cmdClone,
+	"custom_columns":       cmdCustomColumns,
+	"embed_metadata":       cmdEmbedMetadata,
+	"export":               cmdExport,
+	"fts_index":            cmdFTSIndex,
+	"fts_search":           cmdFTSSearch,
+	"list":                 cmdList,
+	"list_categories":      cmdListCategories,
+	"remove":               cmdRemove,
+	"remove_custom_column": cmdRemoveCustomColumn,
+	"remove_format":        cmdRemoveFormat,
+	"restore_database":     cmdRestoreDatabase,
+	"saved_searches":       cmdSavedSearches,
+	"search":               cmdSearch,
+	"set_custom":           cmdSetCustom,
+	"set_metadata":         cmdSetMetadata,
+	"show_metadata":        cmdShowMetadata,
+}
+
+// customDatatypes are the column types calibre supports.
+var customDatatypes = []string{"bool", "comments", "composite", "datetime", "enumeration", "float", "int", "rating", "series", "text"}
+
+// integersFromString mirrors calibre.db.cli.integers_from_string: a comma
+// separated list of ids and ranges whose end is excluded unless
+// includeLast is set.
+func integersFromString(arg string, includeLast bool) ([]int, error) {
+	var ids []int
+	for _, part := range strings.Split(arg, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid literal for int() with base 10: '%s'", lo)
+		}
+		if !isRange {
+			ids = append(ids, start)
+			continue
+		}
+		end, err := strconv.Atoi(hi)
+		if err != nil {
+			return nil, fmt.Errorf("invalid literal for int() with base 10: '%s'", hi)
+		}
+		if includeLast {
+			end++
+		}
+		for id := start; id < end; id++ {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func parseIDs(command string, args []string, includeLast bool) ([]int, error) {
+	var ids []int
+	for _, arg := range args {
+		parsed, err := integersFromString(arg, includeLast)
+		if err != nil {
+			return nil, raise(command, "ValueError", err.Error(),
+				`  File "calibre/db/cli/__init__.py", line 25, in integers_from_string`)
+		}
+		ids = append(ids, parsed...)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids), nil
+}
+
+func parseID(command, arg string) (int, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return 0, raise(command, "ValueError", fmt.Sprintf("invalid literal for int() with base 10: '%s'", arg))
+	}
+	return id, nil
+}
+
+func splitList(s, sep string) []string {
+	var out []string
+	for _, part := range strings.Split(s, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func cmdAdd(e *env, p parsed) error {
+	empty := p.has("empty")
+	if len(p.args) == 0 && !empty {
+		return systemExit{"You must specify at least one file to add"}
+	}
+	var authors []string
+	if a := p.str("authors", ""); a != "" {
+		authors = splitList(a, "&")
+	}
+	automerge := p.str("automerge", "disabled")
+
+	apply := func(b *book) {
+		if t := p.str("title", ""); t != "" {
+			b.Title = t
+			b.TitleSort = titleSort(t)
+		}
+		if p.has("isbn") {
+			b.Identifiers["isbn"] = p.str("isbn", "")
+		}
+		for _, ident := range p.list("identifier") {
+			if k, v, ok := strings.Cut(ident, ":"); ok {
+				b.Identifiers[strings.ToLower(k)] = v
+			}
+		}
+		if p.has("tags") {
+			b.Tags = splitList(p.str("tags", ""), ",")
+		}
+		if p.has("languages") {
+			b.Languages = splitList(p.str("languages", ""), ",")
+		}
+		if p.has("series") {
+			b.Series = p.str("series", "")
+			b.SeriesIndex = p.float("series_index", 1)
+		}
+		if cover := p.str("cover", ""); cover != "" {
+			if data, err := os.ReadFile(cover); err == nil {
+				_ = os.MkdirAll(filepath.Join(e.lib.path, b.Path), 0o755)
+				if os.WriteFile(filepath.Join(e.lib.path, b.Path, "cover.jpg"), data, 0o644) == nil {
+					b.HasCover = true
+				}
+			}
+		}
+	}
+
+	var added []int
+	type duplicate struct{ title, path string }
+	var duplicates []duplicate
+	if empty {
+		b := e.lib.newBook(p.str("title", ""), authors)
+		apply(b)
+		added = append(added, b.ID)
+	}
+	for _, path := range p.args {
+		title, fileAuthors := metadataFromFilename(path)
+		if p.has("title") {
+			title = p.str("title", "")
+		}
+		if len(authors) > 0 {
+			fileAuthors = authors
+		}
+		if existing := e.lib.findDuplicate(title, fileAuthors); existing != nil && !p.has("duplicates") || existing != nil && automerge != "disabled" {
+			switch automerge {
+			case "ignore", "overwrite":
+				if _, err := os.Stat(path); err == nil {
+					if err := e.lib.addFormat(existing, path, automerge == "overwrite"); err != nil {
+						return err
+					}
+				}
+				continue
+			case "new_record":
+				// falls through to creating a new record
+			default:
+				duplicates = append(duplicates, duplicate{title, path})
+				continue
+			}
+		}
+		b := e.lib.newBook(title, fileAuthors)
+		apply(b)
+		if _, err := os.Stat(path); err == nil {
+			if err := e.lib.addFormat(b, path, true); err != nil {
+				return err
+			}
+		}
+		added = append(added, b.ID)
+	}
+	if err := e.lib.save(); err != nil {
+		return err
+	}
+	if len(added) > 0 {
+		ids := make([]string, len(added))
+		for i, id := range added {
+			ids[i] = strconv.Itoa(id)
+		}
+		fmt.Fprintf(e.stdout, "Added book ids: %s\n", strings.Join(ids, ", "))
+	}
+	if len(duplicates) > 0 {
+		fmt.Fprintln(e.stdout, "The following books were not added as they already exist in the database (see --duplicates option or --automerge option):")
+		for _, d := range duplicates {
+			fmt.Fprintf(e.stdout, "  %s\n    %s\n", d.title, d.path)
+		}
+	}
+	return nil
+}
+
+// metadataFromFilename applies calibre's default filename pattern
+// "Title - Author" to a file name.
+func metadataFromFilename(path string) (string, []string) {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if title, author, ok := strings.Cut(base, " - "); ok {
+		return strings.TrimSpace(title), splitList(author, "&")
+	}
+	return base, nil
+}
+
+func (lib *library) findDuplicate(title string, authors []string) *book {
+	if len(authors) == 0 {
+		authors = []string{"Unknown"}
+	}
+	for _, b := range lib.Books {
+		if strings.EqualFold(b.Title, title) && strings.EqualFold(strings.Join(b.Authors, "&"), strings.Join(authors, "&")) {
+			return b
+		}
+	}
+	return nil
+}
+
+// addFormat copies the file at path into the book folder.
+func (lib *library) addFormat(b *book, path string, replace bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return raise("add", "FileNotFoundError", fmt.Sprintf("[Errno 2] No such file or directory: '%s'", path))
+	}
+	fmtName := strings.ToUpper(strings.TrimPrefix(filepath.Ext(path), "."))
+	if fmtName == "" {
+		fmtName = "UNKNOWN"
+	}
+	if _, exists := b.Formats[fmtName]; exists && !replace {
+		return nil
+	}
+	dir := filepath.Join(lib.path, b.Path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	name := sanitize(b.Title) + " - " + sanitize(b.Authors[0]) + "." + strings.ToLower(fmtName)
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+		return err
+	}
+	if b.Formats == nil {
+		b.Formats = map[string]string{}
+	}
+	b.Formats[fmtName] = name
+	b.touch()
+	return nil
+}
+
+func cmdAddFormat(e *env, p parsed) error {
+	if len(p.args) < 2 {
+		return systemExit{"You must specify an id and an e-book file"}
+	}
+	id, err := parseID("add_format", p.args[0])
+	if err != nil {
+		return err
+	}
+	b := e.lib.book(id)
+	if b == nil {
+		return systemExit{fmt.Sprintf("A book with id: %d does not exist", id)}
+	}
+	path := p.args[1]
+	if _, err := os.Stat(path); err != nil {
+		return raise("add_format", "FileNotFoundError", fmt.Sprintf("[Errno 2] No such file or directory: '%s'", path))
+	}
+	if p.has("as_extra_data_file") {
+		dir := filepath.Join(e.lib.path, b.Path, "data")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, filepath.Base(path)), data, 0o644)
+	}
+	fmtName := strings.ToUpper(strings.TrimPrefix(filepath.Ext(path), "."))
+	if _, exists := b.Formats[fmtName]; exists && p.has("replace") {
+		return systemExit{fmt.Sprintf("A %s file already exists for book: %d, not replacing", fmtName, id)}
+	}
+	if err := e.lib.addFormat(b, path, true); err != nil {
+		return err
+	}
+	return e.lib.save()
+}
+
+func cmdRemoveFormat(e *env, p parsed) error {
+	if len(p.args) < 2 {
+		return systemExit{"You must specify an id and a format"}
+	}
+	id, err := parseID("remove_format", p.args[0])
+	if err != nil {
+		return err
+	}
+	b := e.lib.book(id)
+	if b == nil {
+		return nil
+	}
+	fmtName := strings.ToUpper(p.args[1])
+	if name, ok := b.Formats[fmtName]; ok {
+		_ = os.Remove(filepath.Join(e.lib.path, b.Path, name))
+		delete(b.Formats, fmtName)
+		b.touch()
+	}
+	return e.lib.save()
+}
+
+func cmdRemove(e *env, p parsed) error {
+	if len(p.args) < 1 {
+		return systemExit{"You must specify at least one book to remove"}
+	}
+	ids, err := parseIDs("remove", p.args, false)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		e.lib.removeBook(id)
+	}
+	return e.lib.save()
+}
+
+// listFields maps list field names to their values for --for-machine output.
+func listField(lib *library, b *book, field, prefix string) (any, bool) {
+	dir := filepath.Join(prefix, b.Path)
+	switch field {
+	case "title":
+		return b.Title, true
+	case "authors":
+		return strings.Join(b.Authors, " & "), true
+	case "author_sort":
+		return b.AuthorSort, true
+	case "comments":
+		return nilIfEmpty(b.Comments), true
+	case "cover":
+		if !b.HasCover {
+			return nil, true
+		}
+		return filepath.Join(dir, "cover.jpg"), true
+	case "formats":
+		paths := make([]string, 0, len(b.Formats))
+		for _, name := range sortedKeys(b.Formats) {
+			paths = append(paths, filepath.Join(dir, b.Formats[name]))
+		}
+		return paths, true
+	case "identifiers":
+		return b.Identifiers, true
+	case "isbn":
+		return b.Identifiers["isbn"], true
+	case "languages":
+		return emptyIfNil(b.Languages), true
+	case "last_modified":
+		return isoformat(b.LastModified), true
+	case "pubdate":
+		return isoformat(b.Pubdate), true
+	case "timestamp":
+		return isoformat(b.Timestamp), true
+	case "publisher":
+		return nilIfEmpty(b.Publisher), true
+	case "rating":
+		if b.Rating == 0 {
+			return nil, true
+		}
+		return b.Rating, true
+	case "series":
+		return nilIfEmpty(b.Series), true
+	case "series_index":
+		return b.SeriesIndex, true
+	case "size":
+		var size int64
+		for _, name := range b.Formats {
+			if info, err := os.Stat(filepath.Join(lib.path, b.Path, name)); err == nil {
+				size = max(size, info.Size())
+			}
+		}
+		return size, true
+	case "tags":
+		return emptyIfNil(b.Tags), true
+	case "template":
+		return "", true
+	case "uuid":
+		return b.UUID, true
+	}
+	if strings.HasPrefix(field, "*") {
+		if col := lib.column(field[1:]); col != nil {
+			return b.Custom[col.Label], true
+		}
+	}
+	return nil, false
+}
+
+func nilIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func emptyIfNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func isoformat(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05-07:00")
+}
+
+var builtinListFields = []string{
+	"author_sort", "authors", "comments", "cover", "formats", "identifiers",
+	"isbn", "languages", "last_modified", "pubdate", "publisher", "rating",
+	"series", "series_index", "size", "tags", "template", "timestamp", "title",
+	"uuid",
+}
+
+func cmdList(e *env, p parsed) error {
+	fields := splitList(p.str("fields", "title,authors"), ",")
+	if slices.Contains(fields, "all") {
+		fields = slices.Clone(builtinListFields)
+		for _, c := range e.lib.Columns {
+			fields = append(fields, "*"+c.Label)
+		}
+	}
+	for _, f := range fields {
+		if _, ok := listField(e.lib, &book{}, f, ""); !ok && f != "id" {
+			return systemExit{"Invalid fields. Available fields: " + strings.Join(builtinListFields, ", ")}
+		}
+	}
+	fields = slices.DeleteFunc(fields, func(f string) bool { return f == "id" })
+
+	match, err := parseSearch(p.str("search", ""))
+	if err != nil {
+		return raise("list", "ParseException", err.Error())
+	}
+	var books []*book
+	for _, b := range e.lib.Books {
+		if match(e.lib, b) {
+			books = append(books, b)
+		}
+	}
+	sortBooks(e.lib, books, p.str("sort_by", "id"), p.has("ascending"))
+	if limit := p.int("limit", -1); limit >= 0 && limit < len(books) {
+		books = books[:limit]
+	}
+	prefix := p.str("prefix", e.lib.path)
+
+	if p.has("for_machine") {
+		rows := make([]orderedRow, 0, len(books))
+		for _, b := range books {
+			row := orderedRow{{"id", b.ID}}
+			for _, f := range fields {
+				v, _ := listField(e.lib, b, f, prefix)
+				row = append(row, kv{f, v})
+			}
+			rows = append(rows, row)
+		}
+		data, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, string(data))
+		return nil
+	}
+
+	sep := p.str("separator", " ")
+	header := append([]string{"id"}, fields...)
+	table := [][]string{header}
+	for _, b := range books {
+		row := []string{strconv.Itoa(b.ID)}
+		for _, f := range fields {
+			v, _ := listField(e.lib, b, f, prefix)
+			row = append(row, displayValue(v))
+		}
+		table = append(table, row)
+	}
+	writeTable(e.stdout, table, sep)
+	return nil
+}
+
+// kv and orderedRow keep list --for-machine keys in calibre's order.
+type kv struct {
+	key   string
+	value any
+}
+
+type orderedRow []kv
+
+func (r orderedRow) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, field := range r {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(field.key)
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
+
+func displayValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []string:
+		return strings.Join(v, ", ")
+	case map[string]string:
+		parts := make([]string, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			parts = append(parts, k+":"+v[k])
+		}
+		return strings.Join(parts, ", ")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func writeTable(w io.Writer, table [][]string, sep string) {
+	widths := make([]int, len(table[0]))
+	for _, row := range table {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	for _, row := range table {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			if i == len(row)-1 {
+				cells[i] = cell
+			} else {
+				cells[i] = cell + strings.Repeat(" ", widths[i]-len(cell))
+			}
+		}
+		fmt.Fprintln(w, strings.TrimRight(strings.Join(cells, sep), " "))
+	}
+}
+
+func sortBooks(lib *library, books []*book, sortBy string, ascending bool) {
+	fields := splitList(sortBy, ",")
+	slices.SortStableFunc(books, func(a, b *book) int {
+		for _, f := range fields {
+			var c int
+			if f == "id" {
+				c = a.ID - b.ID
+			} else {
+				va, _ := listField(lib, a, f, "")
+				vb, _ := listField(lib, b, f, "")
+				fa, aNum := va.(float64)
+				fb, bNum := vb.(float64)
+				if aNum && bNum {
+					c = int(fa*1000 - fb*1000)
+				} else {
+					c = strings.Compare(strings.ToLower(displayValue(va)), strings.ToLower(displayValue(vb)))
+				}
+			}
+			if c != 0 {
+				if !ascending {
+					return -c
+				}
+				return c
+			}
+		}
+		return 0
+	})
+}
+
+func cmdSearch(e *env, p parsed) error {
+	query := strings.Join(p.args, " ")
+	if strings.TrimSpace(query) == "" {
+		return systemExit{"Error: You must specify the search expression"}
+	}
+	match, err := parseSearch(query)
+	if err != nil {
+		return raise("search", "ParseException", err.Error())
+	}
+	var ids []string
+	for _, b := range e.lib.Books {
+		if match(e.lib, b) {
+			ids = append(ids, strconv.Itoa(b.ID))
+		}
+	}
+	if len(ids) == 0 {
+		return systemExit{"No books matching the search expression: " + query}
+	}
+	if limit := p.int("limit", -1); limit >= 0 && limit < len(ids) {
+		ids = ids[:limit]
+	}
+	fmt.Fprintln(e.stdout, strings.Join(ids, ","))
+	return nil
+}
+
+func cmdShowMetadata(e *env, p parsed) error {
+	if len(p.args) < 1 {
+		return systemExit{"You must specify an id"}
+	}
+	id, err := parseID("show_metadata", p.args[0])
+	if err != nil {
+		return err
+	}
+	b := e.lib.book(id)
+	if b == nil {
+		return systemExit{fmt.Sprintf("Id #%d is not present in database.", id)}
+	}
+	if p.has("as_opf") {
+		fmt.Fprint(e.stdout, renderOPF(e.lib, b))
+		return nil
+	}
+	fmt.Fprint(e.stdout, metadataText(e.lib, b))
+	return nil
+}
+
+func metadataText(lib *library, b *book) string {
+	var s strings.Builder
+	line := func(label, value string) {
+		if value == "" {
+			return
+		}
+		fmt.Fprintf(&s, "%-20s: %s\n", label, value)
+	}
+	line("Title", b.Title)
+	line("Title sort", b.TitleSort)
+	line("Author(s)", strings.Join(b.Authors, " & ")+" ["+b.AuthorSort+"]")
+	line("Publisher", b.Publisher)
+	line("Tags", strings.Join(b.Tags, ", "))
+	line("Languages", strings.Join(b.Languages, ", "))
+	if b.Series != "" {
+		line("Series", fmt.Sprintf("%s #%s", b.Series, strconv.FormatFloat(b.SeriesIndex, 'f', -1, 64)))
+	}
+	if b.Rating != 0 {
+		line("Rating", strconv.FormatFloat(b.Rating/2, 'f', -1, 64))
+	}
+	line("Timestamp", isoformat(b.Timestamp))
+	if !b.Pubdate.Equal(undefinedDate) {
+		line("Published", isoformat(b.Pubdate))
+	}
+	line("Identifiers", displayValue(b.Identifiers))
+	line("Comments", b.Comments)
+	for _, col := range lib.Columns {
+		if v, ok := b.Custom[col.Label]; ok && v != nil {
+			line(col.Name+" (#"+col.Label+")", displayValue(customDisplay(v)))
+		}
+	}
+	return s.String()
+}
+
+func customDisplay(v any) any {
+	if list, ok := v.([]any); ok {
+		out := make([]string, len(list))
+		for i, x := range list {
+			out[i] = fmt.Sprint(x)
+		}
+		return out
+	}
+	return v
+}
+
+var settableFields = []string{
+	"author_sort", "authors", "comments", "identifiers", "languages", "pubdate",
+	"publisher", "rating", "series", "series_index", "sort", "tags", "timestamp", "title",
+}
+
+func cmdSetMetadata(e *env, p parsed) error {
+	if p.has("list_fields") {
+		for _, f := range settableFields {
+			fmt.Fprintln(e.stdout, f)
+		}
+		for _, c := range e.lib.Columns {
+			fmt.Fprintln(e.stdout, "#"+c.Label)
+		}
+		return nil
+	}
+	if len(p.args) < 1 {
+		return systemExit{"You must specify a record id as the first argument"}
+	}
+	fields := p.list("field")
+	if len(p.args) < 2 && len(fields) == 0 {
+		return systemExit{"You must specify either a field or an OPF file"}
+	}
+	id, err := parseID("set_metadata", p.args[0])
+	if err != nil {
+		return err
+	}
+	b := e.lib.book(id)
+	if b == nil {
+		return systemExit{fmt.Sprintf("No book with id: %d in the database", id)}
+	}
+	if len(p.args) > 1 {
+		data, err := os.ReadFile(p.args[1])
+		if err != nil {
+			return systemExit{fmt.Sprintf("The OPF file %s does not exist", p.args[1])}
+		}
+		if err := applyOPF(b, data); err != nil {
+			return raise("set_metadata", "lxml.etree.XMLSyntaxError", err.Error(),
+				`  File "calibre/ebooks/metadata/opf2.py", line 517, in __init__`)
+		}
+	}
+	for _, f := range fields {
+		name, value, ok := strings.Cut(f, ":")
+		if !ok {
+			return systemExit{fmt.Sprintf("%s is not a valid field specification", f)}
+		}
+		if err := setField(e.lib, b, name, value); err != nil {
+			return err
+		}
+	}
+	b.touch()
+	if err := e.lib.save(); err != nil {
+		return err
+	}
+	fmt.Fprint(e.stdout, metadataText(e.lib, b))
+	return nil
+}
+
+func setField(lib *library, b *book, name, value string) error {
+	switch name {
+	case "title":
+		b.Title = value
+		b.TitleSort = titleSort(value)
+	case "sort":
+		b.TitleSort = value
+	case "authors":
+		b.Authors = splitList(value, "&")
+		b.AuthorSort = authorSort(b.Authors)
+	case "author_sort":
+		b.AuthorSort = value
+	case "comments":
+		b.Comments = value
+	case "publisher":
+		b.Publisher = value
+	case "series":
+		b.Series = value
+	case "series_index":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return systemExit{fmt.Sprintf("%s is not a valid number", value)}
+		}
+		b.SeriesIndex = f
+	case "rating":
+		// like calibre, a field without a value is cleared
+		if value == "" {
+			b.Rating = 0
+			break
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return systemExit{fmt.Sprintf("%s is not a valid number", value)}
+		}
+		b.Rating = min(10, max(0, f*2))
+	case "tags":
+		b.Tags = splitList(value, ",")
+	case "languages":
+		b.Languages = splitList(value, ",")
+	case "identifiers":
+		b.Identifiers = map[string]string{}
+		for _, ident := range splitList(value, ",") {
+			if k, v, ok := strings.Cut(ident, ":"); ok {
+				b.Identifiers[strings.ToLower(k)] = v
+			}
+		}
+	case "pubdate", "timestamp":
+		t, err := parseDate(value)
+		if err != nil {
+			return systemExit{fmt.Sprintf("%s is not a valid date", value)}
+		}
+		if name == "pubdate" {
+			b.Pubdate = t
+		} else {
+			b.Timestamp = t
+		}
+	default:
+		if strings.HasPrefix(name, "#") {
+			col := lib.column(name)
+			if col == nil {
+				return systemExit{fmt.Sprintf("%s is not a known field", name)}
+			}
+			v, err := convertCustom(col, value)
+			if err != nil {
+				return err
+			}
+			setCustomValue(b, col, v, false)
+			return nil
+		}
+		return systemExit{fmt.Sprintf("%s is not a known field", name)}
+	}
+	return nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02", "2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, errors.New("invalid date")
+}
+
+// convertCustom turns a command line value into the stored representation
+// for the column's datatype.
+func convertCustom(col *column, value string) (any, error) {
+	if col.IsMultiple {
+		sep := ","
+		if isNames, _ := col.Display["is_names"].(bool); isNames {
+			sep = "&"
+		}
+		var out []any
+		for _, v := range splitList(value, sep) {
+			out = append(out, v)
+		}
+		return out, nil
+	}
+	// like calibre, an empty value clears the column
+	if value == "" {
+		return nil, nil
+	}
+	switch col.Datatype {
+	case "int":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, raise("set_custom", "ValueError", fmt.Sprintf("invalid literal for int() with base 10: '%s'", value),
+				`  File "calibre/db/write.py", line 140, in adapt_number`)
+		}
+		return float64(n), nil
+	case "float", "rating":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, raise("set_custom", "ValueError", fmt.Sprintf("could not convert string to float: '%s'", value),
+				`  File "calibre/db/write.py", line 140, in adapt_number`)
+		}
+		return f, nil
+	case "bool":
+		switch strings.ToLower(value) {
+		case "true", "yes", "y", "1":
+			return true, nil
+		case "false", "no", "n", "0":
+			return false, nil
+		}
+		return nil, nil
+	case "datetime":
+		t, err := parseDate(value)
+		if err != nil {
+			return nil, systemExit{fmt.Sprintf("%s is not a valid date", value)}
+		}
+		return isoformat(t), nil
+	case "composite":
+		return nil, systemExit{fmt.Sprintf("Cannot set the value of the composite column: #%s", col.Label)}
+	default:
+		return value, nil
+	}
+}
+
+func setCustomValue(b *book, col *column, v any, appendValues bool) {
+	if b.Custom == nil {
+		b.Custom = map[string]any{}
+	}
+	if list, ok := v.([]any); ok && appendValues {
+		if existing, ok := b.Custom[col.Label].([]any); ok {
+			for _, x := range list {
+				if !slices.Contains(existing, x) {
+					existing = append(existing, x)
+				}
+			}
+			v = existing
+		}
+	}
+	b.Custom[col.Label] = v
+	b.touch()
+}
+
+func cmdSetCustom(e *env, p parsed) error {
+	if len(p.args) < 3 {
+		return systemExit{"Error: You must specify a field name, id and value"}
+	}
+	col := e.lib.column(p.args[0])
+	if col == nil {
+		return systemExit{fmt.Sprintf("No column with name %s found. Use calibredb custom_columns to get a list of columns", p.args[0])}
+	}
+	id, err := parseID("set_custom", p.args[1])
+	if err != nil {
+		return err
+	}
+	b := e.lib.book(id)
+	if b == nil {
+		return systemExit{fmt.Sprintf("No book with id: %d found", id)}
+	}
+	v, err := convertCustom(col, p.args[2])
+	if err != nil {
+		return err
+	}
+	setCustomValue(b, col, v, p.has("append"))
+	if err := e.lib.save(); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout, displayValue(customDisplay(b.Custom[col.Label])))
+	return nil
+}
+
+func cmdAddCustomColumn(e *env, p parsed) error {
+	if len(p.args) < 3 {
+		return systemExit{"You must specify label, name and datatype"}
+	}
+	label, name, datatype := p.args[0], p.args[1], p.args[2]
+	if !slices.Contains(customDatatypes, datatype) {
+		return systemExit{fmt.Sprintf("Unknown datatype: %s. Datatype must be one of: %s", datatype, strings.Join(customDatatypes, ", "))}
+	}
+	display := map[string]any{}
+	if err := json.Unmarshal([]byte(p.str("display", "{}")), &display); err != nil {
+		return raise("add_custom_column", "json.decoder.JSONDecodeError", err.Error(),
+			`  File "json/__init__.py", line 346, in loads`)
+	}
+	if e.lib.column(label) != nil {
+		return raise("add_custom_column", "apsw.ConstraintError", "UNIQUE constraint failed: custom_columns.label",
+			`  File "calibre/db/cli/cmd_add_custom_column.py", line 72, in do_add_custom_column`,
+			`  File "calibre/db/legacy.py", line 812, in create_custom_column`,
+			`  File "calibre/db/cache.py", line 86, in call_func_with_lock`,
+			`  File "calibre/db/cache.py", line 2669, in create_custom_column`,
+			`  File "calibre/db/backend.py", line 1244, in create_custom_column`,
+			`  File "calibre/db/backend.py", line 1171, in execute`,
+			`  File "src/cursor.c", line 189, in resetcursor`)
+	}
+	col := &column{
+		Num:        e.lib.NextColumnID,
+		Label:      label,
+		Name:       name,
+		Datatype:   datatype,
+		IsMultiple: p.has("is_multiple"),
+		Display:    display,
+	}
+	e.lib.NextColumnID++
+	e.lib.Columns = append(e.lib.Columns, col)
+	if err := e.lib.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Custom column created with id: %d\n", col.Num)
+	return nil
+}
+
+func cmdCustomColumns(e *env, p parsed) error {
+	for _, col := range e.lib.Columns {
+		fmt.Fprintf(e.stdout, "%s (%d)\n", col.Label, col.Num)
+		if p.has("details") {
+			display, _ := json.Marshal(col.Display)
+			fmt.Fprintf(e.stdout, "  column: %d\n", col.Num)
+			fmt.Fprintf(e.stdout, "  datatype: %s\n", col.Datatype)
+			fmt.Fprintf(e.stdout, "  display: %s\n", display)
+			fmt.Fprintf(e.stdout, "  is_multiple: %t\n", col.IsMultiple)
+			fmt.Fprintf(e.stdout, "  label: %s\n", col.Label)
+			fmt.Fprintf(e.stdout, "  name: %s\n", col.Name)
+		}
+	}
+	return nil
+}
+
+func cmdRemoveCustomColumn(e *env, p parsed) error {
+	if len(p.args) < 1 {
+		return systemExit{"Error: You must specify a column label"}
+	}
+	col := e.lib.column(p.args[0])
+	if col == nil {
+		return systemExit{fmt.Sprintf("No column named %s found. You must use column labels, not column names.", p.args[0])}
+	}
+	if !p.has("force") {
+		fmt.Fprintf(e.stdout, "You will lose all data in the column: %s. Are you sure (y/n)? ", col.Name)
+		answer, err := bufio.NewReader(e.stdin).ReadString('\n')
+		if err != nil && answer == "" {
+			return raise("remove_custom_column", "EOFError", "EOF when reading a line",
+				`  File "calibre/db/cli/cmd_remove_custom_column.py", line 31, in input_unicode`)
+		}
+		if strings.ToLower(strings.TrimSpace(answer)) != "y" {
+			return nil
+		}
+	}
+	e.lib.Columns = slices.DeleteFunc(e.lib.Columns, func(c *column) bool { return c == col })
+	for _, b := range e.lib.Books {
+		delete(b.Custom, col.Label)
+	}
+	return e.lib.save()
+}
+
+func cmdSavedSearches(e *env, p parsed) error {
+	if len(p.args) < 1 {
+		return systemExit{"Error: You must specify an action (add|remove|list)"}
+	}
+	switch p.args[0] {
+	case "list":
+		for _, name := range sortedKeys(e.lib.SavedSearches) {
+			fmt.Fprintf(e.stdout, "Name: %s\nSearch: %s\n\n", name, e.lib.SavedSearches[name])
+		}
+		return nil
+	case "add":
+		if len(p.args) < 3 {
+			return systemExit{"Error: You must specify a name and a search expression"}
+		}
+		e.lib.SavedSearches[p.args[1]] = p.args[2]
+		if err := e.lib.save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(e.stdout, "%s added\n", p.args[1])
+		return nil
+	case "remove":
+		if len(p.args) < 2 {
+			return systemExit{"Error: You must specify a name"}
+		}
+		delete(e.lib.SavedSearches, p.args[1])
+		if err := e.lib.save(); err != nil {
+			return err
+		}
+		fmt.Fprintf(e.stdout, "%s removed\n", p.args[1])
+		return nil
+	}
+	return systemExit{"Error: Action " + p.args[0] + " not recognized, must be one of: (add|remove|list)"}
+}
+
+type categoryItem struct {
+	category, name string
+	count          int
+}
+
+func categories(lib *library) map[string][]categoryItem {
+	counts := map[string]map[string]int{}
+	add := func(category string, values ...string) {
+		if counts[category] == nil {
+			counts[category] = map[string]int{}
+		}
+		for _, v := range values {
+			counts[category][v]++
+		}
+	}
+	for _, b := range lib.Books {
+		add("authors", b.Authors...)
+		add("tags", b.Tags...)
+		add("languages", b.Languages...)
+		add("publisher", nonEmpty(b.Publisher)...)
+		add("series", nonEmpty(b.Series)...)
+		add("formats", slices.Sorted(func(yield func(string) bool) {
+			for f := range b.Formats {
+				if !yield(f) {
+					return
+				}
+			}
+		})...)
+		for _, col := range lib.Columns {
+			if col.Datatype == "text" || col.Datatype == "enumeration" || col.Datatype == "series" {
+				add("#"+col.Label, customValues(b.Custom[col.Label])...)
+			}
+		}
+	}
+	out := map[string][]categoryItem{}
+	for category, items := range counts {
+		for _, name := range sortedKeys(items) {
+			out[category] = append(out[category], categoryItem{category, name, items[name]})
+		}
+	}
+	return out
+}
+
+func cmdListCategories(e *env, p parsed) error {
+	cats := categories(e.lib)
+	names := sortedKeys(cats)
+	if want := splitList(p.str("categories", ""), ","); len(want) > 0 {
+		names = slices.DeleteFunc(names, func(n string) bool { return !slices.Contains(want, n) })
+	}
+	if p.has("csv") {
+		w := csv.NewWriter(e.stdout)
+		if p.str("dialect", "excel") == "excel-tab" {
+			w.Comma = '\t'
+		}
+		if p.has("item_count") {
+			_ = w.Write([]string{"category", "count"})
+			for _, n := range names {
+				_ = w.Write([]string{n, strconv.Itoa(len(cats[n]))})
+			}
+		} else {
+			_ = w.Write([]string{"category", "tag_name", "count", "rating"})
+			for _, n := range names {
+				for _, item := range cats[n] {
+					_ = w.Write([]string{n, item.name, strconv.Itoa(item.count), "0.0"})
+				}
+			}
+		}
+		w.Flush()
+		return w.Error()
+	}
+	if p.has("item_count") {
+		table := [][]string{{"CATEGORY", "ITEMS"}}
+		for _, n := range names {
+			table = append(table, []string{n, strconv.Itoa(len(cats[n]))})
+		}
+		writeTable(e.stdout, table, " ")
+		return nil
+	}
+	table := [][]string{{"CATEGORY", "ITEM", "COUNT", "ICON", "RATING"}}
+	for _, n := range names {
+		for _, item := range cats[n] {
+			table = append(table, []string{n, item.name, strconv.Itoa(item.count), "", "0.0"})
+		}
+	}
+	writeTable(e.stdout, table, " ")
+	return nil
+}
+
+func cmdBackupMetadata(e *env, p parsed) error {
+	for _, b := range e.lib.Books {
+		dir := filepath.Join(e.lib.path, b.Path)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "metadata.opf"), []byte(renderOPF(e.lib, b)), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var libraryReports = []string{
+	"invalid_titles", "extra_titles", "invalid_authors", "extra_authors",
+	"missing_formats", "extra_formats", "extra_files", "missing_covers",
+	"extra_covers", "malformed_formats", "malformed_paths", "failed_folders",
+}
+
+func cmdCheckLibrary(e *env, p parsed) error {
+	reports := libraryReports
+	if r := p.str("report", ""); r != "" {
+		reports = splitList(r, ",")
+		for _, r := range reports {
+			if !slices.Contains(libraryReports, r) {
+				return systemExit{"Unknown report check " + r}
+			}
+		}
+	}
+	if p.has("vacuum_fts_db") {
+		fmt.Fprintln(e.stdout, "Vacuuming the full text search database, this may take a while...")
+	}
+	var problems [][]string
+	if slices.Contains(reports, "missing_formats") {
+		for _, b := range e.lib.Books {
+			for _, f := range sortedKeys(b.Formats) {
+				if _, err := os.Stat(filepath.Join(e.lib.path, b.Path, b.Formats[f])); err != nil {
+					problems = append(problems, []string{"missing_formats", b.Title, filepath.Join(b.Path, b.Formats[f])})
+				}
+			}
+		}
+	}
+	if slices.Contains(reports, "missing_covers") {
+		for _, b := range e.lib.Books {
+			if _, err := os.Stat(filepath.Join(e.lib.path, b.Path, "cover.jpg")); b.HasCover && err != nil {
+				problems = append(problems, []string{"missing_covers", b.Title, b.Path})
+			}
+		}
+	}
+	for _, problem := range problems {
+		if p.has("csv") {
+			fmt.Fprintln(e.stdout, strings.Join(problem, ","))
+		} else {
+			fmt.Fprintf(e.stdout, "%s - %s: %s\n", problem[0], problem[1], problem[2])
+		}
+	}
+	return nil
+}
+
+func cmdRestoreDatabase(e *env, p parsed) error {
+	if !p.has("really_do_it") {
+		return systemExit{"You must provide the --really-do-it option to do a recovery"}
+	}
+	for _, b := range e.lib.Books {
+		data, err := os.ReadFile(filepath.Join(e.lib.path, b.Path, "metadata.opf"))
+		if err != nil {
+			continue
+		}
+		_ = applyOPF(b, data)
+	}
+	if err := e.lib.save(); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout, "Restoring database succeeded")
+	fmt.Fprintf(e.stdout, "old database saved as %s\n", filepath.Join(e.lib.path, "metadata_pre_restore.db"))
+	return nil
+}
+
+func cmdClone(e *env, p parsed) error {
+	if len(p.args) < 1 {
+		return systemExit{"Error: You must specify the path to the cloned library"}
+	}
+	dest := p.args[0]
+	if entries, err := os.ReadDir(dest); err == nil && len(entries) > 0 {
+		return systemExit{fmt.Sprintf("%s is not empty. You must choose an empty folder for the new library.", dest)}
+	}
+	clone, err := openLibrary(dest)
+	if err != nil {
+		return err
+	}
+	defer clone.unlock()
+	for _, col := range e.lib.Columns {
+		c := *col
+		clone.Columns = append(clone.Columns, &c)
+	}
+	clone.NextColumnID = e.lib.NextColumnID
+	for k, v := range e.lib.SavedSearches {
+		clone.SavedSearches[k] = v
+	}
+	return clone.save()
+}
+
+func cmdEmbedMetadata(e *env, p parsed) error {
+	var ids []int
+	if slices.Contains(p.args, "all") {
+		ids = e.lib.allIDs()
+	} else {
+		var err error
+		if ids, err = parseIDs("embed_metadata", p.args, true); err != nil {
+			return err
+		}
+	}
+	only := p.list("only_formats")
+	for _, id := range ids {
+		b := e.lib.book(id)
+		if b == nil {
+			continue
+		}
+		for _, f := range sortedKeys(b.Formats) {
+			if len(only) > 0 && !slices.ContainsFunc(only, func(o string) bool { return strings.EqualFold(o, f) }) {
+				continue
+			}
+			fmt.Fprintf(e.stdout, "Updated metadata in: %s (%s)\n", b.Title, f)
+		}
+	}
+	return nil
+}
+
+func cmdExport(e *env, p parsed) error {
+	var ids []int
+	if p.has("all") {
+		ids = e.lib.allIDs()
+	} else {
+		if len(p.args) < 1 {
+			return systemExit{"You must specify some ids or the --all option"}
+		}
+		var err error
+		if ids, err = parseIDs("export", p.args, false); err != nil {
+			return err
+		}
+	}
+	toDir, err := filepath.Abs(p.str("to_dir", "."))
+	if err != nil {
+		return err
+	}
+	var formats []string
+	if f := p.str("formats", ""); f != "" {
+		formats = splitList(strings.ToUpper(f), ",")
+	}
+	for i, id := range ids {
+		b := e.lib.book(id)
+		if b == nil {
+			fmt.Fprintf(e.stdout, "No book with id %d present\n", id)
+			continue
+		}
+		if p.has("progress") {
+			fmt.Fprintf(e.stdout, "Exporting book %d of %d: %s\n", i+1, len(ids), b.Title)
+		}
+		dir, base := toDir, ""
+		if p.has("single_dir") {
+			base = fmt.Sprintf("%s - %s", sanitize(b.Title), sanitize(strings.Join(b.Authors, " & ")))
+		} else {
+			rel := renderExportTemplate(p.str("template", "{author_sort}/{title}/{title} - {authors}"), b)
+			if p.has("to_lowercase") {
+				rel = strings.ToLower(rel)
+			}
+			if p.has("replace_whitespace") {
+				rel = strings.ReplaceAll(rel, " ", "_")
+			}
+			dir = filepath.Join(toDir, filepath.Dir(rel))
+			base = filepath.Base(rel)
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		for _, f := range sortedKeys(b.Formats) {
+			if len(formats) > 0 && !slices.Contains(formats, f) {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(e.lib.path, b.Path, b.Formats[f]))
+			if err != nil {
+				continue
+			}
+			if err := os.WriteFile(filepath.Join(dir, base+"."+strings.ToLower(f)), data, 0o644); err != nil {
+				return err
+			}
+		}
+		if b.HasCover && !p.has("dont_save_cover") {
+			if data, err := os.ReadFile(filepath.Join(e.lib.path, b.Path, "cover.jpg")); err == nil {
+				_ = os.WriteFile(filepath.Join(dir, base+".jpg"), data, 0o644)
+			}
+		}
+		if !p.has("dont_write_opf") {
+			if err := os.WriteFile(filepath.Join(dir, base+".opf"), []byte(renderOPF(e.lib, b)), 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func renderExportTemplate(template string, b *book) string {
+	r := strings.NewReplacer(
+		"{author_sort}", sanitize(b.AuthorSort),
+		"{authors}", sanitize(strings.Join(b.Authors, " & ")),
+		"{title}", sanitize(b.Title),
+		"{id}", strconv.Itoa(b.ID),
+		"{series}", sanitize(b.Series),
+		"{series_index}", strconv.FormatFloat(b.SeriesIndex, 'f', -1, 64),
+		"{publisher}", sanitize(b.Publisher),
+		"{isbn}", b.Identifiers["isbn"],
+	)
+	return r.Replace(template)
+}
+
+func cmdCatalog(e *env, p parsed) error {
+	if len(p.args) < 1 {
+		return systemExit{"You must specify a catalog output file"}
+	}
+	dest := p.args[0]
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(dest), "."))
+	var books []*book
+	if ids := p.str("ids", ""); ids != "" {
+		parsedIDs, err := parseIDs("catalog", []string{ids}, false)
+		if err != nil {
+			return err
+		}
+		for _, id := range parsedIDs {
+			if b := e.lib.book(id); b != nil {
+				books = append(books, b)
+			}
+		}
+	} else {
+		match, err := parseSearch(p.str("search", ""))
+		if err != nil {
+			return raise("catalog", "ParseException", err.Error())
+		}
+		for _, b := range e.lib.Books {
+			if match(e.lib, b) {
+				books = append(books, b)
+			}
+		}
+	}
+	var data []byte
+	switch ext {
+	case "csv":
+		// the plugin options; fields the fake does not know are left out
+		columns := map[string]func(b *book) string{
+			"id":      func(b *book) string { return strconv.Itoa(b.ID) },
+			"title":   func(b *book) string { return b.Title },
+			"authors": func(b *book) string { return strings.Join(b.Authors, " & ") },
+			"tags":    func(b *book) string { return strings.Join(b.Tags, ", ") },
+			"uuid":    func(b *book) string { return b.UUID },
+		}
+		fields := []string{"id", "title", "authors", "tags", "uuid"}
+		if f := p.str("fields", "all"); f != "all" {
+			fields = slices.DeleteFunc(strings.Split(f, ","), func(f string) bool { return columns[f] == nil })
+		}
+		if p.str("sort_by", "id") == "title_sort" {
+			slices.SortStableFunc(books, func(a, b *book) int { return strings.Compare(a.Title, b.Title) })
+		}
+		var s strings.Builder
+		w := csv.NewWriter(&s)
+		_ = w.Write(fields)
+		for _, b := range books {
+			row := make([]string, len(fields))
+			for i, f := range fields {
+				row[i] = columns[f](b)
+			}
+			_ = w.Write(row)
+		}
+		w.Flush()
+		data = []byte(s.String())
+	case "xml":
+		var s strings.Builder
+		s.WriteString("<?xml version='1.0' encoding='utf-8'?>\n<calibredb>\n")
+		for _, b := range books {
+			fmt.Fprintf(&s, "  <record>\n    <id>%d</id>\n    <title>%s</title>\n    <uuid>%s</uuid>\n  </record>\n", b.ID, esc(b.Title), b.UUID)
+		}
+		s.WriteString("</calibredb>\n")
+		data = []byte(s.String())
+	case "epub", "mobi", "azw3":
+		data = []byte("fake " + ext + " catalog\n")
+	default:
+		return systemExit{fmt.Sprintf("No plugin available for format: %s", ext)}
+	}
+	if p.has("verbose") {
+		fmt.Fprintf(e.stdout, "Generating %s catalog of %d books\n", strings.ToUpper(ext), len(books))
+	}
+	return os.WriteFile(dest, data, 0o644)
+}
+
+func cmdFTSIndex(e *env, p parsed) error {
+	if len(p.args) < 1 {
+		return systemExit{"Error: You must specify the indexing action"}
+	}
+	switch p.args[0] {
+	case "status":
+		if e.lib.FTSEnabled {
+			fmt.Fprintln(e.stdout, "Indexing is enabled")
+			fmt.Fprintln(e.stdout, "All books have been indexed")
+		} else {
+			fmt.Fprintln(e.stdout, "Indexing is disabled")
+		}
+		return nil
+	case "enable":
+		e.lib.FTSEnabled = true
+		fmt.Fprintln(e.stdout, "Full text searching has been enabled")
+	case "disable":
+		e.lib.FTSEnabled = false
+		fmt.Fprintln(e.stdout, "Full text searching has been disabled")
+	case "reindex":
+		fmt.Fprintln(e.stdout, "Re-indexing all books")
+	default:
+		return systemExit{fmt.Sprintf("Error: %s is not a known action", p.args[0])}
+	}
+	return e.lib.save()
+}
+
+func cmdFTSSearch(e *env, p parsed) error {
+	query := strings.Join(p.args, " ")
+	if strings.TrimSpace(query) == "" {
+		return systemExit{"Error: You must specify the search expression"}
+	}
+	if !e.lib.FTSEnabled {
+		return systemExit{"Full text searching is not enabled on this library. Use the calibredb fts_index enable --wait-for-completion command to enable it"}
+	}
+	type result struct {
+		BookID int    `json:"book_id"`
+		Format string `json:"format"`
+		Title  string `json:"title"`
+		Text   string `json:"text,omitempty"`
+	}
+	var results []result
+	for _, b := range e.lib.Books {
+		if strings.Contains(strings.ToLower(b.Title+" "+b.Comments), strings.ToLower(query)) {
+			for _, f := range sortedKeys(b.Formats) {
+				r := result{BookID: b.ID, Format: f, Title: b.Title}
+				if p.has("include_snippets") {
+					r.Text = b.Comments
+				}
+				results = append(results, r)
+			}
+		}
+	}
+	if p.str("output_format", "text") == "json" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, string(data))
+		return nil
+	}
+	for _, r := range results {
+		fmt.Fprintf(e.stdout, "%s [%s] (%d)\n", r.Title, r.Format, r.BookID)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,163 @@
+// Package fakecalibredb implements a stand-in for calibre's calibredb command
+// line tool. It stores a library as a JSON document instead of SQLite and
+// reproduces calibredb's option parsing, output formats and error reporting
+// (including Python tracebacks), so the calibredb wrappers can be tested on
+// machines without calibre installed.
+package fakecalibredb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultVersion is the calibre version reported by --version unless the
+// FAKECALIBREDB_VERSION environment variable overrides it.
+const DefaultVersion = "8.14.0"
+
+// env is the state shared by a single command invocation.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	lib    *library
+}
+
+// systemExit mirrors Python's SystemExit(message): the message is printed on
+// stderr without a traceback and the exit status is 1.
+type systemExit struct {
+	msg string
+}
+
+func (e systemExit) Error() string {
+	return e.msg
+}
+
+// pyException is an uncaught Python exception. It is printed as a traceback
+// whose last line is "Type: message", which is what the calibredb wrappers
+// surface as the error.
+type pyException struct {
+	typ    string
+	msg    string
+	frames []string
+}
+
+func (e pyException) Error() string {
+	return e.typ + ": " + e.msg
+}
+
+var baseFrames = []string{
+	`  File "runpy.py", line 198, in _run_module_as_main`,
+	`  File "runpy.py", line 88, in _run_code`,
+	`  File "site.py", line 42, in <module>`,
+	`  File "site.py", line 38, in main`,
+	`  File "calibre/db/cli/main.py", line 253, in main`,
+	`  File "calibre/db/cli/main.py", line 40, in run_cmd`,
+}
+
+func raise(command, typ, msg string, frames ...string) pyException {
+	all := append([]string{}, baseFrames...)
+	all = append(all, fmt.Sprintf(`  File "calibre/db/cli/cmd_%s.py", line 81, in main`, command))
+	all = append(all, frames...)
+	return pyException{typ: typ, msg: msg, frames: all}
+}
+
+// Main runs calibredb with the given arguments (excluding the program name)
+// and returns the process exit status.
+func Main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(stdout, mainHelp())
+		return 0
+	}
+	if args[0] == "--version" {
+		fmt.Fprintf(stdout, "calibredb (calibre %s)\n", Version())
+		return 0
+	}
+	name := args[0]
+	spec, ok := commands[name]
+	if !ok {
+		if strings.HasPrefix(name, "-") {
+			// global options before the command, e.g. --with-library=x list
+			return usage(stderr, "Usage: calibredb command [options] [arguments]", "no command specified")
+		}
+		return usage(stderr, "Usage: calibredb command [options] [arguments]", fmt.Sprintf("%s is not a known command", name))
+	}
+	if name == "catalog" {
+		spec = spec.withCatalogPlugin(args[1:])
+	}
+	for _, arg := range args[1:] {
+		if arg == "--" {
+			break
+		}
+		if arg == "-h" || arg == "--help" {
+			fmt.Fprint(stdout, spec.help())
+			return 0
+		}
+	}
+	p, err := spec.parse(args[1:])
+	if err != nil {
+		var ue usageError
+		errors.As(err, &ue)
+		return usage(stderr, "Usage: "+strings.Replace(ue.usage, "%prog", "calibredb", 1), ue.msg)
+	}
+
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+	err = run(e, name, p)
+	if e.lib != nil {
+		e.lib.unlock()
+	}
+	return report(stderr, err)
+}
+
+func run(e *env, name string, p parsed) error {
+	path := p.str("library_path", "")
+	if path == "" {
+		return systemExit{"No library path specified. Use --with-library to specify the path to a calibre library."}
+	}
+	if strings.Contains(path, "://") {
+		return raise(name, "Exception", "Could not connect to the calibre Content server at "+path)
+	}
+	lib, err := openLibrary(path)
+	if err != nil {
+		return raise(name, "apsw.CantOpenError", err.Error(),
+			`  File "calibre/db/backend.py", line 523, in __init__`)
+	}
+	e.lib = lib
+	return handlers[name](e, p)
+}
+
+func usage(stderr io.Writer, usage, msg string) int {
+	fmt.Fprintf(stderr, "%s\n\ncalibredb: error: %s\n", usage, msg)
+	return 2
+}
+
+func report(stderr io.Writer, err error) int {
+	if err == nil {
+		return 0
+	}
+	var se systemExit
+	var pe pyException
+	switch {
+	case errors.As(err, &se):
+		fmt.Fprintln(stderr, se.msg)
+	case errors.As(err, &pe):
+		fmt.Fprintln(stderr, "Traceback (most recent call last):")
+		for _, frame := range pe.frames {
+			fmt.Fprintln(stderr, frame)
+		}
+		fmt.Fprintln(stderr, pe.Error())
+	default:
+		fmt.Fprintln(stderr, err.Error())
+	}
+	return 1
+}
+
+// Version returns the calibre version the fake reports.
+func Version() string {
+	if v := os.Getenv("FAKECALIBREDB_VERSION"); v != "" {
+		return v
+	}
+	return DefaultVersion
+}
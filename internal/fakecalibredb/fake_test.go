@@ -0,0 +1,119 @@
+package fakecalibredb_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/internal/fakecalibredb"
+)
+
+func run(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := fakecalibredb.Main(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestMain_ExitStatus(t *testing.T) {
+	lib := "--with-library=" + t.TempDir()
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "version",
+			args:       []string{"--version"},
+			wantStdout: "calibredb (calibre " + fakecalibredb.DefaultVersion + ")",
+		},
+		{
+			name:       "command help",
+			args:       []string{"list", "--help"},
+			wantStdout: "Usage: calibredb list [options]",
+		},
+		{
+			name:       "unknown command",
+			args:       []string{"frobnicate"},
+			wantCode:   2,
+			wantStderr: "calibredb: error: frobnicate is not a known command",
+		},
+		{
+			name:       "unknown option",
+			args:       []string{"list", "--bogus", lib},
+			wantCode:   2,
+			wantStderr: "calibredb: error: no such option: --bogus",
+		},
+		{
+			name:       "invalid choice",
+			args:       []string{"list_categories", "--dialect", "tsv", lib},
+			wantCode:   2,
+			wantStderr: "invalid choice: 'tsv'",
+		},
+		{
+			name:       "system exit",
+			args:       []string{"show_metadata", "3", lib},
+			wantCode:   1,
+			wantStderr: "Id #3 is not present in database.\n",
+		},
+		{
+			name:       "traceback",
+			args:       []string{"show_metadata", "x", lib},
+			wantCode:   1,
+			wantStderr: "Traceback (most recent call last):\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := run(t, "", tt.args...)
+			if code != tt.wantCode {
+				t.Errorf("exit status = %d, want %d (stderr %q)", code, tt.wantCode, stderr)
+			}
+			if !strings.Contains(stdout, tt.wantStdout) {
+				t.Errorf("stdout = %q, want to contain %q", stdout, tt.wantStdout)
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("stderr = %q, want to contain %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestMain_Library(t *testing.T) {
+	lib := "--with-library=" + t.TempDir()
+	steps := []struct {
+		args       []string
+		stdin      string
+		wantCode   int
+		wantStdout string
+	}{
+		{args: []string{"add", "--empty", "--title", "The Hobbit", "--authors", "J. R. R. Tolkien", lib}, wantStdout: "Added book ids: 1\n"},
+		{args: []string{"add", "--empty", "--title", "Dune", "--tags", "scifi", lib}, wantStdout: "Added book ids: 2\n"},
+		{args: []string{"search", "tags:scifi", lib}, wantStdout: "2\n"},
+		{args: []string{"search", "not", "tags:scifi", lib}, wantStdout: "1\n"},
+		{args: []string{"list", "--fields", "title", "--sort-by", "title", "--ascending", "--for-machine", lib}, wantStdout: `"title": "Dune"`},
+		{args: []string{"show_metadata", "1", lib}, wantStdout: "Title sort          : Hobbit, The\n"},
+		{args: []string{"set_metadata", "2", "--field", "series:Dune", "--field", "series_index:1", lib}, wantStdout: "Series              : Dune #1\n"},
+		{args: []string{"add_custom_column", "read", "Read", "bool", lib}, wantStdout: "Custom column created with id: 1\n"},
+		{args: []string{"set_custom", "read", "1", "yes", lib}, wantStdout: "true\n"},
+		{args: []string{"search", "#read:true", lib}, wantStdout: "1\n"},
+		{args: []string{"remove_custom_column", "read", lib}, stdin: "n\n", wantStdout: "Are you sure (y/n)?"},
+		{args: []string{"custom_columns", lib}, wantStdout: "read (1)\n"},
+		{args: []string{"remove_custom_column", "--force", "read", lib}},
+		{args: []string{"saved_searches", "add", "fiction", "tags:scifi", lib}, wantStdout: "fiction added\n"},
+		{args: []string{"search", "search:fiction", lib}, wantStdout: "2\n"},
+		{args: []string{"remove", "1-3", lib}},
+		{args: []string{"search", "title:Dune", lib}, wantCode: 1},
+	}
+	for _, step := range steps {
+		code, stdout, stderr := run(t, step.stdin, step.args...)
+		if code != step.wantCode {
+			t.Fatalf("%v: exit status = %d, want %d (stderr %q)", step.args, code, step.wantCode, stderr)
+		}
+		if !strings.Contains(stdout, step.wantStdout) {
+			t.Fatalf("%v: stdout = %q, want to contain %q", step.args, stdout, step.wantStdout)
+		}
+	}
+}
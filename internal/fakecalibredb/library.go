@@ -0,0 +1,261 @@
+package fakecalibredb
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// dbName is the file holding the library. It shares calibre's name so that
+// code watching metadata.db for changes behaves the same against the fake.
+const dbName = "metadata.db"
+
+type library struct {
+	path     string
+	lockFile string
+
+	LibraryID     string            `json:"library_id"`
+	NextBookID    int               `json:"next_book_id"`
+	NextColumnID  int               `json:"next_column_id"`
+	Books         []*book           `json:"books"`
+	Columns       []*column         `json:"custom_columns"`
+	SavedSearches map[string]string `json:"saved_searches"`
+	FTSEnabled    bool              `json:"fts_enabled"`
+}
+
+type book struct {
+	ID           int               `json:"id"`
+	UUID         string            `json:"uuid"`
+	Title        string            `json:"title"`
+	TitleSort    string            `json:"title_sort"`
+	Authors      []string          `json:"authors"`
+	AuthorSort   string            `json:"author_sort"`
+	Comments     string            `json:"comments,omitempty"`
+	Publisher    string            `json:"publisher,omitempty"`
+	Series       string            `json:"series,omitempty"`
+	SeriesIndex  float64           `json:"series_index"`
+	Rating       float64           `json:"rating,omitempty"`
+	Tags         []string          `json:"tags,omitempty"`
+	Languages    []string          `json:"languages,omitempty"`
+	Identifiers  map[string]string `json:"identifiers,omitempty"`
+	Pubdate      time.Time         `json:"pubdate"`
+	Timestamp    time.Time         `json:"timestamp"`
+	LastModified time.Time         `json:"last_modified"`
+	Path         string            `json:"path"`
+	Formats      map[string]string `json:"formats,omitempty"`
+	HasCover     bool              `json:"has_cover,omitempty"`
+	Custom       map[string]any    `json:"custom,omitempty"`
+}
+
+type column struct {
+	Num        int            `json:"num"`
+	Label      string         `json:"label"`
+	Name       string         `json:"name"`
+	Datatype   string         `json:"datatype"`
+	IsMultiple bool           `json:"is_multiple"`
+	Display    map[string]any `json:"display"`
+}
+
+// undefinedDate is what calibre stores for dates that were never set.
+var undefinedDate = time.Date(101, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// openLibrary loads the library at path, creating an empty one like calibredb
+// does when the folder has no database yet. The library stays locked until
+// unlock is called so concurrent invocations serialize.
+func openLibrary(path string) (*library, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+	lib := &library{path: path, lockFile: filepath.Join(path, ".fakecalibredb.lock")}
+	if err := lib.lock(); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(path, dbName))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		lib.LibraryID = newUUID()
+		lib.NextBookID = 1
+		lib.NextColumnID = 1
+		lib.SavedSearches = map[string]string{}
+		if err := lib.save(); err != nil {
+			lib.unlock()
+			return nil, err
+		}
+		return lib, nil
+	case err != nil:
+		lib.unlock()
+		return nil, err
+	}
+	if err := json.Unmarshal(data, lib); err != nil {
+		lib.unlock()
+		return nil, fmt.Errorf("file is not a database: %s", filepath.Join(path, dbName))
+	}
+	if lib.SavedSearches == nil {
+		lib.SavedSearches = map[string]string{}
+	}
+	return lib, nil
+}
+
+func (lib *library) lock() error {
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		f, err := os.OpenFile(lib.lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			return f.Close()
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return err
+		}
+		if info, statErr := os.Stat(lib.lockFile); statErr == nil && time.Since(info.ModTime()) > time.Minute {
+			// stale lock left by a killed process
+			_ = os.Remove(lib.lockFile)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return errors.New("database is locked")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (lib *library) unlock() {
+	_ = os.Remove(lib.lockFile)
+}
+
+func (lib *library) save() error {
+	data, err := json.MarshalIndent(lib, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(lib.path, dbName+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(lib.path, dbName))
+}
+
+func (lib *library) book(id int) *book {
+	for _, b := range lib.Books {
+		if b.ID == id {
+			return b
+		}
+	}
+	return nil
+}
+
+func (lib *library) column(label string) *column {
+	label = strings.TrimPrefix(label, "#")
+	for _, c := range lib.Columns {
+		if c.Label == label {
+			return c
+		}
+	}
+	return nil
+}
+
+func (lib *library) allIDs() []int {
+	ids := make([]int, 0, len(lib.Books))
+	for _, b := range lib.Books {
+		ids = append(ids, b.ID)
+	}
+	return ids
+}
+
+// newBook creates a book with calibre's defaults and stores it in the library.
+func (lib *library) newBook(title string, authors []string) *book {
+	if title == "" {
+		title = "Unknown"
+	}
+	if len(authors) == 0 {
+		authors = []string{"Unknown"}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	b := &book{
+		ID:           lib.NextBookID,
+		UUID:         newUUID(),
+		Title:        title,
+		TitleSort:    titleSort(title),
+		Authors:      authors,
+		AuthorSort:   authorSort(authors),
+		SeriesIndex:  1,
+		Pubdate:      undefinedDate,
+		Timestamp:    now,
+		LastModified: now,
+		Identifiers:  map[string]string{},
+		Formats:      map[string]string{},
+	}
+	b.Path = bookPath(b)
+	lib.NextBookID++
+	lib.Books = append(lib.Books, b)
+	return b
+}
+
+func (lib *library) removeBook(id int) {
+	b := lib.book(id)
+	if b == nil {
+		return
+	}
+	_ = os.RemoveAll(filepath.Join(lib.path, b.Path))
+	lib.Books = slices.DeleteFunc(lib.Books, func(x *book) bool { return x.ID == id })
+}
+
+func (b *book) touch() {
+	b.LastModified = time.Now().UTC().Truncate(time.Second)
+}
+
+// bookPath is the folder calibre uses for a book: Author/Title (id).
+func bookPath(b *book) string {
+	return filepath.Join(sanitize(b.Authors[0]), fmt.Sprintf("%s (%d)", sanitize(b.Title), b.ID))
+}
+
+var unsafeChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]`)
+
+func sanitize(s string) string {
+	s = strings.TrimSpace(unsafeChars.ReplaceAllString(s, "_"))
+	if len(s) > 60 {
+		s = s[:60]
+	}
+	if s == "" {
+		return "Unknown"
+	}
+	return s
+}
+
+func titleSort(title string) string {
+	lower := strings.ToLower(title)
+	for _, article := range []string{"the ", "a ", "an "} {
+		if strings.HasPrefix(lower, article) {
+			return title[len(article):] + ", " + title[:len(article)-1]
+		}
+	}
+	return title
+}
+
+func authorSort(authors []string) string {
+	sorted := make([]string, 0, len(authors))
+	for _, a := range authors {
+		parts := strings.Fields(a)
+		if len(parts) < 2 {
+			sorted = append(sorted, a)
+			continue
+		}
+		last := parts[len(parts)-1]
+		sorted = append(sorted, last+", "+strings.Join(parts[:len(parts)-1], " "))
+	}
+	return strings.Join(sorted, " & ")
+}
+
+func newUUID() string {
+	var u [16]byte
+	_, _ = rand.Read(u[:])
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
@@ -0,0 +1,165 @@
+package fakecalibredb
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type opfPackage struct {
+	XMLName  xml.Name    `xml:"package"`
+	Version  string      `xml:"version,attr"`
+	Metadata opfMetadata `xml:"metadata"`
+}
+
+type opfMetadata struct {
+	Identifiers []opfIdentifier `xml:"identifier"`
+	Title       string          `xml:"title"`
+	Creators    []opfCreator    `xml:"creator"`
+	Description string          `xml:"description"`
+	Publisher   string          `xml:"publisher"`
+	Date        string          `xml:"date"`
+	Languages   []string        `xml:"language"`
+	Subjects    []string        `xml:"subject"`
+	Metas       []opfMeta       `xml:"meta"`
+}
+
+type opfIdentifier struct {
+	ID     string `xml:"id,attr,omitempty"`
+	Scheme string `xml:"scheme,attr,omitempty"`
+	Value  string `xml:",chardata"`
+}
+
+type opfCreator struct {
+	Role   string `xml:"role,attr,omitempty"`
+	FileAs string `xml:"file-as,attr,omitempty"`
+	Name   string `xml:",chardata"`
+}
+
+type opfMeta struct {
+	Name    string `xml:"name,attr,omitempty"`
+	Content string `xml:"content,attr,omitempty"`
+}
+
+// renderOPF writes the OPF 2 document calibredb show_metadata --as-opf prints.
+func renderOPF(lib *library, b *book) string {
+	var s strings.Builder
+	s.WriteString(`<?xml version='1.0' encoding='utf-8'?>` + "\n")
+	s.WriteString(`<package xmlns="http://www.idpf.org/2007/opf" unique-identifier="uuid_id" version="2.0">` + "\n")
+	s.WriteString(`    <metadata xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:opf="http://www.idpf.org/2007/opf">` + "\n")
+	fmt.Fprintf(&s, "        <dc:identifier opf:scheme=\"calibre\" id=\"calibre_id\">%d</dc:identifier>\n", b.ID)
+	fmt.Fprintf(&s, "        <dc:identifier opf:scheme=\"uuid\" id=\"uuid_id\">%s</dc:identifier>\n", esc(b.UUID))
+	for _, scheme := range sortedKeys(b.Identifiers) {
+		fmt.Fprintf(&s, "        <dc:identifier opf:scheme=\"%s\">%s</dc:identifier>\n", esc(strings.ToUpper(scheme)), esc(b.Identifiers[scheme]))
+	}
+	fmt.Fprintf(&s, "        <dc:title>%s</dc:title>\n", esc(b.Title))
+	for _, a := range b.Authors {
+		fmt.Fprintf(&s, "        <dc:creator opf:file-as=\"%s\" opf:role=\"aut\">%s</dc:creator>\n", esc(b.AuthorSort), esc(a))
+	}
+	s.WriteString("        <dc:contributor opf:file-as=\"calibre\" opf:role=\"bkp\">calibre (" + Version() + ") [https://calibre-ebook.com]</dc:contributor>\n")
+	if !b.Pubdate.Equal(undefinedDate) {
+		fmt.Fprintf(&s, "        <dc:date>%s</dc:date>\n", b.Pubdate.Format(time.RFC3339))
+	}
+	if b.Comments != "" {
+		fmt.Fprintf(&s, "        <dc:description>%s</dc:description>\n", esc(b.Comments))
+	}
+	if b.Publisher != "" {
+		fmt.Fprintf(&s, "        <dc:publisher>%s</dc:publisher>\n", esc(b.Publisher))
+	}
+	for _, l := range b.Languages {
+		fmt.Fprintf(&s, "        <dc:language>%s</dc:language>\n", esc(l))
+	}
+	for _, t := range b.Tags {
+		fmt.Fprintf(&s, "        <dc:subject>%s</dc:subject>\n", esc(t))
+	}
+	if b.Series != "" {
+		fmt.Fprintf(&s, "        <meta name=\"calibre:series\" content=\"%s\"/>\n", esc(b.Series))
+		fmt.Fprintf(&s, "        <meta name=\"calibre:series_index\" content=\"%s\"/>\n", strconv.FormatFloat(b.SeriesIndex, 'f', -1, 64))
+	}
+	if b.Rating != 0 {
+		fmt.Fprintf(&s, "        <meta name=\"calibre:rating\" content=\"%s\"/>\n", strconv.FormatFloat(b.Rating, 'f', -1, 64))
+	}
+	fmt.Fprintf(&s, "        <meta name=\"calibre:timestamp\" content=\"%s\"/>\n", b.Timestamp.Format(time.RFC3339))
+	fmt.Fprintf(&s, "        <meta name=\"calibre:title_sort\" content=\"%s\"/>\n", esc(b.TitleSort))
+	s.WriteString("    </metadata>\n")
+	s.WriteString("    <guide/>\n")
+	s.WriteString("</package>\n")
+	return s.String()
+}
+
+// applyOPF updates b from an OPF document the way set_metadata does: fields
+// present in the OPF replace the stored values.
+func applyOPF(b *book, data []byte) error {
+	var pkg opfPackage
+	if err := xml.Unmarshal(data, &pkg); err != nil {
+		return err
+	}
+	m := pkg.Metadata
+	if m.Title != "" {
+		b.Title = strings.TrimSpace(m.Title)
+		b.TitleSort = titleSort(b.Title)
+	}
+	var authors []string
+	for _, c := range m.Creators {
+		if c.Role == "" || c.Role == "aut" {
+			authors = append(authors, strings.TrimSpace(c.Name))
+		}
+	}
+	if len(authors) > 0 {
+		b.Authors = authors
+		b.AuthorSort = authorSort(authors)
+	}
+	if m.Description != "" {
+		b.Comments = m.Description
+	}
+	if m.Publisher != "" {
+		b.Publisher = m.Publisher
+	}
+	if len(m.Languages) > 0 {
+		b.Languages = m.Languages
+	}
+	if len(m.Subjects) > 0 {
+		b.Tags = m.Subjects
+	}
+	if m.Date != "" {
+		if t, err := time.Parse(time.RFC3339, m.Date); err == nil {
+			b.Pubdate = t
+		} else if t, err := time.Parse("2006-01-02", m.Date); err == nil {
+			b.Pubdate = t
+		}
+	}
+	for _, id := range m.Identifiers {
+		scheme := strings.ToLower(id.Scheme)
+		if scheme == "" || scheme == "calibre" || scheme == "uuid" {
+			continue
+		}
+		if b.Identifiers == nil {
+			b.Identifiers = map[string]string{}
+		}
+		b.Identifiers[scheme] = strings.TrimSpace(id.Value)
+	}
+	for _, meta := range m.Metas {
+		switch meta.Name {
+		case "calibre:series":
+			b.Series = meta.Content
+		case "calibre:series_index":
+			if f, err := strconv.ParseFloat(meta.Content, 64); err == nil {
+				b.SeriesIndex = f
+			}
+		case "calibre:rating":
+			if f, err := strconv.ParseFloat(meta.Content, 64); err == nil {
+				b.Rating = f
+			}
+		}
+	}
+	b.touch()
+	return nil
+}
+
+func esc(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
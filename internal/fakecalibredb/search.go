@@ -0,0 +1,387 @@
+package fakecalibredb
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// matcher reports whether a book satisfies a parsed search expression.
+type matcher func(lib *library, b *book) bool
+
+// parseSearch compiles the subset of calibre's search language used by the
+// tests and tools in this repository: field:value terms with =, ~ and numeric
+// comparison prefixes, true/false, quoting, parentheses and and/or/not.
+func parseSearch(query string) (matcher, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return func(*library, *book) bool { return true }, nil
+	}
+	p := &searchParser{tokens: tokens}
+	m, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("Invalid search expression: unexpected %q", p.tokens[p.pos])
+	}
+	return m, nil
+}
+
+func tokenize(query string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			start := i
+			inQuote := false
+			for i < len(query) {
+				c := query[i]
+				if c == '"' {
+					inQuote = !inQuote
+				} else if c == '\\' && inQuote && i+1 < len(query) {
+					i++
+				} else if !inQuote && (c == ' ' || c == '\t' || c == '\n' || c == '(' || c == ')') {
+					break
+				}
+				i++
+			}
+			if inQuote {
+				return nil, fmt.Errorf("Invalid search expression: unterminated quote in %q", query[start:])
+			}
+			tokens = append(tokens, query[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+type searchParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *searchParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *searchParser) or() (matcher, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "or") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(lib *library, b *book) bool { return l(lib, b) || right(lib, b) }
+	}
+	return left, nil
+}
+
+func (p *searchParser) and() (matcher, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		next := p.peek()
+		if next == "" || next == ")" || strings.EqualFold(next, "or") {
+			return left, nil
+		}
+		if strings.EqualFold(next, "and") {
+			p.pos++
+		}
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(lib *library, b *book) bool { return l(lib, b) && right(lib, b) }
+	}
+}
+
+func (p *searchParser) not() (matcher, error) {
+	if strings.EqualFold(p.peek(), "not") {
+		p.pos++
+		inner, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return func(lib *library, b *book) bool { return !inner(lib, b) }, nil
+	}
+	return p.primary()
+}
+
+func (p *searchParser) primary() (matcher, error) {
+	tok := p.peek()
+	switch tok {
+	case "":
+		return nil, fmt.Errorf("Invalid search expression: unexpected end of query")
+	case ")":
+		return nil, fmt.Errorf("Invalid search expression: unexpected )")
+	case "(":
+		p.pos++
+		m, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("Invalid search expression: missing )")
+		}
+		p.pos++
+		return m, nil
+	}
+	p.pos++
+	return term(tok)
+}
+
+var bareFields = []string{"title", "authors", "tags", "series", "publisher", "comments"}
+
+func term(tok string) (matcher, error) {
+	field, value := "", tok
+	if !strings.HasPrefix(tok, `"`) {
+		if f, v, ok := strings.Cut(tok, ":"); ok {
+			field, value = strings.ToLower(f), v
+		}
+	}
+	value = unquote(value)
+	switch field {
+	case "author":
+		field = "authors"
+	case "tag":
+		field = "tags"
+	case "comment":
+		field = "comments"
+	case "format":
+		field = "formats"
+	case "identifier":
+		field = "identifiers"
+	case "language":
+		field = "languages"
+	}
+
+	if field == "" {
+		match, err := textMatcher(value)
+		if err != nil {
+			return nil, err
+		}
+		return func(lib *library, b *book) bool {
+			for _, f := range bareFields {
+				for _, v := range fieldValues(lib, b, f) {
+					if match(v) {
+						return true
+					}
+				}
+			}
+			return false
+		}, nil
+	}
+	if field == "search" {
+		return func(lib *library, b *book) bool {
+			query, ok := lib.SavedSearches[value]
+			if !ok {
+				return false
+			}
+			m, err := parseSearch(query)
+			return err == nil && m(lib, b)
+		}, nil
+	}
+	if field == "identifiers" {
+		scheme, ident, hasIdent := strings.Cut(value, ":")
+		match, err := textMatcher(ident)
+		if err != nil {
+			return nil, err
+		}
+		return func(lib *library, b *book) bool {
+			for k, v := range b.Identifiers {
+				if (scheme == "" || strings.EqualFold(k, scheme)) && (!hasIdent || match(v)) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	if lower := strings.ToLower(value); lower == "true" || lower == "false" {
+		want := lower == "true"
+		return func(lib *library, b *book) bool {
+			return (len(fieldValues(lib, b, field)) > 0) == want
+		}, nil
+	}
+	if isNumericField(field) {
+		cmp, n, err := numericComparison(field, value)
+		if err == nil {
+			return func(lib *library, b *book) bool {
+				for _, v := range fieldValues(lib, b, field) {
+					if f, err := strconv.ParseFloat(v, 64); err == nil && cmp(f, n) {
+						return true
+					}
+				}
+				return false
+			}, nil
+		}
+	}
+	match, err := textMatcher(value)
+	if err != nil {
+		return nil, err
+	}
+	return func(lib *library, b *book) bool {
+		for _, v := range fieldValues(lib, b, field) {
+			if match(v) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && strings.HasPrefix(s, `"`) && strings.HasSuffix(s, `"`) {
+		s = s[1 : len(s)-1]
+		s = strings.ReplaceAll(s, `\"`, `"`)
+	}
+	return s
+}
+
+// textMatcher implements calibre's three match kinds: =exact, ~regex and the
+// default case insensitive "contains".
+func textMatcher(value string) (func(string) bool, error) {
+	switch {
+	case strings.HasPrefix(value, "="):
+		want := strings.ToLower(value[1:])
+		return func(v string) bool { return strings.ToLower(v) == want }, nil
+	case strings.HasPrefix(value, "~"):
+		re, err := regexp.Compile("(?i)" + value[1:])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid regular expression: %s", value[1:])
+		}
+		return re.MatchString, nil
+	default:
+		want := strings.ToLower(value)
+		return func(v string) bool { return strings.Contains(strings.ToLower(v), want) }, nil
+	}
+}
+
+func isNumericField(field string) bool {
+	switch field {
+	case "id", "rating", "series_index", "size":
+		return true
+	}
+	return strings.HasPrefix(field, "#")
+}
+
+func numericComparison(field, value string) (func(a, b float64) bool, float64, error) {
+	ops := []struct {
+		prefix string
+		cmp    func(a, b float64) bool
+	}{
+		{">=", func(a, b float64) bool { return a >= b }},
+		{"<=", func(a, b float64) bool { return a <= b }},
+		{"!=", func(a, b float64) bool { return a != b }},
+		{">", func(a, b float64) bool { return a > b }},
+		{"<", func(a, b float64) bool { return a < b }},
+		{"=", func(a, b float64) bool { return a == b }},
+		{"", func(a, b float64) bool { return a == b }},
+	}
+	for _, op := range ops {
+		if rest, ok := strings.CutPrefix(value, op.prefix); ok {
+			n, err := strconv.ParseFloat(rest, 64)
+			if err != nil {
+				return nil, 0, err
+			}
+			if field == "rating" {
+				// searches use stars, the database stores half stars
+				n *= 2
+			}
+			return op.cmp, n, nil
+		}
+	}
+	return nil, 0, fmt.Errorf("not a number")
+}
+
+// fieldValues returns the searchable values of a field as strings.
+func fieldValues(lib *library, b *book, field string) []string {
+	switch field {
+	case "id":
+		return []string{strconv.Itoa(b.ID)}
+	case "title":
+		return []string{b.Title}
+	case "authors":
+		return b.Authors
+	case "tags":
+		return b.Tags
+	case "series":
+		return nonEmpty(b.Series)
+	case "series_index":
+		if b.Series == "" {
+			return nil
+		}
+		return []string{strconv.FormatFloat(b.SeriesIndex, 'f', -1, 64)}
+	case "publisher":
+		return nonEmpty(b.Publisher)
+	case "comments":
+		return nonEmpty(b.Comments)
+	case "languages":
+		return b.Languages
+	case "formats":
+		formats := make([]string, 0, len(b.Formats))
+		for f := range b.Formats {
+			formats = append(formats, f)
+		}
+		return formats
+	case "uuid":
+		return []string{b.UUID}
+	case "rating":
+		if b.Rating == 0 {
+			return nil
+		}
+		return []string{strconv.FormatFloat(b.Rating, 'f', -1, 64)}
+	case "cover":
+		if b.HasCover {
+			return []string{"true"}
+		}
+		return nil
+	}
+	if col := lib.column(field); col != nil {
+		return customValues(b.Custom[col.Label])
+	}
+	return nil
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+func customValues(v any) []string {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, x := range v {
+			out = append(out, fmt.Sprint(x))
+		}
+		return out
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
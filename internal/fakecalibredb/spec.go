@@ -0,0 +1,313 @@
+package fakecalibredb
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type optionKind int
+
+const (
+	optBool optionKind = iota
+	optString
+	optInt
+	optFloat
+	optChoice
+	optAppend
+)
+
+type optionSpec struct {
+	names   []string
+	kind    optionKind
+	choices []string
+	help    string
+}
+
+type commandSpec struct {
+	usage       string
+	description string
+	options     []optionSpec
+}
+
+// globalOptions are accepted by every command, exactly like calibredb.
+var globalOptions = []optionSpec{
+	{names: []string{"--library-path", "--with-library"}, kind: optString, help: "Path to the calibre library. Default is to use the path stored in the settings. You can also connect to a calibre Content server to perform actions on remote libraries. To do so use a URL of the form: http://hostname:port/#library_id for example, http://localhost:8080/#mylibrary. library_id is the library id of the library you want to connect to on the Content server. You can use the special library_id value of - to get a list of library ids available on the server. For details on how to setup access via a Content server, see https://manual.calibre-ebook.com/generated/en/calibredb.html."},
+	{names: []string{"--username"}, kind: optString, help: "Username for connecting to a calibre Content server"},
+	{names: []string{"--password"}, kind: optString, help: "Password for connecting to a calibre Content server. To read the password from standard input, use the special value: <stdin>. To read the password from a file, use: <f:/path/to/file> (i.e. <f: followed by the full path to the file and a trailing >). The angle brackets in the above are required, remember to escape them or use quotes for your shell."},
+	{names: []string{"--timeout"}, kind: optFloat, help: "The timeout, in seconds, when connecting to a calibre library over the network. The default is two minutes."},
+}
+
+// dest is the attribute name optparse derives from the first long option.
+func (o optionSpec) dest() string {
+	for _, name := range o.names {
+		if strings.HasPrefix(name, "--") {
+			return strings.ReplaceAll(strings.TrimPrefix(name, "--"), "-", "_")
+		}
+	}
+	return strings.TrimPrefix(o.names[0], "-")
+}
+
+// parsed holds the result of parsing a command line with optparse semantics.
+type parsed struct {
+	values map[string][]string
+	args   []string
+}
+
+func (p parsed) has(dest string) bool {
+	_, ok := p.values[dest]
+	return ok
+}
+
+func (p parsed) str(dest, def string) string {
+	if v := p.values[dest]; len(v) > 0 {
+		return v[len(v)-1]
+	}
+	return def
+}
+
+func (p parsed) list(dest string) []string {
+	return p.values[dest]
+}
+
+func (p parsed) int(dest string, def int) int {
+	if v := p.values[dest]; len(v) > 0 {
+		if n, err := strconv.Atoi(v[len(v)-1]); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+func (p parsed) float(dest string, def float64) float64 {
+	if v := p.values[dest]; len(v) > 0 {
+		if n, err := strconv.ParseFloat(v[len(v)-1], 64); err == nil {
+			return n
+		}
+	}
+	return def
+}
+
+// usageError is reported like optparse does: usage line, blank line and
+// "calibredb: error: ..." with exit status 2.
+type usageError struct {
+	usage string
+	msg   string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+// parse emulates optparse: options may appear anywhere, take their value
+// either inline (--opt=value) or from the next argument, and "--" ends option
+// processing.
+func (spec commandSpec) parse(argv []string) (parsed, error) {
+	all := append(slices.Clone(spec.options), globalOptions...)
+	find := func(name string) (optionSpec, bool) {
+		for _, o := range all {
+			if slices.Contains(o.names, name) {
+				return o, true
+			}
+		}
+		return optionSpec{}, false
+	}
+	p := parsed{values: make(map[string][]string)}
+	for i := 0; i < len(argv); i++ {
+		arg := argv[i]
+		if arg == "--" {
+			p.args = append(p.args, argv[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" || isNumber(arg) {
+			p.args = append(p.args, arg)
+			continue
+		}
+		name, inline, hasInline := strings.Cut(arg, "=")
+		if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			// short option with attached value: -fVALUE
+			name, inline, hasInline = arg[:2], arg[2:], true
+		}
+		opt, ok := find(name)
+		if !ok {
+			return p, usageError{spec.usage, "no such option: " + name}
+		}
+		if opt.kind == optBool {
+			if hasInline {
+				return p, usageError{spec.usage, name + " option does not take a value"}
+			}
+			p.values[opt.dest()] = append(p.values[opt.dest()], "true")
+			continue
+		}
+		value := inline
+		if !hasInline {
+			if i+1 >= len(argv) {
+				return p, usageError{spec.usage, name + " option requires 1 argument"}
+			}
+			i++
+			value = argv[i]
+		}
+		switch opt.kind {
+		case optInt:
+			if _, err := strconv.Atoi(value); err != nil {
+				return p, usageError{spec.usage, fmt.Sprintf("option %s: invalid integer value: '%s'", name, value)}
+			}
+		case optFloat:
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return p, usageError{spec.usage, fmt.Sprintf("option %s: invalid floating-point value: '%s'", name, value)}
+			}
+		case optChoice:
+			if !slices.Contains(opt.choices, value) {
+				quoted := make([]string, len(opt.choices))
+				for i, c := range opt.choices {
+					quoted[i] = "'" + c + "'"
+				}
+				return p, usageError{spec.usage, fmt.Sprintf("option %s: invalid choice: '%s' (choose from %s)", name, value, strings.Join(quoted, ", "))}
+			}
+		}
+		p.values[opt.dest()] = append(p.values[opt.dest()], value)
+	}
+	return p, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+const helpPosition = 24
+
+// help renders the command help in the layout produced by calibre's
+// OptionParser.
+func (spec commandSpec) help() string {
+	var b strings.Builder
+	b.WriteString("Usage: " + strings.Replace(spec.usage, "%prog", "calibredb", 1) + "\n\n")
+	b.WriteString(spec.description + "\n\n")
+	b.WriteString("Whenever you pass arguments to calibredb that have spaces in them, enclose the arguments in quotation marks. For example: \"/some path/with spaces\"\n\n")
+	b.WriteString("Options:\n")
+	writeOption(&b, "  ", "-h, --help", "show this help message and exit")
+	writeOption(&b, "  ", "--version", "show program's version number and exit")
+	if len(spec.options) > 0 {
+		for _, o := range spec.options {
+			writeOption(&b, "  ", o.invocation(), o.helpText())
+		}
+	}
+	b.WriteString("\n  GLOBAL OPTIONS:\n")
+	for _, o := range globalOptions {
+		writeOption(&b, "    ", o.invocation(), o.help)
+	}
+	return b.String()
+}
+
+func (o optionSpec) helpText() string {
+	if o.kind == optChoice && !strings.Contains(o.help, "Choices:") {
+		return o.help + " Choices: " + strings.Join(o.choices, ", ")
+	}
+	return o.help
+}
+
+// invocation formats the option strings the way optparse does, for example
+// "-f FIELDS, --fields=FIELDS".
+func (o optionSpec) invocation() string {
+	metavar := strings.ToUpper(o.dest())
+	parts := make([]string, 0, len(o.names))
+	// optparse lists short options before long ones
+	names := slices.Clone(o.names)
+	slices.SortStableFunc(names, func(a, b string) int {
+		return boolInt(strings.HasPrefix(a, "--")) - boolInt(strings.HasPrefix(b, "--"))
+	})
+	for _, name := range names {
+		switch {
+		case o.kind == optBool:
+			parts = append(parts, name)
+		case strings.HasPrefix(name, "--"):
+			parts = append(parts, name+"="+metavar)
+		default:
+			parts = append(parts, name+" "+metavar)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func writeOption(b *strings.Builder, indent, invocation, help string) {
+	lines := wrap(help, 80-helpPosition)
+	head := indent + invocation
+	if len(head) <= helpPosition-2 {
+		b.WriteString(head + strings.Repeat(" ", helpPosition-len(head)))
+	} else {
+		b.WriteString(head + "\n")
+		if len(lines) > 0 {
+			b.WriteString(strings.Repeat(" ", helpPosition))
+		}
+	}
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString(strings.Repeat(" ", helpPosition))
+		}
+		b.WriteString(line + "\n")
+	}
+	if len(lines) == 0 && len(head) <= helpPosition-2 {
+		b.WriteString("\n")
+	}
+}
+
+func wrap(text string, width int) []string {
+	var lines []string
+	var line strings.Builder
+	for _, word := range strings.Fields(text) {
+		if line.Len() > 0 && line.Len()+1+len(word) > width {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(word)
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// mainHelp is printed for calibredb --help.
+func mainHelp() string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var b strings.Builder
+	b.WriteString("Usage: calibredb command [options] [arguments]\n\n")
+	b.WriteString("calibredb is the command line interface to the calibre books database.\n\n")
+	b.WriteString("command is one of:\n")
+	for _, name := range names {
+		b.WriteString("  " + name + "\n")
+	}
+	b.WriteString("\nFor help on an individual command: calibredb command --help\n\n")
+	b.WriteString("Options:\n")
+	writeOption(&b, "  ", "-h, --help", "show this help message and exit")
+	writeOption(&b, "  ", "--version", "show program's version number and exit")
+	return b.String()
+}
+
+// withCatalogPlugin adds the options of the catalog plugin for the
+// destination file in args, the way calibredb catalog picks the plugin by
+// the extension of its first argument.
+func (spec commandSpec) withCatalogPlugin(args []string) commandSpec {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return spec
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(args[0]), "."))
+	spec.options = append(slices.Clone(spec.options), catalogPlugins[ext]...)
+	return spec
+}
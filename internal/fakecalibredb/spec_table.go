@@ -0,0 +1,254 @@
+package fakecalibredb
+
+// commands describes the calibredb command line surface emulated by the fake.
+// It mirrors calibre 8.14, including options the generated wrappers do not
+// model yet, so that help output and option parsing behave like the real tool.
+var commands = map[string]commandSpec{
+	"add": {
+		usage:       "%prog add [options] file1 file2 file3 ...",
+		description: "Add the specified files as books to the database. You can also specify folders, see\nthe folder related options below.",
+		options: []optionSpec{
+			{names: []string{"--authors", "-a"}, kind: optString, help: "Set the authors of the added book(s)"},
+			{names: []string{"--automerge", "-m"}, kind: optChoice, choices: []string{"disabled", "ignore", "overwrite", "new_record"}, help: "If books with similar titles and authors are found, merge the incoming formats (files) automatically into existing book records. A value of \" ignore \" means duplicate formats are discarded. A value of \" overwrite \" means duplicate formats in the library are overwritten with the newly added files. A value of \" new_record \" means duplicate formats are placed into a new book record."},
+			{names: []string{"--cover", "-c"}, kind: optString, help: "Path to the cover to use for the added book"},
+			{names: []string{"--duplicates", "-d"}, kind: optBool, help: "Add books to database even if they already exist. Comparison is done based on book titles and authors. Note that the --automerge option takes precedence."},
+			{names: []string{"--empty", "-e"}, kind: optBool, help: "Add an empty book (a book with no formats)"},
+			{names: []string{"--identifier", "-I"}, kind: optAppend, help: "Set the identifiers for this book, e.g. -I asin:XXX -I isbn:YYY"},
+			{names: []string{"--isbn", "-i"}, kind: optString, help: "Set the ISBN of the added book(s)"},
+			{names: []string{"--languages", "-l"}, kind: optString, help: "A comma separated list of languages (best to use ISO639 language codes, though some language names may also be recognized)"},
+			{names: []string{"--one-book-per-directory", "-1"}, kind: optBool, help: "Assume that each folder has only a single logical book and that all files in it are different e-book formats of that book"},
+			{names: []string{"--recurse", "-r"}, kind: optBool, help: "Process folders recursively"},
+			{names: []string{"--series", "-s"}, kind: optString, help: "Set the series of the added book(s)"},
+			{names: []string{"--series-index", "-S"}, kind: optFloat, help: "Set the series number of the added book(s)"},
+			{names: []string{"--tags", "-T"}, kind: optString, help: "Set the tags of the added book(s)"},
+			{names: []string{"--title", "-t"}, kind: optString, help: "Set the title of the added book(s)"},
+		},
+	},
+	"add_custom_column": {
+		usage:       "%prog add_custom_column [options] label name datatype",
+		description: "Create a custom column. label is the machine friendly name of the column. Should\nnot contain spaces or colons. name is the human friendly name of the column.\ndatatype is one of: bool, comments, composite, datetime, enumeration, float, int, rating, series, text",
+		options: []optionSpec{
+			{names: []string{"--display"}, kind: optString, help: "A dictionary of options to customize how the data in this column will be interpreted. This is a JSON  string. For enumeration columns, use --display \" {\\ \" enum_values\\ \" :[\\ \" val1\\ \" , \\ \" val2\\ \" ]} \" There are many options that can go into the display variable.The options by column type are: composite: composite_template, composite_sort, make_category,contains_html, use_decorations datetime: date_format enumeration: enum_values, enum_colors, use_decorations int, float: number_format text: is_names, use_decorations  The best way to find legal combinations is to create a custom column of the appropriate type in the GUI then look at the backup OPF for a book (ensure that a new OPF has been created since the column was added). You will see the JSON for the \" display \" for the new column in the OPF."},
+			{names: []string{"--is-multiple"}, kind: optBool, help: "This column stores tag like data (i.e. multiple comma separated values). Only applies if datatype is text."},
+		},
+	},
+	"add_format": {
+		usage:       "%prog add_format [options] id ebook_file",
+		description: "Add the e-book in ebook_file to the available formats for the logical book identified by id. You can get id by using the search command. If the format already exists, it is replaced, unless the do not replace option is specified.",
+		options: []optionSpec{
+			{names: []string{"--as-extra-data-file"}, kind: optBool, help: "Add the file as an extra data file to the book, not an ebook format"},
+			{names: []string{"--dont-replace"}, kind: optBool, help: "Do not replace the format if it already exists"},
+		},
+	},
+	"backup_metadata": {
+		usage:       "%prog backup_metadata [options]",
+		description: "Backup the metadata stored in the database into individual OPF files in each\nbooks folder. This normally happens automatically, but you can run this\ncommand to force re-generation of the OPF files, with the –all option.",
+		options: []optionSpec{
+			{names: []string{"--all"}, kind: optBool, help: "Normally, this command only operates on books that have out of date OPF files. This option makes it operate on all books."},
+		},
+	},
+	"catalog": {
+		usage:       "%prog catalog /path/to/destination.(csv|epub|mobi|xml...) [options]",
+		description: "Export a catalog in format specified by path/to/destination extension.\nOptions control how entries are displayed in the generated catalog output.\nNote that different catalog formats support different sets of options. To\nsee the different options, specify the name of the output file and then the\n–help option.",
+		options: []optionSpec{
+			{names: []string{"--ids", "-i"}, kind: optString, help: "Comma-separated list of database IDs to catalog. If declared, --search is ignored. Default: all"},
+			{names: []string{"--search", "-s"}, kind: optString, help: "Filter the results by the search query. For the format of the search query, please see the search-related documentation in the User Manual. Default: no filtering"},
+			{names: []string{"--verbose", "-v"}, kind: optBool, help: "Show detailed output information. Useful for debugging"},
+		},
+	},
+	"check_library": {
+		usage:       "%prog check_library [options]",
+		description: "Perform some checks on the filesystem representing a library. Reports are invalid_titles, extra_titles, invalid_authors, extra_authors, missing_formats, extra_formats, extra_files, missing_covers, extra_covers, malformed_formats, malformed_paths, failed_folders",
+		options: []optionSpec{
+			{names: []string{"--csv", "-c"}, kind: optBool, help: "Output in CSV"},
+			{names: []string{"--ignore_extensions", "-e"}, kind: optString, help: "Comma-separated list of extensions to ignore. Default: all"},
+			{names: []string{"--ignore_names", "-n"}, kind: optString, help: "Comma-separated list of names to ignore. Default: all"},
+			{names: []string{"--report", "-r"}, kind: optString, help: "Comma-separated list of reports. Default: all"},
+			{names: []string{"--vacuum-fts-db"}, kind: optBool, help: "Vacuum the full text search database. This can be very slow and memory intensive, depending on the size of the database."},
+		},
+	},
+	"clone": {
+		usage:       "%prog clone path/to/new/library",
+		description: "Create a clone of the current library. This creates a new, empty library that has all the\nsame custom columns, Virtual libraries and other settings as the current library.",
+	},
+	"custom_columns": {
+		usage:       "%prog custom_columns [options]",
+		description: "List available custom columns. Shows column labels and ids.",
+		options: []optionSpec{
+			{names: []string{"--details", "-d"}, kind: optBool, help: "Show details for each column."},
+		},
+	},
+	"embed_metadata": {
+		usage:       "%prog embed_metadata [options] book_id",
+		description: "Update the metadata in the actual book files stored in the calibre library from\nthe metadata in the calibre database.  Normally, metadata is updated only when\nexporting files from calibre, this command is useful if you want the files to\nbe updated in place. Note that different file formats support different amounts\nof metadata. You can use the special value ‘all’ for book_id to update metadata\nin all books. You can also specify many book ids separated by spaces and id ranges\nseparated by hyphens. For example: calibredb embed_metadata 1 2 10-15 23",
+		options: []optionSpec{
+			{names: []string{"--only-formats", "-f"}, kind: optAppend, help: "Only update metadata in files of the specified format. Specify it multiple times for multiple formats. By default, all formats are updated."},
+		},
+	},
+	"export": {
+		usage:       "%prog export [options] ids",
+		description: "Export the books specified by ids (a comma separated list) to the filesystem.\nThe export operation saves all formats of the book, its cover and metadata (in\nan OPF file). Any extra data files associated with the book are also saved.\nYou can get id numbers from the search command.",
+		options: []optionSpec{
+			{names: []string{"--all"}, kind: optBool, help: "Export all books in database, ignoring the list of ids."},
+			{names: []string{"--dont-asciiize"}, kind: optBool, help: "Have calibre convert all non English characters into English equivalents for the file names. This is useful if saving to a legacy filesystem without full support for Unicode filenames. Specifying this switch will turn this behavior off."},
+			{names: []string{"--dont-save-cover"}, kind: optBool, help: "Normally, calibre will save the cover in a separate file along with the actual e-book files. Specifying this switch will turn this behavior off."},
+			{names: []string{"--dont-save-extra-files"}, kind: optBool, help: "Save any data files associated with the book when saving the book Specifying this switch will turn this behavior off."},
+			{names: []string{"--dont-update-metadata"}, kind: optBool, help: "Normally, calibre will update the metadata in the saved files from what is in the calibre library. Makes saving to disk slower. Specifying this switch will turn this behavior off."},
+			{names: []string{"--dont-write-opf"}, kind: optBool, help: "Normally, calibre will write the metadata into a separate OPF file along with the actual e-book files. Specifying this switch will turn this behavior off."},
+			{names: []string{"--formats"}, kind: optString, help: "Comma separated list of formats to save for each book. By default all available formats are saved."},
+			{names: []string{"--progress"}, kind: optBool, help: "Report progress"},
+			{names: []string{"--replace-whitespace"}, kind: optBool, help: "Replace whitespace with underscores."},
+			{names: []string{"--single-dir"}, kind: optBool, help: "Export all books into a single folder"},
+			{names: []string{"--template"}, kind: optString, help: "The template to control the filename and folder structure of the saved files. Default is \"{author_sort}/{title}/{title} - {authors}\" which will save books into a per-author subfolder with filenames containing title and author. Available controls are: {author_sort, authors, id, isbn, languages, last_modified, pubdate, publisher, rating, series, series_index, tags, timestamp, title}"},
+			{names: []string{"--timefmt"}, kind: optString, help: "The format in which to display dates. %d - day, %b - month, %m - month number, %Y - year. Default is: %b, %Y"},
+			{names: []string{"--to-dir"}, kind: optString, help: "Export books to the specified folder. Default is ."},
+			{names: []string{"--to-lowercase"}, kind: optBool, help: "Convert paths to lowercase."},
+		},
+	},
+	"fts_index": {
+		usage:       "%prog fts_index [options] enable/disable/status/reindex",
+		description: "Control the Full text search indexing process.",
+		options: []optionSpec{
+			{names: []string{"--indexing-speed"}, kind: optChoice, choices: []string{"fast", "slow", ""}, help: "The speed of indexing. Use fast for fast indexing using all your computers resources and slow for less resource intensive indexing. Note that the speed is reset to slow after every invocation."},
+			{names: []string{"--wait-for-completion"}, kind: optBool, help: "Wait till all books are indexed, showing indexing progress periodically"},
+		},
+	},
+	"fts_search": {
+		usage:       "%prog fts_search [options] search expression",
+		description: "Do a full text search on the entire library or a subset of it.",
+		options: []optionSpec{
+			{names: []string{"--do-not-match-on-related-words"}, kind: optBool, help: "Only match on exact words not related words. So correction will not match correcting."},
+			{names: []string{"--include-snippets"}, kind: optBool, help: "Include snippets of the text surrounding each match. Note that this makes searching much slower."},
+			{names: []string{"--indexing-threshold"}, kind: optFloat, help: "How much of the library must be indexed before searching is allowed, as a percentage. Defaults to 90"},
+			{names: []string{"--match-end-marker"}, kind: optString, help: "The marker used to indicate the end of a matched word inside a snippet"},
+			{names: []string{"--match-start-marker"}, kind: optString, help: "The marker used to indicate the start of a matched word inside a snippet"},
+			{names: []string{"--output-format"}, kind: optChoice, choices: []string{"text", "json"}, help: "The format to output the search results in. Either \" text \" for plain text or \" json \" for JSON output."},
+			{names: []string{"--restrict-to"}, kind: optString, help: "Restrict the searched books, either using a search expression or ids. For example: ids:1,2,3 to restrict by ids or search:tag:foo to restrict to books having the tag foo."},
+		},
+	},
+	"list": {
+		usage:       "%prog list [options]",
+		description: "List the books available in the calibre database.",
+		options: []optionSpec{
+			{names: []string{"--ascending"}, kind: optBool, help: "Sort results in ascending order"},
+			{names: []string{"--fields", "-f"}, kind: optString, help: "The fields to display when listing books in the database. Should be a comma separated list of fields. Available fields: author_sort, authors, comments, cover, formats, identifiers, isbn, languages, last_modified, pubdate, publisher, rating, series, series_index, size, tags, template, timestamp, title, uuid Default: title,authors. The special field \" all \" can be used to select all fields. In addition to the builtin fields above, custom fields are also available as *field_name, for example, for a custom field #rating, use the name: *rating"},
+			{names: []string{"--for-machine"}, kind: optBool, help: "Generate output in JSON format, which is more suitable for machine parsing. Causes the line width and separator options to be ignored."},
+			{names: []string{"--limit"}, kind: optInt, help: "The maximum number of results to display. Default: all"},
+			{names: []string{"--line-width", "-w"}, kind: optInt, help: "The maximum width of a single line in the output. Defaults to detecting screen size."},
+			{names: []string{"--prefix"}, kind: optString, help: "The prefix for all file paths. Default is the absolute path to the library folder."},
+			{names: []string{"--search", "-s"}, kind: optString, help: "Filter the results by the search query. For the format of the search query, please see the search related documentation in the User Manual. Default is to do no filtering."},
+			{names: []string{"--separator"}, kind: optString, help: "The string used to separate fields. Default is a space."},
+			{names: []string{"--sort-by"}, kind: optString, help: "The field by which to sort the results. You can specify multiple fields by separating them with commas. Available fields: author_sort, authors, comments, cover, formats, identifiers, isbn, languages, last_modified, pubdate, publisher, rating, series, series_index, size, tags, template, timestamp, title, uuid Default: id. In addition to the builtin fields above, custom fields are also available as *field_name, for example, for a custom field #rating, use the name: *rating"},
+			{names: []string{"--template"}, kind: optString, help: "The template to run if \" template \" is in the field list. Note that templates are ignored while connecting to a calibre server. Default: None"},
+			{names: []string{"--template_file", "-t"}, kind: optString, help: "Path to a file containing the template to run if \" template \" is in the field list. Default: None"},
+			{names: []string{"--template_heading"}, kind: optString, help: "Heading for the template column. Default: template. This option is ignored if the option --for-machine is set"},
+		},
+	},
+	"list_categories": {
+		usage:       "%prog list_categories [options]",
+		description: "Produce a report of the category information in the database. The\ninformation is the equivalent of what is shown in the Tag browser.",
+		options: []optionSpec{
+			{names: []string{"--categories", "-r"}, kind: optString, help: "Comma-separated list of category lookup names. Default: all"},
+			{names: []string{"--csv", "-c"}, kind: optBool, help: "Output in CSV"},
+			{names: []string{"--dialect"}, kind: optChoice, choices: []string{"excel", "excel-tab", "unix"}, help: "The type of CSV file to produce. Choices: excel, excel-tab, unix"},
+			{names: []string{"--item_count", "-i"}, kind: optBool, help: "Output only the number of items in a category instead of the counts per item within the category"},
+			{names: []string{"--width", "-w"}, kind: optInt, help: "The maximum width of a single line in the output. Defaults to detecting screen size."},
+		},
+	},
+	"remove": {
+		usage:       "%prog remove ids",
+		description: "Remove the books identified by ids from the database. ids should be a comma separated list of id numbers (you can get id numbers by using the search command). For example, 23,34,57-85 (when specifying a range, the last number in the range is not included).",
+		options: []optionSpec{
+			{names: []string{"--permanent"}, kind: optBool, help: "Do not use the Recycle Bin"},
+		},
+	},
+	"remove_custom_column": {
+		usage:       "%prog remove_custom_column [options] label",
+		description: "Remove the custom column identified by label. You can see available\ncolumns with the custom_columns command.",
+		options: []optionSpec{
+			{names: []string{"--force", "-f"}, kind: optBool, help: "Do not ask for confirmation"},
+		},
+	},
+	"remove_format": {
+		usage:       "%prog remove_format [options] id fmt",
+		description: "Remove the format fmt from the logical book identified by id. You can get id by using the search command. fmt should be a file extension like LRF or TXT or EPUB. If the logical book does not have fmt available, do nothing.",
+	},
+	"restore_database": {
+		usage:       "%prog restore_database [options]",
+		description: "Restore this database from the metadata stored in OPF files in each\nfolder of the calibre library. This is useful if your metadata.db file\nhas been corrupted.",
+		options: []optionSpec{
+			{names: []string{"--really-do-it", "-r"}, kind: optBool, help: "Really do the recovery. The command will not run unless this option is specified."},
+		},
+	},
+	"saved_searches": {
+		usage:       "%prog saved_searches [options] (list|add|remove)",
+		description: "Manage the saved searches stored in this database.\nIf you try to add a query with a name that already exists, it will be\nreplaced.",
+	},
+	"search": {
+		usage:       "%prog search [options] search expression",
+		description: "Search the library for the specified search term, returning a comma separated\nlist of book ids matching the search expression. The output format is useful\nto feed into other commands that accept a list of ids as input.",
+		options: []optionSpec{
+			{names: []string{"--limit", "-l"}, kind: optInt, help: "The maximum number of results to return. Default is all results."},
+		},
+	},
+	"set_custom": {
+		usage:       "%prog set_custom [options] column id value",
+		description: "Set the value of a custom column for the book identified by id.\nYou can get a list of ids using the search command.\nYou can get a list of custom column names using the custom_columns\ncommand.",
+		options: []optionSpec{
+			{names: []string{"--append", "-a"}, kind: optBool, help: "If the column stores multiple values, append the specified values to the existing ones, instead of replacing them."},
+		},
+	},
+	"set_metadata": {
+		usage:       "%prog set_metadata [options] book_id [/path/to/metadata.opf]",
+		description: "Set the metadata stored in the calibre database for the book identified by\nbook_id from the OPF file metadata.opf. book_id is a book id number from the\nsearch command. You can get a quick feel for the OPF format by using the\n–as-opf switch to the show_metadata command. You can also set the metadata of\nindividual fields with the –field option. If you use the –field option, there\nis no need to specify an OPF file.",
+		options: []optionSpec{
+			{names: []string{"--field", "-f"}, kind: optAppend, help: "The field to set. Format is field_name:value, for example: --field tags:tag1,tag2. Use --list-fields to get a list of all field names. You can specify this option multiple times to set multiple fields. Note: For languages you must use the ISO639 language codes (e.g. en for English, fr for French and so on). For identifiers, the syntax is --field identifiers:isbn:XXXX,doi:YYYYY. For boolean (yes/no) fields use true and false or yes and no."},
+			{names: []string{"--list-fields", "-l"}, kind: optBool, help: "List the metadata field names that can be used with the --field option"},
+		},
+	},
+	"show_metadata": {
+		usage:       "%prog show_metadata [options] id",
+		description: "Show the metadata stored in the calibre database for the book identified by id.\nid is an id number from the search command.",
+		options: []optionSpec{
+			{names: []string{"--as-opf"}, kind: optBool, help: "Print metadata in OPF form (XML)"},
+		},
+	},
+}
+
+// catalogPlugins are the options the catalog plugins add to calibredb
+// catalog, by the extension of the destination file.
+var catalogPlugins = map[string][]optionSpec{
+	"csv": csvCatalogOptions,
+	"xml": csvCatalogOptions,
+
+	"azw3": epubCatalogOptions,
+	"epub": epubCatalogOptions,
+	"mobi": epubCatalogOptions,
+}
+
+var csvCatalogOptions = []optionSpec{
+	{names: []string{"--fields"}, kind: optString, help: "The fields to output when cataloging books in the database. Should be a comma-separated list of fields. Available fields: all, title, title_sort, author_sort, authors, comments, cover, formats, id, isbn, library_name, ondevice, pubdate, publisher, rating, series_index, series, size, tags, timestamp, uuid, languages, identifiers, plus any user-created custom fields. Example: --fields=title,authors,tags Default: 'all' Applies to: CSV, XML output formats"},
+	{names: []string{"--sort-by"}, kind: optString, help: "Output field to sort on. Available fields: author_sort, id, rating, size, timestamp, title_sort Default: 'id' Applies to: CSV, XML output formats"},
+}
+
+var epubCatalogOptions = []optionSpec{
+	{names: []string{"--catalog-title"}, kind: optString, help: "Title of generated catalog used as title in metadata. Default: 'My Books' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--cross-reference-authors"}, kind: optBool, help: "Create cross-references in Authors section for books with multiple authors. Default: 'False' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--debug-pipeline"}, kind: optString, help: "Save the output from different stages of the conversion pipeline to the specified folder. Useful if you are unsure at which stage of the conversion process a bug is occurring. Default: 'None' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--exclude-genre"}, kind: optString, help: "Regex describing tags to exclude as genres. Default: '\\[.+\\]|^\\+$' excludes bracketed tags, e.g. '[Project Gutenberg]', and '+', the default tag for read books. Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--exclusion-rules"}, kind: optString, help: "Specifies the rules used to exclude books from the generated catalog. The model for an exclusion rule is either ('<rule name>','Tags','<comma-separated list of tags>') or ('<rule name>','<custom column>','<pattern>'). Default: \"(('Catalogs','Tags','Catalog'),)\" Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--generate-authors"}, kind: optBool, help: "Include 'Authors' section in catalog. Default: 'False' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--generate-descriptions"}, kind: optBool, help: "Include 'Descriptions' section in catalog. Default: 'False' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--generate-genres"}, kind: optBool, help: "Include 'Genres' section in catalog. Default: 'False' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--generate-recently-added"}, kind: optBool, help: "Include 'Recently Added' section in catalog. Default: 'False' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--generate-series"}, kind: optBool, help: "Include 'Series' section in catalog. Default: 'False' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--generate-titles"}, kind: optBool, help: "Include 'Titles' section in catalog. Default: 'False' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--genre-source-field"}, kind: optString, help: "Source field for 'Genres' section. Default: 'Tags' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--header-note-source-field"}, kind: optString, help: "Custom field containing note text to insert in Description header. Default: '' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--merge-comments-rule"}, kind: optString, help: "#<custom field>:[before|after]:[True|False] specifying: <custom field> Custom field containing notes to merge with comments [before|after] Placement of notes with respect to comments [True|False] - A horizontal rule is inserted between notes and comments Default: '::' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--output-profile"}, kind: optString, help: "Specifies the output profile. In some cases, an output profile is required to optimize the catalog for the device. For example, 'kindle' or 'kindle_dx' creates a structured Table of Contents with Sections and Articles. Default: 'None' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--prefix-rules"}, kind: optString, help: "Specifies the rules used to include prefixes indicating read books, wishlist items and other user-specified prefixes. The model for a prefix rule is ('<rule name>','<source field>','<pattern>','<prefix>'). When multiple rules are defined, the first matching rule will be used. Default: \"(('Read books','tags','+','✓'),('Wishlist item','tags','Wishlist','×'))\" Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--preset"}, kind: optString, help: "Use a named preset created with the GUI catalog builder. A preset specifies all settings for building a catalog. Default: 'None' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--thumb-width"}, kind: optString, help: "Size hint (in inches) for book covers in catalog. Range: 1.0 - 2.0 Default: '1.0' Applies to: AZW3, EPUB, MOBI output formats"},
+	{names: []string{"--use-existing-cover"}, kind: optBool, help: "Replace existing cover when generating the catalog. Default: 'False' Applies to: AZW3, EPUB, MOBI output formats"},
+}
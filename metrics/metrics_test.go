@@ -0,0 +1,129 @@
+package metrics_test
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/metrics"
+)
+
+var (
+	commentRe = regexp.MustCompile(`^# (HELP|TYPE) ([a-zA-Z_:][a-zA-Z0-9_:]*) (.*)$`)
+	sampleRe  = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{(?:[a-zA-Z_][a-zA-Z0-9_]*="(?:[^"\\]|\\.)*",?)*\})? (\S+)$`)
+)
+
+// parse reads the exposition format, failing the test on lines that do not
+// follow it or samples without a TYPE line. It returns the samples keyed by
+// name and labels as written and the type of each family.
+func parse(t *testing.T, r io.Reader) (map[string]float64, map[string]string) {
+	t.Helper()
+	samples, types := map[string]float64{}, map[string]string{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if m := commentRe.FindStringSubmatch(line); m != nil {
+			if m[1] == "TYPE" {
+				types[m[2]] = m[3]
+			}
+			continue
+		}
+		m := sampleRe.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("malformed line %q", line)
+		}
+		family := m[1]
+		if types[family] == "" {
+			family = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(family, "_bucket"), "_sum"), "_count")
+		}
+		if types[family] == "" {
+			t.Fatalf("sample %q without a TYPE line", line)
+		}
+		v, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			t.Fatalf("bad value in %q: %v", line, err)
+		}
+		samples[m[1]+m[2]] = v
+	}
+	return samples, types
+}
+
+func TestRegistry(t *testing.T) {
+	r := metrics.NewRegistry()
+	requests := r.NewCounter("requests_total", "Requests.\nBy route.", "route", "status")
+	inFlight := r.NewGauge("in_flight", "Requests running.")
+	duration := r.NewHistogram("duration_seconds", "Duration.", []float64{1, 0.1}, "route")
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			requests.Inc("/books", "200")
+			inFlight.Add(1)
+		}()
+	}
+	wg.Wait()
+	requests.Add(2, `/a "quoted"\path`, "500")
+	inFlight.Add(-3)
+	for _, v := range []float64{0.05, 0.5, 0.5, 7} {
+		duration.Observe(v, "/books")
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != metrics.ContentType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rec.Body.String()
+	samples, types := parse(t, strings.NewReader(body))
+	want := map[string]float64{
+		`requests_total{route="/books",status="200"}`:              10,
+		`requests_total{route="/a \"quoted\"\\path",status="500"}`: 2,
+		`in_flight`: 7,
+		`duration_seconds_bucket{route="/books",le="0.1"}`:  1,
+		`duration_seconds_bucket{route="/books",le="1"}`:    3,
+		`duration_seconds_bucket{route="/books",le="+Inf"}`: 4,
+		`duration_seconds_sum{route="/books"}`:              8.05,
+		`duration_seconds_count{route="/books"}`:            4,
+	}
+	for key, v := range want {
+		if samples[key] != v {
+			t.Errorf("%s = %v, want %v", key, samples[key], v)
+		}
+	}
+	if len(samples) != len(want) {
+		t.Errorf("got %d samples, want %d:\n%s", len(samples), len(want), body)
+	}
+	if types["requests_total"] != "counter" || types["in_flight"] != "gauge" || types["duration_seconds"] != "histogram" {
+		t.Errorf("types = %v", types)
+	}
+	if !strings.Contains(body, "# HELP requests_total Requests.\\nBy route.\n") {
+		t.Errorf("HELP not escaped:\n%s", body)
+	}
+}
+
+func TestRegistry_Misuse(t *testing.T) {
+	r := metrics.NewRegistry()
+	c := r.NewCounter("c_total", "C.", "a")
+	for name, fn := range map[string]func(){
+		"twice":        func() { r.NewGauge("c_total", "again") },
+		"label values": func() { c.Inc("x", "y") },
+		"negative add": func() { c.Add(-1, "x") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s did not panic", name)
+				}
+			}()
+			fn()
+		}()
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/veverkap/calibre-rest/audit"
+	"github.com/veverkap/calibre-rest/auth"
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// WithAuditLog serves the log at GET /audit. The Calibre passed to New should
+// record into the same log, see calibredb.WithAudit.
+func WithAuditLog(log *audit.Log) ServerOption {
+	return func(s *Server) {
+		s.audit = log
+	}
+}
+
+// calibreFor returns the Calibre to run commands for r with, so that audit
+// events name the authenticated caller and log records carry the request id.
+func (s *Server) calibreFor(r *http.Request) *calibredb.Calibre {
+	c := s.calibre
+	if p := auth.FromContext(r.Context()); p != nil {
+		c = c.As(p.Name)
+	}
+	if id := requestID(r); id != "" {
+		c = c.LogWith("request_id", id)
+	}
+	return c
+}
+
+type auditResponse struct {
+	Events []calibredb.AuditEvent `json:"events"`
+}
+
+// handleAudit serves GET /audit, the newest events first.
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if s.audit == nil {
+		writeError(w, http.StatusNotFound, errors.New("the audit log is not configured"))
+		return
+	}
+	query := r.URL.Query()
+	var f audit.Filter
+	var err error
+	if f.BookID, err = intParam(query, "book_id", 0); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if f.Limit, err = intParam(query, "limit", defaultAuditLimit); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if f.Limit < 1 || f.Limit > maxAuditLimit {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be between 1 and %d", maxAuditLimit))
+		return
+	}
+	f.Actor = query.Get("user")
+	for name, t := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if raw := query.Get(name); raw != "" {
+			if *t, err = time.Parse(time.RFC3339, raw); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("%s must be an RFC 3339 time such as 2006-01-02T15:04:05Z", name))
+				return
+			}
+		}
+	}
+
+	events, err := s.audit.Query(f)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if events == nil {
+		events = []calibredb.AuditEvent{}
+	}
+	writeJSON(w, http.StatusOK, auditResponse{Events: events})
+}
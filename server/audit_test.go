@@ -0,0 +1,101 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/veverkap/calibre-rest/audit"
+	"github.com/veverkap/calibre-rest/auth"
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/server"
+)
+
+func TestAudit(t *testing.T) {
+	dir := t.TempDir()
+	log, err := audit.Open(filepath.Join(dir, "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Close()
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []calibredb.AuditEvent{
+		{Actor: "alice", Command: "add", BookIDs: calibredb.NewBookIDs(1)},
+		{Actor: "bob", Command: "remove", BookIDs: calibredb.NewBookIDs(1)},
+		{Actor: "alice", Command: "set_custom", BookIDs: calibredb.NewBookIDs(2)},
+	} {
+		e.Time = start.Add(time.Duration(i) * time.Hour)
+		if err := log.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := auth.NewKeyStore(filepath.Join(dir, "keys.json"))
+	adminKey, _, err := store.Create("ops", []auth.Scope{auth.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	readKey, _, err := store.Create("reader", []auth.Scope{auth.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.New(calibredb.NewCalibre(calibredb.WithLibraryPath(dir)),
+		server.WithAuthenticator(auth.NewAuthenticator(auth.WithKeyStore(store))),
+		server.WithAuditLog(log),
+	)
+
+	tests := []struct {
+		target string
+		key    string
+		want   int
+		events []string
+	}{
+		{target: "/audit", key: adminKey, want: http.StatusOK, events: []string{"set_custom", "remove", "add"}},
+		{target: "/audit?book_id=1", key: adminKey, want: http.StatusOK, events: []string{"remove", "add"}},
+		{target: "/audit?user=alice&limit=1", key: adminKey, want: http.StatusOK, events: []string{"set_custom"}},
+		{target: "/audit?since=2026-10-01T13:00:00Z&until=2026-10-01T14:00:00Z", key: adminKey, want: http.StatusOK, events: []string{"remove"}},
+		{target: "/audit?book_id=9", key: adminKey, want: http.StatusOK, events: []string{}},
+		{target: "/audit?since=yesterday", key: adminKey, want: http.StatusBadRequest},
+		{target: "/audit?limit=0", key: adminKey, want: http.StatusBadRequest},
+		{target: "/audit", key: readKey, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.key)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s status = %d, want %d: %s", tt.target, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if tt.events == nil {
+			continue
+		}
+		var body struct {
+			Events []calibredb.AuditEvent `json:"events"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Events == nil || len(body.Events) != len(tt.events) {
+			t.Errorf("GET %s = %s, want %v", tt.target, rec.Body, tt.events)
+			continue
+		}
+		for i, e := range body.Events {
+			if e.Command != tt.events[i] {
+				t.Errorf("GET %s event %d = %s, want %s", tt.target, i, e.Command, tt.events[i])
+			}
+		}
+	}
+}
+
+func TestAudit_NotConfigured(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestServer(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/audit", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+const (
+	defaultPerPage = 50
+	maxPerPage     = 500
+)
+
+type bookListResponse struct {
+	calibredb.BookPage
+	Page    int `json:"page,omitempty"`
+	PerPage int `json:"per_page"`
+}
+
+// handleListBooks serves GET /books. Clients page either with page and
+// per_page or with the opaque cursor returned in the previous response;
+// cursors stay stable while books are added or removed.
+func (s *Server) handleListBooks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	perPage, err := intParam(query, "per_page", defaultPerPage)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if perPage < 1 || perPage > maxPerPage {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("per_page must be between 1 and %d", maxPerPage))
+		return
+	}
+	page, err := intParam(query, "page", 1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if page < 1 {
+		writeError(w, http.StatusBadRequest, errors.New("page must be at least 1"))
+		return
+	}
+	cursor := query.Get("cursor")
+	if cursor != "" && query.Has("page") {
+		writeError(w, http.StatusBadRequest, errors.New("page and cursor are mutually exclusive"))
+		return
+	}
+	order := query.Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		writeError(w, http.StatusBadRequest, errors.New("order must be asc or desc"))
+		return
+	}
+
+	opts := calibredb.ListBooksOptions{
+		Search:     query.Get("search"),
+		SortBy:     query.Get("sort"),
+		Descending: order == "desc",
+		Limit:      perPage,
+		Cursor:     cursor,
+	}
+	if fields := query.Get("fields"); fields != "" {
+		opts.Fields = strings.Split(fields, ",")
+	}
+	if cursor == "" {
+		opts.Offset = (page - 1) * perPage
+	}
+
+	result, err := s.calibreFor(r).ListBooks(opts)
+	if err != nil {
+		if errors.Is(err, calibredb.ErrInvalidCursor) || errors.Is(err, calibredb.ErrInvalidSortField) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeError(w, statusFor(err), err)
+		return
+	}
+
+	resp := bookListResponse{BookPage: result, PerPage: perPage}
+	if cursor == "" {
+		resp.Page = page
+	}
+	setPaginationHeaders(w, r.URL, resp)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// setPaginationHeaders adds X-Total-Count and an RFC 8288 Link header. Page
+// requests link to numbered pages; cursor requests link to cursors.
+func setPaginationHeaders(w http.ResponseWriter, u *url.URL, resp bookListResponse) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(resp.Total))
+
+	link := func(rel string, set map[string]string) string {
+		query := u.Query()
+		query.Del("page")
+		query.Del("cursor")
+		for k, v := range set {
+			query.Set(k, v)
+		}
+		target := url.URL{Path: u.Path, RawQuery: query.Encode()}
+		return fmt.Sprintf("<%s>; rel=%q", target.String(), rel)
+	}
+
+	var links []string
+	if resp.Page > 0 {
+		lastPage := max(1, (resp.Total+resp.PerPage-1)/resp.PerPage)
+		links = append(links, link("first", map[string]string{"page": "1"}))
+		if resp.Page > 1 {
+			links = append(links, link("prev", map[string]string{"page": strconv.Itoa(min(resp.Page-1, lastPage))}))
+		}
+		if resp.Page < lastPage {
+			links = append(links, link("next", map[string]string{"page": strconv.Itoa(resp.Page + 1)}))
+		}
+		links = append(links, link("last", map[string]string{"page": strconv.Itoa(lastPage)}))
+	} else {
+		if resp.PrevCursor != "" {
+			links = append(links, link("prev", map[string]string{"cursor": resp.PrevCursor}))
+		}
+		if resp.NextCursor != "" {
+			links = append(links, link("next", map[string]string{"cursor": resp.NextCursor}))
+		}
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
+
+func intParam(query url.Values, name string, def int) (int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", name)
+	}
+	return n, nil
+}
+
+func boolParam(query url.Values, name string) (bool, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return b, nil
+}
@@ -0,0 +1,161 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/auth"
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/server"
+)
+
+const listOutput = `[
+ {"id": 1, "title": "Dune", "authors": "Frank Herbert"},
+ {"id": 2, "title": "Anathem", "authors": "Neal Stephenson"},
+ {"id": 3, "title": "Cryptonomicon", "authors": "Neal Stephenson"},
+ {"id": 4, "title": "Blindsight", "authors": "Peter Watts"},
+ {"id": 5, "title": "Emma", "authors": "Jane Austen"}
+]`
+
+// newTestServer returns a server backed by a stub calibredb that prints
+// listOutput for every invocation.
+func newTestServer(t *testing.T) *server.Server {
+	t.Helper()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "calibredb")
+	body := "#!/bin/sh\ncat <<'JSON'\n" + listOutput + "\nJSON\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c := calibredb.NewCalibre(
+		calibredb.WithLibraryPath(dir),
+		calibredb.WithCalibreDBLocation(script),
+	)
+	return server.New(c)
+}
+
+func TestListBooks_PagePagination(t *testing.T) {
+	srv := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/books?page=2&per_page=2&sort=title", nil)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Books []calibredb.Book `json:"books"`
+		Total int              `json:"total"`
+		Page  int              `json:"page"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Total != 5 || body.Page != 2 || len(body.Books) != 2 {
+		t.Fatalf("unexpected body: %s", rec.Body)
+	}
+	if body.Books[0].Title != "Cryptonomicon" || body.Books[1].Title != "Dune" {
+		t.Errorf("page 2 = %v, %v", body.Books[0].Title, body.Books[1].Title)
+	}
+	if got := rec.Header().Get("X-Total-Count"); got != "5" {
+		t.Errorf("X-Total-Count = %q", got)
+	}
+	link := rec.Header().Get("Link")
+	for _, want := range []string{
+		`</books?page=1&per_page=2&sort=title>; rel="prev"`,
+		`</books?page=3&per_page=2&sort=title>; rel="next"`,
+		`</books?page=3&per_page=2&sort=title>; rel="last"`,
+	} {
+		if !strings.Contains(link, want) {
+			t.Errorf("Link = %q, want it to contain %q", link, want)
+		}
+	}
+}
+
+func TestListBooks_CursorPagination(t *testing.T) {
+	srv := newTestServer(t)
+
+	var titles []string
+	target := "/books?per_page=2&sort=title&cursor="
+	for target != "" {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+		}
+		var body calibredb.BookPage
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		for _, b := range body.Books {
+			titles = append(titles, b.Title)
+		}
+		target = ""
+		if body.NextCursor != "" {
+			if !strings.Contains(rec.Header().Get("Link"), `rel="next"`) {
+				t.Errorf("Link header missing next: %q", rec.Header().Get("Link"))
+			}
+			target = "/books?per_page=2&sort=title&cursor=" + body.NextCursor
+		}
+	}
+	if got := strings.Join(titles, ","); got != "Anathem,Blindsight,Cryptonomicon,Dune,Emma" {
+		t.Errorf("cursor walk = %s", got)
+	}
+}
+
+func TestListBooks_BadRequests(t *testing.T) {
+	srv := newTestServer(t)
+	for _, target := range []string{
+		"/books?per_page=0",
+		"/books?per_page=1000",
+		"/books?page=0",
+		"/books?page=abc",
+		"/books?page=2&cursor=abc",
+		"/books?order=sideways",
+		"/books?cursor=garbage",
+		"/books?sort=nonsense",
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s status = %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestListBooks_Auth(t *testing.T) {
+	dir := t.TempDir()
+	store := auth.NewKeyStore(filepath.Join(dir, "keys.json"))
+	readKey, _, err := store.Create("reader", []auth.Scope{auth.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloadKey, _, err := store.Create("fetcher", []auth.Scope{auth.ScopeDownload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := calibredb.NewCalibre(calibredb.WithLibraryPath(dir), calibredb.WithCalibreDBLocation("/bin/false"))
+	srv := server.New(c, server.WithAuthenticator(auth.NewAuthenticator(auth.WithKeyStore(store))))
+
+	for token, want := range map[string]int{
+		"":          http.StatusUnauthorized,
+		downloadKey: http.StatusForbidden,
+		readKey:     http.StatusBadGateway, // authorized, calibredb fails
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/books", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("GET /books with %q = %d, want %d", token, rec.Code, want)
+		}
+	}
+}
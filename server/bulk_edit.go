@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+// ndjson is the media type of the streamed bulk edit response.
+const ndjson = "application/x-ndjson"
+
+// bulkEditLine is one line of the streamed bulk edit response: progress after
+// every book and the result at the end.
+type bulkEditLine struct {
+	Progress *bulkEditProgress         `json:"progress,omitempty"`
+	Result   *calibredb.BulkEditResult `json:"result,omitempty"`
+	Error    string                    `json:"error,omitempty"`
+}
+
+type bulkEditProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// handleBulkEdit serves POST /books/bulk-edit. Clients that accept
+// application/x-ndjson get a progress line after every book and the result
+// as the last line; the others get the result once the edit is done.
+func (s *Server) handleBulkEdit(w http.ResponseWriter, r *http.Request) {
+	var opts calibredb.BulkEditOptions
+	if err := readJSON(w, r, &opts); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	c := s.calibreFor(r)
+	if !strings.Contains(r.Header.Get("Accept"), ndjson) {
+		result, err := c.BulkEdit(opts)
+		if err != nil {
+			writeError(w, bulkEditStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	w.Header().Set("Content-Type", ndjson)
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	started := false
+	write := func(line bulkEditLine) {
+		if !started {
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		_ = enc.Encode(line)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	opts.Progress = func(done, total int) {
+		write(bulkEditLine{Progress: &bulkEditProgress{Done: done, Total: total}})
+	}
+	result, err := c.BulkEdit(opts)
+	switch {
+	case err != nil && !started:
+		writeError(w, bulkEditStatus(err), err)
+	case err != nil:
+		write(bulkEditLine{Error: err.Error()})
+	default:
+		write(bulkEditLine{Result: &result})
+	}
+}
+
+func bulkEditStatus(err error) int {
+	if errors.Is(err, calibredb.ErrEmptyPatch) {
+		return http.StatusBadRequest
+	}
+	return statusFor(err)
+}
@@ -0,0 +1,103 @@
+package server_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/server"
+)
+
+// newBulkEditServer returns a server backed by a stub calibredb that lists
+// two books for list and records every other command in calls.log.
+func newBulkEditServer(t *testing.T, opts ...server.ServerOption) (*server.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "calibredb")
+	body := `#!/bin/sh
+if [ "$1" = list ]; then
+cat <<'JSON'
+[{"id": 1, "title": "Dune", "tags": ["scifi"], "series": ""},
+ {"id": 2, "title": "Anathem", "tags": ["scifi", "favorite"], "series": ""}]
+JSON
+exit 0
+fi
+echo "$@" >> "` + filepath.Join(dir, "calls.log") + `"
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c := calibredb.NewCalibre(calibredb.WithLibraryPath(dir), calibredb.WithCalibreDBLocation(script))
+	return server.New(c, opts...), filepath.Join(dir, "calls.log")
+}
+
+func postBulkEdit(srv *server.Server, body, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/books/bulk-edit", strings.NewReader(body))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestBulkEdit(t *testing.T) {
+	srv, calls := newBulkEditServer(t)
+
+	rec := postBulkEdit(srv, `{"search": "tags:scifi", "patch": {"add_tags": ["favorite"]}, "dry_run": true}`, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("dry run status = %d: %s", rec.Code, rec.Body)
+	}
+	var result calibredb.BulkEditResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if !result.DryRun || result.Matched != 2 || result.Changed != 1 || !result.Books[0].Changed || result.Books[1].Changed {
+		t.Errorf("dry run = %s", rec.Body)
+	}
+	if _, err := os.Stat(calls); !os.IsNotExist(err) {
+		t.Error("the dry run ran a command")
+	}
+
+	rec = postBulkEdit(srv, `{"search": "tags:scifi", "patch": {"add_tags": ["favorite"]}}`, "application/x-ndjson")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("streamed status = %d, type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var lines []map[string]json.RawMessage
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var line map[string]json.RawMessage
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("%v in %s", err, scanner.Text())
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 3 || string(lines[0]["progress"]) != `{"done":1,"total":2}` || lines[2]["result"] == nil {
+		t.Errorf("streamed body = %s", rec.Body)
+	}
+	data, _ := os.ReadFile(calls)
+	if string(data) != "set_metadata 1 --field tags:scifi,favorite --with-library="+filepath.Dir(calls)+"\n" {
+		t.Errorf("commands = %q", data)
+	}
+}
+
+func TestBulkEdit_BadRequests(t *testing.T) {
+	srv, _ := newBulkEditServer(t)
+	for _, body := range []string{
+		`{"search": "tags:scifi", "patch": {}}`,
+		`{"patch": {"add_tags": ["x"]}}`,
+		`{"search": "tags:scifi", "patch": {"add_tags": ["x"]}, "force": true}`,
+		`{"search": "tags:scifi", "patch": {"custom": [{"value": "epic"}]}}`,
+		`not json`,
+	} {
+		if rec := postBulkEdit(srv, body, ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST %s status = %d, want 400: %s", body, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"slices"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+// catalogTypes are the media types of the catalog formats.
+var catalogTypes = map[string]string{
+	"azw3": "application/vnd.amazon.ebook",
+	"csv":  "text/csv; charset=utf-8",
+	"epub": "application/epub+zip",
+	"mobi": "application/x-mobipocket-ebook",
+	"xml":  "application/xml",
+}
+
+type catalogRequest struct {
+	// Format is the catalog format: azw3, csv, epub, mobi or xml.
+	Format string `json:"format"`
+	// Ids limits the catalog to these books, e.g. "1,2,10-15"; Search is
+	// ignored then.
+	Ids    calibredb.BookIDs `json:"ids,omitempty"`
+	Search string            `json:"search,omitempty"`
+	// CSV holds the options of the csv and xml formats, EPUB those of azw3,
+	// epub and mobi.
+	CSV  *calibredb.CSVCatalogOptions  `json:"csv,omitempty"`
+	EPUB *calibredb.EPUBCatalogOptions `json:"epub,omitempty"`
+}
+
+// handleCatalog serves POST /catalog. The catalog is generated by a job; the
+// response points to it and the Location header to its status.
+func (s *Server) handleCatalog(w http.ResponseWriter, r *http.Request) {
+	var req catalogRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !slices.Contains(calibredb.CatalogFormats, req.Format) {
+		writeError(w, http.StatusBadRequest, errors.New("format must be one of azw3, csv, epub, mobi or xml"))
+		return
+	}
+	c := s.calibreFor(r)
+	file := "catalog." + req.Format
+	opts := calibredb.GenerateCatalogOptions{
+		CatalogOptions: calibredb.CatalogOptions{
+			// replaced by the job directory, the extension picks the plugin
+			Path:   file,
+			Ids:    req.Ids,
+			Search: req.Search,
+		},
+		CSV:  req.CSV,
+		EPUB: req.EPUB,
+	}
+	if err := c.Validate(opts); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	j, err := s.jobs.start("catalog", jobOwner(r), func(dir string) (jobResult, error) {
+		opts.Path = filepath.Join(dir, file)
+		if _, err := c.GenerateCatalog(opts); err != nil {
+			return jobResult{}, err
+		}
+		return jobResult{file: file, contentType: catalogTypes[req.Format]}, nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+j.ID)
+	writeJSON(w, http.StatusAccepted, j)
+}
@@ -0,0 +1,137 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/auth"
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/server"
+)
+
+type jobResponse struct {
+	ID     string `json:"id"`
+	State  string `json:"state"`
+	Error  string `json:"error"`
+	Result string `json:"result"`
+}
+
+// newCatalogServer returns a server backed by a stub calibredb that writes
+// its arguments to the catalog file, or fails for the search "fail".
+func newCatalogServer(t *testing.T, opts ...server.ServerOption) *server.Server {
+	t.Helper()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "calibredb")
+	body := `#!/bin/sh
+case "$*" in *"--search fail"*) echo "no books" >&2; exit 1;; esac
+echo "$@" > "$2"
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return server.New(calibredb.NewCalibre(calibredb.WithLibraryPath(dir), calibredb.WithCalibreDBLocation(script)), opts...)
+}
+
+func TestCatalog(t *testing.T) {
+	srv := newCatalogServer(t)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+	start := func(body string) jobResponse {
+		t.Helper()
+		rec := do(http.MethodPost, "/catalog", body)
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("POST /catalog status = %d: %s", rec.Code, rec.Body)
+		}
+		var j jobResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &j); err != nil || j.ID == "" || rec.Header().Get("Location") != "/jobs/"+j.ID {
+			t.Fatalf("POST /catalog = %s, Location %q, %v", rec.Body, rec.Header().Get("Location"), err)
+		}
+		rec = do(http.MethodGet, "/jobs/"+j.ID+"?wait=true", "")
+		if err := json.Unmarshal(rec.Body.Bytes(), &j); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("GET /jobs/%s = %d: %s", j.ID, rec.Code, rec.Body)
+		}
+		return j
+	}
+
+	j := start(`{"format": "epub", "search": "tags:sf", "epub": {"catalog_title": "Shelf", "generate_series": true}}`)
+	if j.State != "succeeded" || j.Result != "/jobs/"+j.ID+"/result" {
+		t.Fatalf("job = %+v", j)
+	}
+	rec := do(http.MethodGet, j.Result, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/epub+zip" ||
+		!strings.Contains(rec.Header().Get("Content-Disposition"), "catalog.epub") {
+		t.Errorf("GET %s = %d, headers %v", j.Result, rec.Code, rec.Header())
+	}
+	if got := rec.Body.String(); !strings.Contains(got, "--search tags:sf --catalog-title Shelf --generate-series") {
+		t.Errorf("catalog generated with %q", got)
+	}
+
+	j = start(`{"format": "csv", "search": "fail"}`)
+	if j.State != "failed" || !strings.Contains(j.Error, "no books") {
+		t.Errorf("failed job = %+v", j)
+	}
+	if rec := do(http.MethodGet, "/jobs/"+j.ID+"/result", ""); rec.Code != http.StatusConflict {
+		t.Errorf("result of a failed job status = %d, want 409", rec.Code)
+	}
+
+	for _, body := range []string{
+		`{"format": "pdf"}`,
+		`{"format": "csv", "epub": {"generate_series": true}}`,
+		`{"format": "xml", "csv": {"sort_by": "pubdate"}}`,
+		`{"format": "epub", "epub": {"thumb_width": 5}}`,
+		`{"format": "csv", "columns": "all"}`,
+	} {
+		if rec := do(http.MethodPost, "/catalog", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("POST /catalog %s status = %d, want 400", body, rec.Code)
+		}
+	}
+	if rec := do(http.MethodGet, "/jobs/unknown", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /jobs/unknown status = %d, want 404", rec.Code)
+	}
+}
+
+func TestCatalog_Auth(t *testing.T) {
+	store := auth.NewKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	readKey, _, err := store.Create("reader", []auth.Scope{auth.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloadKey, _, err := store.Create("fetcher", []auth.Scope{auth.ScopeDownload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newCatalogServer(t, server.WithAuthenticator(auth.NewAuthenticator(auth.WithKeyStore(store))))
+	do := func(method, target, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/catalog", `{"format": "csv"}`, readKey); rec.Code != http.StatusForbidden {
+		t.Errorf("POST /catalog with a read key = %d, want 403", rec.Code)
+	}
+	rec := do(http.MethodPost, "/catalog", `{"format": "csv"}`, downloadKey)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("POST /catalog with a download key = %d: %s", rec.Code, rec.Body)
+	}
+	var j jobResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &j); err != nil {
+		t.Fatal(err)
+	}
+	if rec := do(http.MethodGet, "/jobs/"+j.ID+"?wait=true", "", downloadKey); rec.Code != http.StatusOK {
+		t.Errorf("GET /jobs/%s with a download key = %d", j.ID, rec.Code)
+	}
+	if rec := do(http.MethodGet, "/jobs/"+j.ID+"/result", "", downloadKey); rec.Code != http.StatusOK {
+		t.Errorf("GET /jobs/%s/result with a download key = %d", j.ID, rec.Code)
+	}
+}
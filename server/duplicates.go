@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+type duplicatesResponse struct {
+	Clusters []calibredb.DuplicateCluster `json:"clusters"`
+}
+
+// handleDuplicates serves GET /library/duplicates.
+func (s *Server) handleDuplicates(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := calibredb.DuplicatesOptions{Search: query.Get("search")}
+	for name, field := range map[string]*bool{
+		"by_formats":       &opts.ByFormats,
+		"keep_articles":    &opts.Normalize.KeepArticles,
+		"keep_punctuation": &opts.Normalize.KeepPunctuation,
+		"keep_subtitles":   &opts.Normalize.KeepSubtitles,
+	} {
+		var err error
+		if *field, err = boolParam(query, name); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	if articles := query.Get("articles"); articles != "" {
+		opts.Normalize.Articles = strings.Split(articles, ",")
+	}
+
+	clusters, err := s.calibreFor(r).FindDuplicates(opts)
+	if err != nil {
+		writeError(w, statusFor(err), err)
+		return
+	}
+	if clusters == nil {
+		clusters = []calibredb.DuplicateCluster{}
+	}
+	writeJSON(w, http.StatusOK, duplicatesResponse{Clusters: clusters})
+}
@@ -0,0 +1,60 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/server"
+)
+
+func TestDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "calibredb")
+	body := `#!/bin/sh
+cat <<'JSON'
+[{"id": 1, "title": "The Dune", "authors": "Frank Herbert", "identifiers": {}, "formats": []},
+ {"id": 2, "title": "Dune", "authors": "Frank Herbert", "identifiers": {}, "formats": []},
+ {"id": 3, "title": "Emma", "authors": "Jane Austen", "identifiers": {}, "formats": []}]
+JSON
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	srv := server.New(calibredb.NewCalibre(calibredb.WithLibraryPath(dir), calibredb.WithCalibreDBLocation(script)))
+
+	tests := []struct {
+		target   string
+		want     int
+		clusters int
+	}{
+		{target: "/library/duplicates", want: http.StatusOK, clusters: 1},
+		{target: "/library/duplicates?keep_articles=true", want: http.StatusOK, clusters: 0},
+		{target: "/library/duplicates?articles=der,die", want: http.StatusOK, clusters: 0},
+		{target: "/library/duplicates?by_formats=maybe", want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if rec.Code != tt.want {
+			t.Errorf("GET %s status = %d, want %d: %s", tt.target, rec.Code, tt.want, rec.Body)
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			Clusters []calibredb.DuplicateCluster `json:"clusters"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Clusters == nil || len(resp.Clusters) != tt.clusters {
+			t.Errorf("GET %s = %s, want %d clusters", tt.target, rec.Body, tt.clusters)
+		}
+	}
+}
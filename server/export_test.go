@@ -0,0 +1,106 @@
+package server_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/auth"
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/server"
+)
+
+// newExportServer returns a server backed by a stub calibredb that exports a
+// book to --to-dir and records its arguments and the folder in dir.
+func newExportServer(t *testing.T, opts ...server.ServerOption) (*server.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "calibredb")
+	body := `#!/bin/sh
+echo "$@" > "` + filepath.Join(dir, "args") + `"
+while [ $# -gt 0 ]; do
+	case "$1" in --to-dir) to="$2";; esac
+	shift
+done
+echo "$to" > "` + filepath.Join(dir, "to") + `"
+mkdir -p "$to/Herbert, Frank/Dune"
+echo dune > "$to/Herbert, Frank/Dune/Dune - Frank Herbert.epub"
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return server.New(calibredb.NewCalibre(calibredb.WithLibraryPath(dir), calibredb.WithCalibreDBLocation(script)), opts...), dir
+}
+
+func TestExport(t *testing.T) {
+	srv, dir := newExportServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/export?ids=1-3&formats=epub,pdf", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("GET /export = %d: %s", rec.Code, rec.Body)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 || zr.File[0].Name != "Herbert, Frank/Dune/Dune - Frank Herbert.epub" {
+		t.Fatalf("zip holds %v", zr.File)
+	}
+	f, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(f); string(data) != "dune\n" {
+		t.Errorf("exported book = %q", data)
+	}
+	// export takes ranges with an exclusive end
+	args, _ := os.ReadFile(filepath.Join(dir, "args"))
+	if !strings.Contains(string(args), "--formats epub,pdf") || !strings.Contains(string(args), " 1-4 ") {
+		t.Errorf("calibredb ran with %s", args)
+	}
+	to, _ := os.ReadFile(filepath.Join(dir, "to"))
+	if _, err := os.Stat(strings.TrimSpace(string(to))); !os.IsNotExist(err) {
+		t.Errorf("export folder %s left behind: %v", to, err)
+	}
+
+	for _, target := range []string{"/export", "/export?ids=x", "/export?ids=1&formats=epub%3Brm"} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET %s status = %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestExport_Auth(t *testing.T) {
+	store := auth.NewKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	readKey, _, err := store.Create("reader", []auth.Scope{auth.ScopeRead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloadKey, _, err := store.Create("fetcher", []auth.Scope{auth.ScopeDownload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, _ := newExportServer(t, server.WithAuthenticator(auth.NewAuthenticator(auth.WithKeyStore(store))))
+
+	for token, want := range map[string]int{
+		readKey:     http.StatusForbidden,
+		downloadKey: http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/export?ids=1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("GET /export with %q = %d, want %d", token, rec.Code, want)
+		}
+	}
+}
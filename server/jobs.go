@@ -0,0 +1,194 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/veverkap/calibre-rest/auth"
+)
+
+// jobTTL is how long a finished job and its result are kept.
+const jobTTL = time.Hour
+
+type jobState string
+
+const (
+	jobRunning   jobState = "running"
+	jobSucceeded jobState = "succeeded"
+	jobFailed    jobState = "failed"
+)
+
+// job is work started by a request that runs on after the response, such as
+// generating a catalog. Its result is a file in a temporary directory that
+// is removed with the job.
+type job struct {
+	ID       string     `json:"id"`
+	Kind     string     `json:"kind"`
+	State    jobState   `json:"state"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	// Result is the URL of the result once the job succeeded.
+	Result string `json:"result,omitempty"`
+
+	owner       string
+	dir         string
+	file        string
+	contentType string
+	done        chan struct{}
+}
+
+// jobResult is what a job function returns: the file it wrote in the job
+// directory and its media type.
+type jobResult struct {
+	file        string
+	contentType string
+}
+
+// jobStore holds the jobs of a server. Finished jobs expire after jobTTL.
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+	now  func() time.Time
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{jobs: map[string]*job{}, now: time.Now}
+}
+
+// start runs fn in the background with a new temporary directory and
+// returns the job. owner is the principal that may see the job, empty on an
+// open server.
+func (s *jobStore) start(kind, owner string, fn func(dir string) (jobResult, error)) (job, error) {
+	s.expire()
+	dir, err := os.MkdirTemp("", "calibre-rest-"+kind+"-")
+	if err != nil {
+		return job{}, err
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	j := &job{
+		ID:      hex.EncodeToString(id),
+		Kind:    kind,
+		State:   jobRunning,
+		Created: s.now().UTC(),
+		owner:   owner,
+		dir:     dir,
+		done:    make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.jobs[j.ID] = j
+	view := *j
+	s.mu.Unlock()
+
+	go func() {
+		result, err := fn(dir)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		finished := s.now().UTC()
+		j.Finished = &finished
+		if err != nil {
+			j.State, j.Error = jobFailed, err.Error()
+		} else {
+			j.State, j.Result = jobSucceeded, "/jobs/"+j.ID+"/result"
+			j.file = filepath.Join(dir, result.file)
+			j.contentType = result.contentType
+		}
+		close(j.done)
+	}()
+	return view, nil
+}
+
+// get returns a copy of the job with id that owner may see.
+func (s *jobStore) get(id, owner string) (job, bool) {
+	s.expire()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok || j.owner != owner {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// expire removes the jobs that finished more than jobTTL ago.
+func (s *jobStore) expire() {
+	s.mu.Lock()
+	var expired []string
+	for id, j := range s.jobs {
+		if j.Finished != nil && s.now().Sub(*j.Finished) > jobTTL {
+			expired = append(expired, j.dir)
+			delete(s.jobs, id)
+		}
+	}
+	s.mu.Unlock()
+	for _, dir := range expired {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// jobOwner names the principal of a request for jobStore, empty on an open
+// server.
+func jobOwner(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		return p.Name
+	}
+	return ""
+}
+
+// handleGetJob serves GET /jobs/{id}. With wait it answers once the job has
+// finished or the client gave up.
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	wait, err := boolParam(r.URL.Query(), "wait")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	j, ok := s.jobs.get(r.PathValue("id"), jobOwner(r))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("no such job"))
+		return
+	}
+	if wait {
+		select {
+		case <-j.done:
+		case <-r.Context().Done():
+			return
+		}
+		j, _ = s.jobs.get(j.ID, j.owner)
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+// handleJobResult serves GET /jobs/{id}/result, the file of a job that
+// succeeded.
+func (s *Server) handleJobResult(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.PathValue("id"), jobOwner(r))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("no such job"))
+		return
+	}
+	switch j.State {
+	case jobRunning:
+		writeError(w, http.StatusConflict, errors.New("the job is still running"))
+		return
+	case jobFailed:
+		writeError(w, http.StatusConflict, errors.New("the job failed: "+j.Error))
+		return
+	}
+	f, err := os.Open(j.file)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", j.contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filepath.Base(j.file)+`"`)
+	http.ServeContent(w, r, filepath.Base(j.file), *j.Finished, f)
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// WithLogger logs every request to l with an id, which is sent back in the
+// X-Request-ID header. The Calibre passed to New should log to the same
+// logger, see calibredb.WithLogger; its records then carry the request id
+// too.
+func WithLogger(l *slog.Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// requestIDHeader carries the id of a request. An id the client sends is
+// kept when it looks like one.
+const requestIDHeader = "X-Request-ID"
+
+var requestIDRe = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type requestIDKey struct{}
+
+// requestID returns the id logRequests gave r, or "" without a logger.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// logRequests gives every request h serves for the route an id and logs it
+// once served, at warn level when it failed on the server's side. Like
+// instrument it sees the requests authentication rejects.
+func (s *Server) logRequests(method, route string, h http.Handler) http.Handler {
+	if s.logger == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDRe.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+
+		level := slog.LevelInfo
+		if rec.status >= http.StatusInternalServerError {
+			level = slog.LevelWarn
+		}
+		s.logger.LogAttrs(r.Context(), level, "request",
+			slog.String("request_id", id),
+			slog.String("method", method),
+			slog.String("route", route),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
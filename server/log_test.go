@@ -0,0 +1,99 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/server"
+)
+
+func TestLogger(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "calibredb")
+	body := "#!/bin/sh\ncat <<'JSON'\n" + listOutput + "\nJSON\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c := calibredb.NewCalibre(
+		calibredb.WithLibraryPath(dir),
+		calibredb.WithCalibreDBLocation(script),
+		calibredb.WithPassword("secret"),
+		calibredb.WithLogger(logger),
+	)
+	srv := server.New(c, server.WithLogger(logger))
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		status int
+		// calibredb says whether the request ran calibredb
+		calibredb bool
+	}{
+		{name: "client id", target: "/books", header: "client-42", status: http.StatusOK, calibredb: true},
+		{name: "generated id", target: "/books", status: http.StatusOK, calibredb: true},
+		{name: "invalid id replaced", target: "/books?per_page=0", header: "not an id", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("X-Request-ID", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+
+			id := rec.Header().Get("X-Request-ID")
+			switch {
+			case id == "":
+				t.Fatal("no X-Request-ID in the response")
+			case tt.header == "client-42" && id != tt.header:
+				t.Errorf("X-Request-ID = %q, want the client's %q", id, tt.header)
+			case tt.header == "not an id" && id == tt.header:
+				t.Errorf("X-Request-ID kept the invalid %q", id)
+			}
+			if bytes.Contains(buf.Bytes(), []byte("secret")) {
+				t.Errorf("the password was logged:\n%s", buf.String())
+			}
+
+			var records []map[string]any
+			dec := json.NewDecoder(&buf)
+			for dec.More() {
+				var r map[string]any
+				if err := dec.Decode(&r); err != nil {
+					t.Fatal(err)
+				}
+				records = append(records, r)
+			}
+			want := 1
+			if tt.calibredb {
+				want = 2
+			}
+			if len(records) != want {
+				t.Fatalf("logged %d records, want %d:\n%v", len(records), want, records)
+			}
+			for _, r := range records {
+				if r["request_id"] != id {
+					t.Errorf("record %q has request_id %v, want %q", r["msg"], r["request_id"], id)
+				}
+			}
+			if tt.calibredb && records[0]["command"] != "list" {
+				t.Errorf("calibredb record = %v, want the list command", records[0])
+			}
+			last := records[len(records)-1]
+			if last["msg"] != "request" || last["route"] != "/books" || last["status"] != float64(tt.status) {
+				t.Errorf("request record = %v", last)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/veverkap/calibre-rest/metrics"
+)
+
+// WithMetrics counts the requests of every route in reg and serves reg at
+// GET /metrics. The Calibre passed to New may record into the same registry,
+// see calibredb.WithMetrics.
+func WithMetrics(reg *metrics.Registry) ServerOption {
+	return func(s *Server) {
+		s.metrics = reg
+		s.requests = reg.NewCounter("http_requests_total", "HTTP requests served, by route and status.", "method", "route", "status")
+		s.requestDuration = reg.NewHistogram("http_request_duration_seconds", "Time taken to serve HTTP requests.", nil, "method", "route")
+	}
+}
+
+// handleMetrics serves GET /metrics, or answers 404 when no registry is
+// configured.
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.metrics == nil {
+		writeError(w, http.StatusNotFound, errors.New("metrics are not configured"))
+		return
+	}
+	s.metrics.Handler().ServeHTTP(w, r)
+}
+
+// instrument counts the requests h serves for the route, labelled by the
+// pattern it was registered with so that paths with ids share a series.
+// Requests rejected by authentication are counted too.
+func (s *Server) instrument(method, route string, h http.Handler) http.Handler {
+	if s.metrics == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		s.requests.Inc(method, route, strconv.Itoa(rec.status))
+		s.requestDuration.Observe(time.Since(start).Seconds(), method, route)
+	})
+}
+
+// statusRecorder keeps the status a handler answered with. It passes Flush
+// on so that streamed responses still stream.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,100 @@
+package server_test
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/metrics"
+	"github.com/veverkap/calibre-rest/server"
+)
+
+// scrape returns the samples of GET /metrics by series, such as
+// `http_requests_total{method="GET",route="/books",status="200"}`.
+func scrape(t *testing.T, srv *server.Server) map[string]string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != metrics.ContentType {
+		t.Errorf("Content-Type = %q", got)
+	}
+	samples := map[string]string{}
+	sc := bufio.NewScanner(rec.Body)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			t.Fatalf("malformed sample %q", line)
+		}
+		samples[line[:i]] = line[i+1:]
+	}
+	return samples
+}
+
+func TestMetrics(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "calibredb")
+	body := "#!/bin/sh\ncat <<'JSON'\n" + listOutput + "\nJSON\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	reg := metrics.NewRegistry()
+	c := calibredb.NewCalibre(
+		calibredb.WithLibraryPath(dir),
+		calibredb.WithCalibreDBLocation(script),
+		calibredb.WithMetrics(calibredb.NewMetrics(reg)),
+	)
+	srv := server.New(c, server.WithMetrics(reg))
+
+	for _, target := range []string{"/books", "/books?sort=title", "/books?per_page=0"} {
+		srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	samples := scrape(t, srv)
+
+	want := map[string]string{
+		`http_requests_total{method="GET",route="/books",status="200"}`:               "2",
+		`http_requests_total{method="GET",route="/books",status="400"}`:               "1",
+		`http_request_duration_seconds_count{method="GET",route="/books"}`:            "3",
+		`http_request_duration_seconds_bucket{method="GET",route="/books",le="+Inf"}`: "3",
+		`calibredb_invocations_total{command="list",exit_code="0"}`:                   "2",
+	}
+	for series, value := range want {
+		if got := samples[series]; got != value {
+			t.Errorf("%s = %q, want %q", series, got, value)
+		}
+	}
+	// the scrape is counted after it is served
+	if got := scrape(t, srv)[`http_requests_total{method="GET",route="/metrics",status="200"}`]; got != "1" {
+		t.Errorf("GET /metrics counted %q times, want 1", got)
+	}
+}
+
+func TestMetrics_NotConfigured(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestServer(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestMetrics_KeepsStreaming(t *testing.T) {
+	srv, _ := newBulkEditServer(t, server.WithMetrics(metrics.NewRegistry()))
+	rec := postBulkEdit(srv, `{"search": "tags:scifi", "patch": {"add_tags": ["favorite"]}}`, "application/x-ndjson")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if !rec.Flushed {
+		t.Error("the progress of the bulk edit was not flushed")
+	}
+}
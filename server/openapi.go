@@ -0,0 +1,349 @@
+package server
+
+import (
+	"cmp"
+	"encoding"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/veverkap/calibre-rest/auth"
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+// operation documents a route in the OpenAPI description served at
+// /openapi.json. Every route must have one with at least a summary.
+type operation struct {
+	Summary     string
+	Description string
+	Params      []param
+	// Body is a value of the JSON request body type, nil when there is none.
+	// For other media types set BodyType instead.
+	Body     any
+	BodyType string
+	// Response is a value of the JSON type of a successful response. For other
+	// media types set ResponseType instead.
+	Response     any
+	ResponseType string
+	// Status is the status of a successful response, 200 when zero.
+	Status int
+	// Errors are the error statuses the route answers with, besides the ones
+	// of authentication.
+	Errors []int
+}
+
+// param is a query, path or header parameter.
+type param struct {
+	Name        string
+	In          string // query when empty
+	Description string
+	// Example is a value of the parameter type, a string when nil.
+	Example  any
+	Required bool
+}
+
+// endpoint is a registered route.
+type endpoint struct {
+	method, path string
+	scope        auth.Scope
+	op           operation
+}
+
+// Endpoints returns the registered routes as "METHOD /path" patterns, for
+// tests that check the description covers all of them.
+func (s *Server) Endpoints() []string {
+	patterns := make([]string, len(s.endpoints))
+	for i, e := range s.endpoints {
+		patterns[i] = e.method + " " + e.path
+	}
+	return patterns
+}
+
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.openAPI())
+}
+
+// openAPI builds the OpenAPI 3.1 description of the registered routes.
+// Request and response schemas come from reflection over the Go types, see
+// schemaBuilder; the components also describe the options of every calibredb
+// wrapper.
+func (s *Server) openAPI() map[string]any {
+	b := &schemaBuilder{components: map[string]any{}}
+	b.ref(reflect.TypeFor[errorResponse]())
+	for _, command := range calibredb.Commands() {
+		b.ref(reflect.TypeOf(calibredb.NewOptions(command)).Elem())
+	}
+
+	paths := map[string]any{}
+	for _, e := range s.endpoints {
+		item, _ := paths[openAPIPath(e.path)].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[openAPIPath(e.path)] = item
+		}
+		item[strings.ToLower(e.method)] = s.openAPIOperation(b, e)
+	}
+
+	doc := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "calibre-rest",
+			"description": "A JSON REST API for calibre libraries, backed by calibredb.",
+			"version":     "1",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": b.components},
+	}
+	if s.auth != nil {
+		doc["components"].(map[string]any)["securitySchemes"] = map[string]any{
+			"basic":  map[string]any{"type": "http", "scheme": "basic"},
+			"bearer": map[string]any{"type": "http", "scheme": "bearer", "description": "An API key made with cli keys create."},
+		}
+	}
+	return doc
+}
+
+func (s *Server) openAPIOperation(b *schemaBuilder, e endpoint) map[string]any {
+	op := map[string]any{
+		"summary":     e.op.Summary,
+		"operationId": operationID(e.method, e.path),
+	}
+	if e.op.Description != "" {
+		op["description"] = e.op.Description
+	}
+
+	var params []any
+	for _, name := range pathParams(e.path) {
+		if !slices.ContainsFunc(e.op.Params, func(p param) bool { return p.Name == name }) {
+			params = append(params, map[string]any{"name": name, "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+		}
+	}
+	for _, p := range e.op.Params {
+		in := cmp.Or(p.In, "query")
+		schema := map[string]any{"type": "string"}
+		if p.Example != nil {
+			schema = b.schema(reflect.TypeOf(p.Example))
+		}
+		param := map[string]any{"name": p.Name, "in": in, "schema": schema}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		if p.Required || in == "path" {
+			param["required"] = true
+		}
+		params = append(params, param)
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	switch {
+	case e.op.BodyType != "":
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{e.op.BodyType: map[string]any{}},
+		}
+	case e.op.Body != nil:
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(e.op.Body))}},
+		}
+	}
+
+	success := map[string]any{"description": "OK"}
+	switch {
+	case e.op.ResponseType != "":
+		success["content"] = map[string]any{e.op.ResponseType: map[string]any{}}
+	case e.op.Response != nil:
+		success["content"] = map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeOf(e.op.Response))}}
+	}
+	responses := map[string]any{strconv.Itoa(cmp.Or(e.op.Status, http.StatusOK)): success}
+	errorStatuses := e.op.Errors
+	if s.auth != nil && e.scope != "" {
+		errorStatuses = append(slices.Clip(errorStatuses), http.StatusUnauthorized, http.StatusForbidden)
+	}
+	for _, status := range errorStatuses {
+		responses[strconv.Itoa(status)] = map[string]any{
+			"description": http.StatusText(status),
+			"content":     map[string]any{"application/json": map[string]any{"schema": b.schema(reflect.TypeFor[errorResponse]())}},
+		}
+	}
+	op["responses"] = responses
+
+	if e.scope != "" {
+		op["x-required-scope"] = string(e.scope)
+		if s.auth != nil {
+			op["security"] = []any{
+				map[string]any{"basic": []string{string(e.scope)}},
+				map[string]any{"bearer": []string{string(e.scope)}},
+			}
+		}
+	}
+	return op
+}
+
+// openAPIPath turns a ServeMux path into an OpenAPI one: "{path...}" becomes
+// "{path}".
+func openAPIPath(path string) string {
+	return strings.ReplaceAll(path, "...}", "}")
+}
+
+func pathParams(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			names = append(names, strings.TrimSuffix(strings.TrimSuffix(name, "}"), "..."))
+		}
+	}
+	return names
+}
+
+// operationID derives an ID such as getBooksId from "GET /books/{id}".
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, word := range strings.FieldsFunc(path, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		b.WriteString(exportedName(word))
+	}
+	return b.String()
+}
+
+// schemaBuilder derives JSON Schemas from Go types. Named structs become
+// components. Properties are named like encoding/json names them, and the
+// validate tags contribute required properties, enums (from oneof, as used
+// by the *Choice types) and bounds.
+type schemaBuilder struct {
+	components map[string]any
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch {
+	case t.Kind() == reflect.Pointer:
+		return b.schema(t.Elem())
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		return b.ref(t)
+	}
+	return map[string]any{}
+}
+
+// ref adds the component for a named struct and refers to it.
+func (b *schemaBuilder) ref(t reflect.Type) map[string]any {
+	name := exportedName(t.Name())
+	if _, ok := b.components[name]; !ok {
+		// a placeholder ends the recursion of self referencing types
+		b.components[name] = map[string]any{}
+		b.components[name] = b.object(t)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	b.fields(t, properties, &required)
+	obj := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+func (b *schemaBuilder) fields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		// the fields of embedded structs are promoted, as encoding/json does
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			b.fields(f.Type, properties, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema := b.schema(f.Type)
+		if applyValidate(schema, f.Tag.Get("validate")) {
+			*required = append(*required, name)
+		}
+		properties[name] = schema
+	}
+}
+
+// applyValidate adds the constraints of a validate tag to schema and reports
+// whether the field is required.
+func applyValidate(schema map[string]any, tag string) bool {
+	required := false
+	numeric := schema["type"] == "integer" || schema["type"] == "number"
+	for _, rule := range strings.Split(tag, ",") {
+		name, value, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "oneof":
+			schema["enum"] = strings.Fields(value)
+		case "gte", "lte", "gt", "lt":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil || !numeric {
+				continue
+			}
+			key := map[string]string{"gte": "minimum", "lte": "maximum", "gt": "exclusiveMinimum", "lt": "exclusiveMaximum"}[name]
+			schema[key] = n
+		case "bookid":
+			schema["pattern"] = `^[0-9]+$`
+		case "bookids":
+			schema["description"] = "Book IDs such as 1,3-5; ranges include both ends."
+			if value == "all" {
+				schema["description"] = "Book IDs such as 1,3-5, or all; ranges include both ends."
+			}
+		case "json":
+			schema["contentMediaType"] = "application/json"
+		}
+	}
+	return required
+}
+
+func exportedName(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
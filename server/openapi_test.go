@@ -0,0 +1,161 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/auth"
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/server"
+)
+
+type openAPIDoc struct {
+	OpenAPI string `json:"openapi"`
+	Paths   map[string]map[string]struct {
+		Summary    string                      `json:"summary"`
+		Responses  map[string]any              `json:"responses"`
+		Security   []map[string][]string       `json:"security"`
+		Parameters []struct{ Name, In string } `json:"parameters"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]map[string]any `json:"properties"`
+			Required   []string                  `json:"required"`
+		} `json:"schemas"`
+		SecuritySchemes map[string]any `json:"securitySchemes"`
+	} `json:"components"`
+}
+
+func getOpenAPI(t *testing.T, srv *server.Server) openAPIDoc {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var doc openAPIDoc
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// TestOpenAPI_DocumentsEveryRoute fails when a route is registered without
+// documentation.
+func TestOpenAPI_DocumentsEveryRoute(t *testing.T) {
+	srv := newTestServer(t)
+	doc := getOpenAPI(t, srv)
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	endpoints := srv.Endpoints()
+	if len(endpoints) == 0 {
+		t.Fatal("no endpoints registered")
+	}
+	for _, pattern := range endpoints {
+		method, path, _ := strings.Cut(pattern, " ")
+		path = strings.ReplaceAll(path, "...}", "}")
+		op, ok := doc.Paths[path][strings.ToLower(method)]
+		if !ok {
+			t.Errorf("%s is not in /openapi.json", pattern)
+			continue
+		}
+		if op.Summary == "" {
+			t.Errorf("%s has no summary", pattern)
+		}
+		if len(op.Responses) == 0 {
+			t.Errorf("%s has no responses", pattern)
+		}
+		for _, segment := range strings.Split(path, "/") {
+			if name, ok := strings.CutPrefix(segment, "{"); ok {
+				name = strings.TrimSuffix(name, "}")
+				if !slices.ContainsFunc(op.Parameters, func(p struct{ Name, In string }) bool { return p.Name == name && p.In == "path" }) {
+					t.Errorf("%s does not document the path parameter %s", pattern, name)
+				}
+			}
+		}
+	}
+}
+
+func TestOpenAPI_Schemas(t *testing.T) {
+	doc := getOpenAPI(t, newTestServer(t))
+	schemas := doc.Components.Schemas
+
+	add, ok := schemas["AddOptions"]
+	if !ok {
+		t.Fatal("AddOptions is not in the components")
+	}
+	// Files may be left out for an empty book
+	if slices.Contains(add.Required, "Files") {
+		t.Errorf("AddOptions required = %v, want Files optional", add.Required)
+	}
+	if got := schemas["AddFormatOptions"].Required; !slices.Equal(got, []string{"Id", "EbookFile"}) {
+		t.Errorf("AddFormatOptions required = %v, want Id and EbookFile", got)
+	}
+	if got := add.Properties["Automerge"]["enum"]; !slicesEqual(got, calibredb.Disabled, calibredb.Ignore, calibredb.Overwrite, calibredb.NewRecord) {
+		t.Errorf("AddOptions.Automerge enum = %v", got)
+	}
+	if got := add.Properties["SeriesIndex"]; got["type"] != "number" || got["minimum"] != 0.0 {
+		t.Errorf("AddOptions.SeriesIndex = %v", got)
+	}
+	if got := add.Properties["Duplicates"]["type"]; got != "boolean" {
+		t.Errorf("AddOptions.Duplicates type = %v", got)
+	}
+	if got := schemas["RemoveOptions"].Properties["Ids"]["type"]; got != "string" {
+		t.Errorf("RemoveOptions.Ids type = %v, want string", got)
+	}
+
+	// the embedded BookPage is flattened into the response
+	list, ok := schemas["BookListResponse"]
+	if !ok {
+		t.Fatal("BookListResponse is not in the components")
+	}
+	for _, name := range []string{"books", "total", "next_cursor", "page", "per_page"} {
+		if _, ok := list.Properties[name]; !ok {
+			t.Errorf("BookListResponse has no %s", name)
+		}
+	}
+	if got := schemas["Book"].Properties["last_modified"]["format"]; got != "date-time" {
+		t.Errorf("Book.last_modified format = %v", got)
+	}
+}
+
+func TestOpenAPI_Security(t *testing.T) {
+	if doc := getOpenAPI(t, newTestServer(t)); doc.Components.SecuritySchemes != nil {
+		t.Errorf("security schemes without authentication: %v", doc.Components.SecuritySchemes)
+	}
+
+	store := auth.NewKeyStore(filepath.Join(t.TempDir(), "keys.json"))
+	c := calibredb.NewCalibre(calibredb.WithLibraryPath(t.TempDir()))
+	srv := server.New(c, server.WithAuthenticator(auth.NewAuthenticator(auth.WithKeyStore(store))))
+	// the description itself is public
+	doc := getOpenAPI(t, srv)
+	if _, ok := doc.Components.SecuritySchemes["bearer"]; !ok {
+		t.Errorf("security schemes = %v", doc.Components.SecuritySchemes)
+	}
+	books := doc.Paths["/books"]["get"]
+	if len(books.Security) != 2 || books.Security[1]["bearer"][0] != "read" {
+		t.Errorf("GET /books security = %v", books.Security)
+	}
+	if _, ok := books.Responses["401"]; !ok {
+		t.Errorf("GET /books responses = %v", books.Responses)
+	}
+}
+
+func slicesEqual[T ~string](got any, want ...T) bool {
+	values, ok := got.([]any)
+	if !ok || len(values) != len(want) {
+		return false
+	}
+	for i, v := range values {
+		if v != string(want[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+// opfType is the media type of OPF documents.
+const opfType = "application/oebps-package+xml"
+
+// pathBookID returns the {id} path value of r as a book id.
+func pathBookID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		return 0, errors.New("the book id must be a positive integer")
+	}
+	return id, nil
+}
+
+// opfStatus maps an error of the OPF handlers to an HTTP status.
+func opfStatus(err error) int {
+	switch {
+	case errors.Is(err, calibredb.ErrInvalidOPF):
+		return http.StatusBadRequest
+	case calibredb.ErrorKindOf(err) == calibredb.ErrorKindNotFound:
+		return http.StatusNotFound
+	}
+	return statusFor(err)
+}
+
+// handleGetOPF serves GET /books/{id}/opf.
+func (s *Server) handleGetOPF(w http.ResponseWriter, r *http.Request) {
+	id, err := pathBookID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opf, err := s.calibreFor(r).OPF(id)
+	if err != nil {
+		writeError(w, opfStatus(err), err)
+		return
+	}
+	w.Header().Set("Content-Type", opfType)
+	_, _ = io.WriteString(w, opf)
+}
+
+// handlePutOPF serves PUT /books/{id}/opf: the body replaces the metadata of
+// the book and the response is the book afterwards.
+func (s *Server) handlePutOPF(w http.ResponseWriter, r *http.Request) {
+	id, err := pathBookID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opf, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.As(err, new(*http.MaxBytesError)) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, status, err)
+		return
+	}
+	book, err := s.calibreFor(r).SetMetadataFromOPF(id, opf)
+	if err != nil {
+		writeError(w, opfStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, book)
+}
@@ -0,0 +1,100 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/server"
+)
+
+const testOPF = `<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>Dune Messiah</dc:title></metadata>
+</package>
+`
+
+func TestOPF(t *testing.T) {
+	// the stub knows book 1 only; set_metadata keeps a copy of the OPF it
+	// was given
+	dir := t.TempDir()
+	script := filepath.Join(dir, "calibredb")
+	body := `#!/bin/sh
+case "$1 $2" in
+"show_metadata 1") cat <<'OPF'
+` + testOPF + `OPF
+;;
+"set_metadata 1") cp "$3" "` + filepath.Join(dir, "applied.opf") + `";;
+"list "*) echo '[{"id": 1, "title": "Dune Messiah", "authors": "Frank Herbert"}]';;
+*) echo "Id #$2 is not present in database." >&2; exit 1;;
+esac
+`
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	srv := server.New(calibredb.NewCalibre(calibredb.WithLibraryPath(dir), calibredb.WithCalibreDBLocation(script)))
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/books/1/opf", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/oebps-package+xml" || !strings.Contains(rec.Body.String(), "<dc:title>Dune Messiah</dc:title>") {
+		t.Fatalf("GET /books/1/opf = %d %v: %s", rec.Code, rec.Header(), rec.Body)
+	}
+
+	rec = do(http.MethodPut, "/books/1/opf", rec.Body.String())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /books/1/opf = %d: %s", rec.Code, rec.Body)
+	}
+	var book calibredb.Book
+	if err := json.Unmarshal(rec.Body.Bytes(), &book); err != nil || book.ID != 1 || book.Title != "Dune Messiah" {
+		t.Errorf("PUT /books/1/opf = %s, %v", rec.Body, err)
+	}
+	if applied, _ := os.ReadFile(filepath.Join(dir, "applied.opf")); !strings.Contains(string(applied), "Dune Messiah") {
+		t.Errorf("set_metadata got %q", applied)
+	}
+
+	tests := []struct {
+		method, target, body string
+		want                 int
+	}{
+		{http.MethodGet, "/books/7/opf", "", http.StatusNotFound},
+		{http.MethodGet, "/books/x/opf", "", http.StatusBadRequest},
+		{http.MethodPut, "/books/7/opf", testOPF, http.StatusNotFound},
+		{http.MethodPut, "/books/0/opf", testOPF, http.StatusBadRequest},
+		{http.MethodPut, "/books/1/opf", `{"title": "Dune"}`, http.StatusBadRequest},
+		{http.MethodPut, "/books/1/opf", `<package version="2.0"><manifest/></package>`, http.StatusBadRequest},
+		{http.MethodPut, "/books/1/opf", `<package><metadata>`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := do(tt.method, tt.target, tt.body); rec.Code != tt.want {
+			t.Errorf("%s %s %q status = %d, want %d: %s", tt.method, tt.target, tt.body, rec.Code, tt.want, rec.Body)
+		}
+	}
+
+	// only an oversized body is too large; a body that breaks off is a bad
+	// request
+	for _, tt := range []struct {
+		name string
+		body io.Reader
+		want int
+	}{
+		{"too large", strings.NewReader(strings.Repeat(" ", 1<<20+1)), http.StatusRequestEntityTooLarge},
+		{"truncated", io.MultiReader(strings.NewReader(testOPF[:40]), iotest.ErrReader(io.ErrUnexpectedEOF)), http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/books/1/opf", tt.body))
+		if rec.Code != tt.want {
+			t.Errorf("PUT /books/1/opf %s status = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// maxBodySize limits the JSON request bodies.
+const maxBodySize = 1 << 20
+
+// readJSON decodes the JSON body of r into v, rejecting unknown fields.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// statusFor maps an error returned by the calibredb package to an HTTP status.
+// Validation failures are the caller's fault, features the installed calibre
+// lacks are not implemented; anything else came from calibredb.
+func statusFor(err error) int {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, calibredb.ErrUnsupportedByCalibreVersion) {
+		return http.StatusNotImplemented
+	}
+	return http.StatusBadGateway
+}
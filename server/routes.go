@@ -0,0 +1,181 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/veverkap/calibre-rest/auth"
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/metrics"
+	"github.com/veverkap/calibre-rest/snapshot"
+)
+
+func (s *Server) routes() {
+	s.handle("GET /openapi.json", "", operation{
+		Summary:      "OpenAPI description of this API",
+		ResponseType: "application/json",
+	}, s.handleOpenAPI)
+
+	s.handle("GET /metrics", auth.ScopeRead, operation{
+		Summary:      "Metrics in the Prometheus text format",
+		Description:  "Counts the requests of every route and, when the server is configured so, the calibredb processes run and the time spent waiting for the library lock.",
+		ResponseType: metrics.ContentType,
+		Errors:       []int{http.StatusNotFound},
+	}, s.handleMetrics)
+
+	s.handle("GET /books", auth.ScopeRead, operation{
+		Summary:     "List books",
+		Description: "Pages either with page and per_page or with the cursor of the previous response; cursors stay stable while books are added or removed. The Link header links to the neighbouring pages and X-Total-Count holds the number of matching books.",
+		Params: []param{
+			{Name: "search", Description: "Calibre search expression, all books when empty."},
+			{Name: "sort", Description: "Field to sort by, id when empty."},
+			{Name: "order", Description: "asc or desc."},
+			{Name: "fields", Description: "Comma separated fields to return, title and authors when empty."},
+			{Name: "page", Description: "Page number, starting at 1.", Example: 1},
+			{Name: "per_page", Description: "Books per page, 1 to 500.", Example: 50},
+			{Name: "cursor", Description: "Cursor from the previous response; excludes page."},
+		},
+		Response: bookListResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotImplemented, http.StatusBadGateway},
+	}, s.handleListBooks)
+
+	s.handle("POST /books/bulk-edit", auth.ScopeWrite, operation{
+		Summary:     "Change the metadata of every book matching a search",
+		Description: "Applies the patch book by book; a book that fails does not stop the others and is reported with its error. With dry_run the response shows the values before and after without changing anything. Clients that accept application/x-ndjson get a progress line after every book and the result as the last line.",
+		Body:        calibredb.BulkEditOptions{},
+		Response:    calibredb.BulkEditResult{},
+		Errors:      []int{http.StatusBadRequest, http.StatusNotImplemented, http.StatusBadGateway},
+	}, s.handleBulkEdit)
+
+	bookIDParam := param{Name: "id", In: "path", Description: "Id of the book.", Example: 1, Required: true}
+	s.handle("GET /books/{id}/opf", auth.ScopeRead, operation{
+		Summary:      "Metadata of a book as an OPF document",
+		Params:       []param{bookIDParam},
+		ResponseType: opfType,
+		Errors:       []int{http.StatusBadRequest, http.StatusNotFound, http.StatusNotImplemented, http.StatusBadGateway},
+	}, s.handleGetOPF)
+
+	s.handle("PUT /books/{id}/opf", auth.ScopeWrite, operation{
+		Summary:     "Replace the metadata of a book from an OPF document",
+		Description: "The body is an OPF 2 or 3 document with a package root and a metadata element, such as the one GET returns. Fields the document leaves out keep their values. The response is the book with all its fields afterwards.",
+		Params:      []param{bookIDParam},
+		BodyType:    opfType,
+		Response:    calibredb.Book{},
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusNotImplemented, http.StatusBadGateway},
+	}, s.handlePutOPF)
+
+	s.handle("GET /library/duplicates", auth.ScopeRead, operation{
+		Summary:     "Find duplicate books",
+		Description: "Groups books that share a normalized title and authors, an identifier such as the ISBN or, with by_formats, an identical format file. Each cluster has a score from 0 to 1, the recommended action (merge or review) and the book to keep. Titles are compared without case, punctuation, leading articles and subtitles unless told otherwise.",
+		Params: []param{
+			{Name: "search", Description: "Calibre search expression limiting the books compared."},
+			{Name: "by_formats", Description: "Also compare the format files by size and SHA-256; needs a local library.", Example: false},
+			{Name: "keep_articles", Description: "Do not remove leading articles from titles.", Example: false},
+			{Name: "keep_punctuation", Description: "Do not remove punctuation from titles and authors.", Example: false},
+			{Name: "keep_subtitles", Description: "Compare whole titles instead of the part before the first colon.", Example: false},
+			{Name: "articles", Description: "Comma separated articles to remove, replacing the default English, German, French, Spanish and Italian ones."},
+		},
+		Response: duplicatesResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotImplemented, http.StatusBadGateway},
+	}, s.handleDuplicates)
+
+	s.handle("GET /export", auth.ScopeDownload, operation{
+		Summary:     "Download books as a zip",
+		Description: "Exports the books with their covers and OPF files to a temporary folder and streams it as a zip, one folder per author and title.",
+		Params: []param{
+			{Name: "ids", Description: "Books to export, e.g. 1,2,10-15, or all.", Example: "1,2,10-15", Required: true},
+			{Name: "formats", Description: "Comma separated formats to export, all when empty.", Example: "epub,pdf"},
+		},
+		ResponseType: "application/zip",
+		Errors:       []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusNotImplemented, http.StatusBadGateway},
+	}, s.handleExport)
+
+	s.handle("POST /catalog", auth.ScopeDownload, operation{
+		Summary:     "Generate a catalog of the library",
+		Description: "Starts a job that writes the catalog in the requested format; the Location header and the response point to the job. csv and xml take the csv options, azw3, epub and mobi the epub ones. Fetch the file from the result URL of the job once it succeeded.",
+		Body:        catalogRequest{},
+		Response:    job{},
+		Status:      http.StatusAccepted,
+		Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+	}, s.handleCatalog)
+
+	jobIDParam := param{Name: "id", In: "path", Description: "Id of the job.", Required: true}
+	s.handle("GET /jobs/{id}", auth.ScopeDownload, operation{
+		Summary:     "State of a job",
+		Description: "Jobs and their results are kept for an hour after they finished. Like the catalogs they produce, they need the download scope.",
+		Params: []param{
+			jobIDParam,
+			{Name: "wait", Description: "Answer once the job has finished.", Example: false},
+		},
+		Response: job{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound},
+	}, s.handleGetJob)
+
+	s.handle("GET /jobs/{id}/result", auth.ScopeDownload, operation{
+		Summary:      "The file a job produced",
+		Params:       []param{jobIDParam},
+		ResponseType: "application/octet-stream",
+		Errors:       []int{http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+	}, s.handleJobResult)
+
+	s.handle("GET /audit", auth.ScopeAdmin, operation{
+		Summary:     "Audit log of the commands that changed the library",
+		Description: "Newest events first. Passwords never appear in the log.",
+		Params: []param{
+			{Name: "book_id", Description: "Only events that changed this book.", Example: 1},
+			{Name: "user", Description: "Only events of this user or API key name."},
+			{Name: "since", Description: "Only events at or after this RFC 3339 time."},
+			{Name: "until", Description: "Only events before this RFC 3339 time."},
+			{Name: "limit", Description: "Number of events, 1 to 1000.", Example: 100},
+		},
+		Response: auditResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	}, s.handleAudit)
+
+	idParam := param{Name: "id", In: "path", Description: "Id of the snapshot, its UTC creation time.", Example: "20260301T120000.000Z", Required: true}
+	snapshotErrors := []int{http.StatusNotFound, http.StatusInternalServerError}
+	s.handle("GET /snapshots", auth.ScopeAdmin, operation{
+		Summary:     "List the snapshots of the library",
+		Description: "Newest first.",
+		Response:    snapshotsResponse{},
+		Errors:      snapshotErrors,
+	}, s.handleListSnapshots)
+
+	s.handle("POST /snapshots", auth.ScopeAdmin, operation{
+		Summary:     "Take a snapshot of the library",
+		Description: "Writes a compressed tar of metadata.db and, when asked, full-text-search.db and the book folders, with a manifest of SHA-256 checksums. Commands that change the library wait while metadata.db is copied. The body may be empty. Old snapshots are pruned by the retention settings afterwards; when that fails the snapshot is still returned, with a warning.",
+		Body:        snapshot.Options{},
+		Response:    createSnapshotResponse{},
+		Status:      http.StatusCreated,
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError, http.StatusNotImplemented},
+	}, s.handleCreateSnapshot)
+
+	s.handle("GET /snapshots/{id}", auth.ScopeAdmin, operation{
+		Summary:  "Describe a snapshot",
+		Params:   []param{idParam},
+		Response: snapshot.Snapshot{},
+		Errors:   snapshotErrors,
+	}, s.handleGetSnapshot)
+
+	s.handle("POST /snapshots/{id}/verify", auth.ScopeAdmin, operation{
+		Summary:     "Check a snapshot against its checksums",
+		Description: "Reads the whole archive and returns its manifest when every file matches.",
+		Params:      []param{idParam},
+		Response:    snapshot.Manifest{},
+		Errors:      snapshotErrors,
+	}, s.handleVerifySnapshot)
+
+	s.handle("POST /snapshots/{id}/restore", auth.ScopeAdmin, operation{
+		Summary:     "Restore the library from a snapshot",
+		Description: "Extracts the snapshot next to the library and checks every file against the manifest before anything is replaced. A snapshot with books replaces the library folder, otherwise only the databases are replaced. What was replaced is kept at the returned previous path.",
+		Params:      []param{idParam},
+		Response:    snapshot.Restored{},
+		Errors:      []int{http.StatusNotFound, http.StatusInternalServerError, http.StatusNotImplemented},
+	}, s.handleRestoreSnapshot)
+
+	s.handle("DELETE /snapshots/{id}", auth.ScopeAdmin, operation{
+		Summary: "Delete a snapshot",
+		Params:  []param{idParam},
+		Status:  http.StatusNoContent,
+		Errors:  snapshotErrors,
+	}, s.handleDeleteSnapshot)
+}
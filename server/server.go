@@ -0,0 +1,73 @@
+// Package server exposes a calibre library over a JSON REST API backed by the
+// calibredb wrappers.
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/veverkap/calibre-rest/audit"
+	"github.com/veverkap/calibre-rest/auth"
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/metrics"
+	"github.com/veverkap/calibre-rest/snapshot"
+)
+
+type Server struct {
+	calibre   *calibredb.Calibre
+	mux       *http.ServeMux
+	auth      *auth.Authenticator
+	audit     *audit.Log
+	snapshots *snapshot.Store
+	jobs      *jobStore
+
+	metrics         *metrics.Registry
+	requests        *metrics.Counter
+	requestDuration *metrics.Histogram
+
+	logger *slog.Logger
+
+	endpoints []endpoint
+}
+
+type ServerOption func(*Server)
+
+// WithAuthenticator requires every request to authenticate and to hold the
+// scope of its endpoint. Without it the server is open to anyone who can
+// reach it.
+func WithAuthenticator(a *auth.Authenticator) ServerOption {
+	return func(s *Server) {
+		s.auth = a
+	}
+}
+
+func New(c *calibredb.Calibre, opts ...ServerOption) *Server {
+	s := &Server{
+		calibre: c,
+		mux:     http.NewServeMux(),
+		jobs:    newJobStore(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.routes()
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// handle registers a handler for a method and path pattern such as "GET /books"
+// that requires scope; an empty scope makes the route public. op documents
+// the route in /openapi.json.
+func (s *Server) handle(pattern string, scope auth.Scope, op operation, handler http.HandlerFunc) {
+	method, path, _ := strings.Cut(pattern, " ")
+	s.endpoints = append(s.endpoints, endpoint{method: method, path: path, scope: scope, op: op})
+	var h http.Handler = handler
+	if s.auth != nil && scope != "" {
+		h = s.auth.Require(scope, handler)
+	}
+	s.mux.Handle(pattern, s.logRequests(method, path, s.instrument(method, path, h)))
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/veverkap/calibre-rest/snapshot"
+)
+
+// WithSnapshots serves the snapshots of store under /snapshots.
+func WithSnapshots(store *snapshot.Store) ServerOption {
+	return func(s *Server) {
+		s.snapshots = store
+	}
+}
+
+type snapshotsResponse struct {
+	Snapshots []snapshot.Snapshot `json:"snapshots"`
+}
+
+type createSnapshotResponse struct {
+	snapshot.Snapshot
+	// Warning is set when the snapshot was taken but pruning the old ones
+	// failed.
+	Warning string `json:"warning,omitempty"`
+}
+
+// snapshotStore returns the store, or answers 404 when none is configured.
+func (s *Server) snapshotStore(w http.ResponseWriter) *snapshot.Store {
+	if s.snapshots == nil {
+		writeError(w, http.StatusNotFound, errors.New("snapshots are not configured"))
+	}
+	return s.snapshots
+}
+
+// snapshotStatus maps an error of the snapshot package to an HTTP status.
+func snapshotStatus(err error) int {
+	switch {
+	case errors.Is(err, snapshot.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, snapshot.ErrRemoteLibrary):
+		return http.StatusNotImplemented
+	}
+	return http.StatusInternalServerError
+}
+
+// handleListSnapshots serves GET /snapshots, the newest first.
+func (s *Server) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	store := s.snapshotStore(w)
+	if store == nil {
+		return
+	}
+	snaps, err := store.List()
+	if err != nil {
+		writeError(w, snapshotStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshotsResponse{Snapshots: snaps})
+}
+
+// handleCreateSnapshot serves POST /snapshots. The body may be empty for a
+// snapshot of metadata.db alone.
+func (s *Server) handleCreateSnapshot(w http.ResponseWriter, r *http.Request) {
+	store := s.snapshotStore(w)
+	if store == nil {
+		return
+	}
+	var opts snapshot.Options
+	if r.ContentLength != 0 {
+		if err := readJSON(w, r, &opts); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	snap, err := store.Create(s.calibreFor(r), opts)
+	if snap.ID == "" {
+		writeError(w, snapshotStatus(err), err)
+		return
+	}
+	resp := createSnapshotResponse{Snapshot: snap}
+	if err != nil {
+		resp.Warning = err.Error()
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// handleGetSnapshot serves GET /snapshots/{id}.
+func (s *Server) handleGetSnapshot(w http.ResponseWriter, r *http.Request) {
+	store := s.snapshotStore(w)
+	if store == nil {
+		return
+	}
+	snap, err := store.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, snapshotStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snap)
+}
+
+// handleVerifySnapshot serves POST /snapshots/{id}/verify.
+func (s *Server) handleVerifySnapshot(w http.ResponseWriter, r *http.Request) {
+	store := s.snapshotStore(w)
+	if store == nil {
+		return
+	}
+	m, err := store.Verify(r.PathValue("id"))
+	if err != nil {
+		writeError(w, snapshotStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// handleRestoreSnapshot serves POST /snapshots/{id}/restore.
+func (s *Server) handleRestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	store := s.snapshotStore(w)
+	if store == nil {
+		return
+	}
+	restored, err := store.Restore(s.calibreFor(r), r.PathValue("id"))
+	if err != nil {
+		writeError(w, snapshotStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, restored)
+}
+
+// handleDeleteSnapshot serves DELETE /snapshots/{id}.
+func (s *Server) handleDeleteSnapshot(w http.ResponseWriter, r *http.Request) {
+	store := s.snapshotStore(w)
+	if store == nil {
+		return
+	}
+	if err := store.Delete(r.PathValue("id")); err != nil {
+		writeError(w, snapshotStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,79 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/server"
+	"github.com/veverkap/calibre-rest/snapshot"
+)
+
+func TestSnapshots(t *testing.T) {
+	library := t.TempDir()
+	db := filepath.Join(library, "metadata.db")
+	if err := os.WriteFile(db, []byte("before"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := snapshot.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := server.New(calibredb.NewCalibre(calibredb.WithLibraryPath(library)), server.WithSnapshots(store))
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := do(http.MethodPost, "/snapshots", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /snapshots status = %d: %s", rec.Code, rec.Body)
+	}
+	var created snapshot.Snapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || created.Files != 1 {
+		t.Fatalf("POST /snapshots = %s, %v", rec.Body, err)
+	}
+	if err := os.WriteFile(db, []byte("after"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec = do(http.MethodGet, "/snapshots", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), created.ID) {
+		t.Errorf("GET /snapshots = %d: %s", rec.Code, rec.Body)
+	}
+	if rec = do(http.MethodPost, "/snapshots/"+created.ID+"/verify", ""); rec.Code != http.StatusOK {
+		t.Errorf("verify status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec = do(http.MethodPost, "/snapshots/"+created.ID+"/restore", ""); rec.Code != http.StatusOK {
+		t.Fatalf("restore status = %d: %s", rec.Code, rec.Body)
+	}
+	if data, _ := os.ReadFile(db); string(data) != "before" {
+		t.Errorf("metadata.db after restore = %q", data)
+	}
+
+	if rec = do(http.MethodDelete, "/snapshots/"+created.ID, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("DELETE status = %d: %s", rec.Code, rec.Body)
+	}
+	for _, target := range []string{"/snapshots/" + created.ID, "/snapshots/..%2Fsecrets"} {
+		if rec = do(http.MethodGet, target, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s status = %d, want 404", target, rec.Code)
+		}
+	}
+	if rec = do(http.MethodPost, "/snapshots", `{"books": true, "everything": true}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST with an unknown field status = %d, want 400", rec.Code)
+	}
+}
+
+func TestSnapshots_NotConfigured(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestServer(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/snapshots", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /snapshots status = %d, want 404", rec.Code)
+	}
+}
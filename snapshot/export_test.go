@@ -0,0 +1,4 @@
+package snapshot
+
+// SwapDatabases exposes swapDatabases to the tests of failed restores.
+var SwapDatabases = swapDatabases
@@ -0,0 +1,716 @@
+// Package snapshot takes point-in-time backups of a local calibre library.
+// A snapshot is a gzip compressed tar of metadata.db, optionally with
+// full-text-search.db and the book folders, that ends with a manifest of the
+// SHA-256 checksum of every file. Snapshots are kept in a directory, pruned
+// by retention rules, and restored only after every checksum was verified.
+//
+// Unlike calibredb backup_metadata, which writes OPF files into the library,
+// snapshots live outside the library and can bring back the whole database
+// as it was.
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+const (
+	metadataDB       = "metadata.db"
+	fullTextSearchDB = "full-text-search.db"
+	// ManifestName is the name of the manifest, the last entry of every
+	// archive.
+	ManifestName = "manifest.json"
+	archiveExt   = ".tar.gz"
+	// idLayout formats the creation time into the id of a snapshot, so ids
+	// sort by age.
+	idLayout = "20060102T150405.000Z"
+)
+
+var idRe = regexp.MustCompile(`^\d{8}T\d{6}\.\d{3}Z$`)
+
+var (
+	// ErrNotFound is returned for an id that is not in the store.
+	ErrNotFound = errors.New("snapshot: no such snapshot")
+	// ErrRemoteLibrary is returned for libraries on a calibre Content
+	// server, whose files cannot be read.
+	ErrRemoteLibrary = errors.New("snapshot: the library is not a local folder")
+	// ErrChecksum is returned when an archive does not match its manifest.
+	ErrChecksum = errors.New("snapshot: checksum mismatch")
+)
+
+// Options says what a snapshot holds besides metadata.db.
+type Options struct {
+	FullTextSearch bool `json:"full_text_search,omitempty"`
+	// Books adds the book folders: every file of the library except hidden
+	// ones at its top.
+	Books bool `json:"books,omitempty"`
+}
+
+// File is a file in a snapshot, with its path relative to the library.
+type File struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Manifest describes the content of an archive.
+type Manifest struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Library string    `json:"library"`
+	Options
+	Files []File `json:"files"`
+}
+
+// Snapshot summarizes a snapshot in the store.
+type Snapshot struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Library string    `json:"library"`
+	Options
+	// Files is the number of files in the archive.
+	Files int `json:"files"`
+	// Size is the size of the archive in bytes.
+	Size int64 `json:"size"`
+}
+
+// Store keeps snapshots in a directory: the archive <id>.tar.gz and its
+// summary <id>.json for each. It is safe for concurrent use.
+type Store struct {
+	dir        string
+	keepLast   int
+	keepDaily  int
+	keepWeekly int
+	now        func() time.Time
+
+	mu sync.Mutex
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithKeepLast keeps the n newest snapshots when pruning.
+func WithKeepLast(n int) Option {
+	return func(s *Store) {
+		s.keepLast = n
+	}
+}
+
+// WithKeepDaily keeps the newest snapshot of each of the n most recent days
+// that have one, in UTC.
+func WithKeepDaily(n int) Option {
+	return func(s *Store) {
+		s.keepDaily = n
+	}
+}
+
+// WithKeepWeekly keeps the newest snapshot of each of the n most recent ISO
+// weeks that have one.
+func WithKeepWeekly(n int) Option {
+	return func(s *Store) {
+		s.keepWeekly = n
+	}
+}
+
+// WithClock replaces time.Now, which dates new snapshots.
+func WithClock(now func() time.Time) Option {
+	return func(s *Store) {
+		s.now = now
+	}
+}
+
+// NewStore returns the store in dir, creating the directory when needed.
+// Without any keep option, pruning keeps every snapshot.
+func NewStore(dir string, opts ...Option) (*Store, error) {
+	s := &Store{dir: dir, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	return s, nil
+}
+
+// Create takes a snapshot of the library of c and then prunes the store. The
+// archive is written with c.Exclusive, so commands that change the library
+// wait until the copy is complete. When pruning fails the new snapshot is
+// returned with the error.
+func (s *Store) Create(c *calibredb.Calibre, opts Options) (Snapshot, error) {
+	library, err := localLibrary(c)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created := s.now().UTC().Truncate(time.Millisecond)
+	for s.exists(created.Format(idLayout)) {
+		created = created.Add(time.Millisecond)
+	}
+	m := Manifest{ID: created.Format(idLayout), Created: created, Library: library, Options: opts, Files: []File{}}
+	tmp := s.archivePath(m.ID) + ".tmp"
+	err = c.Exclusive(func() error {
+		return writeArchive(tmp, library, &m)
+	})
+	if err == nil {
+		err = os.Rename(tmp, s.archivePath(m.ID))
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return Snapshot{}, fmt.Errorf("snapshot: %w", err)
+	}
+	snap := m.summary()
+	if info, err := os.Stat(s.archivePath(m.ID)); err == nil {
+		snap.Size = info.Size()
+	}
+	if err := s.writeSummary(snap); err != nil {
+		_ = os.Remove(s.archivePath(m.ID))
+		return Snapshot{}, err
+	}
+	if _, err := s.prune(); err != nil {
+		return snap, err
+	}
+	return snap, nil
+}
+
+// List returns the snapshots in the store, newest first.
+func (s *Store) List() ([]Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list()
+}
+
+func (s *Store) list() ([]Snapshot, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+	snaps := []Snapshot{}
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !idRe.MatchString(id) || !s.exists(id) {
+			continue
+		}
+		snap, err := s.readSummary(id)
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, snap)
+	}
+	slices.SortFunc(snaps, func(a, b Snapshot) int { return strings.Compare(b.ID, a.ID) })
+	return snaps, nil
+}
+
+// Get returns the snapshot with the given id.
+func (s *Store) Get(id string) (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.exists(id) {
+		return Snapshot{}, ErrNotFound
+	}
+	return s.readSummary(id)
+}
+
+// Verify reads the archive of a snapshot and checks every file against the
+// checksums of its manifest.
+func (s *Store) Verify(id string) (Manifest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.exists(id) {
+		return Manifest{}, ErrNotFound
+	}
+	return readArchive(s.archivePath(id), nil)
+}
+
+// Delete removes a snapshot.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.exists(id) {
+		return ErrNotFound
+	}
+	return s.remove(id)
+}
+
+// Prune removes the snapshots that no retention rule keeps and returns their
+// ids. A snapshot is kept when any rule keeps it; without rules every
+// snapshot is kept.
+func (s *Store) Prune() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prune()
+}
+
+func (s *Store) prune() ([]string, error) {
+	if s.keepLast == 0 && s.keepDaily == 0 && s.keepWeekly == 0 {
+		return []string{}, nil
+	}
+	snaps, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	keep := map[string]bool{}
+	for _, snap := range snaps[:min(s.keepLast, len(snaps))] {
+		keep[snap.ID] = true
+	}
+	keepPeriods(snaps, s.keepDaily, keep, func(t time.Time) string {
+		return t.UTC().Format(time.DateOnly)
+	})
+	keepPeriods(snaps, s.keepWeekly, keep, func(t time.Time) string {
+		year, week := t.UTC().ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+	removed := []string{}
+	for _, snap := range snaps {
+		if keep[snap.ID] {
+			continue
+		}
+		if err := s.remove(snap.ID); err != nil {
+			return removed, err
+		}
+		removed = append(removed, snap.ID)
+	}
+	return removed, nil
+}
+
+// keepPeriods keeps the newest of snaps, which are ordered newest first, in
+// each of the n most recent periods that have one.
+func keepPeriods(snaps []Snapshot, n int, keep map[string]bool, period func(time.Time) string) {
+	seen := map[string]bool{}
+	for _, snap := range snaps {
+		p := period(snap.Created)
+		if seen[p] {
+			continue
+		}
+		if len(seen) == n {
+			return
+		}
+		seen[p] = true
+		keep[snap.ID] = true
+	}
+}
+
+// Restored reports a restore.
+type Restored struct {
+	Snapshot Snapshot `json:"snapshot"`
+	// Previous is where the replaced library, or for snapshots without books
+	// the replaced databases, were moved. It is empty when there was nothing
+	// to replace.
+	Previous string `json:"previous,omitempty"`
+}
+
+// Restore brings the library of c back to a snapshot. The archive is
+// extracted next to the library and every file is checked against the
+// manifest first; only then, with c.Exclusive, is the library swapped in. A
+// snapshot with books replaces the whole library folder, which is kept as
+// <library>.before-restore-<time>. Otherwise only the databases are replaced
+// and the previous ones, including a full-text-search.db the snapshot lacks,
+// are kept in the hidden folder .before-restore-<time> of the library.
+func (s *Store) Restore(c *calibredb.Calibre, id string) (Restored, error) {
+	library, err := localLibrary(c)
+	if err != nil {
+		return Restored{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.exists(id) {
+		return Restored{}, ErrNotFound
+	}
+	snap, err := s.readSummary(id)
+	if err != nil {
+		return Restored{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(library), 0o755); err != nil {
+		return Restored{}, fmt.Errorf("snapshot: %w", err)
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(library), "."+filepath.Base(library)+".restore-")
+	if err != nil {
+		return Restored{}, fmt.Errorf("snapshot: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	m, err := readArchive(s.archivePath(id), func(name string, r io.Reader) error {
+		return extract(filepath.Join(staging, filepath.FromSlash(name)), r)
+	})
+	if err != nil {
+		return Restored{}, err
+	}
+
+	restored := Restored{Snapshot: snap}
+	stamp := s.now().UTC().Format(idLayout)
+	err = c.Exclusive(func() error {
+		if m.Books {
+			return swapLibrary(library, staging, library+".before-restore-"+stamp, &restored)
+		}
+		return swapDatabases(library, staging, filepath.Join(library, ".before-restore-"+stamp), m.Files, &restored)
+	})
+	if err != nil {
+		return Restored{}, fmt.Errorf("snapshot: %w", err)
+	}
+	return restored, nil
+}
+
+// swapLibrary replaces the library folder by the staged one.
+func swapLibrary(library, staging, previous string, restored *Restored) error {
+	info, err := os.Stat(library)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return os.Rename(staging, library)
+	case err != nil:
+		return err
+	}
+	if err := os.Chmod(staging, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Rename(library, previous); err != nil {
+		return err
+	}
+	if err := os.Rename(staging, library); err != nil {
+		// put the library back
+		_ = os.Rename(previous, library)
+		return err
+	}
+	restored.Previous = previous
+	return nil
+}
+
+// swapDatabases moves the staged databases into the library. The current
+// ones are moved to previous first, together with a full-text-search.db the
+// snapshot does not have since it would not match the older metadata.db.
+// When a move fails, those already made are undone so that the library never
+// mixes old and restored databases.
+func swapDatabases(library, staging, previous string, files []File, restored *Restored) (err error) {
+	if err := os.MkdirAll(library, 0o755); err != nil {
+		return err
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Path)
+	}
+	current := names
+	if !slices.Contains(names, fullTextSearchDB) {
+		current = append(slices.Clip(names), fullTextSearchDB)
+	}
+
+	var undo [][2]string
+	move := func(from, to string) error {
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+		undo = append(undo, [2]string{from, to})
+		return nil
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			_ = os.Rename(undo[i][1], undo[i][0])
+		}
+		// only removed when nothing is left in it
+		_ = os.Remove(previous)
+	}()
+
+	movedAside := false
+	for _, name := range current {
+		path := filepath.Join(library, name)
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(previous, 0o700); err != nil {
+			return err
+		}
+		if err := move(path, filepath.Join(previous, name)); err != nil {
+			return err
+		}
+		movedAside = true
+	}
+	for _, name := range names {
+		if err := move(filepath.Join(staging, name), filepath.Join(library, name)); err != nil {
+			return err
+		}
+	}
+	if movedAside {
+		restored.Previous = previous
+	}
+	return nil
+}
+
+// localLibrary returns the absolute path of the library of c.
+func localLibrary(c *calibredb.Calibre) (string, error) {
+	if c.LibraryPath == "" || strings.Contains(c.LibraryPath, "://") {
+		return "", ErrRemoteLibrary
+	}
+	library, err := filepath.Abs(c.LibraryPath)
+	if err != nil {
+		return "", fmt.Errorf("snapshot: %w", err)
+	}
+	return library, nil
+}
+
+func (m Manifest) summary() Snapshot {
+	return Snapshot{ID: m.ID, Created: m.Created, Library: m.Library, Options: m.Options, Files: len(m.Files)}
+}
+
+func (s *Store) archivePath(id string) string {
+	return filepath.Join(s.dir, id+archiveExt)
+}
+
+func (s *Store) summaryPath(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// exists reports whether the store has the archive of a valid id, which also
+// keeps ids from naming files outside the store.
+func (s *Store) exists(id string) bool {
+	if !idRe.MatchString(id) {
+		return false
+	}
+	_, err := os.Stat(s.archivePath(id))
+	return err == nil
+}
+
+func (s *Store) readSummary(id string) (Snapshot, error) {
+	data, err := os.ReadFile(s.summaryPath(id))
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("snapshot: %w", err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return Snapshot{}, fmt.Errorf("snapshot: %s: %w", s.summaryPath(id), err)
+	}
+	return snap, nil
+}
+
+func (s *Store) writeSummary(snap Snapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.summaryPath(snap.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, s.summaryPath(snap.ID)); err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) remove(id string) error {
+	for _, p := range []string{s.archivePath(id), s.summaryPath(id)} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeArchive writes the files of the library selected by m.Options to a
+// new archive at name, adds them to m.Files and ends the archive with m.
+func writeArchive(name, library string, m *Manifest) (err error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	if err := addFile(tw, library, metadataDB, m); err != nil {
+		return err
+	}
+	if m.FullTextSearch {
+		// libraries that never enabled full text search have no database
+		err := addFile(tw, library, fullTextSearchDB, m)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if m.Books {
+		err := filepath.WalkDir(library, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(library, p)
+			if err != nil || rel == "." {
+				return err
+			}
+			top := !strings.ContainsRune(rel, filepath.Separator)
+			switch {
+			case top && strings.HasPrefix(rel, "."):
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			case top && (rel == metadataDB || rel == fullTextSearchDB):
+				return nil
+			case !d.Type().IsRegular():
+				return nil
+			}
+			return addFile(tw, library, filepath.ToSlash(rel), m)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: ManifestName, Mode: 0o600, Size: int64(len(data)), ModTime: m.Created}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// addFile adds the file at the slash separated path rel of the library.
+func addFile(tw *tar.Writer, library, rel string, m *Manifest) error {
+	f, err := os.Open(filepath.Join(library, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = rel
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	h := sha256.New()
+	// a file growing meanwhile is cut at the size in the header
+	if _, err := io.CopyN(io.MultiWriter(tw, h), f, info.Size()); err != nil {
+		return fmt.Errorf("%s: %w", rel, err)
+	}
+	m.Files = append(m.Files, File{Path: rel, Size: info.Size(), SHA256: hex.EncodeToString(h.Sum(nil))})
+	return nil
+}
+
+// readArchive reads the archive at name, passes every file to fn when it is
+// not nil and checks the files against the manifest at the end. An error
+// from readArchive means fn may have seen files that do not match.
+func readArchive(name string, fn func(name string, r io.Reader) error) (Manifest, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("snapshot: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("snapshot: %s: %w", filepath.Base(name), err)
+	}
+	tr := tar.NewReader(gz)
+	seen := map[string]File{}
+	var m *Manifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Manifest{}, fmt.Errorf("snapshot: %s: %w", filepath.Base(name), err)
+		}
+		if m != nil {
+			return Manifest{}, fmt.Errorf("snapshot: %s: %s follows the manifest", filepath.Base(name), hdr.Name)
+		}
+		if hdr.Name == ManifestName {
+			m = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(m); err != nil {
+				return Manifest{}, fmt.Errorf("snapshot: %s: manifest: %w", filepath.Base(name), err)
+			}
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg || path.Clean(hdr.Name) != hdr.Name || !filepath.IsLocal(hdr.Name) {
+			return Manifest{}, fmt.Errorf("snapshot: %s: unexpected entry %q", filepath.Base(name), hdr.Name)
+		}
+		if _, ok := seen[hdr.Name]; ok {
+			return Manifest{}, fmt.Errorf("snapshot: %s: %s appears twice", filepath.Base(name), hdr.Name)
+		}
+		h := sha256.New()
+		var r io.Reader = io.TeeReader(tr, h)
+		if fn != nil {
+			err = fn(hdr.Name, r)
+		} else {
+			_, err = io.Copy(io.Discard, r)
+		}
+		if err != nil {
+			return Manifest{}, fmt.Errorf("snapshot: %s: %w", hdr.Name, err)
+		}
+		seen[hdr.Name] = File{Path: hdr.Name, Size: hdr.Size, SHA256: hex.EncodeToString(h.Sum(nil))}
+	}
+	if m == nil {
+		return Manifest{}, fmt.Errorf("snapshot: %s has no manifest", filepath.Base(name))
+	}
+	for _, want := range m.Files {
+		got, ok := seen[want.Path]
+		if !ok {
+			return Manifest{}, fmt.Errorf("%w: %s is missing", ErrChecksum, want.Path)
+		}
+		if got != want {
+			return Manifest{}, fmt.Errorf("%w: %s", ErrChecksum, want.Path)
+		}
+		delete(seen, want.Path)
+	}
+	for p := range seen {
+		return Manifest{}, fmt.Errorf("%w: %s is not in the manifest", ErrChecksum, p)
+	}
+	return *m, nil
+}
+
+// extract writes r to the new file name.
+func extract(name string, r io.Reader) (err error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	_, err = io.Copy(f, r)
+	return err
+}
@@ -0,0 +1,353 @@
+package snapshot_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/internal/fakecalibredb"
+	"github.com/veverkap/calibre-rest/snapshot"
+)
+
+var calibredbPath string
+
+// TestMain runs the fake calibredb when the test binary is started as
+// calibredb, see the calibredb package tests.
+func TestMain(m *testing.M) {
+	if filepath.Base(os.Args[0]) == "calibredb" {
+		os.Exit(fakecalibredb.Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	dir, err := os.MkdirTemp("", "fakecalibredb")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	self, err := os.Executable()
+	if err == nil {
+		calibredbPath = filepath.Join(dir, "calibredb")
+		err = os.Symlink(self, calibredbPath)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newLibrary returns a library with a book for each title.
+func newLibrary(t *testing.T, titles ...string) *calibredb.Calibre {
+	t.Helper()
+	dir := t.TempDir()
+	c := calibredb.NewCalibre(
+		calibredb.WithLibraryPath(filepath.Join(dir, "library")),
+		calibredb.WithCalibreDBLocation(calibredbPath),
+	)
+	for _, title := range titles {
+		addBook(t, c, title)
+	}
+	return c
+}
+
+func addBook(t *testing.T, c *calibredb.Calibre, title string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), title+" - Someone.epub")
+	if err := os.WriteFile(path, []byte(title), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Add(calibredb.AddOptions{Files: []string{path}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func titles(t *testing.T, c *calibredb.Calibre) []string {
+	t.Helper()
+	page, err := c.ListBooks(calibredb.ListBooksOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, b := range page.Books {
+		out = append(out, b.Title)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestStore_Restore(t *testing.T) {
+	c := newLibrary(t, "Dune", "Emma")
+	store, err := snapshot.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("metadata only", func(t *testing.T) {
+		snap, err := store.Create(c, snapshot.Options{FullTextSearch: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snap.Files != 1 || snap.Size == 0 || snap.Library != c.LibraryPath {
+			t.Errorf("Create() = %+v", snap)
+		}
+		m, err := store.Verify(snap.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(m.Files) != 1 || m.Files[0].Path != "metadata.db" || len(m.Files[0].SHA256) != 64 {
+			t.Errorf("Verify() = %+v", m)
+		}
+
+		addBook(t, c, "Anathem")
+		restored, err := store.Restore(c, snap.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := titles(t, c); !slices.Equal(got, []string{"Dune", "Emma"}) {
+			t.Errorf("books after restore = %v", got)
+		}
+		if _, err := os.Stat(filepath.Join(restored.Previous, "metadata.db")); err != nil || filepath.Dir(restored.Previous) != c.LibraryPath {
+			t.Errorf("previous database in %q: %v", restored.Previous, err)
+		}
+	})
+
+	t.Run("books", func(t *testing.T) {
+		snap, err := store.Create(c, snapshot.Options{Books: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// metadata.db and three epubs: the restore above dropped the record of
+		// Anathem, not its folder
+		if snap.Files != 4 {
+			t.Errorf("Create() = %+v", snap)
+		}
+		if _, err := c.Remove(calibredb.RemoveOptions{Ids: calibredb.NewBookIDs(1)}); err != nil {
+			t.Fatal(err)
+		}
+		restored, err := store.Restore(c, snap.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := titles(t, c); !slices.Equal(got, []string{"Dune", "Emma"}) {
+			t.Errorf("books after restore = %v", got)
+		}
+		if !strings.HasPrefix(restored.Previous, c.LibraryPath+".before-restore-") {
+			t.Errorf("previous library = %q", restored.Previous)
+		}
+		if got := titles(t, calibredb.NewCalibre(calibredb.WithLibraryPath(restored.Previous), calibredb.WithCalibreDBLocation(calibredbPath))); !slices.Equal(got, []string{"Emma"}) {
+			t.Errorf("books of the previous library = %v", got)
+		}
+	})
+
+	snaps, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 2 || !snaps[0].Books || snaps[1].Books {
+		t.Errorf("List() = %+v", snaps)
+	}
+}
+
+func TestStore_Tampered(t *testing.T) {
+	c := newLibrary(t, "Dune")
+	dir := t.TempDir()
+	store, err := snapshot.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := store.Create(c, snapshot.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrite(t, filepath.Join(dir, snap.ID+".tar.gz"), func(name string, data []byte) []byte {
+		if name == "metadata.db" {
+			return bytes.Replace(data, []byte("Dune"), []byte("Emma"), 1)
+		}
+		return data
+	})
+
+	if _, err := store.Verify(snap.ID); !errors.Is(err, snapshot.ErrChecksum) {
+		t.Errorf("Verify() = %v, want ErrChecksum", err)
+	}
+	addBook(t, c, "Anathem")
+	if _, err := store.Restore(c, snap.ID); !errors.Is(err, snapshot.ErrChecksum) {
+		t.Errorf("Restore() = %v, want ErrChecksum", err)
+	}
+	if got := titles(t, c); !slices.Equal(got, []string{"Anathem", "Dune"}) {
+		t.Errorf("books after a failed restore = %v", got)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(c.LibraryPath))
+	if len(entries) != 1 {
+		t.Errorf("left behind next to the library: %v", entries)
+	}
+}
+
+// rewrite replaces the content of the entries of an archive, keeping the
+// manifest as it was.
+func rewrite(t *testing.T, archive string, edit func(name string, data []byte) []byte) {
+	t.Helper()
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	out := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(out)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = edit(hdr.Name, data)
+		hdr.Size = int64(len(data))
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archive, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStore_Prune(t *testing.T) {
+	c := newLibrary(t, "Dune")
+	day := func(d, hour int) time.Time { return time.Date(2026, 3, d, hour, 0, 0, 0, time.UTC) }
+	times := []time.Time{day(2, 10), day(2, 12), day(3, 10), day(4, 10), day(4, 11), day(5, 9)}
+	var now time.Time
+	store, err := snapshot.NewStore(t.TempDir(),
+		snapshot.WithKeepLast(1),
+		snapshot.WithKeepDaily(3),
+		snapshot.WithClock(func() time.Time { return now }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, now = range times {
+		if _, err := store.Create(c, snapshot.Options{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snaps, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []time.Time
+	for _, s := range snaps {
+		got = append(got, s.Created)
+	}
+	if want := []time.Time{day(5, 9), day(4, 11), day(3, 10)}; !slices.EqualFunc(got, want, time.Time.Equal) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+
+	if err := store.Delete(snaps[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(snaps[0].ID); !errors.Is(err, snapshot.ErrNotFound) {
+		t.Errorf("Delete() of a deleted snapshot = %v", err)
+	}
+	if _, err := store.Get("../secrets"); !errors.Is(err, snapshot.ErrNotFound) {
+		t.Errorf("Get() of a bad id = %v", err)
+	}
+}
+
+func TestStore_RemoteLibrary(t *testing.T) {
+	store, err := snapshot.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := calibredb.NewCalibre(calibredb.WithLibraryPath("http://localhost:8080/#books"))
+	if _, err := store.Create(c, snapshot.Options{}); !errors.Is(err, snapshot.ErrRemoteLibrary) {
+		t.Errorf("Create() = %v, want ErrRemoteLibrary", err)
+	}
+}
+
+func TestStore_Restore_FullTextSearch(t *testing.T) {
+	c := newLibrary(t, "Dune")
+	store, err := snapshot.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := store.Create(c, snapshot.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an index built after the snapshot does not match the restored metadata.db
+	index := filepath.Join(c.LibraryPath, "full-text-search.db")
+	if err := os.WriteFile(index, []byte("index"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := store.Restore(c, snap.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(index); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("full-text-search.db left in the library: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(restored.Previous, "full-text-search.db")); err != nil || string(data) != "index" {
+		t.Errorf("previous full-text-search.db = %q, %v", data, err)
+	}
+}
+
+func TestSwapDatabases_Rollback(t *testing.T) {
+	library, staging := t.TempDir(), t.TempDir()
+	for path, content := range map[string]string{
+		filepath.Join(library, "metadata.db"):         "old",
+		filepath.Join(library, "full-text-search.db"): "old index",
+		// the staged full-text-search.db is missing, so its move fails
+		filepath.Join(staging, "metadata.db"): "restored",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	previous := filepath.Join(library, ".before-restore")
+	var restored snapshot.Restored
+	files := []snapshot.File{{Path: "metadata.db"}, {Path: "full-text-search.db"}}
+	if err := snapshot.SwapDatabases(library, staging, previous, files, &restored); err == nil {
+		t.Fatal("SwapDatabases() succeeded without a staged full-text-search.db")
+	}
+	for path, want := range map[string]string{
+		filepath.Join(library, "metadata.db"):         "old",
+		filepath.Join(library, "full-text-search.db"): "old index",
+		filepath.Join(staging, "metadata.db"):         "restored",
+	} {
+		if data, err := os.ReadFile(path); err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", path, data, err, want)
+		}
+	}
+	if _, err := os.Stat(previous); !errors.Is(err, os.ErrNotExist) || restored.Previous != "" {
+		t.Errorf("previous = %q: %v", restored.Previous, err)
+	}
+}
@@ -0,0 +1,377 @@
+// Package watchfolder imports the e-books dropped into inbox directories. It
+// polls the directories, waits until a file has stopped growing, and adds the
+// files that share a basename (dune.epub, dune.mobi) as one book: the first
+// with calibredb add and the others with add_format.
+//
+// Imported files are moved to the processed directory of their inbox. Files
+// that could not be imported are moved to its failed directory, next to a
+// name.error.txt file that says why.
+package watchfolder
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+// Names of the directories inside an inbox that receive the files after an
+// import.
+const (
+	ProcessedDir = "processed"
+	FailedDir    = "failed"
+)
+
+// Actor is who the watcher runs commands as, see calibredb.Calibre.As.
+const Actor = "watch-folder"
+
+// Result is the outcome of importing a group of files.
+type Result struct {
+	// Files are the paths the files had in the inbox.
+	Files   []string
+	BookIDs calibredb.BookIDs
+	Err     error
+}
+
+// Watcher imports the files of its inbox directories, see Run.
+type Watcher struct {
+	calibre   *calibredb.Calibre
+	dirs      []string
+	interval  time.Duration
+	settle    time.Duration
+	automerge calibredb.AutomergeChoice
+	tags      string
+	languages string
+	publish   func(calibredb.Event)
+	onError   func(error)
+
+	mu   sync.Mutex
+	seen map[string]observation
+}
+
+// observation is what a scan saw of a file.
+type observation struct {
+	size    int64
+	modTime time.Time
+	// since is when the file was first seen with this size and time.
+	since time.Time
+}
+
+// Option configures a Watcher.
+type Option func(*Watcher)
+
+// WithInterval polls the inboxes every d, 5 seconds by default.
+func WithInterval(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.interval = d
+	}
+}
+
+// WithSettle imports a file once its size and modification time did not
+// change for d, 10 seconds by default. With zero a file is imported when two
+// scans in a row saw it unchanged.
+func WithSettle(d time.Duration) Option {
+	return func(w *Watcher) {
+		w.settle = d
+	}
+}
+
+// WithAutomerge sets what add does with books that are already in the
+// library. With the default, Disabled, such files fail to import.
+func WithAutomerge(automerge calibredb.AutomergeChoice) Option {
+	return func(w *Watcher) {
+		w.automerge = automerge
+	}
+}
+
+// WithTags sets the comma separated tags of the imported books.
+func WithTags(tags string) Option {
+	return func(w *Watcher) {
+		w.tags = tags
+	}
+}
+
+// WithLanguages sets the comma separated languages of the imported books.
+func WithLanguages(languages string) Option {
+	return func(w *Watcher) {
+		w.languages = languages
+	}
+}
+
+// WithEvents reports every import to publish as an import.completed or
+// import.failed event. Its signature fits webhook.Dispatcher.Publish.
+func WithEvents(publish func(calibredb.Event)) Option {
+	return func(w *Watcher) {
+		w.publish = publish
+	}
+}
+
+// WithOnError is called for failed imports and for inboxes that cannot be
+// read. By default the errors are printed to stderr.
+func WithOnError(onError func(error)) Option {
+	return func(w *Watcher) {
+		w.onError = onError
+	}
+}
+
+// New returns a watcher that imports the files of dirs into the library of c,
+// creating the directories when needed.
+func New(c *calibredb.Calibre, dirs []string, opts ...Option) (*Watcher, error) {
+	if len(dirs) == 0 {
+		return nil, errors.New("watchfolder: no directory to watch")
+	}
+	w := &Watcher{
+		calibre:  c.As(Actor),
+		dirs:     dirs,
+		interval: 5 * time.Second,
+		settle:   10 * time.Second,
+		onError: func(err error) {
+			fmt.Fprintln(os.Stderr, err)
+		},
+		seen: map[string]observation{},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if !slices.Contains([]calibredb.AutomergeChoice{"", calibredb.Disabled, calibredb.Ignore, calibredb.Overwrite, calibredb.NewRecord}, w.automerge) {
+		return nil, fmt.Errorf("watchfolder: unknown automerge %q", w.automerge)
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("watchfolder: %w", err)
+		}
+	}
+	return w, nil
+}
+
+// Run scans the inboxes every interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.Scan()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan imports the groups of files whose files all settled and returns their
+// results. Run calls it every interval.
+func (w *Watcher) Scan() []Result {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var results []Result
+	present := map[string]bool{}
+	for _, dir := range w.dirs {
+		groups, err := w.settled(dir, present)
+		if err != nil {
+			w.onError(err)
+			continue
+		}
+		for _, files := range groups {
+			r := w.importGroup(dir, files)
+			for _, f := range files {
+				delete(w.seen, f)
+			}
+			w.report(r)
+			results = append(results, r)
+		}
+	}
+	// forget the files that went away by other means
+	for path := range w.seen {
+		if !present[path] {
+			delete(w.seen, path)
+		}
+	}
+	return results
+}
+
+// settled lists the files of dir grouped by basename and returns the groups
+// whose files all settled, in the order of their names.
+func (w *Watcher) settled(dir string, present map[string]bool) ([][]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("watchfolder: %w", err)
+	}
+	now := time.Now()
+	groups := map[string][]string{}
+	unsettled := map[string]bool{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || ignored(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("watchfolder: %w", err)
+		}
+		path := filepath.Join(dir, entry.Name())
+		present[path] = true
+		key := basename(entry.Name())
+		groups[key] = append(groups[key], path)
+
+		seen, ok := w.seen[path]
+		if !ok || seen.size != info.Size() || !seen.modTime.Equal(info.ModTime()) {
+			w.seen[path] = observation{size: info.Size(), modTime: info.ModTime(), since: now}
+			unsettled[key] = true
+			continue
+		}
+		if now.Sub(seen.since) < w.settle {
+			unsettled[key] = true
+		}
+	}
+	var ready [][]string
+	for _, key := range slices.Sorted(maps.Keys(groups)) {
+		if !unsettled[key] {
+			ready = append(ready, groups[key])
+		}
+	}
+	return ready, nil
+}
+
+// ignored reports whether a file is not an e-book to import: hidden files and
+// partial downloads.
+func ignored(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".part", ".partial", ".crdownload", ".download", ".tmp":
+		return true
+	}
+	return false
+}
+
+// basename groups the formats of a book: "Dune - Frank Herbert.epub" and
+// "Dune - Frank Herbert.mobi" share "Dune - Frank Herbert".
+func basename(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// importGroup adds the first file as a book and the others as its formats, and
+// moves every file to the processed or failed directory.
+func (w *Watcher) importGroup(dir string, files []string) Result {
+	r := Result{Files: files}
+	first, rest := files[0], files[1:]
+	add := func(file string) (string, error) {
+		return w.calibre.Add(calibredb.AddOptions{
+			Files:     []string{file},
+			Automerge: w.automerge,
+			Tags:      w.tags,
+			Languages: w.languages,
+		})
+	}
+	out, err := add(first)
+	failed := map[string]error{}
+	switch {
+	case err != nil:
+		r.Err = err
+	case calibredb.AddedBookIDs(out).IsEmpty() && cmp.Or(w.automerge, calibredb.Disabled) == calibredb.Disabled:
+		r.Err = fmt.Errorf("%s is already in the library", filepath.Base(first))
+	case calibredb.AddedBookIDs(out).IsEmpty():
+		// merged into existing books, which the other formats are too
+		for _, f := range rest {
+			if _, err := add(f); err != nil {
+				failed[f] = err
+			}
+		}
+	default:
+		r.BookIDs = calibredb.AddedBookIDs(out)
+		id := strconv.Itoa(r.BookIDs.IDs()[0])
+		for _, f := range rest {
+			if _, err := w.calibre.AddFormat(calibredb.AddFormatOptions{Id: id, EbookFile: f}); err != nil {
+				failed[f] = err
+			}
+		}
+	}
+	if r.Err != nil {
+		for _, f := range files {
+			failed[f] = r.Err
+		}
+	}
+	var errs []error
+	for _, f := range files {
+		if err := failed[f]; err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(f), err))
+		}
+	}
+	if r.Err == nil {
+		r.Err = errors.Join(errs...)
+	}
+
+	for _, f := range files {
+		var err error
+		if ferr := failed[f]; ferr != nil {
+			err = moveFailed(dir, f, ferr)
+		} else {
+			_, err = move(f, filepath.Join(dir, ProcessedDir))
+		}
+		if err != nil {
+			w.onError(fmt.Errorf("watchfolder: %w", err))
+		}
+	}
+	return r
+}
+
+// moveFailed moves the file at path to the failed directory and writes the
+// error next to it.
+func moveFailed(dir, path string, importErr error) error {
+	dest, err := move(path, filepath.Join(dir, FailedDir))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dest+".error.txt", []byte(importErr.Error()+"\n"), 0o644)
+}
+
+// move moves the file at path into dir under the same name, or under
+// "name (2).ext" and so on when that is taken, and returns its new path.
+func move(path, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	name := filepath.Base(path)
+	dest := filepath.Join(dir, name)
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(dest); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		dest = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", basename(name), i, filepath.Ext(name)))
+	}
+	return dest, os.Rename(path, dest)
+}
+
+// report hands the result to the event and error handlers.
+func (w *Watcher) report(r Result) {
+	if r.Err != nil {
+		w.onError(fmt.Errorf("watchfolder: importing %s: %w", strings.Join(r.Files, ", "), r.Err))
+	}
+	if w.publish == nil {
+		return
+	}
+	e := w.calibre.NewEvent(calibredb.EventImportCompleted)
+	if r.Err != nil {
+		e.Type = calibredb.EventImportFailed
+		e.Error = r.Err.Error()
+	}
+	e.BookIDs = r.BookIDs
+	for _, f := range r.Files {
+		e.Files = append(e.Files, filepath.Base(f))
+	}
+	w.publish(e)
+}
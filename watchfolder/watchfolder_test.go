@@ -0,0 +1,247 @@
+package watchfolder_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/internal/fakecalibredb"
+	"github.com/veverkap/calibre-rest/watchfolder"
+)
+
+var calibredbPath string
+
+// TestMain runs the fake calibredb when the test binary is started as
+// calibredb, see the calibredb package tests.
+func TestMain(m *testing.M) {
+	if filepath.Base(os.Args[0]) == "calibredb" {
+		os.Exit(fakecalibredb.Main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	dir, err := os.MkdirTemp("", "fakecalibredb")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	self, err := os.Executable()
+	if err == nil {
+		calibredbPath = filepath.Join(dir, "calibredb")
+		err = os.Symlink(self, calibredbPath)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newCalibre(t *testing.T) *calibredb.Calibre {
+	t.Helper()
+	return calibredb.NewCalibre(
+		calibredb.WithLibraryPath(filepath.Join(t.TempDir(), "library")),
+		calibredb.WithCalibreDBLocation(calibredbPath),
+	)
+}
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func TestWatcher_Scan(t *testing.T) {
+	c := newCalibre(t)
+	inbox := t.TempDir()
+	var events []calibredb.Event
+	w, err := watchfolder.New(c, []string{inbox},
+		watchfolder.WithSettle(0),
+		watchfolder.WithTags("inbox"),
+		watchfolder.WithLanguages("eng"),
+		watchfolder.WithEvents(func(e calibredb.Event) { events = append(events, e) }),
+		watchfolder.WithOnError(func(error) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFiles(t, inbox, "Dune - Frank Herbert.epub", "Dune - Frank Herbert.txt", "Emma - Jane Austen.epub", ".hidden.epub", "Anathem.epub.part")
+	if results := w.Scan(); len(results) != 0 {
+		t.Fatalf("first Scan() imported %+v before the files settled", results)
+	}
+	results := w.Scan()
+	if len(results) != 2 {
+		t.Fatalf("second Scan() = %+v, want two groups", results)
+	}
+	for _, r := range results {
+		if r.Err != nil || r.BookIDs.Len() != 1 {
+			t.Errorf("result %+v", r)
+		}
+	}
+	if got := len(results[0].Files); got != 2 {
+		t.Errorf("Dune was imported with %d files, want 2", got)
+	}
+
+	out, err := c.List(calibredb.ListOptions{Fields: "title,formats,tags,languages", ForMachine: ptr(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var books []struct {
+		Title     string
+		Formats   []string
+		Tags      []string
+		Languages []string
+	}
+	if err := json.Unmarshal([]byte(out), &books); err != nil {
+		t.Fatalf("%v in %s", err, out)
+	}
+	if len(books) != 2 {
+		t.Fatalf("library = %+v", books)
+	}
+	for _, b := range books {
+		wantFormats := map[string]int{"Dune": 2, "Emma": 1}[b.Title]
+		if len(b.Formats) != wantFormats || !slices.Equal(b.Tags, []string{"inbox"}) || !slices.Equal(b.Languages, []string{"eng"}) {
+			t.Errorf("book = %+v", b)
+		}
+	}
+
+	if got := listDir(t, inbox); !slices.Equal(got, []string{".hidden.epub", "Anathem.epub.part"}) {
+		t.Errorf("inbox = %v", got)
+	}
+	if got := listDir(t, filepath.Join(inbox, watchfolder.ProcessedDir)); len(got) != 3 {
+		t.Errorf("processed = %v", got)
+	}
+	if len(events) != 2 || events[0].Type != calibredb.EventImportCompleted || events[0].Actor != watchfolder.Actor ||
+		!slices.Equal(events[0].Files, []string{"Dune - Frank Herbert.epub", "Dune - Frank Herbert.txt"}) {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestWatcher_Failed(t *testing.T) {
+	c := newCalibre(t)
+	elsewhere := t.TempDir()
+	writeFiles(t, elsewhere, "Dune - Frank Herbert.txt")
+	if _, err := c.Add(calibredb.AddOptions{Files: []string{filepath.Join(elsewhere, "Dune - Frank Herbert.txt")}}); err != nil {
+		t.Fatal(err)
+	}
+	inbox := t.TempDir()
+	var events []calibredb.Event
+	w, err := watchfolder.New(c, []string{inbox},
+		watchfolder.WithSettle(0),
+		watchfolder.WithEvents(func(e calibredb.Event) { events = append(events, e) }),
+		watchfolder.WithOnError(func(error) {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, inbox, "Dune - Frank Herbert.epub")
+	w.Scan()
+	results := w.Scan()
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("Scan() = %+v, want the duplicate to fail", results)
+	}
+
+	failed := filepath.Join(inbox, watchfolder.FailedDir)
+	if got := listDir(t, failed); !slices.Equal(got, []string{"Dune - Frank Herbert.epub", "Dune - Frank Herbert.epub.error.txt"}) {
+		t.Errorf("failed = %v", got)
+	}
+	sidecar, _ := os.ReadFile(filepath.Join(failed, "Dune - Frank Herbert.epub.error.txt"))
+	if !strings.Contains(string(sidecar), "already in the library") {
+		t.Errorf("error file = %q", sidecar)
+	}
+	if len(events) != 1 || events[0].Type != calibredb.EventImportFailed || events[0].Error == "" {
+		t.Errorf("events = %+v", events)
+	}
+
+	// with automerge the same file is merged into the book
+	w, err = watchfolder.New(c, []string{inbox}, watchfolder.WithSettle(0), watchfolder.WithAutomerge(calibredb.Ignore))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, inbox, "Dune - Frank Herbert.epub")
+	w.Scan()
+	if results := w.Scan(); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Scan() with automerge = %+v", results)
+	}
+	if got := listDir(t, filepath.Join(inbox, watchfolder.ProcessedDir)); !slices.Equal(got, []string{"Dune - Frank Herbert.epub"}) {
+		t.Errorf("processed = %v", got)
+	}
+}
+
+func TestWatcher_Automerge(t *testing.T) {
+	// the stub adds nothing, as when every file is merged into an existing
+	// book, and records its arguments
+	dir := t.TempDir()
+	script := filepath.Join(dir, "calibredb")
+	body := "#!/bin/sh\necho \"$@\" >> \"" + filepath.Join(dir, "calls.log") + "\"\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	c := calibredb.NewCalibre(calibredb.WithLibraryPath(dir), calibredb.WithCalibreDBLocation(script))
+	inbox := t.TempDir()
+	w, err := watchfolder.New(c, []string{inbox},
+		watchfolder.WithSettle(0),
+		watchfolder.WithAutomerge(calibredb.Ignore),
+		watchfolder.WithTags("inbox"),
+		watchfolder.WithLanguages("eng"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, inbox, "Dune - Frank Herbert.epub", "Dune - Frank Herbert.txt")
+	w.Scan()
+	if results := w.Scan(); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Scan() = %+v", results)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "calls.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(calls) != 2 {
+		t.Fatalf("calls = %q", calls)
+	}
+	// every format gets the tags and languages, not only the first one
+	for _, call := range calls {
+		if !strings.Contains(call, "--tags inbox") || !strings.Contains(call, "--languages eng") {
+			t.Errorf("call %q lacks the tags or languages", call)
+		}
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	c := newCalibre(t)
+	if _, err := watchfolder.New(c, nil); err == nil {
+		t.Error("New() without directories succeeded")
+	}
+	if _, err := watchfolder.New(c, []string{t.TempDir()}, watchfolder.WithAutomerge("merge")); err == nil {
+		t.Error("New() with an unknown automerge succeeded")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
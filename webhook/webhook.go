@@ -0,0 +1,392 @@
+// Package webhook delivers library change events to HTTP endpoints. Each event
+// is POSTed as JSON and signed with HMAC-SHA256 of the body under the secret
+// of the endpoint:
+//
+//	X-Calibre-Rest-Event: book.added
+//	X-Calibre-Rest-Delivery: 5f0c...
+//	X-Calibre-Rest-Signature: sha256=<hex>
+//
+// Failed deliveries are retried with exponential backoff. With a queue
+// directory pending deliveries survive restarts.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+)
+
+// Headers of a delivery.
+const (
+	HeaderEvent     = "X-Calibre-Rest-Event"
+	HeaderDelivery  = "X-Calibre-Rest-Delivery"
+	HeaderSignature = "X-Calibre-Rest-Signature"
+)
+
+// Endpoint is a receiver of events.
+type Endpoint struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	// Events limits the event types sent, all of them when empty.
+	Events []calibredb.EventType `json:"events,omitempty"`
+}
+
+func (e Endpoint) wants(t calibredb.EventType) bool {
+	return len(e.Events) == 0 || slices.Contains(e.Events, t)
+}
+
+// Sign returns the signature header value for body under secret, for
+// receivers to compare with hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// key identifies the endpoint among those with the same URL, without
+// storing its secret in the queue.
+func (e Endpoint) key() string {
+	sum := sha256.Sum256([]byte(e.URL + "\x00" + e.Secret))
+	return hex.EncodeToString(sum[:8])
+}
+
+// delivery is one event for one endpoint, as stored in the queue directory.
+type delivery struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Endpoint is the key of the endpoint, whose secret signs the body.
+	Endpoint    string              `json:"endpoint"`
+	Event       calibredb.EventType `json:"event"`
+	Body        json.RawMessage     `json:"body"`
+	Attempts    int                 `json:"attempts"`
+	NextAttempt time.Time           `json:"next_attempt"`
+	LastError   string              `json:"last_error,omitempty"`
+}
+
+// Dispatcher queues events and delivers them in the background, see Run.
+type Dispatcher struct {
+	endpoints   []Endpoint
+	client      *http.Client
+	queueDir    string
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	onError     func(error)
+
+	mu      sync.Mutex
+	pending []*delivery
+	wake    chan struct{}
+}
+
+// Option configures a Dispatcher.
+type Option func(*Dispatcher)
+
+// WithQueueDir keeps pending deliveries as files in dir so that they survive
+// restarts. Deliveries that exhausted their attempts are moved to
+// dir/failed.
+func WithQueueDir(dir string) Option {
+	return func(d *Dispatcher) {
+		d.queueDir = dir
+	}
+}
+
+// WithHTTPClient sets the client deliveries are made with. The default times
+// out after 10 seconds.
+func WithHTTPClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithMaxAttempts gives up on a delivery after n attempts, 8 by default.
+func WithMaxAttempts(n int) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = n
+	}
+}
+
+// WithBackoff waits base after the first failure and doubles the wait after
+// each further one, up to max. The default is 1s up to 10m.
+func WithBackoff(base, max time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.backoff, d.maxBackoff = base, max
+	}
+}
+
+// WithOnError is called for failed deliveries and queue errors. By default
+// they are printed to stderr.
+func WithOnError(onError func(error)) Option {
+	return func(d *Dispatcher) {
+		d.onError = onError
+	}
+}
+
+// New returns a dispatcher for endpoints. With a queue directory the
+// deliveries left by a previous run are loaded.
+func New(endpoints []Endpoint, opts ...Option) (*Dispatcher, error) {
+	d := &Dispatcher{
+		endpoints:   endpoints,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: 8,
+		backoff:     time.Second,
+		maxBackoff:  10 * time.Minute,
+		onError: func(err error) {
+			fmt.Fprintln(os.Stderr, "webhook:", err)
+		},
+		wake: make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	if d.queueDir != "" {
+		if err := d.load(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Publish queues e for every endpoint that wants it. Its signature fits
+// calibredb.WithEvents.
+func (d *Dispatcher) Publish(e calibredb.Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		d.onError(err)
+		return
+	}
+	d.mu.Lock()
+	for _, endpoint := range d.endpoints {
+		if !endpoint.wants(e.Type) {
+			continue
+		}
+		dl := &delivery{ID: newID(), URL: endpoint.URL, Endpoint: endpoint.key(), Event: e.Type, Body: body, NextAttempt: time.Now()}
+		if err := d.store(dl); err != nil {
+			d.onError(err)
+		}
+		d.pending = append(d.pending, dl)
+	}
+	d.mu.Unlock()
+	d.signal()
+}
+
+// Pending returns the number of deliveries waiting to be made.
+func (d *Dispatcher) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.pending)
+}
+
+func (d *Dispatcher) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers events until ctx is done. Deliveries are made one at a time in
+// the order they became due.
+func (d *Dispatcher) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		next := d.deliverDue(ctx)
+		if !next.IsZero() {
+			timer.Reset(time.Until(next))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// deliverDue makes the deliveries that are due and returns when the next one
+// is, or the zero time when none is pending.
+func (d *Dispatcher) deliverDue(ctx context.Context) time.Time {
+	for ctx.Err() == nil {
+		dl, next := d.nextDue()
+		if dl == nil {
+			return next
+		}
+		err := d.send(ctx, dl)
+		if ctx.Err() != nil {
+			// shutting down, the delivery stays queued as it was
+			return time.Time{}
+		}
+		d.finish(dl, err)
+	}
+	return time.Time{}
+}
+
+// nextDue returns the earliest due delivery, or when the next one is due.
+func (d *Dispatcher) nextDue() (*delivery, time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var next time.Time
+	now := time.Now()
+	for _, dl := range d.pending {
+		if !dl.NextAttempt.After(now) {
+			return dl, time.Time{}
+		}
+		if next.IsZero() || dl.NextAttempt.Before(next) {
+			next = dl.NextAttempt
+		}
+	}
+	return nil, next
+}
+
+func (d *Dispatcher) send(ctx context.Context, dl *delivery) error {
+	i := slices.IndexFunc(d.endpoints, func(e Endpoint) bool { return e.key() == dl.Endpoint })
+	if i < 0 {
+		return errEndpointRemoved
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.URL, bytes.NewReader(dl.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "calibre-rest-webhook")
+	req.Header.Set(HeaderEvent, string(dl.Event))
+	req.Header.Set(HeaderDelivery, dl.ID)
+	if secret := d.endpoints[i].Secret; secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, dl.Body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s answered %s", dl.URL, resp.Status)
+	}
+	return nil
+}
+
+// errEndpointRemoved drops deliveries to endpoints no longer configured.
+var errEndpointRemoved = errors.New("endpoint is no longer configured")
+
+// finish removes a delivery that succeeded or gave up, and schedules the next
+// attempt of one that failed.
+func (d *Dispatcher) finish(dl *delivery, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil {
+		d.remove(dl, false)
+		return
+	}
+	dl.Attempts++
+	dl.LastError = err.Error()
+	if errors.Is(err, errEndpointRemoved) || dl.Attempts >= d.maxAttempts {
+		d.remove(dl, true)
+		d.onError(fmt.Errorf("giving up on %s delivery %s to %s after %d attempts: %w", dl.Event, dl.ID, dl.URL, dl.Attempts, err))
+		return
+	}
+	wait := d.backoff << (dl.Attempts - 1)
+	if wait > d.maxBackoff || wait <= 0 {
+		wait = d.maxBackoff
+	}
+	dl.NextAttempt = time.Now().Add(wait)
+	if err := d.store(dl); err != nil {
+		d.onError(err)
+	}
+}
+
+func (d *Dispatcher) remove(dl *delivery, failed bool) {
+	d.pending = slices.DeleteFunc(d.pending, func(p *delivery) bool { return p == dl })
+	if d.queueDir == "" {
+		return
+	}
+	path := filepath.Join(d.queueDir, dl.ID+".json")
+	if failed {
+		if err := os.MkdirAll(filepath.Join(d.queueDir, "failed"), 0o700); err == nil {
+			if err := d.writeFile(filepath.Join(d.queueDir, "failed", dl.ID+".json"), dl); err != nil {
+				d.onError(err)
+			}
+		}
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		d.onError(err)
+	}
+}
+
+// store writes the delivery to the queue directory.
+func (d *Dispatcher) store(dl *delivery) error {
+	if d.queueDir == "" {
+		return nil
+	}
+	return d.writeFile(filepath.Join(d.queueDir, dl.ID+".json"), dl)
+}
+
+func (d *Dispatcher) writeFile(path string, dl *delivery) error {
+	data, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".delivery-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// load reads the deliveries a previous run left in the queue directory.
+func (d *Dispatcher) load() error {
+	if err := os.MkdirAll(d.queueDir, 0o700); err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	entries, err := os.ReadDir(d.queueDir)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(d.queueDir, name))
+		if err != nil {
+			return fmt.Errorf("webhook: %w", err)
+		}
+		var dl delivery
+		if err := json.Unmarshal(data, &dl); err != nil {
+			return fmt.Errorf("webhook: %s: %w", name, err)
+		}
+		d.pending = append(d.pending, &dl)
+	}
+	slices.SortFunc(d.pending, func(a, b *delivery) int { return a.NextAttempt.Compare(b.NextAttempt) })
+	return nil
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
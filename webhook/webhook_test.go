@@ -0,0 +1,247 @@
+package webhook_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/veverkap/calibre-rest/calibredb"
+	"github.com/veverkap/calibre-rest/webhook"
+)
+
+// receiver is an httptest server that records deliveries and fails the first
+// failures of them.
+type receiver struct {
+	*httptest.Server
+	mu        sync.Mutex
+	failures  int
+	received  []calibredb.Event
+	headers   []http.Header
+	delivered chan struct{}
+}
+
+func newReceiver(t *testing.T, secret string, failures int) *receiver {
+	t.Helper()
+	r := &receiver{failures: failures, delivered: make(chan struct{}, 16)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if secret != "" && !hmac.Equal([]byte(req.Header.Get(webhook.HeaderSignature)), []byte(webhook.Sign(secret, body))) {
+			t.Errorf("bad signature %q", req.Header.Get(webhook.HeaderSignature))
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.failures > 0 {
+			r.failures--
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		var e calibredb.Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Errorf("body %s: %v", body, err)
+		}
+		r.received = append(r.received, e)
+		r.headers = append(r.headers, req.Header.Clone())
+		r.delivered <- struct{}{}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) wait(t *testing.T, n int) {
+	t.Helper()
+	for range n {
+		select {
+		case <-r.delivered:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %d deliveries", n)
+		}
+	}
+}
+
+func run(t *testing.T, d *webhook.Dispatcher) context.CancelFunc {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func TestDispatcher_Deliver(t *testing.T) {
+	all := newReceiver(t, "s3cret", 0)
+	removals := newReceiver(t, "", 0)
+	d, err := webhook.New([]webhook.Endpoint{
+		{URL: all.URL, Secret: "s3cret"},
+		{URL: removals.URL, Events: []calibredb.EventType{calibredb.EventBookRemoved}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, d)
+
+	d.Publish(calibredb.Event{Type: calibredb.EventBookAdded, BookIDs: calibredb.NewBookIDs(1, 2)})
+	d.Publish(calibredb.Event{Type: calibredb.EventBookRemoved, BookIDs: calibredb.NewBookIDs(2)})
+	all.wait(t, 2)
+	removals.wait(t, 1)
+
+	if got := all.received[0]; got.Type != calibredb.EventBookAdded || got.BookIDs.String() != "1,2" {
+		t.Errorf("first event = %+v", got)
+	}
+	if h := all.headers[0]; h.Get(webhook.HeaderEvent) != "book.added" || h.Get(webhook.HeaderDelivery) == "" || h.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", h)
+	}
+	if len(removals.received) != 1 || removals.received[0].Type != calibredb.EventBookRemoved {
+		t.Errorf("filtered endpoint received %+v", removals.received)
+	}
+	if removals.headers[0].Get(webhook.HeaderSignature) != "" {
+		t.Error("delivery without a secret is signed")
+	}
+}
+
+func TestDispatcher_SameURL(t *testing.T) {
+	// one receiver behind two endpoints with their own secrets and events
+	r := newReceiver(t, "", 0)
+	d, err := webhook.New([]webhook.Endpoint{
+		{URL: r.URL, Secret: "first", Events: []calibredb.EventType{calibredb.EventBookAdded}},
+		{URL: r.URL, Secret: "second", Events: []calibredb.EventType{calibredb.EventBookRemoved}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, d)
+	e := calibredb.Event{Type: calibredb.EventBookRemoved, BookIDs: calibredb.NewBookIDs(2)}
+	d.Publish(e)
+	r.wait(t, 1)
+	body, _ := json.Marshal(e)
+	if got := r.headers[0].Get(webhook.HeaderSignature); got != webhook.Sign("second", body) {
+		t.Errorf("signature = %q, want that of the second secret", got)
+	}
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	r := newReceiver(t, "", 2)
+	d, err := webhook.New([]webhook.Endpoint{{URL: r.URL}}, webhook.WithBackoff(10*time.Millisecond, 20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, d)
+	d.Publish(calibredb.Event{Type: calibredb.EventMetadataUpdated, BookIDs: calibredb.NewBookIDs(3)})
+	r.wait(t, 1)
+	waitPending(t, d, 0)
+}
+
+func TestDispatcher_GivesUp(t *testing.T) {
+	r := newReceiver(t, "", 100)
+	dir := t.TempDir()
+	failed := make(chan error, 1)
+	d, err := webhook.New([]webhook.Endpoint{{URL: r.URL}},
+		webhook.WithQueueDir(dir),
+		webhook.WithMaxAttempts(2),
+		webhook.WithBackoff(time.Millisecond, time.Millisecond),
+		webhook.WithOnError(func(err error) { failed <- err }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(t, d)
+	d.Publish(calibredb.Event{Type: calibredb.EventBookAdded})
+	select {
+	case <-failed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the dispatcher did not give up")
+	}
+	if entries, _ := filepath.Glob(filepath.Join(dir, "failed", "*.json")); len(entries) != 1 {
+		t.Errorf("failed deliveries = %v", entries)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(entries) != 0 {
+		t.Errorf("queued deliveries = %v", entries)
+	}
+}
+
+func TestDispatcher_PersistentQueue(t *testing.T) {
+	dir := t.TempDir()
+	r := newReceiver(t, "k", 1<<30) // down until told otherwise
+	endpoints := []webhook.Endpoint{{URL: r.URL, Secret: "k"}}
+	opts := []webhook.Option{
+		webhook.WithQueueDir(dir),
+		webhook.WithBackoff(50*time.Millisecond, 50*time.Millisecond),
+		webhook.WithOnError(func(error) {}),
+	}
+
+	d, err := webhook.New(endpoints, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := run(t, d)
+	d.Publish(calibredb.Event{Type: calibredb.EventFormatAdded, BookIDs: calibredb.NewBookIDs(4), Format: "EPUB"})
+	waitForFile(t, dir, func(attempts int) bool { return attempts > 0 })
+	stop()
+
+	r.mu.Lock()
+	r.failures = 0
+	r.mu.Unlock()
+
+	d, err = webhook.New(endpoints, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Pending() != 1 {
+		t.Fatalf("Pending() after restart = %d, want 1", d.Pending())
+	}
+	run(t, d)
+	r.wait(t, 1)
+	if got := r.received[0]; got.Type != calibredb.EventFormatAdded || got.Format != "EPUB" {
+		t.Errorf("delivered %+v", got)
+	}
+	waitPending(t, d, 0)
+	if entries, _ := filepath.Glob(filepath.Join(dir, "*.json")); len(entries) != 0 {
+		t.Errorf("queued deliveries after delivery = %v", entries)
+	}
+}
+
+// waitPending waits until d has n pending deliveries; the receiver sees a
+// delivery before the dispatcher learns that it succeeded.
+func waitPending(t *testing.T, d *webhook.Dispatcher, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for d.Pending() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Pending() = %d, want %d", d.Pending(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitForFile waits until the queue directory holds a delivery whose attempts
+// satisfy ok.
+func waitForFile(t *testing.T, dir string, ok func(attempts int) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		entries, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		for _, entry := range entries {
+			data, err := os.ReadFile(entry)
+			var queued struct{ Attempts int }
+			if err == nil && json.Unmarshal(data, &queued) == nil && ok(queued.Attempts) {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for the queued delivery")
+}